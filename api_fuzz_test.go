@@ -0,0 +1,169 @@
+package rbxauth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// The seed corpus of each target is in testdata/fuzz.
+
+// responseTransport responds to every request with a JSON body and status.
+type responseTransport struct {
+	status int
+	body   []byte
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t responseTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	return &http.Response{
+		StatusCode: t.status,
+		Header:     http.Header{"Content-Type": {"application/json; charset=utf-8"}},
+		Body:       ioutil.NopCloser(bytes.NewReader(t.body)),
+		Request:    req,
+	}, nil
+}
+
+// fuzzStatus maps an arbitrary value to an HTTP status code.
+func fuzzStatus(code uint16) int {
+	return 100 + int(code)%500
+}
+
+// fuzzConfig returns a Config whose every request receives body with the
+// given status.
+func fuzzConfig(status int, body []byte) Config {
+	return Config{Client: &http.Client{Transport: responseTransport{status: status, body: body}}}
+}
+
+// checkStatus fails if err does not report a status that is not 2XX.
+func checkStatus(t *testing.T, status int, err error) {
+	t.Helper()
+	if status >= 200 && status < 300 {
+		return
+	}
+	if err == nil {
+		t.Fatalf("status %d: expected error", status)
+	}
+	if code, ok := HTTPStatus(err); !ok || code != status {
+		t.Fatalf("status %d: expected status in error, got %v", status, err)
+	}
+}
+
+func FuzzLoginResponseDecode(f *testing.F) {
+	f.Fuzz(func(t *testing.T, code uint16, body []byte) {
+		status := fuzzStatus(code)
+		cfg := fuzzConfig(status, body)
+		cookies, step, err := cfg.LoginCred(Cred{Type: "Username", Ident: "alice"}, []byte("pass"))
+		checkStatus(t, status, err)
+		if err != nil {
+			if cookies != nil || step != nil {
+				t.Fatalf("expected no result with error %v", err)
+			}
+			return
+		}
+		if cookies != nil && step != nil {
+			t.Fatal("expected only one of cookies and step")
+		}
+	})
+}
+
+func FuzzErrorsResponseDecode(f *testing.F) {
+	f.Fuzz(func(t *testing.T, code uint16, body []byte) {
+		status := fuzzStatus(code)
+		cfg := fuzzConfig(status, body)
+		req, err := cfg.newRequest(context.Background(), "POST", "https://auth.roblox.com/v2/logout", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = cfg.requestAPI("fuzz", req, &errorsResponse{})
+		checkStatus(t, status, err)
+
+		// Errors that decode are reported with every code.
+		var want errorsResponse
+		if json.Unmarshal(body, &want) != nil || len(want.Errors) == 0 {
+			return
+		}
+		if err == nil {
+			t.Fatalf("expected error for %q", body)
+		}
+		codes := make([]int, len(want.Errors))
+		for i, e := range want.Errors {
+			codes[i] = e.Code
+		}
+		if got := ErrorCodes(err); !reflect.DeepEqual(got, codes) {
+			t.Fatalf("expected codes %v, got %v", codes, got)
+		}
+	})
+}
+
+func TestErrorsObject(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		body string
+		// codes is nil if the body does not decode.
+		codes []int
+	}{
+		{"array", `{"errors":[{"code":1,"message":"a"},{"code":2,"message":"b"}]}`, []int{1, 2}},
+		{"object", `{"errors":{"code":4,"message":"Account has been locked."}}`, []int{4}},
+		{"object with space", `{"errors": 	{"code":7}}`, []int{7}},
+		{"empty array", `{"errors":[]}`, []int{}},
+		{"null", `{"errors":null}`, []int{}},
+		{"string", `{"errors":"failed"}`, nil},
+		{"bad object", `{"errors":{"code":"x"}}`, nil},
+	} {
+		var resp errorsResponse
+		err := json.Unmarshal([]byte(test.body), &resp)
+		if test.codes == nil {
+			if err == nil {
+				t.Errorf("%s: expected decode error", test.name)
+			}
+		} else if err != nil {
+			t.Errorf("%s: decode: %v", test.name, err)
+		} else if len(resp.Errors) != len(test.codes) {
+			t.Errorf("%s: expected %d errors, got %d", test.name, len(test.codes), len(resp.Errors))
+		}
+
+		// The status is retained whether or not the body decodes.
+		cfg := fuzzConfig(http.StatusForbidden, []byte(test.body))
+		_, _, err = cfg.LoginCred(Cred{Type: "Username", Ident: "alice"}, []byte("pass"))
+		if code, ok := HTTPStatus(err); !ok || code != http.StatusForbidden {
+			t.Errorf("%s: expected status 403, got %v", test.name, err)
+		}
+		if len(test.codes) > 0 && !reflect.DeepEqual(ErrorCodes(err), test.codes) {
+			t.Errorf("%s: expected codes %v, got %v", test.name, test.codes, ErrorCodes(err))
+		}
+	}
+
+	// A locked account reported as an object is classified.
+	cfg := fuzzConfig(http.StatusForbidden, []byte(`{"errors":{"code":4,"message":"Account has been locked."}}`))
+	if _, _, err := cfg.LoginCred(Cred{Type: "Username", Ident: "alice"}, []byte("pass")); !errors.Is(err, ErrAccountLocked) {
+		t.Errorf("locked: expected ErrAccountLocked, got %v", err)
+	}
+}
+
+func TestReadCookiesLongLine(t *testing.T) {
+	// A line longer than the default buffer of a bufio.Scanner is read.
+	const size = 200 << 10
+	cookies, err := ReadCookies(strings.NewReader("Set-Cookie: a=" + strings.Repeat("x", size) + "\n"))
+	if err != nil {
+		t.Fatalf("long line: %v", err)
+	}
+	if len(cookies) != 1 || len(cookies[0].Value) != size {
+		t.Errorf("long line: unexpected cookies")
+	}
+
+	// A line longer than the limit is reported as such.
+	line := "Set-Cookie: a=" + strings.Repeat("x", MaxCookiesSize)
+	if _, err := ReadCookies(strings.NewReader(line)); !errors.Is(err, ErrCookiesTooLarge) {
+		t.Errorf("oversized line: expected ErrCookiesTooLarge, got %v", err)
+	}
+}
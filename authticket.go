@@ -0,0 +1,109 @@
+package rbxauth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+const (
+	// authTicketHeader is the response header containing a created
+	// authentication ticket.
+	authTicketHeader = "Rbx-Authentication-Ticket"
+	// negotiationHeader must be present when redeeming a ticket.
+	negotiationHeader = "RBXAuthenticationNegotiation"
+)
+
+// These errors classify failures to redeem an authentication ticket.
+var (
+	ErrInvalidAuthTicket = errors.New("invalid authentication ticket")
+	ErrAuthTicketExpired = errors.New("authentication ticket expired")
+)
+
+// redeemErrorCodes maps error codes returned by the ticket redemption endpoint
+// to a kind.
+//
+//	1: Authentication ticket is invalid.
+//	2: Authentication ticket has expired.
+var redeemErrorCodes = map[int]error{
+	1: ErrInvalidAuthTicket,
+	2: ErrAuthTicketExpired,
+}
+
+// CreateAuthTicket creates an authentication ticket from the session
+// represented by the given cookies. The ticket can be redeemed once, such as
+// by a game client, to receive a new session for the same account.
+//
+// referer is sent as the Referer header, which is required by the API. It is
+// typically the URL of a Roblox page, such as "https://www.roblox.com/".
+//
+// Returns ErrUnauthenticated if the session is not valid.
+func (c Config) CreateAuthTicket(cookies []*http.Cookie, referer string) (string, error) {
+	return c.CreateAuthTicketContext(context.Background(), cookies, referer)
+}
+
+// CreateAuthTicketContext is like CreateAuthTicket, but with a context.
+func (c Config) CreateAuthTicketContext(ctx context.Context, cookies []*http.Cookie, referer string) (ticket string, err error) {
+	defer wrapOp("create auth ticket", &err)
+
+	endpoint := c.AuthTicketEndpoint
+	if endpoint == "" {
+		endpoint = DefaultAuthTicketEndpoint
+	}
+	req, err := c.newRequest(ctx, "POST", endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Referer", referer)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+
+	resp, err := c.requestAPI("auth-ticket", req, &errorsResponse{})
+	if err != nil {
+		if code, ok := HTTPStatus(err); ok && code == http.StatusUnauthorized {
+			return "", ErrUnauthenticated
+		}
+		return "", err
+	}
+	if ticket = resp.Header.Get(authTicketHeader); ticket == "" {
+		return "", errors.New("response is missing ticket")
+	}
+	return ticket, nil
+}
+
+// RedeemAuthTicket exchanges an authentication ticket for the cookies of a
+// new session.
+//
+// The returned error matches ErrInvalidAuthTicket if the ticket is not valid,
+// and ErrAuthTicketExpired if the ticket has expired.
+func (c Config) RedeemAuthTicket(ticket string) ([]*http.Cookie, error) {
+	return c.RedeemAuthTicketContext(context.Background(), ticket)
+}
+
+// RedeemAuthTicketContext is like RedeemAuthTicket, but with a context.
+func (c Config) RedeemAuthTicketContext(ctx context.Context, ticket string) (cookies []*http.Cookie, err error) {
+	defer wrapOp("redeem auth ticket", &err)
+
+	body, _ := json.Marshal(&authTicketRedeemRequest{AuthenticationTicket: ticket})
+	endpoint := c.RedeemAuthTicketEndpoint
+	if endpoint == "" {
+		endpoint = DefaultRedeemAuthTicketEndpoint
+	}
+	req, err := c.newRequest(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set(negotiationHeader, "1")
+
+	resp, err := c.requestAPI("redeem-auth-ticket", req, &errorsResponse{})
+	if err != nil {
+		return nil, classify(err, redeemErrorCodes)
+	}
+	return responseCookies(resp), nil
+}
@@ -0,0 +1,103 @@
+package rbxauth_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/anaminus/rbxauth"
+	"github.com/anaminus/rbxauth/rbxauthtest"
+)
+
+func TestAuthTicket(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass"})
+	cfg := srv.Config()
+	rec := &headerRecorder{transport: cfg.Client.Transport, headers: map[string][]http.Header{}}
+	cfg.Client = &http.Client{Transport: rec}
+	cookies, _, err := cfg.Login("alice", []byte("pass"))
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+
+	const referer = "https://www.roblox.com/games/1"
+	ticket, err := cfg.CreateAuthTicket(cookies, referer)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if ticket == "" {
+		t.Fatal("expected ticket")
+	}
+	// The last request is the one accepted after CSRF validation.
+	created := rec.headers[rbxauthtest.AuthTicketPath]
+	if len(created) == 0 {
+		t.Fatal("no create requests")
+	}
+	h := created[len(created)-1]
+	if got := h.Get("Referer"); got != referer {
+		t.Errorf("create: expected Referer %q, got %q", referer, got)
+	}
+	if h.Get("X-CSRF-TOKEN") == "" {
+		t.Error("create: expected CSRF token")
+	}
+	req := &http.Request{Header: h}
+	if c, err := req.Cookie(rbxauthtest.SessionCookieName); err != nil || c.Value != cookieValue(cookies, rbxauthtest.SessionCookieName) {
+		t.Errorf("create: expected session cookie, got %v", err)
+	}
+
+	redeemed, err := cfg.RedeemAuthTicket(ticket)
+	if err != nil {
+		t.Fatalf("redeem: %v", err)
+	}
+	for i, h := range rec.headers[rbxauthtest.RedeemAuthTicketPath] {
+		if h.Get("RBXAuthenticationNegotiation") == "" {
+			t.Errorf("redeem %d: expected negotiation header", i)
+		}
+		if len(h.Values("Cookie")) != 0 {
+			t.Errorf("redeem %d: expected no cookies, got %q", i, h.Values("Cookie"))
+		}
+	}
+	checkSession(t, cfg, redeemed, rbxauth.UserInfo{ID: 1, Name: "alice"})
+	if cookieValue(redeemed, rbxauthtest.SessionCookieName) == cookieValue(cookies, rbxauthtest.SessionCookieName) {
+		t.Error("expected a new session")
+	}
+}
+
+func TestAuthTicketErrors(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass"})
+	cfg := srv.Config()
+	cookies, _, err := cfg.Login("alice", []byte("pass"))
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+
+	// The referer is required by the API.
+	_, err = cfg.CreateAuthTicket(cookies, "")
+	if code, ok := rbxauth.HTTPStatus(err); !ok || code != http.StatusBadRequest {
+		t.Errorf("no referer: expected status 400, got %v", err)
+	}
+	stale := rbxauth.FromSecurityToken("stale")
+	if _, err := cfg.CreateAuthTicket(stale, "https://www.roblox.com/"); !errors.Is(err, rbxauth.ErrUnauthenticated) {
+		t.Errorf("stale: expected ErrUnauthenticated, got %v", err)
+	}
+
+	ticket, err := cfg.CreateAuthTicket(cookies, "https://www.roblox.com/")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := cfg.RedeemAuthTicket(ticket); err != nil {
+		t.Fatalf("redeem: %v", err)
+	}
+	// A ticket is redeemed only once.
+	if _, err := cfg.RedeemAuthTicket(ticket); !errors.Is(err, rbxauth.ErrInvalidAuthTicket) {
+		t.Errorf("reused: expected ErrInvalidAuthTicket, got %v", err)
+	}
+
+	ticket, err = cfg.CreateAuthTicket(cookies, "https://www.roblox.com/")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	srv.ExpireAuthTickets()
+	if _, err := cfg.RedeemAuthTicket(ticket); !errors.Is(err, rbxauth.ErrAuthTicketExpired) {
+		t.Errorf("expired: expected ErrAuthTicketExpired, got %v", err)
+	}
+}
@@ -0,0 +1,164 @@
+package rbxauth
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// BatchAccount specifies the credentials of an account to be logged in by
+// BatchLogin.
+type BatchAccount struct {
+	Cred     Cred
+	Password []byte
+}
+
+// BatchOptions configures the pacing of BatchLogin.
+type BatchOptions struct {
+	// Concurrency is the maximum number of logins in flight at once. If less
+	// than 1, then logins are performed one at a time.
+	Concurrency int
+	// Interval is the minimum duration between the start of each login.
+	Interval time.Duration
+}
+
+// BatchResult is the result of logging in one account of a batch.
+type BatchResult struct {
+	// Cred is the credentials of the account.
+	Cred Cred
+	// Result is the result of the login, or nil if Err is set. If the account
+	// requires multi-step authentication, then Result.Step or
+	// Result.Challenge is set, and can be completed after the batch
+	// finishes.
+	Result *LoginResult
+	// Err is any error that occurred while logging in.
+	Err error
+}
+
+// BatchLogin logs in each of the given accounts, according to opts, which may
+// be nil. A result is returned for each account, in the same order. An error
+// from one account does not prevent the remaining accounts from being logged
+// in.
+//
+// If ctx is canceled, then accounts not yet logged in have the context's
+// error as their result.
+//
+// The CSRF token is shared between logins. If TokenCache is nil, then the
+// Config is cloned to provide one.
+//
+// If WaitOnThrottle is set, then an account whose login is throttled does not
+// hold up the others. Instead, it is logged in again once the remaining
+// accounts have been attempted and its RetryAfter has elapsed.
+func (c Config) BatchLogin(ctx context.Context, accounts []BatchAccount, opts *BatchOptions) []BatchResult {
+	if c.TokenCache == nil {
+		c = c.Clone()
+	}
+	var concurrency int
+	pace := pacer{cfg: &c}
+	if opts != nil {
+		concurrency = opts.Concurrency
+		pace.interval = opts.Interval
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	// Throttled accounts are retried by the batch rather than by the login,
+	// so the password must survive the first attempt.
+	login := c
+	login.WaitOnThrottle = false
+	login.WipePassword = false
+
+	results := make([]BatchResult, len(accounts))
+	var mu sync.Mutex
+	var throttled []throttledAccount
+	run := func(queue []throttledAccount) {
+		indexes := make(chan throttledAccount)
+		var wg sync.WaitGroup
+		for n := 0; n < concurrency && n < len(queue); n++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for t := range indexes {
+					account := accounts[t.index]
+					result := &results[t.index]
+					result.Cred = account.Cred
+					var err error
+					if !t.at.IsZero() {
+						err = c.sleep(ctx, t.at.Sub(c.now()))
+					}
+					if err == nil {
+						err = pace.wait(ctx)
+					}
+					if err != nil {
+						result.Result, result.Err = nil, err
+					} else {
+						result.Result, result.Err = login.LoginCredResult(ctx, account.Cred, account.Password, nil)
+						var terr *ThrottleError
+						if c.WaitOnThrottle && t.at.IsZero() && errors.As(result.Err, &terr) {
+							mu.Lock()
+							throttled = append(throttled, throttledAccount{index: t.index, at: c.now().Add(terr.RetryAfter)})
+							mu.Unlock()
+							continue
+						}
+					}
+					if c.WipePassword {
+						wipe(account.Password)
+					}
+				}
+			}()
+		}
+		for _, t := range queue {
+			indexes <- t
+		}
+		close(indexes)
+		wg.Wait()
+	}
+
+	queue := make([]throttledAccount, len(accounts))
+	for i := range queue {
+		queue[i].index = i
+	}
+	run(queue)
+	if len(throttled) > 0 {
+		sort.Slice(throttled, func(i, j int) bool {
+			return throttled[i].at.Before(throttled[j].at)
+		})
+		run(throttled)
+	}
+	return results
+}
+
+// throttledAccount is an account of a batch, to be logged in after at. at is
+// zero for the first attempt.
+type throttledAccount struct {
+	index int
+	at    time.Time
+}
+
+// pacer ensures a minimum interval between events, according to the clock of
+// cfg.
+type pacer struct {
+	cfg      *Config
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// wait blocks until the interval since the previous event has elapsed, or ctx
+// is done.
+func (p *pacer) wait(ctx context.Context) error {
+	p.mu.Lock()
+	now := p.cfg.now()
+	at := p.next
+	if at.Before(now) {
+		at = now
+	}
+	p.next = at.Add(p.interval)
+	p.mu.Unlock()
+
+	return p.cfg.sleep(ctx, at.Sub(now))
+}
@@ -0,0 +1,76 @@
+package rbxauth_test
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anaminus/rbxauth"
+	"github.com/anaminus/rbxauth/rbxauthtest"
+)
+
+// fakeClock is a clock that advances only when slept upon.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	delays []time.Duration
+}
+
+// install sets the Now and Sleep of cfg to use the clock.
+func (c *fakeClock) install(cfg *rbxauth.Config) {
+	c.now = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	cfg.Now = func() time.Time {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.now
+	}
+	cfg.Sleep = func(ctx context.Context, d time.Duration) error {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if d > 0 {
+			c.delays = append(c.delays, d)
+			c.now = c.now.Add(d)
+		}
+		return ctx.Err()
+	}
+}
+
+func TestBatchLoginPacing(t *testing.T) {
+	const n = 4
+	srv := rbxauthtest.NewServer()
+	t.Cleanup(srv.Close)
+	accounts := make([]rbxauth.BatchAccount, n)
+	for i := range accounts {
+		name := "user" + strconv.Itoa(i)
+		srv.AddAccount(rbxauthtest.Account{ID: int64(i + 1), Name: name, Password: "pass"})
+		accounts[i] = rbxauth.BatchAccount{
+			Cred:     rbxauth.Cred{Type: "Username", Ident: name},
+			Password: []byte("pass"),
+		}
+	}
+
+	cfg := srv.Config()
+	var clock fakeClock
+	clock.install(&cfg)
+	results := cfg.BatchLogin(context.Background(), accounts, &rbxauth.BatchOptions{
+		Concurrency: 1,
+		Interval:    time.Minute,
+	})
+	for i, result := range results {
+		if result.Err != nil {
+			t.Errorf("account %d: %v", i, result.Err)
+		} else if result.Cred != accounts[i].Cred || len(result.Result.Cookies) == 0 {
+			t.Errorf("account %d: unexpected result", i)
+		}
+	}
+	if len(clock.delays) != n-1 {
+		t.Fatalf("expected %d waits, got %v", n-1, clock.delays)
+	}
+	for i, d := range clock.delays {
+		if d != time.Minute {
+			t.Errorf("wait %d: expected %s, got %s", i+1, time.Minute, d)
+		}
+	}
+}
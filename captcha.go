@@ -0,0 +1,134 @@
+package rbxauth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Headers of the challenge protocol, through which the API requests a captcha
+// and receives its solution.
+const (
+	challengeIDHeader       = "rblx-challenge-id"
+	challengeTypeHeader     = "rblx-challenge-type"
+	challengeMetadataHeader = "rblx-challenge-metadata"
+)
+
+// CaptchaPublicKeyLogin is the public key of the captcha presented for a
+// login, used when the challenge does not specify a key.
+const CaptchaPublicKeyLogin = "476068BF-9607-4799-B53D-966BE98E2B81"
+
+// CaptchaChallenge contains the parameters of a captcha that must be solved,
+// which may be passed to a solving service.
+type CaptchaChallenge struct {
+	// Provider is the captcha provider.
+	Provider string
+	// PublicKey is the public key of the captcha, as given to the provider.
+	PublicKey string
+	// ChallengeID identifies the challenge. Empty if the API did not use the
+	// challenge protocol.
+	ChallengeID string
+	// CaptchaID identifies the captcha.
+	CaptchaID string
+	// Blob is the data exchange blob to be passed to the provider.
+	Blob string
+	// ActionType is the action for which the captcha was required.
+	ActionType string
+	// Metadata is the decoded challenge metadata, which is usually a JSON
+	// object. May be nil.
+	Metadata []byte
+}
+
+// challengeMetadata implements the metadata of a captcha challenge.
+type challengeMetadata struct {
+	UnifiedCaptchaID string `json:"unifiedCaptchaId"`
+	DataExchangeBlob string `json:"dataExchangeBlob"`
+	ActionType       string `json:"actionType"`
+	PublicKey        string `json:"publicKey,omitempty"`
+}
+
+// continuationMetadata implements the metadata sent with the solution of a
+// captcha challenge.
+type continuationMetadata struct {
+	UnifiedCaptchaID string `json:"unifiedCaptchaId"`
+	CaptchaToken     string `json:"captchaToken"`
+	ActionType       string `json:"actionType"`
+}
+
+// decodeMetadata decodes the base64 value of a metadata header.
+func decodeMetadata(s string) ([]byte, error) {
+	if b, err := base64.StdEncoding.DecodeString(s); err == nil {
+		return b, nil
+	}
+	return base64.RawStdEncoding.DecodeString(s)
+}
+
+// isChallenge returns whether resp requests a captcha through the challenge
+// protocol.
+func isChallenge(resp *http.Response) bool {
+	return resp != nil && strings.EqualFold(resp.Header.Get(challengeTypeHeader), "captcha")
+}
+
+// ifLoginCaptcha is like ifCaptcha, but also recognizes a captcha requested
+// through the challenge headers of resp, and sets the Challenge of the
+// resulting CaptchaError.
+func ifLoginCaptcha(resp *http.Response, err error) error {
+	err = ifCaptcha(err)
+	var cerr *CaptchaError
+	if !errors.As(err, &cerr) {
+		if !isChallenge(resp) {
+			return err
+		}
+		cerr = &CaptchaError{Provider: CaptchaProviderArkoseLabs, err: err}
+		err = cerr
+	}
+
+	challenge := &CaptchaChallenge{
+		Provider:   cerr.Provider,
+		PublicKey:  CaptchaPublicKeyLogin,
+		ActionType: ActionLogin,
+		CaptchaID:  cerr.Data["unifiedCaptchaId"],
+		Blob:       cerr.Data["dxBlob"],
+	}
+	if isChallenge(resp) {
+		challenge.ChallengeID = resp.Header.Get(challengeIDHeader)
+		if b, err := decodeMetadata(resp.Header.Get(challengeMetadataHeader)); err == nil && len(b) > 0 {
+			challenge.Metadata = b
+			var meta challengeMetadata
+			if json.Unmarshal(b, &meta) == nil {
+				if meta.UnifiedCaptchaID != "" {
+					challenge.CaptchaID = meta.UnifiedCaptchaID
+				}
+				if meta.DataExchangeBlob != "" {
+					challenge.Blob = meta.DataExchangeBlob
+				}
+				if meta.ActionType != "" {
+					challenge.ActionType = meta.ActionType
+				}
+				if meta.PublicKey != "" {
+					challenge.PublicKey = meta.PublicKey
+				}
+			}
+		}
+	}
+	cerr.Challenge = challenge
+	return err
+}
+
+// setContinuation sets the headers of req that pass token as the solution to
+// the challenge.
+func (c *CaptchaChallenge) setContinuation(req *http.Request, token string) {
+	if c.ChallengeID == "" {
+		return
+	}
+	meta, _ := json.Marshal(&continuationMetadata{
+		UnifiedCaptchaID: c.CaptchaID,
+		CaptchaToken:     token,
+		ActionType:       c.ActionType,
+	})
+	req.Header.Set(challengeIDHeader, c.ChallengeID)
+	req.Header.Set(challengeTypeHeader, "captcha")
+	req.Header.Set(challengeMetadataHeader, base64.StdEncoding.EncodeToString(meta))
+}
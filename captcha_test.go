@@ -0,0 +1,144 @@
+package rbxauth_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/anaminus/rbxauth"
+	"github.com/anaminus/rbxauth/rbxauthtest"
+)
+
+func TestCaptchaChallenge(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass", Captcha: "solution"})
+	cfg := srv.Config()
+
+	_, _, err := cfg.Login("alice", []byte("pass"))
+	if !errors.Is(err, rbxauth.ErrCaptchaRequired) {
+		t.Fatalf("expected ErrCaptchaRequired, got %v", err)
+	}
+	if errors.Is(err, rbxauth.ErrBadCredentials) {
+		t.Error("captcha error matches ErrBadCredentials")
+	}
+	var cerr *rbxauth.CaptchaError
+	if !errors.As(err, &cerr) {
+		t.Fatalf("expected CaptchaError, got %T", err)
+	}
+	if cerr.Provider != rbxauth.CaptchaProviderArkoseLabs {
+		t.Errorf("unexpected provider %q", cerr.Provider)
+	}
+	c := cerr.Challenge
+	if c == nil {
+		t.Fatal("expected challenge")
+	}
+	if c.ChallengeID == "" || c.CaptchaID == "" {
+		t.Errorf("challenge missing IDs: %+v", c)
+	}
+	if c.Blob != "blob-"+c.CaptchaID {
+		t.Errorf("unexpected blob %q", c.Blob)
+	}
+	if c.ActionType != rbxauth.ActionLogin {
+		t.Errorf("unexpected action type %q", c.ActionType)
+	}
+	if c.PublicKey != rbxauth.CaptchaPublicKeyLogin {
+		t.Errorf("unexpected public key %q", c.PublicKey)
+	}
+}
+
+func TestCaptchaRequiredCode(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass"})
+	srv.Fail(rbxauthtest.LoginPath, 403, 2, "You must pass the robot test before logging in.")
+	cfg := srv.Config()
+
+	_, _, err := cfg.Login("alice", []byte("pass"))
+	var cerr *rbxauth.CaptchaError
+	if !errors.As(err, &cerr) {
+		t.Fatalf("expected CaptchaError, got %v", err)
+	}
+	if cerr.Provider != rbxauth.CaptchaProviderArkoseLabs {
+		t.Errorf("unexpected provider %q", cerr.Provider)
+	}
+	if !rbxauth.HasCode(err, 2) {
+		t.Error("expected underlying error code 2")
+	}
+}
+
+func TestCaptchaSolver(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass", Captcha: "solution"})
+	cfg := srv.Config()
+	var solved int
+	cfg.CaptchaSolver = func(challenge rbxauth.CaptchaChallenge) (string, error) {
+		solved++
+		return "solution", nil
+	}
+
+	cookies, _, err := cfg.Login("alice", []byte("pass"))
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if len(cookies) == 0 {
+		t.Fatal("expected session cookies")
+	}
+	if solved != 1 {
+		t.Errorf("expected solver to be called once, got %d", solved)
+	}
+	if !srv.LastLogin().Captcha {
+		t.Error("server did not record a solved captcha")
+	}
+}
+
+func TestCaptchaSolverWrong(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass", Captcha: "solution"})
+	cfg := srv.Config()
+	cfg.CaptchaSolver = func(challenge rbxauth.CaptchaChallenge) (string, error) {
+		return "wrong", nil
+	}
+
+	_, _, err := cfg.Login("alice", []byte("pass"))
+	if !errors.Is(err, rbxauth.ErrCaptchaRequired) {
+		t.Fatalf("expected ErrCaptchaRequired, got %v", err)
+	}
+}
+
+func TestLoginOptionsCaptcha(t *testing.T) {
+	var req struct {
+		CaptchaToken    string `json:"captchaToken"`
+		CaptchaProvider string `json:"captchaProvider"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(403)
+		w.Write([]byte(`{"errors":[{"code":2,"message":"captcha"}]}`))
+	}))
+	defer srv.Close()
+	cfg := rbxauth.Config{
+		Client:        srv.Client(),
+		AllowInsecure: true,
+		LoginEndpoint: srv.URL + rbxauthtest.LoginPath,
+	}
+
+	for _, test := range []struct {
+		opts     rbxauth.LoginOptions
+		provider string
+	}{
+		{rbxauth.LoginOptions{CaptchaToken: "token"}, rbxauth.CaptchaProviderArkoseLabs},
+		{rbxauth.LoginOptions{CaptchaToken: "token", CaptchaProvider: "other"}, "other"},
+	} {
+		req.CaptchaToken, req.CaptchaProvider = "", ""
+		cred := rbxauth.Cred{Type: "Username", Ident: "alice"}
+		_, _, err := cfg.LoginCredOpts(context.Background(), cred, []byte("pass"), &test.opts)
+		if !errors.Is(err, rbxauth.ErrCaptchaRequired) {
+			t.Errorf("expected ErrCaptchaRequired, got %v", err)
+		}
+		if req.CaptchaToken != test.opts.CaptchaToken {
+			t.Errorf("sent captcha token %q, expected %q", req.CaptchaToken, test.opts.CaptchaToken)
+		}
+		if req.CaptchaProvider != test.provider {
+			t.Errorf("sent captcha provider %q, expected %q", req.CaptchaProvider, test.provider)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package rbxauth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// These errors are returned for a PendingChallenge.
+var (
+	// ErrChallengeRequired is returned by login methods that cannot return a
+	// PendingChallenge, when the login requires out-of-band approval. Use
+	// LoginCredResult to receive the challenge.
+	ErrChallengeRequired = errors.New("login requires approval")
+	// ErrChallengePending is returned by PendingChallenge.Poll when the login
+	// has not yet been approved.
+	ErrChallengePending = errors.New("login approval pending")
+	// ErrChallengeDenied is returned by PendingChallenge.Poll when the login
+	// was denied or canceled.
+	ErrChallengeDenied = errors.New("login denied")
+)
+
+// Default durations used by an interactive login when polling a login that
+// requires approval.
+const (
+	DefaultPollInterval = 3 * time.Second
+	DefaultPollTimeout  = 5 * time.Minute
+)
+
+// Statuses of a PendingChallenge reported by the API.
+const (
+	challengePending   = "Pending"
+	challengeApproved  = "Approved"
+	challengeDenied    = "Denied"
+	challengeCancelled = "Cancelled"
+	challengeExpired   = "Expired"
+)
+
+// PendingChallenge holds the state of a login that must be approved outside
+// of the program, such as by following a link sent via email, or by
+// confirming the login from another device.
+type PendingChallenge struct {
+	cfg    Config
+	ticket string
+
+	// User is the user being authenticated, as reported by the initial login
+	// response. May be nil.
+	User *UserInfo
+
+	// Expires is the time after which the challenge is no longer valid. The
+	// API does not report the expiration of a challenge, so it is estimated
+	// as DefaultStepTTL after the challenge was issued.
+	Expires time.Time
+}
+
+// Valid returns whether the challenge has not yet expired. A challenge with a
+// zero Expires is always valid.
+func (p *PendingChallenge) Valid() bool {
+	return p.Expires.IsZero() || p.cfg.now().Before(p.Expires)
+}
+
+// endpoint returns the URL of a challenge action.
+func (p *PendingChallenge) endpoint(action string) string {
+	endpoint := p.cfg.IdentityVerificationEndpoint
+	if endpoint == "" {
+		endpoint = DefaultIdentityVerificationEndpoint
+	}
+	return endpoint + "/" + action
+}
+
+// Poll checks whether the login has been approved. If so, returns HTTP cookies
+// representing the authenticated session. Returns ErrChallengePending if the
+// login has not yet been approved, and ErrChallengeDenied if the login was
+// denied.
+func (p *PendingChallenge) Poll() (cookies []*http.Cookie, err error) {
+	return p.PollContext(context.Background())
+}
+
+// PollContext is like Poll, but with a context.
+func (p *PendingChallenge) PollContext(ctx context.Context) (cookies []*http.Cookie, err error) {
+	defer wrapOp("poll", &err)
+	if !p.Valid() {
+		return nil, ErrStepExpired
+	}
+
+	body, _ := json.Marshal(&identityVerificationRequest{Ticket: p.ticket})
+	req, err := p.cfg.newRequest(ctx, "POST", p.endpoint("status"), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	var apiResp identityVerificationStatusResponse
+	resp, err := p.cfg.requestAPI("identity-verification", req, &apiResp)
+	if err != nil {
+		return nil, err
+	}
+	switch apiResp.Status {
+	case challengeApproved:
+		return responseCookies(resp), nil
+	case challengePending:
+		return nil, ErrChallengePending
+	case challengeDenied, challengeCancelled:
+		return nil, ErrChallengeDenied
+	case challengeExpired:
+		return nil, ErrStepExpired
+	}
+	return nil, errors.New("unknown status " + apiResp.Status)
+}
+
+// Wait polls the challenge every interval until the login is approved, the
+// login is denied, or ctx is done. The interval is waited according to
+// Config.Sleep.
+func (p *PendingChallenge) Wait(ctx context.Context, interval time.Duration) (cookies []*http.Cookie, err error) {
+	for {
+		cookies, err = p.PollContext(ctx)
+		if !errors.Is(err, ErrChallengePending) {
+			return cookies, err
+		}
+		if err := p.cfg.sleep(ctx, interval); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Cancel abandons the challenge, invalidating any pending approval.
+func (p *PendingChallenge) Cancel() error {
+	return p.CancelContext(context.Background())
+}
+
+// CancelContext is like Cancel, but with a context.
+func (p *PendingChallenge) CancelContext(ctx context.Context) (err error) {
+	defer wrapOp("cancel", &err)
+	body, _ := json.Marshal(&identityVerificationRequest{Ticket: p.ticket})
+	req, err := p.cfg.newRequest(ctx, "POST", p.endpoint("cancel"), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	_, err = p.cfg.requestAPI("identity-verification", req, &errorsResponse{})
+	return err
+}
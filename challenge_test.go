@@ -0,0 +1,159 @@
+package rbxauth_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anaminus/rbxauth"
+	"github.com/anaminus/rbxauth/rbxauthtest"
+)
+
+// loginChallenge logs into the account named alice with cfg, which must
+// require approval, returning the pending challenge.
+func loginChallenge(t *testing.T, cfg rbxauth.Config) *rbxauth.PendingChallenge {
+	t.Helper()
+	cred := rbxauth.Cred{Type: "Username", Ident: "alice"}
+	result, err := cfg.LoginCredResult(context.Background(), cred, []byte("pass"), nil)
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if result.Challenge == nil {
+		t.Fatalf("expected challenge, got %d cookies", len(result.Cookies))
+	}
+	return result.Challenge
+}
+
+func TestChallengePoll(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass", Approval: true, ApproveAfter: 3})
+	cfg := srv.Config()
+
+	// Login methods that cannot return a challenge report it as an error.
+	if _, _, err := cfg.Login("alice", []byte("pass")); !errors.Is(err, rbxauth.ErrChallengeRequired) {
+		t.Errorf("expected ErrChallengeRequired, got %v", err)
+	}
+
+	challenge := loginChallenge(t, cfg)
+	for i := 0; i < 3; i++ {
+		if _, err := challenge.Poll(); !errors.Is(err, rbxauth.ErrChallengePending) {
+			t.Fatalf("poll %d: expected ErrChallengePending, got %v", i+1, err)
+		}
+	}
+	cookies, err := challenge.Poll()
+	if err != nil {
+		t.Fatalf("approved: %v", err)
+	}
+	checkSession(t, cfg, cookies, rbxauth.UserInfo{ID: 1, Name: "alice"})
+}
+
+func TestChallengeDenied(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass", Approval: true, ApproveAfter: 1, Deny: true})
+	cfg := srv.Config()
+	challenge := loginChallenge(t, cfg)
+	if _, err := challenge.Poll(); !errors.Is(err, rbxauth.ErrChallengePending) {
+		t.Fatalf("expected ErrChallengePending, got %v", err)
+	}
+	if _, err := challenge.Poll(); !errors.Is(err, rbxauth.ErrChallengeDenied) {
+		t.Errorf("expected ErrChallengeDenied, got %v", err)
+	}
+
+	// A canceled challenge can no longer be approved.
+	challenge = loginChallenge(t, cfg)
+	if err := challenge.Cancel(); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	if cookies, err := challenge.Poll(); err == nil {
+		t.Errorf("canceled: expected error, got %d cookies", len(cookies))
+	}
+}
+
+func TestChallengeWait(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass", Approval: true, ApproveAfter: 4})
+	cfg := srv.Config()
+	var clock fakeClock
+	clock.install(&cfg)
+
+	cookies, err := loginChallenge(t, cfg).Wait(context.Background(), 2*time.Second)
+	if err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+	checkSession(t, cfg, cookies, rbxauth.UserInfo{ID: 1, Name: "alice"})
+	want := []time.Duration{2 * time.Second, 2 * time.Second, 2 * time.Second, 2 * time.Second}
+	if !equalDelays(clock.delays, want) {
+		t.Errorf("expected delays %v, got %v", want, clock.delays)
+	}
+
+	// The challenge expires while waiting.
+	clock.delays = nil
+	_, err = loginChallenge(t, cfg).Wait(context.Background(), rbxauth.DefaultStepTTL)
+	if !errors.Is(err, rbxauth.ErrStepExpired) {
+		t.Errorf("expected ErrStepExpired, got %v", err)
+	}
+}
+
+func TestChallengePrompt(t *testing.T) {
+	for _, test := range []struct {
+		name         string
+		approveAfter int
+		deny         bool
+		// err is a substring of the expected error, or empty if the login
+		// succeeds.
+		err    string
+		delays []time.Duration
+	}{
+		{"approved", 2, false, "", []time.Duration{5 * time.Second, 5 * time.Second}},
+		{"immediate", 0, false, "", nil},
+		{"denied", 1, true, "login denied", []time.Duration{5 * time.Second}},
+		// The last interval is cut short by the timeout.
+		{"timeout", 100, false, "timed out", []time.Duration{5 * time.Second, 5 * time.Second, 2 * time.Second}},
+	} {
+		srv := newServer(t, rbxauthtest.Account{
+			ID:           1,
+			Name:         "alice",
+			Password:     "pass",
+			Approval:     true,
+			ApproveAfter: test.approveAfter,
+			Deny:         test.deny,
+		})
+		cfg := srv.Config()
+		cfg.PollInterval = 5 * time.Second
+		cfg.PollTimeout = 12 * time.Second
+		var clock fakeClock
+		clock.install(&cfg)
+		var out strings.Builder
+		s := &rbxauth.Stream{Config: cfg, Reader: strings.NewReader("pass\n"), Writer: &out, Quiet: true}
+		_, cookies, err := s.PromptCred(rbxauth.Cred{Type: "Username", Ident: "alice"})
+		if !strings.Contains(out.String(), rbxauth.DefaultMessages.ApproveLogin) {
+			t.Errorf("%s: expected approval message, got %q", test.name, out.String())
+		}
+		if !equalDelays(clock.delays, test.delays) {
+			t.Errorf("%s: expected delays %v, got %v", test.name, test.delays, clock.delays)
+		}
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: expected error containing %q, got %v", test.name, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		checkSession(t, cfg, cookies, rbxauth.UserInfo{ID: 1, Name: "alice"})
+	}
+}
+
+// equalDelays returns whether a and b contain the same durations.
+func equalDelays(a, b []time.Duration) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,81 @@
+package rbxauth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+)
+
+// ErrCommandNoOutput is returned when a command run to provide a secret exits
+// successfully, but writes nothing to stdout.
+var ErrCommandNoOutput = errors.New("command produced no output")
+
+// CommandError is returned when a command run to provide a secret fails.
+type CommandError struct {
+	// Name is the name of the command.
+	Name string
+	// Stderr is the output of the command to stderr, with surrounding space
+	// trimmed.
+	Stderr string
+
+	err error
+}
+
+// Error implements the error interface.
+func (err *CommandError) Error() string {
+	msg := "command " + err.Name + ": " + err.err.Error()
+	if err.Stderr != "" {
+		msg += ": " + err.Stderr
+	}
+	return msg
+}
+
+// Unwrap implements the Unwrap interface.
+func (err *CommandError) Unwrap() error {
+	return err.err
+}
+
+// runSecretCommand runs the command described by args, which is not run
+// through a shell, and returns its output to stdout with surrounding space
+// trimmed. The command is killed if ctx is done. Returns a *CommandError if
+// the command fails or produces no output.
+func runSecretCommand(ctx context.Context, args []string) ([]byte, error) {
+	if len(args) == 0 || args[0] == "" {
+		return nil, errors.New("empty command")
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	defer wipe(stdout.Bytes())
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			err = ctxErr
+		}
+		return nil, &CommandError{Name: args[0], Stderr: strings.TrimSpace(stderr.String()), err: err}
+	}
+	out := bytes.TrimSpace(stdout.Bytes())
+	if len(out) == 0 {
+		return nil, &CommandError{Name: args[0], Stderr: strings.TrimSpace(stderr.String()), err: ErrCommandNoOutput}
+	}
+	return append([]byte(nil), out...), nil
+}
+
+// commandContext returns the context within which a command run by the
+// stream must finish, which is bounded by Timeout.
+func (s *Stream) commandContext() (context.Context, context.CancelFunc) {
+	if s.Timeout > 0 {
+		return context.WithTimeout(s.context(), s.Timeout)
+	}
+	return context.WithCancel(s.context())
+}
+
+// commandSecret runs args with runSecretCommand, within commandContext.
+func (s *Stream) commandSecret(args []string) ([]byte, error) {
+	ctx, cancel := s.commandContext()
+	defer cancel()
+	return runSecretCommand(ctx, args)
+}
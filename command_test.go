@@ -0,0 +1,109 @@
+package rbxauth
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+// helperCommandEnv names the environment variable that makes
+// TestHelperCommand act as a command that provides a secret, according to
+// the argument following the flags of the test binary.
+const helperCommandEnv = "RBXAUTH_HELPER_COMMAND"
+
+func TestHelperCommand(t *testing.T) {
+	if os.Getenv(helperCommandEnv) == "" {
+		return
+	}
+	switch flag.Arg(0) {
+	case "success":
+		fmt.Println("  secret ")
+	case "fail":
+		fmt.Fprintln(os.Stderr, "vault is sealed")
+		os.Exit(3)
+	case "empty":
+		fmt.Println(" ")
+		fmt.Fprintln(os.Stderr, "no such entry")
+	case "hang":
+		time.Sleep(time.Minute)
+	}
+	os.Exit(0)
+}
+
+// helperCommand returns the arguments of a command that runs
+// TestHelperCommand with mode.
+func helperCommand(t *testing.T, mode string) []string {
+	t.Setenv(helperCommandEnv, "1")
+	return []string{os.Args[0], "-test.run=^TestHelperCommand$", "--", mode}
+}
+
+func TestRunSecretCommand(t *testing.T) {
+	ctx := context.Background()
+	out, err := runSecretCommand(ctx, helperCommand(t, "success"))
+	if err != nil {
+		t.Fatalf("success: %v", err)
+	}
+	if string(out) != "secret" {
+		t.Errorf("success: expected trimmed output %q, got %q", "secret", out)
+	}
+
+	var cerr *CommandError
+	_, err = runSecretCommand(ctx, helperCommand(t, "fail"))
+	if !errors.As(err, &cerr) || cerr.Stderr != "vault is sealed" || cerr.Name != os.Args[0] {
+		t.Errorf("fail: expected CommandError with stderr, got %v", err)
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+		t.Errorf("fail: expected exit code 3, got %v", err)
+	}
+
+	_, err = runSecretCommand(ctx, helperCommand(t, "empty"))
+	if !errors.As(err, &cerr) || cerr.Stderr != "no such entry" {
+		t.Errorf("empty: expected CommandError with stderr, got %v", err)
+	}
+	if !errors.Is(err, ErrCommandNoOutput) {
+		t.Errorf("empty: expected ErrCommandNoOutput, got %v", err)
+	}
+
+	timeout, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = runSecretCommand(timeout, helperCommand(t, "hang"))
+	if !errors.As(err, &cerr) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("hang: expected CommandError with deadline, got %v", err)
+	}
+	if d := time.Since(start); d > 10*time.Second {
+		t.Errorf("hang: command was not killed, took %s", d)
+	}
+
+	if _, err := runSecretCommand(ctx, nil); err == nil {
+		t.Error("no command: expected error")
+	}
+}
+
+func TestStreamPasswordCommand(t *testing.T) {
+	s := &Stream{PasswordCommand: helperCommand(t, "success")}
+	password, ok, err := s.sourcePassword()
+	if err != nil || !ok || string(password) != "secret" {
+		t.Errorf("success: expected password, got %q, %t, %v", password, ok, err)
+	}
+	if n := s.PasswordAttempts(); n != 1 {
+		t.Errorf("expected 1 attempt, got %d", n)
+	}
+
+	// The command is bounded by the Timeout of the stream.
+	s = &Stream{PasswordCommand: helperCommand(t, "hang"), Timeout: 100 * time.Millisecond}
+	if _, _, err := s.sourcePassword(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("hang: expected deadline, got %v", err)
+	}
+
+	s = &Stream{PasswordCommand: helperCommand(t, "success"), PasswordEnv: "PASSWORD"}
+	if _, _, err := s.sourcePassword(); err == nil {
+		t.Error("multiple sources: expected error")
+	}
+}
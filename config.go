@@ -4,10 +4,20 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"mime"
 	"net/http"
+	"os"
+	"reflect"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode"
 )
 
 // Each of these constants define the default value used when the corresponding
@@ -18,66 +28,308 @@ const (
 	DefaultVerifyEndpoint = "https://auth.roblox.com/v2/twostepverification/verify"
 	DefaultResendEndpoint = "https://auth.roblox.com/v2/twostepverification/resend"
 
+	DefaultLogoutAllEndpoint = "https://auth.roblox.com/v2/logoutfromallsessionsandreauthenticate"
+
+	DefaultValidatePasswordEndpoint = "https://auth.roblox.com/v2/passwords/validate"
+	DefaultChangePasswordEndpoint   = "https://auth.roblox.com/v2/user/passwords/change"
+
+	DefaultMetadataEndpoint = "https://auth.roblox.com/v2/metadata"
+
+	DefaultAuthTicketEndpoint       = "https://auth.roblox.com/v1/authentication-ticket"
+	DefaultRedeemAuthTicketEndpoint = "https://auth.roblox.com/v1/authentication-ticket/redeem"
+
+	DefaultEmailEndpoint       = "https://accountsettings.roblox.com/v1/email"
+	DefaultEmailVerifyEndpoint = "https://accountsettings.roblox.com/v1/email/verify"
+
+	// The %s verb is replaced with the name of a social provider.
+	DefaultSocialLoginEndpoint = "https://auth.roblox.com/v1/social/%s/login"
+
+	DefaultSignupEndpoint = "https://auth.roblox.com/v2/signup"
+
+	DefaultValidateUsernameEndpoint   = "https://auth.roblox.com/v2/usernames/validate"
+	DefaultRecommendUsernamesEndpoint = "https://auth.roblox.com/v2/usernames/recommendations"
+
 	// The %d verb is replaced with a user ID.
-	DefaultUserIDEndpoint = "https://api.roblox.com/users/%d"
+	DefaultUserIDEndpoint = "https://users.roblox.com/v1/users/%d"
+
+	DefaultAuthenticatedEndpoint  = "https://users.roblox.com/v1/users/authenticated"
+	DefaultUsernameLookupEndpoint = "https://users.roblox.com/v1/usernames/users"
+	DefaultRefreshEndpoint        = DefaultAuthenticatedEndpoint
+
+	// The %d verb is replaced with a user ID, and the %s verb is replaced with
+	// the media type of the challenge (authenticator, email, sms).
+	DefaultTwoStepChallengeEndpoint = "https://twostepverification.roblox.com/v1/users/%d/challenges/%s"
+	// The %d verb is replaced with a user ID.
+	DefaultTwoStepLoginEndpoint = "https://auth.roblox.com/v3/users/%d/two-step-verification/login"
+	// Base URL of a login that requires out-of-band approval. Followed by the
+	// action.
+	DefaultIdentityVerificationEndpoint = "https://auth.roblox.com/v1/identity-verification/login"
+	// Base URL of a login that requires security questions to be answered.
+	// Followed by the action.
+	DefaultSecurityQuestionsEndpoint = "https://auth.roblox.com/v1/security-questions/login"
+
+	DefaultUnlockPINEndpoint = "https://auth.roblox.com/v1/account/pin/unlock"
+	DefaultLockPINEndpoint   = "https://auth.roblox.com/v1/account/pin/lock"
+
+	DefaultPasswordResetSendEndpoint   = "https://auth.roblox.com/v2/passwords/reset/send"
+	DefaultPasswordResetVerifyEndpoint = "https://auth.roblox.com/v2/passwords/reset/verify"
+	DefaultPasswordResetEndpoint       = "https://auth.roblox.com/v2/passwords/reset"
 )
 
+// DefaultRetryBaseDelay is the default value of Config.RetryBaseDelay.
+const DefaultRetryBaseDelay = 500 * time.Millisecond
+
+// MaxRetryDelay is the maximum delay before retrying a request, not counting
+// jitter or a Retry-After header.
+const MaxRetryDelay = 30 * time.Second
+
+// DefaultMaxResponseBytes is the default value of Config.MaxResponseBytes.
+const DefaultMaxResponseBytes = 4 << 20
+
+// ErrResponseTooLarge is returned when the body of a response exceeds
+// Config.MaxResponseBytes.
+var ErrResponseTooLarge = errors.New("response too large")
+
+// DefaultResendCooldown is the default value of Config.ResendCooldown.
+const DefaultResendCooldown = 30 * time.Second
+
+// DefaultCodeRetries is the number of times a verification code is prompted
+// again when Config.CodeRetries is zero.
+const DefaultCodeRetries = 2
+
 const tokenHeader = "X-CSRF-TOKEN"
 
+// requestIDHeader is the response header that identifies a request, which may
+// be quoted when reporting a problem.
+const requestIDHeader = "X-Roblox-Request-Id"
+
 ////////////////////////////////////////////////////////////////////////////////
 
-// statusError represents an error derived from the status code of an HTTP
-// response. It also wraps an API error response.
-type statusError struct {
-	code int
-	resp error
+// StatusError represents an error derived from the status code of an HTTP
+// response. It also wraps an API error response, if present.
+type StatusError struct {
+	// Code is the status code of the response.
+	Code int
+	// Err is the error response, or nil if the response did not contain an
+	// error.
+	Err error
+	// RequestID is the identifier of the request reported by the response,
+	// if present.
+	RequestID string
 }
 
 // Error implements the error interface.
-func (err statusError) Error() string {
-	if err.resp == nil {
-		return "http status " + strconv.Itoa(err.code) + ": " + http.StatusText(err.code)
+func (err StatusError) Error() string {
+	s := "http status " + strconv.Itoa(err.Code)
+	if err.RequestID != "" {
+		s += " (request " + err.RequestID + ")"
 	}
-	return "http status " + strconv.Itoa(err.code) + ": " + err.resp.Error()
+	if err.Err == nil {
+		return s + ": " + http.StatusText(err.Code)
+	}
+	return s + ": " + err.Err.Error()
 }
 
 // Unwrap implements the Unwrap interface.
-func (err statusError) Unwrap() error {
-	return err.resp
+func (err StatusError) Unwrap() error {
+	return err.Err
+}
+
+// Is returns whether target is a StatusError with the same status code.
+func (err StatusError) Is(target error) bool {
+	switch target := target.(type) {
+	case StatusError:
+		return target.Code == err.Code
+	case *StatusError:
+		return target != nil && target.Code == err.Code
+	}
+	return false
 }
 
 // StatusCode returns the status code of the error.
-func (err statusError) StatusCode() int {
-	return err.code
+func (err StatusError) StatusCode() int {
+	return err.Code
 }
 
-// if Status wraps err in a statusError if code is not 2XX, and returns err
+// HTTPStatus returns the status code of the first StatusError in err's chain.
+// Returns false if err does not contain a StatusError.
+func HTTPStatus(err error) (code int, ok bool) {
+	var serr *StatusError
+	if errors.As(err, &serr) {
+		return serr.Code, true
+	}
+	return 0, false
+}
+
+// if Status wraps err in a StatusError if code is not 2XX, and returns err
 // otherwise.
 func ifStatus(code int, err error) error {
 	if code < 200 || code >= 300 {
-		return &statusError{code: code, resp: err}
+		return &StatusError{Code: code, Err: err}
 	}
 	return err
 }
 
+// Error codes returned by the login endpoint.
+const (
+	codeTokenValidation = 0
+	codeCaptchaRequired = 2
+)
+
+// CaptchaProviderArkoseLabs is the captcha provider used by the login endpoint.
+const CaptchaProviderArkoseLabs = "PROVIDER_ARKOSE_LABS"
+
+// CaptchaError is returned when a login requires a captcha to be solved. The
+// captcha must be solved out-of-band, and the resulting token passed to the
+// next login attempt via LoginOptions.
+type CaptchaError struct {
+	// Provider is the captcha provider expected by the API.
+	Provider string
+	// Data contains challenge metadata included with the response, such as
+	// the blob to be passed to the provider. May be nil.
+	Data map[string]string
+	// Challenge contains the parameters of the captcha, for a captcha
+	// required by a login. May be nil.
+	Challenge *CaptchaChallenge
+
+	err error
+}
+
+// Error implements the error interface.
+func (err *CaptchaError) Error() string {
+	if err.err == nil {
+		return "captcha required"
+	}
+	return "captcha required: " + err.err.Error()
+}
+
+// Unwrap implements the Unwrap interface.
+func (err *CaptchaError) Unwrap() error {
+	return err.err
+}
+
+// Is returns whether target is ErrCaptchaRequired.
+func (err *CaptchaError) Is(target error) bool {
+	return target == ErrCaptchaRequired
+}
+
+// ifCaptcha wraps err in a CaptchaError if err contains an API error
+// indicating that a captcha must be solved, and returns err otherwise.
+func ifCaptcha(err error) error {
+	var errResp ErrorResponse
+	if !errors.As(err, &errResp) || errResp.Code != codeCaptchaRequired {
+		return err
+	}
+	cerr := &CaptchaError{Provider: CaptchaProviderArkoseLabs, err: err}
+	if errResp.FieldData != "" {
+		// Field data is a JSON object encoded as a string.
+		var data map[string]interface{}
+		if json.Unmarshal([]byte(errResp.FieldData), &data) == nil {
+			cerr.Data = make(map[string]string, len(data))
+			for k, v := range data {
+				cerr.Data[k] = fmt.Sprint(v)
+			}
+		}
+	}
+	return cerr
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 
 // Config configures an authentication action. Authentication endpoints must
 // implement Roblox's Auth v2 API. When an endpoint is an empty string, the
 // value of the corresponding Default constant is used instead.
+//
+// The methods of Config receive it by value, so that a Config can be copied
+// and used concurrently. As a result, state learned during a call, such as the
+// CSRF token, is not retained by the caller's Config unless it is held by
+// TokenCache or TokenStore, which are shared by copies. Without either, each
+// call must obtain the token again, costing an extra exchange. A Config
+// returned by NewConfig has a TokenCache.
 type Config struct {
-	// Client is used to make requests. If nil, the http.DefaultClient is used.
+	// Client is used to make requests. If nil, then a client shared by every
+	// Config is used, unless ProxyURL is set. See HTTPClient.
 	Client *http.Client
 
+	// Timeout is the duration after which a single exchange with the API
+	// fails with an error matching ErrTimeout, including the time taken to
+	// read the response. If zero, DefaultTimeout is used when Client is nil,
+	// and no timeout is applied otherwise. If negative, no timeout is
+	// applied. The Context of a request may still impose a deadline.
+	Timeout time.Duration
+
+	// ProxyURL, if not empty, is the URL of a proxy through which requests
+	// are made. The scheme may be http, https, socks5, or socks5h, and the
+	// URL may contain credentials. Must not be set with Client.
+	ProxyURL string
+
 	// Token is a string passed through requests to prevent cross-site request
-	// forgery. The config automatically sets the this value from the previous
-	// request.
+	// forgery. It is the initial token of each call, and is updated from each
+	// response only within the call's copy of the Config. Set TokenCache to
+	// retain the token between calls.
 	Token string
 
+	// TokenCache, if non-nil, is used to hold the token instead of the Token
+	// field. Because it is held by pointer, the token is shared by copies of
+	// the Config, and can be safely used by multiple goroutines. The Token
+	// field is still used as the initial token when the cache is empty.
+	TokenCache *TokenCache
+
+	// TokenStore, if non-nil, is used to load the token when there is no
+	// current token, and to save the token whenever it changes.
+	TokenStore TokenStore
+
+	// CaptchaSolver, if non-nil, is called when a login requires a captcha,
+	// and returns the token obtained from solving the captcha. The login is
+	// then sent again once with the token. If the solver returns an error,
+	// then the login fails with the error.
+	CaptchaSolver func(challenge CaptchaChallenge) (token string, err error)
+
+	// AutoPrime causes a login to call PrimeToken before the login request
+	// when there is no current token, rather than relying on the login
+	// request being rejected and sent again.
+	AutoPrime bool
+
+	// AllowInsecure permits endpoints to use the http scheme, such as for a
+	// local stub of the API. Otherwise, Validate requires https.
+	AllowInsecure bool
+
 	// LoginEndpoint specifies the URL used for logging in.
 	LoginEndpoint string
 	// LogoutEndpoint specifies the URL used for logging out.
 	LogoutEndpoint string
+	// LogoutAllEndpoint specifies the URL used for logging out of all other
+	// sessions.
+	LogoutAllEndpoint string
+	// ValidatePasswordEndpoint specifies the URL used to validate a password.
+	ValidatePasswordEndpoint string
+	// ChangePasswordEndpoint specifies the URL used to change a password.
+	ChangePasswordEndpoint string
+	// MetadataEndpoint specifies the URL used to get authentication metadata.
+	MetadataEndpoint string
+	// AuthTicketEndpoint specifies the URL used to create an authentication
+	// ticket.
+	AuthTicketEndpoint string
+	// RedeemAuthTicketEndpoint specifies the URL used to redeem an
+	// authentication ticket.
+	RedeemAuthTicketEndpoint string
+	// EmailEndpoint specifies the URL used to get the email of an account.
+	EmailEndpoint string
+	// EmailVerifyEndpoint specifies the URL used to send a verification
+	// email.
+	EmailVerifyEndpoint string
+	// SocialLoginEndpoint specifies the URL used to log in with a social
+	// provider. The URL must contain a "%s" format verb, which is replaced
+	// with the provider.
+	SocialLoginEndpoint string
+	// SignupEndpoint specifies the URL used to create an account.
+	SignupEndpoint string
+	// ValidateUsernameEndpoint specifies the URL used to validate a
+	// username.
+	ValidateUsernameEndpoint string
+	// RecommendUsernamesEndpoint specifies the URL used to get suggested
+	// usernames.
+	RecommendUsernamesEndpoint string
 	// VerifyEndpoint specifies the URL used for verifying a two-step
 	// authentication code.
 	VerifyEndpoint string
@@ -87,46 +339,802 @@ type Config struct {
 	// UserIDEndpoint specifies the URL used to fetch a username from an ID. The
 	// URL must contain a "%d" format verb, which is replaced with the user ID.
 	UserIDEndpoint string
+	// LegacyUserIDEndpoint, if not empty, specifies a URL used to fetch a
+	// username from an ID when UserIDEndpoint responds with a 404 status. The
+	// URL must contain a "%d" format verb, and implement the response of the
+	// decommissioned api.roblox.com/users/%d endpoint.
+	LegacyUserIDEndpoint string
+	// AuthenticatedEndpoint specifies the URL used to fetch the user
+	// associated with a session.
+	AuthenticatedEndpoint string
+	// RefreshEndpoint specifies an authenticated URL requested to keep a
+	// session alive.
+	RefreshEndpoint string
+	// UsernameLookupEndpoint specifies the URL used to fetch user IDs from
+	// usernames.
+	UsernameLookupEndpoint string
+
+	// TwoStepChallengeEndpoint specifies the base URL of the two-step
+	// verification challenge API. The URL must contain a "%d" verb, which is
+	// replaced with the user ID, followed by a "%s" verb, which is replaced
+	// with the media type of the challenge.
+	TwoStepChallengeEndpoint string
+	// TwoStepLoginEndpoint specifies the URL used to complete a login after a
+	// challenge has been verified. The URL must contain a "%d" verb, which is
+	// replaced with the user ID.
+	TwoStepLoginEndpoint string
+	// LegacyTwoStep forces two-step verification to use VerifyEndpoint and
+	// ResendEndpoint instead of the challenge API. Codes sent to an
+	// authenticator app are always verified with the challenge API.
+	LegacyTwoStep bool
+	// IdentityVerificationEndpoint specifies the base URL used to poll and
+	// cancel a login that requires out-of-band approval. The action is
+	// appended to the URL.
+	IdentityVerificationEndpoint string
+	// SecurityQuestionsEndpoint specifies the base URL used to get and answer
+	// the security questions of a login. The action is appended to the URL.
+	SecurityQuestionsEndpoint string
+	// UnlockPINEndpoint specifies the URL used to unlock an account PIN.
+	UnlockPINEndpoint string
+	// LockPINEndpoint specifies the URL used to lock an account PIN.
+	LockPINEndpoint string
+	// PasswordResetSendEndpoint specifies the URL used to send the code of a
+	// password reset.
+	PasswordResetSendEndpoint string
+	// PasswordResetVerifyEndpoint specifies the URL used to verify the code
+	// of a password reset.
+	PasswordResetVerifyEndpoint string
+	// PasswordResetEndpoint specifies the URL used to set a new password
+	// after the code of a password reset has been verified.
+	PasswordResetEndpoint string
+	// TokenEndpoint specifies the URL requested by PrimeToken. If empty,
+	// LogoutEndpoint is used.
+	TokenEndpoint string
+
+	// MaxRetries is the maximum number of times a request is retried after
+	// receiving a status indicating a transient failure (429, 502, 503).
+	MaxRetries int
+	// RetryBaseDelay is the delay before the first retry, which doubles with
+	// each subsequent retry, up to MaxRetryDelay. A Retry-After header in the
+	// response takes precedence. If zero, DefaultRetryBaseDelay is used.
+	RetryBaseDelay time.Duration
+
+	// ResendCooldown is the minimum duration between sending two-step
+	// verification codes. Step.Resend fails without making a request if the
+	// cooldown has not elapsed. If zero, DefaultResendCooldown is used. If
+	// negative, there is no cooldown.
+	ResendCooldown time.Duration
+
+	// Now returns the current time. If nil, time.Now is used.
+	Now func() time.Time
+	// Sleep waits for the given duration, returning early with the error of
+	// the context if it is done first. If nil, a timer is used.
+	Sleep func(ctx context.Context, d time.Duration) error
+
+	// WaitOnThrottle causes a login that fails with a ThrottleError to wait
+	// for RetryAfter, then log in again once.
+	WaitOnThrottle bool
+
+	// WipePassword causes login methods to overwrite the given password with
+	// zeros before returning. Regardless of this setting, copies of the
+	// password made internally are always wiped.
+	WipePassword bool
+
+	// PollInterval is the interval at which a login requiring out-of-band
+	// approval is polled by an interactive login. If zero,
+	// DefaultPollInterval is used.
+	PollInterval time.Duration
+	// PollTimeout is the duration an interactive login waits for a login to
+	// be approved. If zero, DefaultPollTimeout is used.
+	PollTimeout time.Duration
+
+	// CodeRetries is the number of times LoginWithPrompter prompts again for
+	// a verification code after an incorrect code is entered, or for the
+	// answer to a security question after an incorrect answer. If zero,
+	// DefaultCodeRetries is used. If negative, the code is not prompted
+	// again.
+	CodeRetries int
+
+	// CodeProvider, if non-nil, returns the two-step verification code sent
+	// via the given media type, instead of the code being prompted. For an
+	// authenticator, TOTPCodeProvider can be used.
+	CodeProvider func(mediaType string) (string, error)
+
+	// Log, if not nil, is called after each HTTP exchange made with the API,
+	// including exchanges retried due to failed token validation.
+	Log func(event LogEvent)
+
+	// Metrics, if non-nil, receives measurements of each exchange made with
+	// the API, and of notable events during a login.
+	Metrics Metrics
+
+	// ResponseHook, if non-nil, is called with each response received from
+	// the API, including responses that are retried. The body of the
+	// response has been read, and is replaced with a buffer that can be read
+	// again.
+	ResponseHook func(resp *http.Response)
+
+	// MaxResponseBytes is the maximum number of bytes read from the body of
+	// a response. A larger body fails with ErrResponseTooLarge. If zero,
+	// DefaultMaxResponseBytes is used. If negative, the body is not limited.
+	MaxResponseBytes int64
+
+	// StrictDecoding causes a successful response that contains fields not
+	// known to the package to fail to decode. This is intended for detecting
+	// changes to the API.
+	StrictDecoding bool
+
+	// DumpRequests, if non-nil, receives the method, URL, and JSON body of
+	// each request made to the API, for debugging. Passwords, PINs, and codes
+	// are redacted, but may still be held in memory while the body is
+	// formatted.
+	DumpRequests io.Writer
+
+	// Headers are added to each request made to the API. Headers set by a
+	// particular request, such as Content-Type, take precedence. The
+	// X-CSRF-TOKEN and Cookie headers cannot be set.
+	Headers http.Header
+	// UserAgent, if non-empty, is used as the User-Agent header of each
+	// request, taking precedence over Headers.
+	UserAgent string
+
+	// PersistentCookies are attached to each login request. This may be used
+	// to present the device cookies of a previous verification, so that the
+	// device is remembered. See VerifyResult.
+	PersistentCookies []*http.Cookie
+
+	// Device, if non-nil, describes the device from which logins are made,
+	// and is included in each login request. If nil, no device information is
+	// sent.
+	Device *DeviceInfo
+
+	// MetadataCache, if non-nil, holds the result of Metadata so that it is
+	// not requested again until MetadataTTL has elapsed.
+	MetadataCache *MetadataCache
+	// MetadataTTL is the duration for which metadata is cached. If zero,
+	// DefaultMetadataTTL is used.
+	MetadataTTL time.Duration
 }
 
-func (c *Config) requestAPI(req *http.Request, apiResp interface{}) (resp *http.Response, err error) {
-	if c.Token != "" {
-		req.Header.Set(tokenHeader, c.Token)
+// TokenCache holds a CSRF token that can be safely accessed concurrently.
+type TokenCache struct {
+	mu    sync.Mutex
+	token string
+}
+
+// Get returns the cached token.
+func (t *TokenCache) Get() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.token
+}
+
+// Set sets the cached token.
+func (t *TokenCache) Set(token string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.token = token
+}
+
+// Clone returns a copy of the Config that uses a new TokenCache, initialized
+// with the current token. The Client is shared with the copy.
+func (c Config) Clone() Config {
+	token := c.token()
+	c.Token = token
+	c.TokenCache = &TokenCache{token: token}
+	return c
+}
+
+// now returns the current time according to Now.
+func (c *Config) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
 	}
+	return time.Now()
+}
 
-	client := c.Client
-	if client == nil {
-		client = http.DefaultClient
+// sleep waits for d according to Sleep, or until ctx is done.
+func (c *Config) sleep(ctx context.Context, d time.Duration) error {
+	if c.Sleep != nil {
+		return c.Sleep(ctx, d)
 	}
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
 
-	resp, err = client.Do(req)
+// token returns the current token. If there is no current token, then the
+// token is loaded from TokenStore, if available.
+func (c *Config) token() string {
+	if c.TokenCache != nil {
+		if token := c.TokenCache.Get(); token != "" {
+			return token
+		}
+	}
+	if c.Token == "" && c.TokenStore != nil {
+		if token, err := c.TokenStore.Load(); err == nil && token != "" {
+			c.setLocalToken(token)
+			return token
+		}
+	}
+	return c.Token
+}
+
+// setToken sets the current token, saving it to TokenStore if it has changed.
+func (c *Config) setToken(token string) {
+	if c.TokenStore != nil && token != c.token() {
+		c.TokenStore.Save(token)
+	}
+	c.setLocalToken(token)
+}
+
+// setLocalToken sets the current token without saving it.
+func (c *Config) setLocalToken(token string) {
+	if c.TokenCache != nil {
+		c.TokenCache.Set(token)
+		return
+	}
+	c.Token = token
+}
+
+// TokenStore persists a CSRF token, allowing it to be reused across separate
+// runs of a program.
+type TokenStore interface {
+	// Load returns the stored token, or an empty string if no token is
+	// stored.
+	Load() (string, error)
+	// Save stores a token. An empty string clears the stored token.
+	Save(token string) error
+}
+
+// FileTokenStore implements TokenStore by storing the token in a file at the
+// given path. The file is created with permissions that restrict access to
+// the current user.
+type FileTokenStore string
+
+// Load implements TokenStore. Returns an empty string if the file does not
+// exist.
+func (f FileTokenStore) Load() (string, error) {
+	b, err := ioutil.ReadFile(string(f))
 	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// Save implements TokenStore. The file is removed if token is empty.
+func (f FileTokenStore) Save(token string) error {
+	if token == "" {
+		if err := os.Remove(string(f)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if err := ioutil.WriteFile(string(f), []byte(token), 0600); err != nil {
+		return err
+	}
+	// WriteFile does not change the permissions of an existing file.
+	return os.Chmod(string(f), 0600)
+}
+
+// PrimeToken makes a request solely to obtain a CSRF token, which is
+// returned. The request is sent to TokenEndpoint, without cookies. If the
+// current token is still accepted, then it is returned unchanged.
+//
+// A Config primes its token implicitly by sending a rejected request again, but
+// doing so explicitly avoids the extra exchange on the first request. Like any
+// other token, the primed token is retained only by TokenCache or TokenStore.
+// Otherwise, it must be assigned to Token to be used by subsequent calls.
+func (c Config) PrimeToken() (string, error) {
+	return c.PrimeTokenContext(context.Background())
+}
+
+// PrimeTokenContext is like PrimeToken, but with a context.
+func (c Config) PrimeTokenContext(ctx context.Context) (token string, err error) {
+	return c.primeToken(ctx)
+}
+
+// primeToken implements PrimeTokenContext, setting the token on c.
+func (c *Config) primeToken(ctx context.Context) (token string, err error) {
+	defer wrapOp("prime token", &err)
+
+	endpoint := c.TokenEndpoint
+	if endpoint == "" {
+		endpoint = c.LogoutEndpoint
+	}
+	if endpoint == "" {
+		endpoint = DefaultLogoutEndpoint
+	}
+	req, err := c.newRequest(ctx, "POST", endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/json")
+	if err := c.applyHeaders(req); err != nil {
+		return "", err
+	}
+
+	// The request is expected to fail, either due to the token or the
+	// absence of a session; only the token is of interest.
+	resp, _, err := c.sendAPI("prime-token", req, &errorsResponse{}, false)
+	if resp == nil {
+		return "", err
+	}
+	if token = c.token(); token == "" {
+		if err == nil {
+			err = ifStatus(resp.StatusCode, nil)
+		}
+		return "", fmt.Errorf("no token received: %w", err)
+	}
+	return token, nil
+}
+
+// headBuffer retains the first bytes written to it.
+type headBuffer struct {
+	bytes.Buffer
+}
+
+// headSize is the number of bytes retained by a headBuffer.
+const headSize = 64
+
+// Write implements the io.Writer interface.
+func (b *headBuffer) Write(p []byte) (int, error) {
+	if n := headSize - b.Len(); n > 0 {
+		if n > len(p) {
+			n = len(p)
+		}
+		b.Buffer.Write(p[:n])
+	}
+	return len(p), nil
+}
+
+// decodeError is returned when a response body could not be decoded.
+type decodeError struct {
+	contentType string
+	head        []byte
+	err         error
+}
+
+// Error implements the error interface.
+func (err *decodeError) Error() string {
+	return fmt.Sprintf("decode response (content type %q, body %q): %s", err.contentType, err.head, err.err)
+}
+
+// Unwrap implements the Unwrap interface.
+func (err *decodeError) Unwrap() error {
+	return err.err
+}
+
+// contentTypeError is returned when a response does not contain JSON, such as
+// an error page served by a proxy.
+type contentTypeError struct {
+	contentType string
+	snippet     string
+}
+
+// Error implements the error interface.
+func (err *contentTypeError) Error() string {
+	if err.snippet == "" {
+		return fmt.Sprintf("unexpected content type %q", err.contentType)
+	}
+	return fmt.Sprintf("unexpected content type %q: %q", err.contentType, err.snippet)
+}
+
+// snippetSize is the number of bytes of a non-JSON response that are read to
+// produce a snippet.
+const snippetSize = 4096
+
+// maxSnippet is the maximum length of a snippet.
+const maxSnippet = 200
+
+// isJSON returns whether the given Content-Type indicates JSON. An empty type
+// is assumed to be JSON.
+func isJSON(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || mediaType == "text/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// snippet returns a short, printable summary of a non-JSON body. The title of
+// an HTML page is preferred, if present.
+func snippet(body []byte) string {
+	text := string(body)
+	lower := strings.ToLower(text)
+	if i := strings.Index(lower, "<title"); i >= 0 {
+		if j := strings.IndexByte(lower[i:], '>'); j >= 0 {
+			start := i + j + 1
+			if k := strings.Index(lower[start:], "</title"); k >= 0 {
+				text = text[start : start+k]
+			}
+		}
+	}
+	text = strings.Join(strings.FieldsFunc(text, func(r rune) bool {
+		return unicode.IsSpace(r) || !unicode.IsPrint(r)
+	}), " ")
+	if len(text) > maxSnippet {
+		text = strings.ToValidUTF8(text[:maxSnippet], "") + "..."
+	}
+	return text
+}
+
+// RetryError is returned when a request fails after being retried.
+type RetryError struct {
+	// Attempts is the number of attempts made.
+	Attempts int
+
+	err error
+}
+
+// Error implements the error interface.
+func (err *RetryError) Error() string {
+	return strconv.Itoa(err.Attempts) + " attempts: " + err.err.Error()
+}
+
+// Unwrap implements the Unwrap interface.
+func (err *RetryError) Unwrap() error {
+	return err.err
+}
+
+// cloneRequest returns a clone of req with a fresh body. A request without a
+// body, such as that of Logout, has no body to rewind, and so is cloned as is.
+func cloneRequest(req *http.Request) *http.Request {
+	r := req.Clone(req.Context())
+	if req.GetBody != nil {
+		r.Body, _ = req.GetBody()
+	}
+	return r
+}
+
+// applyHeaders adds UserAgent and Headers to req, without replacing headers
+// already set.
+func (c *Config) applyHeaders(req *http.Request) error {
+	if c.UserAgent != "" && req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+	for name, values := range c.Headers {
+		name = http.CanonicalHeaderKey(name)
+		switch name {
+		case http.CanonicalHeaderKey(tokenHeader), "Cookie":
+			return fmt.Errorf("header %s cannot be set", name)
+		}
+		if _, ok := req.Header[name]; ok {
+			continue
+		}
+		req.Header[name] = append([]string(nil), values...)
+	}
+	return nil
+}
+
+// retryDelay returns the duration to wait before retrying a request that
+// received resp, or false if the request should not be retried.
+func (c *Config) retryDelay(resp *http.Response, attempt int) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable:
+	default:
+		return 0, false
+	}
+	if d, ok := retryAfter(resp, c.now()); ok {
+		return d, true
+	}
+	base := c.RetryBaseDelay
+	if base <= 0 {
+		base = DefaultRetryBaseDelay
+	}
+	// Double the delay for each attempt, without overflowing.
+	d := base
+	for n := 1; n < attempt && d < MaxRetryDelay; n++ {
+		d <<= 1
+	}
+	if d > MaxRetryDelay {
+		d = MaxRetryDelay
+	}
+	// Add up to 50% jitter.
+	return d + time.Duration(rand.Int63n(int64(d)/2+1)), true
+}
+
+// newRequest is like http.NewRequestWithContext, but first validates the
+// endpoints of c, so that a malformed endpoint is reported as such rather than
+// by a confusing failure to build or send the request.
+func (c *Config) newRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	if err := c.validate(); err != nil {
 		return nil, err
 	}
+	return http.NewRequestWithContext(ctx, method, url, body)
+}
+
+// requestAPI sends req, decoding the response body into apiResp. The request
+// is retried according to MaxRetries. op names the endpoint for Metrics.
+func (c *Config) requestAPI(op string, req *http.Request, apiResp interface{}) (resp *http.Response, err error) {
+	if req.Body != nil && req.GetBody == nil {
+		// Buffer the body so that it can be sent again.
+		body, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(body)), nil
+		}
+		req.Body, _ = req.GetBody()
+	}
+	if err := c.applyHeaders(req); err != nil {
+		return nil, err
+	}
+	if c.DumpRequests != nil {
+		c.dumpRequest(req)
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, err = c.doAPI(op, cloneRequest(req), apiResp)
+		if err == nil || attempt > c.MaxRetries {
+			if err != nil && attempt > 1 {
+				err = &RetryError{Attempts: attempt, err: err}
+			}
+			return resp, err
+		}
+		delay, ok := c.retryDelay(resp, attempt)
+		if !ok {
+			return resp, err
+		}
+		if err := c.sleep(req.Context(), delay); err != nil {
+			return resp, &RetryError{Attempts: attempt, err: err}
+		}
+	}
+}
+
+// maxTokenRetries is the number of times doAPI sends a request again due to
+// failed token validation. Two are needed when a stale token is rejected
+// without a replacement: the first to send no token, and the second to send
+// the token received in response.
+const maxTokenRetries = 2
+
+// doAPI performs a single attempt of requestAPI. Returns the response, if
+// received, even when an error occurs.
+func (c *Config) doAPI(op string, req *http.Request, apiResp interface{}) (resp *http.Response, err error) {
+	for n := 0; ; n++ {
+		var retry bool
+		if resp, retry, err = c.sendAPI(op, req, apiResp, n < maxTokenRetries); !retry {
+			return resp, err
+		}
+		// Failed token validation, retry with new token.
+		req = cloneRequest(req)
+	}
+}
+
+// DefaultTimeout is the value of Config.Timeout used when Client is nil.
+const DefaultTimeout = 30 * time.Second
+
+// ErrTimeout is matched by the error returned when an exchange with the API
+// exceeds Config.Timeout.
+var ErrTimeout = errors.New("request timed out")
+
+// timeoutError is returned when an exchange exceeds the timeout of a Config.
+type timeoutError struct {
+	timeout time.Duration
+	err     error
+}
+
+func (e *timeoutError) Error() string {
+	return "request timed out after " + e.timeout.String() + ": " + e.err.Error()
+}
+
+func (e *timeoutError) Is(target error) bool {
+	return target == ErrTimeout
+}
+
+func (e *timeoutError) Unwrap() error {
+	return e.err
+}
+
+// Timeout implements the net.Error interface.
+func (e *timeoutError) Timeout() bool {
+	return true
+}
+
+// timeout returns the effective Timeout.
+func (c *Config) timeout() time.Duration {
+	if c.Timeout == 0 && c.Client == nil {
+		return DefaultTimeout
+	}
+	return c.Timeout
+}
+
+// sendAPI performs a single exchange of doAPI. Returns true if the request
+// should be sent again due to failed token validation, which is never the case
+// if canRetry is false.
+func (c *Config) sendAPI(op string, req *http.Request, apiResp interface{}, canRetry bool) (resp *http.Response, retry bool, err error) {
+	if token := c.token(); token != "" {
+		req.Header.Set(tokenHeader, token)
+	}
+
+	client, err := c.httpClient()
+	if err != nil {
+		return nil, false, err
+	}
+
+	if c.Metrics != nil {
+		start := c.now()
+		defer func() {
+			var status int
+			if resp != nil {
+				status = resp.StatusCode
+			}
+			merr := err
+			if retry {
+				merr = &tokenRetryError{err: err}
+			}
+			c.Metrics.ObserveRequest(op, status, c.now().Sub(start), merr)
+		}()
+	}
+
+	var codes []int
+	if c.Log != nil {
+		start := c.now()
+		defer func() {
+			event := LogEvent{
+				Method:    req.Method,
+				URL:       req.URL.String(),
+				TokenSent: req.Header.Get(tokenHeader) != "",
+				Elapsed:   c.now().Sub(start),
+				Codes:     codes,
+				Retry:     retry,
+				Err:       err,
+			}
+			if resp != nil {
+				event.Status = resp.StatusCode
+				event.TokenReceived = resp.Header.Get(tokenHeader) != ""
+				for _, cookie := range responseCookies(resp) {
+					event.Cookies = append(event.Cookies, cookie.Name)
+				}
+			}
+			c.Log(event)
+		}()
+	}
+
+	// Deferred last so that the error is replaced before it is observed.
+	if timeout := c.timeout(); timeout > 0 {
+		parent := req.Context()
+		ctx, cancel := context.WithTimeout(parent, timeout)
+		defer cancel()
+		req = req.WithContext(ctx)
+		defer func() {
+			if err != nil && ctx.Err() == context.DeadlineExceeded && parent.Err() == nil {
+				err = &timeoutError{timeout: timeout, err: err}
+			}
+		}()
+	}
+
+	resp, err = client.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
 	defer resp.Body.Close()
+	defer func() {
+		var serr *StatusError
+		if errors.As(err, &serr) {
+			serr.RequestID = resp.Header.Get(requestIDHeader)
+		}
+	}()
+
+	limit := c.MaxResponseBytes
+	if limit == 0 {
+		limit = DefaultMaxResponseBytes
+	}
+	if limit > 0 {
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{&limitReader{r: resp.Body, n: limit, err: ErrResponseTooLarge}, resp.Body}
+	}
+
+	if c.ResponseHook != nil {
+		// Buffer the body so that it can be read again by the hook.
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return resp, false, err
+		}
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+		defer func() {
+			resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+			c.ResponseHook(resp)
+		}()
+	}
 
 	if token := resp.Header.Get(tokenHeader); token != "" {
-		c.Token = token
+		c.setToken(token)
 	}
 
-	jd := json.NewDecoder(resp.Body)
-	if err = jd.Decode(apiResp); err != nil {
-		return resp, ifStatus(resp.StatusCode, err)
+	resetResponse(apiResp)
+	if contentType := resp.Header.Get("Content-Type"); !isJSON(contentType) {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, snippetSize))
+		return resp, false, ifStatus(resp.StatusCode, &contentTypeError{
+			contentType: contentType,
+			snippet:     snippet(body),
+		})
+	}
+	var head headBuffer
+	r := io.TeeReader(resp.Body, &head)
+	// The full body is retained for strict decoding.
+	var body bytes.Buffer
+	if c.StrictDecoding {
+		r = io.TeeReader(r, &body)
+	}
+	jd := json.NewDecoder(r)
+	if err = jd.Decode(apiResp); err == io.EOF {
+		// An empty body is treated as an empty response.
+		return resp, false, ifStatus(resp.StatusCode, nil)
+	} else if err != nil {
+		return resp, false, ifStatus(resp.StatusCode, &decodeError{
+			contentType: resp.Header.Get("Content-Type"),
+			head:        head.Bytes(),
+			err:         err,
+		})
 	}
 
 	if e, ok := apiResp.(interface{ errResp() errorsResponse }); ok && e != nil {
 		if errResp := e.errResp(); len(errResp.Errors) > 0 {
-			if resp.StatusCode == 403 &&
-				errResp.Errors[0].Code == 0 &&
-				req.Header.Get(tokenHeader) == "" {
-				// Failed token validation, retry with new token.
-				return c.requestAPI(req.Clone(context.Background()), apiResp)
+			for _, e := range errResp.Errors {
+				codes = append(codes, e.Code)
+			}
+			if resp.StatusCode == 403 && errResp.Errors[0].Code == codeTokenValidation && !isChallenge(resp) {
+				sent := req.Header.Get(tokenHeader)
+				if sent != "" && resp.Header.Get(tokenHeader) == "" {
+					// The sent token is stale, but no replacement was
+					// given.
+					c.setToken("")
+				}
+				if canRetry && c.token() != sent {
+					return resp, true, ifStatus(resp.StatusCode, errResp)
+				}
 			}
-			return nil, ifStatus(resp.StatusCode, errResp)
+			return resp, false, ifStatus(resp.StatusCode, errResp)
 		}
 	}
 
-	return resp, ifStatus(resp.StatusCode, nil)
+	if c.StrictDecoding && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		if err := decodeStrict(body.Bytes(), apiResp); err != nil {
+			return resp, false, &decodeError{
+				contentType: resp.Header.Get("Content-Type"),
+				head:        head.Bytes(),
+				err:         err,
+			}
+		}
+	}
+
+	return resp, false, ifStatus(resp.StatusCode, nil)
+}
+
+// decodeStrict decodes b into a new value of the type pointed to by v,
+// returning an error if b contains unknown fields. v is not modified.
+func decodeStrict(b []byte, v interface{}) error {
+	t := reflect.TypeOf(v)
+	if t == nil || t.Kind() != reflect.Ptr {
+		return nil
+	}
+	jd := json.NewDecoder(bytes.NewReader(b))
+	jd.DisallowUnknownFields()
+	return jd.Decode(reflect.New(t.Elem()).Interface())
 }
 
 // LoginCred attempts to authenticate a user by using the provided credentials.
@@ -137,103 +1145,386 @@ func (c *Config) requestAPI(req *http.Request, apiResp interface{}) (resp *http.
 // account. Note that an initial request must be made in order to associate the
 // ID with its corresponding credentials.
 //
-// The password argument is specified as a slice for future compatibility, where
-// the password may be handled within secured memory.
+// The password argument is specified as a slice so that it can be wiped from
+// memory after use; see SecurePassword and Config.WipePassword.
 //
 // On success, a list of HTTP cookies representing the session are returned. If
 // multi-step authentication is required, then a Step object is additionally
 // returned.
 //
 // If a response has a non-2XX status, then this function returns an error that
-// implements `interface { StatusCode() int }`.
+// contains a *StatusError.
 func (c Config) LoginCred(cred Cred, password []byte) (cookies []*http.Cookie, step *Step, err error) {
-	defer func() {
-		if err != nil {
-			err = fmt.Errorf("login: %w", err)
+	return c.LoginCredContext(context.Background(), cred, password)
+}
+
+// LoginCredContext is like LoginCred, but with a context that bounds each
+// request made during the login.
+func (c Config) LoginCredContext(ctx context.Context, cred Cred, password []byte) (cookies []*http.Cookie, step *Step, err error) {
+	return c.LoginCredOpts(ctx, cred, password, nil)
+}
+
+// LoginOptions specifies optional parameters for a login.
+type LoginOptions struct {
+	// CaptchaToken is the token obtained from solving a captcha challenge.
+	CaptchaToken string
+	// CaptchaProvider is the provider of the captcha. If empty while
+	// CaptchaToken is set, CaptchaProviderArkoseLabs is used.
+	CaptchaProvider string
+}
+
+// LoginCredOpts is like LoginCredContext, with additional options. opts may be
+// nil.
+//
+// If the API requires a captcha to be solved, then the returned error will
+// contain a *CaptchaError.
+func (c Config) LoginCredOpts(ctx context.Context, cred Cred, password []byte, opts *LoginOptions) (cookies []*http.Cookie, step *Step, err error) {
+	result, err := c.LoginCredResult(ctx, cred, password, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	if result.Challenge != nil {
+		return nil, nil, fmt.Errorf("login: %w", ErrChallengeRequired)
+	}
+	if result.Questions != nil {
+		return nil, nil, fmt.Errorf("login: %w", ErrQuestionsRequired)
+	}
+	return result.Cookies, result.Step, nil
+}
+
+// LoginResult contains the result of a successful login.
+type LoginResult struct {
+	// Cookies contains the HTTP cookies representing the session.
+	Cookies []*http.Cookie
+	// Step is non-nil if multi-step authentication is required.
+	Step *Step
+	// Challenge is non-nil if the login must be approved outside of the
+	// program.
+	Challenge *PendingChallenge
+	// Questions is non-nil if security questions must be answered.
+	Questions *QuestionStep
+	// User is the user that was authenticated. May be nil if the API did not
+	// include the user in its response.
+	User *UserInfo
+	// PendingActions lists the actions that the user must take before the
+	// session can be used fully. The login succeeds regardless.
+	PendingActions []PendingAction
+
+	// RememberDevice is whether the device was remembered after two-step
+	// verification, and Resends is the number of times the verification code
+	// was resent. They are set only by an interactive login, such as
+	// Stream.PromptResult, and are zero if Step is nil.
+	RememberDevice bool
+	Resends        int
+}
+
+// LoginCredResult is like LoginCredOpts, but returns the result of the login
+// as a LoginResult.
+//
+// If the account is temporarily locked due to too many attempts, the returned
+// error contains a *ThrottleError. If WaitOnThrottle is set, then the login is
+// instead sent again once after the duration reported by the error.
+func (c Config) LoginCredResult(ctx context.Context, cred Cred, password []byte, opts *LoginOptions) (result *LoginResult, err error) {
+	defer wrapOp("login", &err)
+	if c.WipePassword {
+		defer wipe(password)
+	}
+	result, err = c.loginCred(ctx, cred, password, opts)
+	var terr *ThrottleError
+	if c.WaitOnThrottle && errors.As(err, &terr) {
+		if err := c.sleep(ctx, terr.RetryAfter); err != nil {
+			return nil, err
 		}
-	}()
+		result, err = c.loginCred(ctx, cred, password, opts)
+	}
+	return result, err
+}
 
+// loginCred implements LoginCredResult, without waiting on a throttled login.
+func (c *Config) loginCred(ctx context.Context, cred Cred, password []byte, opts *LoginOptions) (result *LoginResult, err error) {
 	if strings.ToLower(cred.Type) == "userid" {
 		userID, err := strconv.ParseInt(cred.Ident, 10, 64)
 		if err != nil {
-			return nil, nil, fmt.Errorf("parse user ID: %w", err)
+			return nil, fmt.Errorf("parse user ID: %w", err)
 		}
 		cred.Type = "Username"
-		cred.Ident, err = c.getUsername(userID)
+		cred.Ident, err = c.getUsername(ctx, userID)
 		if err != nil {
-			return nil, nil, err
+			return nil, err
 		}
 	}
 
-	body, _ := json.Marshal(&loginRequest{
+	apiReq := loginRequest{
 		CredType:  cred.Type,
 		CredValue: cred.Ident,
-		Password:  string(password),
-	})
+		Password:  password,
+	}
+	if opts != nil && opts.CaptchaToken != "" {
+		apiReq.CaptchaToken = opts.CaptchaToken
+		apiReq.CaptchaProvider = opts.CaptchaProvider
+		if apiReq.CaptchaProvider == "" {
+			apiReq.CaptchaProvider = CaptchaProviderArkoseLabs
+		}
+	}
+	if c.AutoPrime && c.token() == "" {
+		if _, err := c.primeToken(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if c.Device != nil {
+		apiReq.DeviceMeta = c.Device.deviceMeta()
+		if apiReq.SecureAuthIntent, err = c.Device.secureAuthIntent(c.now()); err != nil {
+			return nil, fmt.Errorf("secure authentication intent: %w", err)
+		}
+	}
+
+	var apiResp loginResponse
+	resp, err := c.sendLogin(ctx, &apiReq, nil, "", &apiResp)
+	if err != nil {
+		err = c.loginError(resp, err)
+		var cerr *CaptchaError
+		if c.CaptchaSolver == nil || !errors.As(err, &cerr) || cerr.Challenge == nil {
+			return nil, err
+		}
+		// Retry once with the solved captcha.
+		challenge := cerr.Challenge
+		token, err := c.CaptchaSolver(*challenge)
+		if err != nil {
+			return nil, fmt.Errorf("solve captcha: %w", err)
+		}
+		apiReq.CaptchaToken = token
+		apiReq.CaptchaID = challenge.CaptchaID
+		apiReq.CaptchaProvider = challenge.Provider
+		if resp, err = c.sendLogin(ctx, &apiReq, challenge, token, &apiResp); err != nil {
+			return nil, c.loginError(resp, err)
+		}
+	}
+
+	username := cred.Ident
+	if apiResp.User != nil {
+		username = apiResp.User.Name
+	}
+	return c.newLoginResult(resp, &apiResp, username), nil
+}
+
+// loginError classifies err, returned by a login that received resp.
+func (c *Config) loginError(resp *http.Response, err error) error {
+	err = classify(ifLoginCaptcha(resp, err), loginErrorCodes)
+	return ifConsent(resp, ifThrottled(resp, err, c.now()))
+}
+
+// sendLogin sends a login request. If challenge is non-nil, then token is
+// passed as its solution.
+func (c *Config) sendLogin(ctx context.Context, apiReq *loginRequest, challenge *CaptchaChallenge, token string, apiResp *loginResponse) (*http.Response, error) {
+	// Marshal directly to avoid copying the password.
+	body, err := apiReq.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(body)
 
 	endpoint := c.LoginEndpoint
 	if endpoint == "" {
 		endpoint = DefaultLoginEndpoint
 	}
-	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+	req, err := c.newRequest(ctx, "POST", endpoint, bytes.NewReader(body))
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
+	for _, cookie := range c.PersistentCookies {
+		req.AddCookie(cookie)
+	}
+	if challenge != nil {
+		challenge.setContinuation(req, token)
+	}
+	return c.requestAPI("login", req, apiResp)
+}
 
-	var apiResp loginResponse
-	resp, err := c.requestAPI(req, &apiResp)
-	if err != nil {
-		return nil, nil, err
+// newLoginResult returns the result of a login from the response to a login
+// request. username is used by the legacy two-step verification API.
+func (c Config) newLoginResult(resp *http.Response, apiResp *loginResponse, username string) *LoginResult {
+	result := &LoginResult{
+		Cookies:        responseCookies(resp),
+		PendingActions: pendingActions(apiResp),
+	}
+	if apiResp.User != nil {
+		result.User = &UserInfo{
+			ID:   apiResp.User.ID,
+			Name: apiResp.User.Name,
+		}
 	}
 
 	if apiResp.TwoStepVerificationData != nil {
-		step := &Step{
+		mediaType, _ := ParseMediaType(apiResp.TwoStepVerificationData.MediaType)
+		now := c.now()
+		result.Step = &Step{
 			cfg:       c,
-			MediaType: apiResp.TwoStepVerificationData.MediaType,
+			MediaType: mediaType,
+			User:      result.User,
+			Expires:   now.Add(DefaultStepTTL),
+			sent:      now,
 			req: twoStepVerificationVerifyRequest{
 				twoStepVerificationTicketRequest: twoStepVerificationTicketRequest{
-					Username:   apiResp.User.Name,
+					Username:   username,
 					Ticket:     apiResp.TwoStepVerificationData.Ticket,
-					ActionType: "Login",
+					ActionType: ActionLogin,
 				},
 			},
 		}
-		return resp.Cookies(), step, nil
+		// The challenge API requires the user ID, so the legacy API is used
+		// if the ID was not included.
+		if result.User != nil && result.User.ID != 0 &&
+			(!c.LegacyTwoStep || result.Step.MediaType == MediaAuthenticator) {
+			result.Step.userID = result.User.ID
+		}
+		c.observeEvent(EventTwoStepRequired)
+	}
+
+	if apiResp.IdentityVerificationLoginTicket != "" {
+		result.Challenge = &PendingChallenge{
+			cfg:     c,
+			ticket:  apiResp.IdentityVerificationLoginTicket,
+			User:    result.User,
+			Expires: c.now().Add(DefaultStepTTL),
+		}
+		c.observeEvent(EventApprovalRequired)
 	}
 
-	return resp.Cookies(), nil, nil
+	if apiResp.SecurityQuestionChallengeID != "" {
+		result.Questions = &QuestionStep{
+			cfg:         c,
+			ChallengeID: apiResp.SecurityQuestionChallengeID,
+			User:        result.User,
+			Expires:     c.now().Add(DefaultStepTTL),
+		}
+		c.observeEvent(EventQuestionsRequired)
+	}
+
+	return result
+}
+
+// LoginCredAuto performs a login without prompting, receiving the two-step
+// verification code from CodeProvider, if verification is required. A login
+// requiring out-of-band approval is waited on according to PollInterval and
+// PollTimeout. The device is not remembered.
+func (c Config) LoginCredAuto(cred Cred, password []byte) (cookies []*http.Cookie, err error) {
+	return c.LoginCredAutoContext(context.Background(), cred, password)
+}
+
+// LoginCredAutoContext is like LoginCredAuto, but with a context that bounds
+// each request made during the login.
+func (c Config) LoginCredAutoContext(ctx context.Context, cred Cred, password []byte) (cookies []*http.Cookie, err error) {
+	result, err := c.LoginCredResult(ctx, cred, password, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer wrapOp("login", &err)
+	if result.Questions != nil {
+		return nil, ErrQuestionsRequired
+	}
+	if challenge := result.Challenge; challenge != nil {
+		interval := c.PollInterval
+		if interval <= 0 {
+			interval = DefaultPollInterval
+		}
+		timeout := c.PollTimeout
+		if timeout <= 0 {
+			timeout = DefaultPollTimeout
+		}
+		wctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return challenge.Wait(wctx, interval)
+	}
+	if step := result.Step; step != nil {
+		if c.CodeProvider == nil {
+			return nil, errors.New("two-step verification required without a code provider")
+		}
+		code, err := c.CodeProvider(string(step.MediaType))
+		if err != nil {
+			return nil, fmt.Errorf("provide code: %w", err)
+		}
+		return step.VerifyContext(ctx, code, false)
+	}
+	return result.Cookies, nil
+}
+
+// LoginSession wraps LoginCred, returning the cookies as a Session. If
+// multi-step authentication is required, then the returned session is nil, and
+// the session can instead be received from Step.VerifySession.
+func (c Config) LoginSession(cred Cred, password []byte) (*Session, *Step, error) {
+	return c.LoginSessionContext(context.Background(), cred, password)
+}
+
+// LoginSessionContext is like LoginSession, but with a context.
+func (c Config) LoginSessionContext(ctx context.Context, cred Cred, password []byte) (*Session, *Step, error) {
+	result, err := c.LoginCredResult(ctx, cred, password, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if result.Challenge != nil {
+		return nil, nil, fmt.Errorf("login: %w", ErrChallengeRequired)
+	}
+	if result.Questions != nil {
+		return nil, nil, fmt.Errorf("login: %w", ErrQuestionsRequired)
+	}
+	if result.Step != nil {
+		return nil, result.Step, nil
+	}
+	sess := NewSession(c, result.Cookies)
+	sess.user = result.User
+	return sess, nil, nil
 }
 
 // Login wraps LoginCred, using a username for the credentials.
 func (c Config) Login(username string, password []byte) ([]*http.Cookie, *Step, error) {
-	return c.LoginCred(Cred{Type: Username, Ident: username}, password)
+	return c.LoginContext(context.Background(), username, password)
+}
+
+// LoginContext is like Login, but with a context.
+func (c Config) LoginContext(ctx context.Context, username string, password []byte) ([]*http.Cookie, *Step, error) {
+	return c.LoginCredContext(ctx, Cred{Type: Username, Ident: username}, password)
 }
 
 // LoginID wraps LoginCred, deriving credentials from the given user ID. Note
 // that an initial request must be made in order to associate the ID with its
 // corresponding credentials.
 func (c Config) LoginID(userID int64, password []byte) ([]*http.Cookie, *Step, error) {
-	username, err := c.getUsername(userID)
+	return c.LoginIDContext(context.Background(), userID, password)
+}
+
+// LoginIDContext is like LoginID, but with a context.
+func (c Config) LoginIDContext(ctx context.Context, userID int64, password []byte) ([]*http.Cookie, *Step, error) {
+	username, err := c.getUsername(ctx, userID)
 	if err != nil {
 		return nil, nil, err
 	}
-	return c.LoginCred(Cred{Type: Username, Ident: username}, password)
+	return c.LoginCredContext(ctx, Cred{Type: Username, Ident: username}, password)
 }
 
-func (c Config) Logout(cookies []*http.Cookie) (err error) {
-	defer func() {
-		if err != nil {
-			err = fmt.Errorf("logout: %w", err)
-		}
-	}()
+// ErrAlreadyLoggedOut is matched by the error returned by Logout when the
+// session is already expired or otherwise invalid, such that there was nothing
+// to log out.
+var ErrAlreadyLoggedOut = errors.New("session already logged out")
+
+// Logout destroys the session represented by the given cookies.
+//
+// Returns an error matching ErrAlreadyLoggedOut if the session is not valid.
+// Any other error indicates that the session may still be valid.
+func (c Config) Logout(cookies []*http.Cookie) error {
+	return c.LogoutContext(context.Background(), cookies)
+}
+
+// LogoutContext is like Logout, but with a context.
+func (c Config) LogoutContext(ctx context.Context, cookies []*http.Cookie) (err error) {
+	defer wrapOp("logout", &err)
 
 	endpoint := c.LogoutEndpoint
 	if endpoint == "" {
 		endpoint = DefaultLogoutEndpoint
 	}
-	req, err := http.NewRequest("POST", endpoint, nil)
+	req, err := c.newRequest(ctx, "POST", endpoint, nil)
 	if err != nil {
 		return err
 	}
@@ -242,33 +1533,235 @@ func (c Config) Logout(cookies []*http.Cookie) (err error) {
 		req.AddCookie(cookie)
 	}
 
-	_, err = c.requestAPI(req, &errorsResponse{})
-	return err
+	if _, err = c.requestAPI("logout", req, &errorsResponse{}); err != nil {
+		if code, ok := HTTPStatus(err); ok && code == http.StatusUnauthorized {
+			return &kindError{kind: ErrAlreadyLoggedOut, err: err}
+		}
+		return err
+	}
+	return nil
 }
 
-func (c Config) getUsername(userID int64) (name string, err error) {
-	defer func() {
-		if err != nil {
-			err = fmt.Errorf("user from ID: %w", err)
+// EnsureLoggedOut is like Logout, but succeeds if the session is already not
+// valid, making it suitable for cleanup that may be repeated.
+func (c Config) EnsureLoggedOut(cookies []*http.Cookie) error {
+	return c.EnsureLoggedOutContext(context.Background(), cookies)
+}
+
+// EnsureLoggedOutContext is like EnsureLoggedOut, but with a context.
+func (c Config) EnsureLoggedOutContext(ctx context.Context, cookies []*http.Cookie) error {
+	if err := c.LogoutContext(ctx, cookies); err != nil && !errors.Is(err, ErrAlreadyLoggedOut) {
+		return err
+	}
+	return nil
+}
+
+// LogoutAll logs out of every session of the account associated with the
+// given cookies, and reauthenticates the current session. Returns the cookies
+// updated with those reissued for the current session. Returns
+// ErrUnauthenticated if the session is not valid.
+func (c Config) LogoutAll(cookies []*http.Cookie) ([]*http.Cookie, error) {
+	return c.LogoutAllContext(context.Background(), cookies)
+}
+
+// LogoutAllContext is like LogoutAll, but with a context.
+func (c Config) LogoutAllContext(ctx context.Context, cookies []*http.Cookie) (newCookies []*http.Cookie, err error) {
+	defer wrapOp("logout all", &err)
+
+	endpoint := c.LogoutAllEndpoint
+	if endpoint == "" {
+		endpoint = DefaultLogoutAllEndpoint
+	}
+	req, err := c.newRequest(ctx, "POST", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+
+	resp, err := c.requestAPI("logout-all", req, &errorsResponse{})
+	if err != nil {
+		if code, ok := HTTPStatus(err); ok && code == http.StatusUnauthorized {
+			return nil, ErrUnauthenticated
 		}
-	}()
-	client := c.Client
-	if client == nil {
-		client = http.DefaultClient
+		return nil, err
 	}
+	return mergeCookies(cookies, responseCookies(resp), c.now()), nil
+}
+
+// ErrUnauthenticated is returned when a session is expired or otherwise
+// invalid.
+var ErrUnauthenticated = errors.New("session is not authenticated")
+
+// UserInfo describes a user account.
+type UserInfo struct {
+	ID          int64
+	Name        string
+	DisplayName string
+}
+
+// Authenticated returns the user associated with the session represented by
+// the given cookies. Returns ErrUnauthenticated if the session is not valid.
+func (c Config) Authenticated(cookies []*http.Cookie) (*UserInfo, error) {
+	return c.AuthenticatedContext(context.Background(), cookies)
+}
+
+// AuthenticatedContext is like Authenticated, but with a context.
+func (c Config) AuthenticatedContext(ctx context.Context, cookies []*http.Cookie) (user *UserInfo, err error) {
+	defer wrapOp("authenticated", &err)
+
+	endpoint := c.AuthenticatedEndpoint
+	if endpoint == "" {
+		endpoint = DefaultAuthenticatedEndpoint
+	}
+	req, err := c.newRequest(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+
+	var apiResp authenticatedUserResponse
+	if _, err = c.requestAPI("authenticated", req, &apiResp); err != nil {
+		if code, ok := HTTPStatus(err); ok && code == http.StatusUnauthorized {
+			return nil, ErrUnauthenticated
+		}
+		return nil, err
+	}
+	if apiResp.ID == 0 {
+		return nil, ErrUnauthenticated
+	}
+	return &UserInfo{
+		ID:          apiResp.ID,
+		Name:        apiResp.Name,
+		DisplayName: apiResp.DisplayName,
+	}, nil
+}
+
+// Refresh keeps alive the session represented by the given cookies. Any
+// cookies updated by the response are merged with the given cookies, and the
+// result is returned. Returns ErrUnauthenticated if the session is not valid.
+func (c Config) Refresh(cookies []*http.Cookie) ([]*http.Cookie, error) {
+	return c.RefreshContext(context.Background(), cookies)
+}
+
+// RefreshContext is like Refresh, but with a context.
+func (c Config) RefreshContext(ctx context.Context, cookies []*http.Cookie) (refreshed []*http.Cookie, err error) {
+	defer wrapOp("refresh", &err)
+
+	endpoint := c.RefreshEndpoint
+	if endpoint == "" {
+		endpoint = DefaultRefreshEndpoint
+	}
+	req, err := c.newRequest(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+
+	resp, err := c.requestAPI("refresh", req, &errorsResponse{})
+	if err != nil {
+		if code, ok := HTTPStatus(err); ok && code == http.StatusUnauthorized {
+			return nil, ErrUnauthenticated
+		}
+		return nil, err
+	}
+	return mergeCookies(cookies, responseCookies(resp), c.now()), nil
+}
+
+// ErrUserNotFound is returned when a user does not exist.
+var ErrUserNotFound = errors.New("user not found")
+
+// GetUserID returns the ID of the user with the given username. Returns
+// ErrUserNotFound if the user does not exist.
+func (c Config) GetUserID(username string) (int64, error) {
+	return c.GetUserIDContext(context.Background(), username)
+}
+
+// GetUserIDContext is like GetUserID, but with a context.
+func (c Config) GetUserIDContext(ctx context.Context, username string) (int64, error) {
+	ids, err := c.GetUserIDsContext(ctx, []string{username})
+	if err != nil {
+		return 0, err
+	}
+	id, ok := ids[username]
+	if !ok {
+		return 0, fmt.Errorf("user ID from name: %w", ErrUserNotFound)
+	}
+	return id, nil
+}
+
+// GetUserIDs returns the IDs of the users with the given usernames. The
+// returned map is keyed by the usernames as given. Usernames that do not exist
+// are not included in the map.
+func (c Config) GetUserIDs(usernames []string) (map[string]int64, error) {
+	return c.GetUserIDsContext(context.Background(), usernames)
+}
+
+// GetUserIDsContext is like GetUserIDs, but with a context.
+func (c Config) GetUserIDsContext(ctx context.Context, usernames []string) (ids map[string]int64, err error) {
+	defer wrapOp("user ID from name", &err)
+	body, _ := json.Marshal(&multiGetByUsernameRequest{
+		Usernames: usernames,
+	})
+	endpoint := c.UsernameLookupEndpoint
+	if endpoint == "" {
+		endpoint = DefaultUsernameLookupEndpoint
+	}
+	req, err := c.newRequest(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	var apiResp multiGetByUsernameResponse
+	if _, err = c.requestAPI("username-lookup", req, &apiResp); err != nil {
+		return nil, err
+	}
+	ids = make(map[string]int64, len(apiResp.Data))
+	for _, user := range apiResp.Data {
+		ids[user.RequestedUsername] = user.ID
+	}
+	return ids, nil
+}
+
+func (c Config) getUsername(ctx context.Context, userID int64) (name string, err error) {
+	defer wrapOp("user from ID", &err)
 	endpoint := c.UserIDEndpoint
 	if endpoint == "" {
 		endpoint = DefaultUserIDEndpoint
 	}
-	req, err := http.NewRequest("GET", fmt.Sprintf(endpoint, userID), nil)
+	req, err := c.newRequest(ctx, "GET", fmt.Sprintf(endpoint, userID), nil)
 	if err != nil {
 		return "", err
 	}
-	var apiResp struct {
-		Username string
-		errorsResponse
+	req.Header.Set("Accept", "application/json")
+	var apiResp userResponseV1
+	if _, err = c.requestAPI("userid", req, &apiResp); err != nil {
+		if code, ok := HTTPStatus(err); ok && code == http.StatusNotFound && c.LegacyUserIDEndpoint != "" {
+			return c.getLegacyUsername(ctx, userID)
+		}
+		return "", err
+	}
+	return apiResp.Name, nil
+}
+
+// getLegacyUsername is like getUsername, but uses LegacyUserIDEndpoint.
+func (c Config) getLegacyUsername(ctx context.Context, userID int64) (name string, err error) {
+	req, err := c.newRequest(ctx, "GET", fmt.Sprintf(c.LegacyUserIDEndpoint, userID), nil)
+	if err != nil {
+		return "", err
 	}
-	if _, err = c.requestAPI(req, &apiResp); err != nil {
+	req.Header.Set("Accept", "application/json")
+	var apiResp userResponse
+	if _, err = c.requestAPI("legacy-userid", req, &apiResp); err != nil {
 		return "", err
 	}
 	return apiResp.Username, nil
@@ -284,6 +1777,52 @@ const (
 	PhoneNumber string = "PhoneNumber" // The phone number associated with the account.
 )
 
+// These constants define Cred.Type values that are handled specially, and
+// are not sent to the API.
+const (
+	// UserID indicates that Ident is the ID of the account; see LoginCred.
+	UserID string = "UserID"
+	// Auto indicates that the type is detected from Ident during an
+	// interactive login; see DetectCredType.
+	Auto string = "Auto"
+)
+
+// DetectCredType guesses the type of credential from the format of ident. An
+// identifier containing "@" is an Email. An identifier consisting of digits,
+// optionally with a leading "+" and common separators, is a PhoneNumber if it
+// has a plausible length. Otherwise, the identifier is a Username.
+//
+// ambiguous is true if ident consists of too few digits to be a phone number,
+// in which case it may be a UserID or a Username. Username is returned in this
+// case.
+func DetectCredType(ident string) (credType string, ambiguous bool) {
+	ident = strings.TrimSpace(ident)
+	if strings.Contains(ident, "@") {
+		return Email, false
+	}
+	plus := strings.HasPrefix(ident, "+")
+	var digits int
+	for i, c := range ident {
+		switch {
+		case '0' <= c && c <= '9':
+			digits++
+		case c == '+' && i == 0:
+		case plus && strings.ContainsRune(" -.()", c):
+		default:
+			return Username, false
+		}
+	}
+	switch {
+	case digits == 0:
+		return Username, false
+	case plus && digits >= 7, digits >= 10:
+		return PhoneNumber, false
+	case plus:
+		return Username, false
+	}
+	return Username, true
+}
+
 // Cred holds credentials used to identify an account.
 type Cred struct {
 	Type  string // Type specifies the kind of identifier.
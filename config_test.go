@@ -0,0 +1,974 @@
+package rbxauth_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anaminus/rbxauth"
+	"github.com/anaminus/rbxauth/rbxauthtest"
+)
+
+// newServer starts a server with a single account, closed when the test
+// finishes.
+func newServer(t *testing.T, account rbxauthtest.Account) *rbxauthtest.Server {
+	t.Helper()
+	srv := rbxauthtest.NewServer()
+	t.Cleanup(srv.Close)
+	srv.AddAccount(account)
+	return srv
+}
+
+// recordSleep sets the Sleep of cfg to return immediately, recording each
+// requested delay.
+func recordSleep(cfg *rbxauth.Config) *[]time.Duration {
+	var delays []time.Duration
+	cfg.Sleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return ctx.Err()
+	}
+	return &delays
+}
+
+func TestRetryTransient(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass"})
+	srv.Fail(rbxauthtest.LoginPath, 429, 0, "TooManyRequests")
+	srv.Fail(rbxauthtest.LoginPath, 503, 0, "ServiceUnavailable")
+
+	cfg := srv.Config()
+	cfg.MaxRetries = 2
+	cfg.RetryBaseDelay = time.Second
+	delays := recordSleep(&cfg)
+	cookies, _, err := cfg.Login("alice", []byte("pass"))
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if len(cookies) == 0 {
+		t.Fatal("expected session cookies")
+	}
+	if len(*delays) != 2 {
+		t.Fatalf("expected 2 retries, got %d", len(*delays))
+	}
+	for i, d := range *delays {
+		min := time.Second << uint(i)
+		if d < min || d > min+min/2 {
+			t.Errorf("retry %d: delay %s outside [%s, %s]", i+1, d, min, min+min/2)
+		}
+	}
+}
+
+func TestRetryExhausted(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass"})
+	for i := 0; i < 3; i++ {
+		srv.Fail(rbxauthtest.LoginPath, 429, 0, "TooManyRequests")
+	}
+
+	cfg := srv.Config()
+	cfg.MaxRetries = 2
+	recordSleep(&cfg)
+	_, _, err := cfg.Login("alice", []byte("pass"))
+	var rerr *rbxauth.RetryError
+	if !errors.As(err, &rerr) {
+		t.Fatalf("expected RetryError, got %v", err)
+	}
+	if rerr.Attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", rerr.Attempts)
+	}
+}
+
+func TestRetryDelayBounded(t *testing.T) {
+	const retries = 100
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass"})
+	for i := 0; i < retries; i++ {
+		srv.Fail(rbxauthtest.LoginPath, 503, 0, "ServiceUnavailable")
+	}
+
+	cfg := srv.Config()
+	cfg.MaxRetries = retries
+	delays := recordSleep(&cfg)
+	if _, _, err := cfg.Login("alice", []byte("pass")); err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if len(*delays) != retries {
+		t.Fatalf("expected %d retries, got %d", retries, len(*delays))
+	}
+	for i, d := range *delays {
+		if d <= 0 || d > rbxauth.MaxRetryDelay+rbxauth.MaxRetryDelay/2 {
+			t.Fatalf("retry %d: delay %s out of bounds", i+1, d)
+		}
+	}
+}
+
+// checkSession checks that cookies represent a session of user.
+func checkSession(t testing.TB, cfg rbxauth.Config, cookies []*http.Cookie, user rbxauth.UserInfo) {
+	t.Helper()
+	if len(cookies) == 0 {
+		t.Fatal("expected session cookies")
+	}
+	got, err := cfg.Authenticated(cookies)
+	if err != nil {
+		t.Fatalf("authenticated: %v", err)
+	}
+	if user.ID != 0 && got.ID != user.ID {
+		t.Errorf("expected user ID %d, got %d", user.ID, got.ID)
+	}
+	if user.Name != "" && !strings.EqualFold(got.Name, user.Name) {
+		t.Errorf("expected user name %q, got %q", user.Name, got.Name)
+	}
+	if user.DisplayName != "" && got.DisplayName != user.DisplayName {
+		t.Errorf("expected display name %q, got %q", user.DisplayName, got.DisplayName)
+	}
+}
+
+// checkLogout logs out of the session represented by cookies, and checks that
+// the session is no longer valid.
+func checkLogout(t testing.TB, cfg rbxauth.Config, cookies []*http.Cookie) {
+	t.Helper()
+	if err := cfg.Logout(cookies); err != nil {
+		t.Fatalf("logout: %v", err)
+	}
+	if _, err := cfg.Authenticated(cookies); !errors.Is(err, rbxauth.ErrUnauthenticated) {
+		t.Errorf("expected ErrUnauthenticated after logout, got %v", err)
+	}
+}
+
+func TestAuthenticated(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass"})
+	cfg := srv.Config()
+	cookies, _, err := cfg.Login("alice", []byte("pass"))
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	checkSession(t, cfg, cookies, rbxauth.UserInfo{ID: 1, Name: "alice", DisplayName: "alice"})
+	checkLogout(t, cfg, cookies)
+
+	bogus := []*http.Cookie{{Name: rbxauthtest.SessionCookieName, Value: "bogus"}}
+	if _, err := cfg.Authenticated(bogus); !errors.Is(err, rbxauth.ErrUnauthenticated) {
+		t.Errorf("expected ErrUnauthenticated for bogus cookie, got %v", err)
+	}
+}
+
+func TestAuthenticatedMalformed(t *testing.T) {
+	for _, body := range []string{
+		`{"id":1,"name":`,
+		`not json`,
+		`{"id":0}`,
+	} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(body))
+		}))
+		cfg := rbxauth.Config{
+			Client:                srv.Client(),
+			AllowInsecure:         true,
+			AuthenticatedEndpoint: srv.URL + rbxauthtest.AuthenticatedPath,
+		}
+		user, err := cfg.Authenticated(nil)
+		srv.Close()
+		if err == nil {
+			t.Errorf("%q: expected error, got user %+v", body, *user)
+		}
+	}
+}
+
+func TestConcurrentLogin(t *testing.T) {
+	const n = 50
+	srv := rbxauthtest.NewServer()
+	t.Cleanup(srv.Close)
+	for i := 1; i <= n; i++ {
+		srv.AddAccount(rbxauthtest.Account{ID: int64(i), Name: "user" + strconv.Itoa(i), Password: "pass"})
+	}
+
+	cfg := srv.Config()
+	var wg sync.WaitGroup
+	errs := make([]error, n)
+	for i := 1; i <= n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			cred := rbxauth.Cred{Type: "Username", Ident: "user" + strconv.Itoa(i)}
+			cookies, _, err := cfg.LoginCred(cred, []byte("pass"))
+			if err == nil {
+				var user *rbxauth.UserInfo
+				if user, err = cfg.Authenticated(cookies); err == nil && user.ID != int64(i) {
+					err = errors.New("session of user " + strconv.FormatInt(user.ID, 10))
+				}
+			}
+			errs[i-1] = err
+		}(i)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("user%d: %v", i+1, err)
+		}
+	}
+	if token := cfg.TokenCache.Get(); token != srv.Token() {
+		t.Errorf("cached token %q does not match server token %q", token, srv.Token())
+	}
+}
+
+func TestTokenRotation(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass"})
+	srv.RotateTokens = true
+	cfg := srv.Config()
+	clone := cfg.Clone()
+	for i := 0; i < 3; i++ {
+		if _, _, err := cfg.Login("alice", []byte("pass")); err != nil {
+			t.Fatalf("login %d: %v", i+1, err)
+		}
+		if token := cfg.TokenCache.Get(); token != srv.Token() {
+			t.Fatalf("login %d: cached token was not updated", i+1)
+		}
+	}
+	if clone.TokenCache.Get() == cfg.TokenCache.Get() {
+		t.Error("clone shares token with original")
+	}
+	if _, _, err := clone.Login("alice", []byte("pass")); err != nil {
+		t.Fatalf("clone login: %v", err)
+	}
+}
+
+func TestLoginLogoutTokenReuse(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass"})
+	srv.RotateTokens = true
+	cfg := srv.Config()
+
+	cookies, _, err := cfg.LoginCred(rbxauth.Cred{Type: "Username", Ident: "alice"}, []byte("pass"))
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	// The first request learns the token.
+	if n := srv.Count(rbxauthtest.LoginPath); n != 2 {
+		t.Errorf("login: expected 2 requests, got %d", n)
+	}
+	// The token rotated by the login is retained by the Config, though the
+	// login was made with a copy of it.
+	if err := cfg.Logout(cookies); err != nil {
+		t.Fatalf("logout: %v", err)
+	}
+	if n := srv.Count(rbxauthtest.LogoutPath); n != 1 {
+		t.Errorf("logout: expected 1 request without CSRF retry, got %d", n)
+	}
+	if token := cfg.TokenCache.Get(); token != srv.Token() {
+		t.Errorf("cached token %q does not match server token %q", token, srv.Token())
+	}
+}
+
+// headerRecorder records the headers of each request, by endpoint path.
+type headerRecorder struct {
+	transport http.RoundTripper
+
+	mu      sync.Mutex
+	headers map[string][]http.Header
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (r *headerRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.mu.Lock()
+	r.headers[req.URL.Path] = append(r.headers[req.URL.Path], req.Header.Clone())
+	r.mu.Unlock()
+	return r.transport.RoundTrip(req)
+}
+
+func TestRequestHeaders(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass", TwoStep: true, Code: "123456"})
+	cfg := srv.Config()
+	rec := &headerRecorder{transport: cfg.Client.Transport, headers: map[string][]http.Header{}}
+	cfg.Client = &http.Client{Transport: rec}
+	cfg.LegacyTwoStep = true
+	cfg.ResendCooldown = -1
+	cfg.UserAgent = "test-agent"
+	cfg.Headers = http.Header{"Accept-Language": {"en-US"}, "Content-Type": {"text/plain"}}
+
+	if _, err := cfg.GetUserID("alice"); err != nil {
+		t.Fatalf("lookup: %v", err)
+	}
+	step := loginStep(t, cfg)
+	if err := step.Resend(); err != nil {
+		t.Fatalf("resend: %v", err)
+	}
+	if _, err := step.Verify("123456", false); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	for _, path := range []string{
+		rbxauthtest.UsernamesPath,
+		rbxauthtest.LoginPath,
+		rbxauthtest.ResendPath,
+		rbxauthtest.VerifyPath,
+	} {
+		headers := rec.headers[path]
+		if len(headers) == 0 {
+			t.Errorf("%s: no requests", path)
+		}
+		for _, h := range headers {
+			if h.Get("User-Agent") != "test-agent" {
+				t.Errorf("%s: unexpected User-Agent %q", path, h.Get("User-Agent"))
+			}
+			if h.Get("Accept-Language") != "en-US" {
+				t.Errorf("%s: unexpected Accept-Language %q", path, h.Get("Accept-Language"))
+			}
+			if h.Get("Content-Type") != "application/json" {
+				t.Errorf("%s: unexpected Content-Type %q", path, h.Get("Content-Type"))
+			}
+		}
+	}
+}
+
+func TestRequestHeadersRejected(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass"})
+	for _, name := range []string{"X-CSRF-TOKEN", "cookie"} {
+		cfg := srv.Config()
+		cfg.Headers = http.Header{name: {"value"}}
+		if _, _, err := cfg.Login("alice", []byte("pass")); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	if n := srv.Count(rbxauthtest.LoginPath); n != 0 {
+		t.Errorf("expected no login requests, got %d", n)
+	}
+}
+
+func TestLoginCredAutoTOTP(t *testing.T) {
+	const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	now := time.Unix(1111111109, 0)
+	srv := newServer(t, rbxauthtest.Account{
+		ID: 1, Name: "alice", Password: "pass",
+		TwoStep: true, MediaType: string(rbxauth.MediaAuthenticator), Code: "081804",
+	})
+	cred := rbxauth.Cred{Type: "Username", Ident: "alice"}
+
+	cfg := srv.Config()
+	cfg.CodeProvider = rbxauth.TOTPCodeProvider(secret, func() time.Time { return now })
+	cookies, err := cfg.LoginCredAuto(cred, []byte("pass"))
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if len(cookies) == 0 {
+		t.Fatal("expected session cookies")
+	}
+
+	// A code for another period is rejected.
+	cfg.CodeProvider = rbxauth.TOTPCodeProvider(secret, func() time.Time { return now.Add(rbxauth.TOTPPeriod) })
+	if _, err := cfg.LoginCredAuto(cred, []byte("pass")); !errors.Is(err, rbxauth.ErrInvalidCode) {
+		t.Errorf("expected ErrInvalidCode, got %v", err)
+	}
+}
+
+func TestResponseStatus(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		status      int
+		contentType string
+		location    string
+		body        string
+		// Expected status of the StatusError, or 0 if there is none.
+		errStatus int
+		// Expected API error codes.
+		codes []int
+	}{
+		{name: "500 empty", status: 500, errStatus: 500},
+		{name: "500 empty JSON", status: 500, contentType: "application/json", errStatus: 500},
+		{name: "500 HTML", status: 500, contentType: "text/html", body: "<html>error</html>", errStatus: 500},
+		{name: "401 errors", status: 401, contentType: "application/json", body: `{"errors":[{"code":0,"message":"Authorization has been denied"}]}`, errStatus: 401, codes: []int{0}},
+		{name: "400 error object", status: 400, contentType: "application/json", body: `{"errors":{"code":3,"message":"Invalid"}}`, errStatus: 400, codes: []int{3}},
+		{name: "200 errors", status: 200, contentType: "application/json", body: `{"errors":[{"code":1,"message":"Incorrect"},{"code":4,"message":"Locked"}]}`, codes: []int{1, 4}},
+		{name: "302", status: 302, errStatus: 302},
+		{name: "302 followed", status: 302, location: "/login-page"},
+	} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/login-page" {
+				w.Header().Set("Content-Type", "text/html")
+				w.Write([]byte("<html>login</html>"))
+				return
+			}
+			if test.location != "" {
+				w.Header().Set("Location", test.location)
+			}
+			if test.contentType != "" {
+				w.Header().Set("Content-Type", test.contentType)
+			}
+			w.WriteHeader(test.status)
+			w.Write([]byte(test.body))
+		}))
+		cfg := rbxauth.Config{
+			Client:        srv.Client(),
+			AllowInsecure: true,
+			LoginEndpoint: srv.URL + rbxauthtest.LoginPath,
+		}
+		cookies, step, err := cfg.Login("alice", []byte("pass"))
+		srv.Close()
+
+		if err == nil {
+			t.Errorf("%s: expected error, got %d cookies and step %v", test.name, len(cookies), step)
+			continue
+		}
+		status, ok := rbxauth.HTTPStatus(err)
+		if test.errStatus == 0 && ok {
+			t.Errorf("%s: unexpected status error %d", test.name, status)
+		} else if test.errStatus != 0 && status != test.errStatus {
+			t.Errorf("%s: expected status %d, got %d: %v", test.name, test.errStatus, status, err)
+		}
+		if codes := rbxauth.ErrorCodes(err); len(codes) != len(test.codes) {
+			t.Errorf("%s: expected codes %v, got %v", test.name, test.codes, codes)
+		} else {
+			for i, code := range codes {
+				if code != test.codes[i] {
+					t.Errorf("%s: expected codes %v, got %v", test.name, test.codes, codes)
+					break
+				}
+			}
+		}
+		// API error details take precedence over the status.
+		var errResp rbxauth.ErrorResponse
+		if len(test.codes) > 0 && (!errors.As(err, &errResp) || !strings.Contains(err.Error(), errResp.Message)) {
+			t.Errorf("%s: error does not report the API message: %v", test.name, err)
+		}
+	}
+}
+
+func TestLoginID(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass"})
+	srv.AddAccount(rbxauthtest.Account{ID: 2, Name: "banned", Password: "pass", Banned: true})
+	cfg := srv.Config()
+
+	cookies, _, err := cfg.LoginID(1, []byte("pass"))
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	checkSession(t, cfg, cookies, rbxauth.UserInfo{ID: 1, Name: "alice"})
+
+	// The name of a banned user is still resolved, leaving the login to
+	// report the ban.
+	cookies, _, err = cfg.LoginID(2, []byte("pass"))
+	if err != nil {
+		t.Fatalf("banned: %v", err)
+	}
+	checkSession(t, cfg, cookies, rbxauth.UserInfo{ID: 2, Name: "banned"})
+
+	if _, _, err := cfg.LoginID(3, []byte("pass")); err == nil {
+		t.Error("unknown: expected error")
+	} else if status, _ := rbxauth.HTTPStatus(err); status != http.StatusNotFound {
+		t.Errorf("unknown: expected status 404, got %v", err)
+	}
+}
+
+func TestLoginIDHTML(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass"})
+	html := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("<!DOCTYPE html><html><body>Page not found</body></html>"))
+	}))
+	t.Cleanup(html.Close)
+
+	cfg := srv.Config()
+	cfg.UserIDEndpoint = html.URL + rbxauthtest.UserIDPath + "%d"
+	_, _, err := cfg.LoginID(1, []byte("pass"))
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if status, _ := rbxauth.HTTPStatus(err); status != http.StatusNotFound {
+		t.Errorf("expected status 404, got %v", err)
+	}
+	if msg := err.Error(); !strings.Contains(msg, "text/html") || !strings.Contains(msg, "Page not found") {
+		t.Errorf("expected content type and body in error, got %q", msg)
+	}
+	if strings.Contains(err.Error(), "invalid character") {
+		t.Errorf("decoding error was reported: %v", err)
+	}
+
+	// A 404 falls back to the legacy endpoint.
+	legacy := http.NewServeMux()
+	legacy.HandleFunc("/legacy/users/1", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"Id":1,"Username":"alice"}`))
+	})
+	legacySrv := httptest.NewServer(legacy)
+	t.Cleanup(legacySrv.Close)
+	cfg.LegacyUserIDEndpoint = legacySrv.URL + "/legacy/users/%d"
+	cookies, _, err := cfg.LoginID(1, []byte("pass"))
+	if err != nil {
+		t.Fatalf("legacy: %v", err)
+	}
+	checkSession(t, cfg, cookies, rbxauth.UserInfo{ID: 1, Name: "alice"})
+}
+
+func TestFileTokenStore(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass"})
+	path := filepath.Join(t.TempDir(), "token")
+	store := rbxauth.FileTokenStore(path)
+
+	// login logs in with a new Config sharing store, as a separate run of a
+	// program would, returning the number of login requests sent.
+	login := func(name string) int {
+		t.Helper()
+		cfg := srv.Config()
+		cfg.TokenStore = store
+		before := srv.Count(rbxauthtest.LoginPath)
+		if _, _, err := cfg.Login("alice", []byte("pass")); err != nil {
+			t.Fatalf("%s: login: %v", name, err)
+		}
+		return srv.Count(rbxauthtest.LoginPath) - before
+	}
+
+	if n := login("first"); n != 2 {
+		t.Errorf("first: expected 2 requests, got %d", n)
+	}
+	if token, err := store.Load(); err != nil || token != srv.Token() {
+		t.Fatalf("first: expected stored token %q, got %q, %v", srv.Token(), token, err)
+	}
+	if info, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	} else if perm := info.Mode().Perm(); runtime.GOOS != "windows" && perm != 0600 {
+		t.Errorf("expected permissions 0600, got %o", perm)
+	}
+
+	// The stored token skips the rejected request.
+	if n := login("second"); n != 1 {
+		t.Errorf("second: expected 1 request, got %d", n)
+	}
+
+	// A stale token is replaced.
+	if err := store.Save("stale"); err != nil {
+		t.Fatal(err)
+	}
+	if n := login("stale"); n != 2 {
+		t.Errorf("stale: expected 2 requests, got %d", n)
+	}
+	if token, _ := store.Load(); token != srv.Token() {
+		t.Errorf("stale: expected stored token %q, got %q", srv.Token(), token)
+	}
+	if n := login("replaced"); n != 1 {
+		t.Errorf("replaced: expected 1 request, got %d", n)
+	}
+
+	// An empty token removes the file.
+	if err := store.Save(""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected file to be removed, got %v", err)
+	}
+}
+
+// cookieValue returns the value of the named cookie, or an empty string if it
+// is not present.
+func cookieValue(cookies []*http.Cookie, name string) string {
+	for _, c := range cookies {
+		if c.Name == name {
+			return c.Value
+		}
+	}
+	return ""
+}
+
+func TestRefresh(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass"})
+	cfg := srv.Config()
+	cookies, _, err := cfg.Login("alice", []byte("pass"))
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	// Unrelated cookies are kept.
+	cookies = append(cookies, &http.Cookie{Name: "other", Value: "1"})
+
+	// Without rotation, the session is unchanged.
+	refreshed, err := cfg.Refresh(cookies)
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if a, b := cookieValue(cookies, rbxauthtest.SessionCookieName), cookieValue(refreshed, rbxauthtest.SessionCookieName); a != b {
+		t.Errorf("session changed from %q to %q", a, b)
+	}
+
+	srv.RotateSessions = true
+	refreshed, err = cfg.Refresh(cookies)
+	if err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	old := cookieValue(cookies, rbxauthtest.SessionCookieName)
+	session := cookieValue(refreshed, rbxauthtest.SessionCookieName)
+	if session == "" || session == old {
+		t.Errorf("rotate: expected new session, got %q", session)
+	}
+	if len(refreshed) != len(cookies) || cookieValue(refreshed, "other") != "1" {
+		t.Errorf("rotate: expected cookies to be merged, got %q", refreshed)
+	}
+	if cookieValue(cookies, rbxauthtest.SessionCookieName) != old {
+		t.Error("rotate: original cookies were modified")
+	}
+	srv.RotateSessions = false
+	checkSession(t, cfg, refreshed, rbxauth.UserInfo{ID: 1, Name: "alice"})
+
+	// The rotated session is dead.
+	if _, err := cfg.Refresh(cookies); !errors.Is(err, rbxauth.ErrUnauthenticated) {
+		t.Errorf("dead: expected ErrUnauthenticated, got %v", err)
+	}
+}
+
+// bodyChecker fails a test if a request to path has a body.
+type bodyChecker struct {
+	t         *testing.T
+	path      string
+	transport http.RoundTripper
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (c bodyChecker) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Path == c.path && req.Body != nil && req.Body != http.NoBody {
+		b, _ := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		c.t.Errorf("%s: unexpected body %q", c.path, b)
+		req.Body = http.NoBody
+	}
+	return c.transport.RoundTrip(req)
+}
+
+func TestLogout(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass"})
+	cfg := srv.Config()
+	rec := &headerRecorder{
+		transport: bodyChecker{t: t, path: rbxauthtest.LogoutPath, transport: cfg.Client.Transport},
+		headers:   map[string][]http.Header{},
+	}
+	cfg.Client = &http.Client{Transport: rec}
+
+	// The logout is the first request, so it is retried with the token
+	// learned from the rejection, despite having no body.
+	cookies, _, err := srv.Config().Login("alice", []byte("pass"))
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	checkLogout(t, cfg, cookies)
+	sent := rec.headers[rbxauthtest.LogoutPath]
+	if len(sent) != 2 {
+		t.Fatalf("live: expected 2 requests, got %d", len(sent))
+	}
+	if sent[0].Get("X-CSRF-TOKEN") != "" || sent[1].Get("X-CSRF-TOKEN") == "" {
+		t.Errorf("live: expected token only on retry, got %q, %q", sent[0].Get("X-CSRF-TOKEN"), sent[1].Get("X-CSRF-TOKEN"))
+	}
+
+	// The session has ended, so there is nothing to log out.
+	for name, cookies := range map[string][]*http.Cookie{
+		"ended": cookies,
+		"stale": rbxauth.FromSecurityToken("stale"),
+	} {
+		if err := cfg.Logout(cookies); !errors.Is(err, rbxauth.ErrAlreadyLoggedOut) {
+			t.Errorf("%s: expected ErrAlreadyLoggedOut, got %v", name, err)
+		}
+		if err := cfg.EnsureLoggedOut(cookies); err != nil {
+			t.Errorf("%s: ensure: %v", name, err)
+		}
+	}
+
+	// Genuine failures are not treated as logged out.
+	cookies, _, err = cfg.Login("alice", []byte("pass"))
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	srv.Fail(rbxauthtest.LogoutPath, http.StatusInternalServerError, 0, "InternalServerError")
+	srv.Fail(rbxauthtest.LogoutPath, http.StatusInternalServerError, 0, "InternalServerError")
+	if err := cfg.Logout(cookies); err == nil || errors.Is(err, rbxauth.ErrAlreadyLoggedOut) {
+		t.Errorf("server error: expected failure, got %v", err)
+	}
+	if err := cfg.EnsureLoggedOut(cookies); err == nil || errors.Is(err, rbxauth.ErrAlreadyLoggedOut) {
+		t.Errorf("server error: ensure: expected failure, got %v", err)
+	}
+	// Failed token validation that cannot be retried is not treated as
+	// logged out.
+	srv.Fail(rbxauthtest.LogoutPath, http.StatusForbidden, 0, "Token Validation Failed")
+	if err := cfg.Logout(cookies); err == nil || errors.Is(err, rbxauth.ErrAlreadyLoggedOut) {
+		t.Errorf("token validation: expected failure, got %v", err)
+	}
+	// Each failure was consumed without ending the session.
+	checkSession(t, cfg, cookies, rbxauth.UserInfo{ID: 1})
+	checkLogout(t, cfg, cookies)
+}
+
+func TestLogoutAll(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass"})
+	cfg := srv.Config()
+	cookies, _, err := cfg.Login("alice", []byte("pass"))
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	other, _, err := cfg.Login("alice", []byte("pass"))
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	cookies = append(cookies, &http.Cookie{Name: "other", Value: "1"})
+
+	newCookies, err := cfg.LogoutAll(cookies)
+	if err != nil {
+		t.Fatalf("logout all: %v", err)
+	}
+	old := cookieValue(cookies, rbxauthtest.SessionCookieName)
+	session := cookieValue(newCookies, rbxauthtest.SessionCookieName)
+	if session == "" || session == old {
+		t.Errorf("expected new session, got %q", session)
+	}
+	if len(newCookies) != len(cookies) || cookieValue(newCookies, "other") != "1" {
+		t.Errorf("expected cookies to be merged, got %q", newCookies)
+	}
+	if cookieValue(cookies, rbxauthtest.SessionCookieName) != old {
+		t.Error("original cookies were modified")
+	}
+	checkSession(t, cfg, newCookies, rbxauth.UserInfo{ID: 1, Name: "alice"})
+
+	// Every previous session is dead.
+	for name, c := range map[string][]*http.Cookie{"old": cookies, "other": other} {
+		if _, err := cfg.Authenticated(c); !errors.Is(err, rbxauth.ErrUnauthenticated) {
+			t.Errorf("%s: expected ErrUnauthenticated, got %v", name, err)
+		}
+	}
+	// A stale session cannot log out other sessions.
+	if _, err := cfg.LogoutAll(cookies); !errors.Is(err, rbxauth.ErrUnauthenticated) {
+		t.Errorf("stale: expected ErrUnauthenticated, got %v", err)
+	}
+	checkSession(t, cfg, newCookies, rbxauth.UserInfo{ID: 1})
+}
+
+// serveResponse returns a server that responds to every request with the
+// given status, content type, and body.
+func serveResponse(t *testing.T, status int, contentType, body string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+		}
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestNonJSONResponses(t *testing.T) {
+	const page = `<!DOCTYPE html>
+<html><head><title>
+	Down for   Maintenance
+</title></head><body>` + "\x00" + `We'll be back soon.</body></html>`
+	for _, test := range []struct {
+		name        string
+		status      int
+		contentType string
+		body        string
+		// contains lists substrings of the error message.
+		contains []string
+	}{
+		{"HTML 503", 503, "text/html; charset=utf-8", page, []string{
+			"http status 503", `"text/html; charset=utf-8"`, `"Down for Maintenance"`,
+		}},
+		{"text 429", 429, "text/plain", "Too many requests\r\n\tslow down", []string{
+			"http status 429", `"text/plain"`, `"Too many requests slow down"`,
+		}},
+		{"long text", 502, "text/plain", strings.Repeat("x", 5000), []string{
+			"http status 502", strings.Repeat("x", 200) + `..."`,
+		}},
+		{"truncated JSON", 200, "application/json", `{"user":{"id":1,"na`, []string{
+			"decode response", `"application/json"`, `{\"user\":{\"id\":1,\"na`, "unexpected EOF",
+		}},
+	} {
+		srv := serveResponse(t, test.status, test.contentType, test.body)
+		cfg := rbxauth.Config{AllowInsecure: true, LoginEndpoint: srv.URL, Sleep: func(context.Context, time.Duration) error { return nil }}
+		_, _, err := cfg.Login("alice", []byte("pass"))
+		if err == nil {
+			t.Errorf("%s: expected error", test.name)
+			continue
+		}
+		for _, s := range test.contains {
+			if !strings.Contains(err.Error(), s) {
+				t.Errorf("%s: expected error containing %q, got %q", test.name, s, err)
+			}
+		}
+		if strings.Contains(err.Error(), "invalid character") {
+			t.Errorf("%s: error reports JSON syntax: %q", test.name, err)
+		}
+		if code, ok := rbxauth.HTTPStatus(err); test.status != 200 && (!ok || code != test.status) {
+			t.Errorf("%s: expected status %d, got %d", test.name, test.status, code)
+		}
+	}
+	srv := serveResponse(t, 503, "text/html", page)
+	cfg := rbxauth.Config{AllowInsecure: true, LoginEndpoint: srv.URL, MaxRetries: 1}
+	delays := recordSleep(&cfg)
+	if _, _, err := cfg.Login("alice", []byte("pass")); !strings.Contains(err.Error(), "Down for Maintenance") {
+		t.Errorf("retried: expected snippet, got %v", err)
+	}
+	if len(*delays) != 1 {
+		t.Errorf("retried: expected 1 retry, got %d", len(*delays))
+	}
+}
+
+func TestEmptyResponse(t *testing.T) {
+	for _, contentType := range []string{"", "application/json"} {
+		srv := serveResponse(t, 200, contentType, "")
+		cfg := rbxauth.Config{AllowInsecure: true, LogoutEndpoint: srv.URL}
+		if err := cfg.Logout(rbxauth.FromSecurityToken("token")); err != nil {
+			t.Errorf("%q: expected success, got %v", contentType, err)
+		}
+	}
+	// An empty error response still reports the status.
+	srv := serveResponse(t, 500, "", "")
+	cfg := rbxauth.Config{AllowInsecure: true, LogoutEndpoint: srv.URL}
+	if code, ok := rbxauth.HTTPStatus(cfg.Logout(rbxauth.FromSecurityToken("token"))); !ok || code != 500 {
+		t.Errorf("500: expected status 500, got %d", code)
+	}
+}
+
+func TestLogRedaction(t *testing.T) {
+	// The password includes characters that are escaped in JSON.
+	const password = `hunter2 "secret" \ pässword`
+	const wrong = "wrong-password-secret"
+	const code = "314159"
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: password, TwoStep: true, Code: code})
+	cfg := srv.Config()
+	cfg.LegacyTwoStep = true
+	var events []rbxauth.LogEvent
+	cfg.Log = func(event rbxauth.LogEvent) {
+		events = append(events, event)
+	}
+	var dump strings.Builder
+	cfg.DumpRequests = &dump
+
+	if _, _, err := cfg.Login("alice", []byte(wrong)); !errors.Is(err, rbxauth.ErrBadCredentials) {
+		t.Fatalf("wrong password: expected ErrBadCredentials, got %v", err)
+	}
+	_, step, err := cfg.Login("alice", []byte(password))
+	if err != nil || step == nil {
+		t.Fatalf("login: expected step, got %v", err)
+	}
+	cookies, err := step.Verify(code, false)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	// Each attempt is logged, including the retry after the token is
+	// rejected, and each body is dumped.
+	if len(events) != 4 || !events[0].Retry {
+		t.Errorf("expected 4 events, starting with a retry, got %v", events)
+	}
+	if n := strings.Count(dump.String(), `"ctype"`); n != 2 {
+		t.Errorf("expected 2 dumped login bodies, got %d", n)
+	}
+
+	var out strings.Builder
+	for _, event := range events {
+		fmt.Fprintf(&out, "%s\n%+v\n%#v\n", event, event, event)
+	}
+	out.WriteString(dump.String())
+	escaped, _ := json.Marshal(password)
+	secrets := []string{password, string(escaped[1 : len(escaped)-1]), wrong, code, "hunter2"}
+	for _, c := range cookies {
+		secrets = append(secrets, c.Value)
+	}
+	for _, secret := range secrets {
+		if strings.Contains(out.String(), secret) {
+			t.Errorf("output contains secret %q:\n%s", secret, out.String())
+		}
+	}
+}
+
+func TestDetectCredType(t *testing.T) {
+	for _, test := range []struct {
+		ident     string
+		credType  string
+		ambiguous bool
+	}{
+		{"alice@example.com", "Email", false},
+		{" alice@example.com ", "Email", false},
+		{"+15551234567", "PhoneNumber", false},
+		{"+1 (555) 123-4567", "PhoneNumber", false},
+		{"+1.555.123", "PhoneNumber", false},
+		{"5551234567", "PhoneNumber", false},
+		{"+12345", "Username", false},
+		{"555-123-4567", "Username", false},
+		{"12345", "Username", true},
+		{"alice", "Username", false},
+		{"alice2", "Username", false},
+		{"", "Username", false},
+	} {
+		credType, ambiguous := rbxauth.DetectCredType(test.ident)
+		if credType != test.credType || ambiguous != test.ambiguous {
+			t.Errorf("%q: expected %s %t, got %s %t", test.ident, test.credType, test.ambiguous, credType, ambiguous)
+		}
+	}
+}
+
+// checkTimeout fails if err is not a timeout that occurred within tolerance
+// of timeout after start.
+func checkTimeout(t *testing.T, name string, err error, timeout time.Duration, start time.Time) {
+	t.Helper()
+	elapsed := time.Since(start)
+	if !errors.Is(err, rbxauth.ErrTimeout) {
+		t.Errorf("%s: expected ErrTimeout, got %v", name, err)
+		return
+	}
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("%s: expected timeout net.Error, got %T", name, err)
+	}
+	if elapsed < timeout || elapsed > timeout+time.Second {
+		t.Errorf("%s: expected timeout after %v, got %v", name, timeout, elapsed)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	const timeout = 100 * time.Millisecond
+	const stall = 10 * time.Second
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass"})
+	srv.AddAccount(rbxauthtest.Account{ID: 2, Name: "bob", Password: "pass", TwoStep: true, Code: "123456"})
+	cfg := srv.Config()
+	cfg.Timeout = timeout
+	cfg.LegacyTwoStep = true
+	cfg.ResendCooldown = -1
+	// The token is primed so that the first request is the stalled one.
+	cfg.Token = srv.Token()
+
+	srv.Stall(rbxauthtest.LoginPath, stall)
+	start := time.Now()
+	_, _, err := cfg.Login("alice", []byte("pass"))
+	checkTimeout(t, "login", err, timeout, start)
+
+	// A step inherits the timeout of its Config.
+	_, step, err := cfg.Login("bob", []byte("pass"))
+	if err != nil || step == nil {
+		t.Fatalf("login: expected step, got %v", err)
+	}
+	srv.Stall(rbxauthtest.ResendPath, stall)
+	start = time.Now()
+	checkTimeout(t, "resend", step.Resend(), timeout, start)
+	srv.Stall(rbxauthtest.VerifyPath, stall)
+	start = time.Now()
+	_, err = step.Verify("123456", false)
+	checkTimeout(t, "verify", err, timeout, start)
+
+	// The step remains usable after a timeout.
+	cookies, err := step.Verify("123456", false)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	checkSession(t, cfg, cookies, rbxauth.UserInfo{ID: 2})
+
+	// A negative timeout waits for the response.
+	cfg.Timeout = -1
+	srv.Stall(rbxauthtest.LoginPath, 2*timeout)
+	if _, _, err := cfg.Login("alice", []byte("pass")); err != nil {
+		t.Errorf("no timeout: %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package rbxauth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// These errors classify logins that are refused because of the age or region
+// of the account. They are matched by a ConsentError.
+var (
+	// ErrParentalConsentRequired indicates that a parent must consent before
+	// the account can log in.
+	ErrParentalConsentRequired = errors.New("parental consent required")
+	// ErrAgeRestricted indicates that the account cannot log in until its age
+	// has been verified, or that it cannot log in from its region.
+	ErrAgeRestricted = errors.New("age restricted")
+)
+
+// ConsentError is returned by a login that is refused with
+// ErrParentalConsentRequired or ErrAgeRestricted.
+type ConsentError struct {
+	// URL is the page where consent is given or the restriction is resolved,
+	// if reported by the API.
+	URL string
+
+	err error
+}
+
+// Error implements the error interface.
+func (err *ConsentError) Error() string {
+	if err.URL == "" {
+		return err.err.Error()
+	}
+	return err.err.Error() + " (see " + err.URL + ")"
+}
+
+// Unwrap implements the Unwrap interface.
+func (err *ConsentError) Unwrap() error {
+	return err.err
+}
+
+// ifConsent returns a ConsentError if err, returned by a login that received
+// resp, indicates that consent is required. Returns err otherwise.
+func ifConsent(resp *http.Response, err error) error {
+	if !errors.Is(err, ErrParentalConsentRequired) && !errors.Is(err, ErrAgeRestricted) {
+		return err
+	}
+	return &ConsentError{URL: consentURL(resp, err), err: err}
+}
+
+// consentURLFields lists the fields of the FieldData of an error that may
+// contain a consent URL.
+var consentURLFields = []string{"consentUrl", "redirectUrl", "url"}
+
+// urlPattern matches a URL within an error message.
+var urlPattern = regexp.MustCompile(`https?://[^\s"'<>]+`)
+
+// consentURL returns the consent URL reported by resp or err. The URL is
+// looked for in the Location header, then in the FieldData of each error,
+// either as a JSON object or as is, then in the message of each error. Returns
+// an empty string if no URL is found.
+func consentURL(resp *http.Response, err error) string {
+	if resp != nil {
+		if u, ok := webURL(resp.Header.Get("Location")); ok {
+			return u
+		}
+	}
+	errs := AllErrors(err)
+	for _, e := range errs {
+		var data map[string]interface{}
+		if json.Unmarshal([]byte(e.FieldData), &data) == nil {
+			for _, field := range consentURLFields {
+				if s, ok := data[field].(string); ok {
+					if u, ok := webURL(s); ok {
+						return u
+					}
+				}
+			}
+		} else if u, ok := webURL(e.FieldData); ok {
+			return u
+		}
+	}
+	for _, e := range errs {
+		if s := urlPattern.FindString(e.Message); s != "" {
+			if u, ok := webURL(strings.TrimRight(s, ".,;:!?)")); ok {
+				return u
+			}
+		}
+	}
+	return ""
+}
+
+// webURL returns s if it is an absolute http or https URL.
+func webURL(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" || u.Scheme != "https" && u.Scheme != "http" {
+		return "", false
+	}
+	return s, true
+}
@@ -2,35 +2,565 @@ package rbxauth
 
 import (
 	"bufio"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"net/textproto"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
 )
 
+// These errors are returned when reading cookies.
+var (
+	// ErrNoCookies is returned when the input contains data, but no cookies.
+	ErrNoCookies = errors.New("no cookies found")
+	// ErrBadFormat is matched by a *CookieFormatError.
+	ErrBadFormat = errors.New("malformed cookie")
+	// ErrCookiesTooLarge is returned when the input exceeds MaxCookiesSize.
+	ErrCookiesTooLarge = errors.New("cookie input too large")
+	// ErrNoSecurityCookie is returned by WriteSecurityToken when there is no
+	// session cookie.
+	ErrNoSecurityCookie = errors.New("no session cookie")
+)
+
+// MaxCookiesSize is the maximum number of bytes read by ReadCookies and
+// ReadCookiesJSON.
+const MaxCookiesSize = 1 << 20
+
+// CookieFormatError is returned when a line of input could not be parsed as a
+// cookie.
+type CookieFormatError struct {
+	// Line is the line number, starting at 1.
+	Line int
+	// Text is the content of the line.
+	Text string
+}
+
+// Error implements the error interface.
+func (err *CookieFormatError) Error() string {
+	text := err.Text
+	if len(text) > 32 {
+		// Truncate on a rune boundary.
+		n := 32
+		for n > 0 && !utf8.RuneStart(text[n]) {
+			n--
+		}
+		text = text[:n] + "..."
+	}
+	return fmt.Sprintf("line %d: %s: %q", err.Line, ErrBadFormat, text)
+}
+
+// Is returns whether target is ErrBadFormat.
+func (err *CookieFormatError) Is(target error) bool {
+	return target == ErrBadFormat
+}
+
+// limitReader returns err after reading more than n bytes.
+type limitReader struct {
+	r   io.Reader
+	n   int64
+	err error
+}
+
+// Read implements the io.Reader interface.
+func (l *limitReader) Read(p []byte) (n int, err error) {
+	if l.n < 0 {
+		return 0, l.err
+	}
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err = l.r.Read(p)
+	if l.n -= int64(n); l.n < 0 {
+		return n, l.err
+	}
+	return n, err
+}
+
+// limitCookies limits r according to MaxCookiesSize.
+func limitCookies(r io.Reader) io.Reader {
+	return &limitReader{r: r, n: MaxCookiesSize, err: ErrCookiesTooLarge}
+}
+
+// isToken returns whether s is a valid header field name.
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case strings.ContainsRune("!#$%&'*+-.^_`|~", c):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
 // ReadCookies parses cookies from r and returns a list of http.Cookies.
-// Cookies are parsed as a number of "Set-Cookie" HTTP headers. Returns an
-// empty list if the reader is empty.
+//
+// Each line is either a "Set-Cookie" HTTP header, or a bare cookie in the same
+// format as the value of a Set-Cookie header (e.g. "name=value; Path=/").
+// Lines that are other HTTP headers are ignored. Lines starting with a space
+// or tab continue the previous line. At most MaxCookiesSize bytes are read.
+//
+// Returns an empty list if the reader is empty. Returns ErrNoCookies if the
+// input contains no cookies, and a *CookieFormatError if a line could not be
+// parsed.
 func ReadCookies(r io.Reader) (cookies []*http.Cookie, err error) {
-	// There's no direct way to parse cookies, so we have to cheat a little.
-	h, err := textproto.NewReader(bufio.NewReader(r)).ReadMIMEHeader()
-	if err != nil && err != io.EOF {
-		return nil, fmt.Errorf("read cookies: %w", err)
+	defer wrapOp("read cookies", &err)
+	return readCookies(r, nil)
+}
+
+// readCookies implements ReadCookies. If meta is not nil, then it receives the
+// metadata headers of the input.
+func readCookies(r io.Reader, meta *CookieMeta) (cookies []*http.Cookie, err error) {
+	// Join folded lines.
+	type line struct {
+		n    int
+		text string
+	}
+	var lines []line
+	scanner := bufio.NewScanner(limitCookies(r))
+	scanner.Buffer(nil, MaxCookiesSize+1)
+	for n := 1; scanner.Scan(); n++ {
+		text := strings.TrimSuffix(scanner.Text(), "\r")
+		if len(lines) > 0 && text != "" && (text[0] == ' ' || text[0] == '\t') {
+			lines[len(lines)-1].text += " " + strings.TrimSpace(text)
+			continue
+		}
+		lines = append(lines, line{n: n, text: text})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	cookies = []*http.Cookie{}
+	empty := true
+	for _, line := range lines {
+		text := strings.TrimSpace(line.text)
+		if text == "" {
+			continue
+		}
+		empty = false
+		value := text
+		colon := strings.IndexByte(text, ':')
+		if eq := strings.IndexByte(text, '='); colon >= 0 && (eq < 0 || colon < eq) {
+			// Header.
+			name := text[:colon]
+			if !isToken(name) {
+				return nil, &CookieFormatError{Line: line.n, Text: line.text}
+			}
+			if !strings.EqualFold(name, "Set-Cookie") {
+				if meta != nil && !meta.set(name, strings.TrimSpace(text[colon+1:])) {
+					return nil, &CookieFormatError{Line: line.n, Text: line.text}
+				}
+				continue
+			}
+			value = strings.TrimSpace(text[colon+1:])
+		}
+		resp := http.Response{Header: http.Header{"Set-Cookie": {value}}}
+		c := responseCookies(&resp)
+		if len(c) == 0 {
+			return nil, &CookieFormatError{Line: line.n, Text: line.text}
+		}
+		cookies = append(cookies, c...)
+	}
+	if !empty && len(cookies) == 0 {
+		return nil, ErrNoCookies
 	}
-	resp := http.Response{Header: http.Header(h)}
-	return resp.Cookies(), nil
+	return cookies, nil
 }
 
 // WriteCookies formats a list of cookies as a number of "Set-Cookie" HTTP
-// headers and writes them to w.
+// headers and writes them to w. Each attribute of a cookie is written,
+// including those in the Unparsed field, so that cookies read by ReadCookies
+// are written as they were received.
 func WriteCookies(w io.Writer, cookies []*http.Cookie) (err error) {
 	// More cheating.
 	h := http.Header{}
 	for _, cookie := range cookies {
-		h.Add("Set-Cookie", cookie.String())
+		h.Add("Set-Cookie", formatCookie(cookie))
 	}
 	if err = h.Write(w); err != nil {
 		return fmt.Errorf("write cookies: %w", err)
 	}
 	return nil
 }
+
+// jsonCookie is the JSON representation of a cookie.
+type jsonCookie struct {
+	Name     string     `json:"name"`
+	Value    string     `json:"value"`
+	Domain   string     `json:"domain,omitempty"`
+	Path     string     `json:"path,omitempty"`
+	Expires  *time.Time `json:"expires,omitempty"`
+	MaxAge   int        `json:"maxAge,omitempty"`
+	Secure   bool       `json:"secure,omitempty"`
+	HttpOnly bool       `json:"httpOnly,omitempty"`
+	SameSite string     `json:"sameSite,omitempty"`
+}
+
+// sameSiteNames maps each mode of the SameSite attribute to its name.
+var sameSiteNames = map[http.SameSite]string{
+	http.SameSiteLaxMode:    "Lax",
+	http.SameSiteStrictMode: "Strict",
+	http.SameSiteNoneMode:   "None",
+}
+
+// parseSameSite returns the mode of the SameSite attribute named by name, or
+// zero if the name is unknown.
+func parseSameSite(name string) http.SameSite {
+	for mode, n := range sameSiteNames {
+		if strings.EqualFold(n, name) {
+			return mode
+		}
+	}
+	return 0
+}
+
+// ReadCookiesJSON parses cookies from r, formatted as a JSON array of objects.
+// Each object has the fields "name", "value", "domain", "path", "expires",
+// "maxAge", "secure", "httpOnly", and "sameSite", where "sameSite" is one of
+// "Lax", "Strict", or "None". At most MaxCookiesSize bytes are read.
+func ReadCookiesJSON(r io.Reader) (cookies []*http.Cookie, err error) {
+	var list []jsonCookie
+	if err = json.NewDecoder(limitCookies(r)).Decode(&list); err != nil {
+		return nil, fmt.Errorf("read cookies: %w", err)
+	}
+	cookies = make([]*http.Cookie, len(list))
+	for i, c := range list {
+		cookies[i] = &http.Cookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   c.Domain,
+			Path:     c.Path,
+			MaxAge:   c.MaxAge,
+			Secure:   c.Secure,
+			HttpOnly: c.HttpOnly,
+			SameSite: parseSameSite(c.SameSite),
+		}
+		if c.Expires != nil {
+			cookies[i].Expires = *c.Expires
+		}
+	}
+	return cookies, nil
+}
+
+// WriteCookiesJSON formats a list of cookies as a JSON array, and writes it to
+// w.
+func WriteCookiesJSON(w io.Writer, cookies []*http.Cookie) (err error) {
+	list := make([]jsonCookie, len(cookies))
+	for i, c := range cookies {
+		list[i] = jsonCookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   c.Domain,
+			Path:     c.Path,
+			MaxAge:   c.MaxAge,
+			Secure:   c.Secure,
+			HttpOnly: c.HttpOnly,
+			SameSite: sameSiteNames[c.SameSite],
+		}
+		if !c.Expires.IsZero() {
+			expires := c.Expires
+			list[i].Expires = &expires
+		}
+	}
+	je := json.NewEncoder(w)
+	je.SetIndent("", "\t")
+	if err = je.Encode(list); err != nil {
+		return fmt.Errorf("write cookies: %w", err)
+	}
+	return nil
+}
+
+// SecurityTokenVar is the name of the variable written by WriteSecurityToken.
+const SecurityTokenVar = "ROBLOSECURITY"
+
+// WriteSecurityToken writes the value of the session cookie within cookies
+// in the given format:
+//
+//	raw:    The value followed by a newline.
+//	env:    A shell export statement, with the value single-quoted.
+//	dotenv: A KEY=value line, with the value double-quoted if necessary.
+//
+// Returns ErrNoSecurityCookie if cookies has no session cookie.
+func WriteSecurityToken(w io.Writer, cookies []*http.Cookie, format string) (err error) {
+	defer wrapOp("write token", &err)
+	var token string
+	for _, cookie := range cookies {
+		if cookie.Name == SessionCookieName {
+			token = cookie.Value
+			break
+		}
+	}
+	if token == "" {
+		return ErrNoSecurityCookie
+	}
+	switch format {
+	case "raw":
+		_, err = fmt.Fprintln(w, token)
+	case "env":
+		_, err = fmt.Fprintf(w, "export %s='%s'\n", SecurityTokenVar, strings.ReplaceAll(token, "'", `'\''`))
+	case "dotenv":
+		_, err = fmt.Fprintf(w, "%s=%s\n", SecurityTokenVar, dotenvQuote(token))
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+	return err
+}
+
+// dotenvQuote returns value double-quoted with escapes, unless it contains
+// only characters that need no quoting.
+func dotenvQuote(value string) string {
+	if strings.IndexFunc(value, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' ||
+			strings.ContainsRune("_-.:|/+=@,", r))
+	}) < 0 {
+		return value
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "\n", `\n`).Replace(value) + `"`
+}
+
+// ReadCookiesAuto parses cookies from r, detecting whether they are formatted
+// as JSON or as headers. If the cookies were written by WriteCookiesEncrypted,
+// then the passphrase is prompted with TerminalPassphrase.
+func ReadCookiesAuto(r io.Reader) (cookies []*http.Cookie, err error) {
+	return ReadCookiesAutoPassphrase(r, TerminalPassphrase)
+}
+
+// DeviceCookieNames lists the names of cookies that identify a device
+// remembered by two-step verification.
+var DeviceCookieNames = []string{"RBXEventTrackerV2"}
+
+// isDeviceCookie returns whether name is in DeviceCookieNames.
+func isDeviceCookie(name string) bool {
+	for _, n := range DeviceCookieNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// SplitDeviceCookies separates cookies into device cookies, as named by
+// DeviceCookieNames, and all other cookies.
+func SplitDeviceCookies(cookies []*http.Cookie) (session, device []*http.Cookie) {
+	for _, cookie := range cookies {
+		if isDeviceCookie(cookie.Name) {
+			device = append(device, cookie)
+		} else {
+			session = append(session, cookie)
+		}
+	}
+	return session, device
+}
+
+// SessionCookieNames lists the names of cookies kept by FilterSessionCookies.
+// The CSRF token is sent as a header rather than a cookie, so only the session
+// cookie is required.
+var SessionCookieNames = []string{SessionCookieName}
+
+// FilterSessionCookies returns the cookies required for an authenticated
+// session, as named by SessionCookieNames. Other cookies, such as those used
+// for tracking, are excluded.
+func FilterSessionCookies(cookies []*http.Cookie) []*http.Cookie {
+	return FilterCookies(cookies, SessionCookieNames...)
+}
+
+// FilterCookies returns the cookies whose name is one of names, retaining
+// their order. Names are case-sensitive. Every cookie with a matching name is
+// included, including duplicates.
+func FilterCookies(cookies []*http.Cookie, names ...string) []*http.Cookie {
+	var filtered []*http.Cookie
+	for _, cookie := range cookies {
+		for _, name := range names {
+			if cookie.Name == name {
+				filtered = append(filtered, cookie)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// cookieExpired returns whether cookie deletes the cookie with its name, by
+// having a negative MaxAge or an expiry before now.
+func cookieExpired(cookie *http.Cookie, now time.Time) bool {
+	return cookie.MaxAge < 0 || !cookie.Expires.IsZero() && cookie.Expires.Before(now)
+}
+
+// MergeCookies returns a copy of old, with cookies replaced by cookies in new
+// that have the same name, such as when a session is reissued. Cookies in new
+// that are not in old are appended, and unrelated cookies in old are kept. A
+// cookie in new that has expired removes the cookie of the same name. If old
+// contains a name more than once, then only the first is replaced, and the
+// remainder are removed.
+func MergeCookies(old, new []*http.Cookie) []*http.Cookie {
+	return mergeCookies(old, new, time.Now())
+}
+
+// mergeCookies implements MergeCookies, relative to now.
+func mergeCookies(old, new []*http.Cookie, now time.Time) []*http.Cookie {
+	merged := make([]*http.Cookie, len(old), len(old)+len(new))
+	copy(merged, old)
+	for _, cookie := range new {
+		var found bool
+		n := 0
+		for _, c := range merged {
+			if c.Name == cookie.Name {
+				if found {
+					continue
+				}
+				found = true
+				if cookieExpired(cookie, now) {
+					continue
+				}
+				c = cookie
+			}
+			merged[n] = c
+			n++
+		}
+		merged = merged[:n]
+		if !found && !cookieExpired(cookie, now) {
+			merged = append(merged, cookie)
+		}
+	}
+	return merged
+}
+
+// RewriteCookieDomain returns a copy of cookies, where the domain of each
+// cookie that is from, or a subdomain of from, is rewritten to the
+// corresponding domain of to. For example, rewriting "roblox.com" to
+// "sitetest1.robloxlabs.com" rewrites ".roblox.com" to
+// ".sitetest1.robloxlabs.com", and "www.roblox.com" to
+// "www.sitetest1.robloxlabs.com". A leading dot in from and to is ignored,
+// while that of each domain is retained. Domains are compared without regard
+// to case. Cookies that do not match, including cookies without a domain, are
+// copied unchanged. cookies is not modified.
+func RewriteCookieDomain(cookies []*http.Cookie, from, to string) []*http.Cookie {
+	from = strings.ToLower(strings.TrimPrefix(from, "."))
+	to = strings.TrimPrefix(to, ".")
+	rewritten := make([]*http.Cookie, len(cookies))
+	for i, c := range cookies {
+		cookie := *c
+		if from != "" {
+			domain := strings.TrimPrefix(cookie.Domain, ".")
+			dot := domain != cookie.Domain
+			switch lower := strings.ToLower(domain); {
+			case lower == from:
+				domain = to
+			case strings.HasSuffix(lower, "."+from):
+				domain = domain[:len(domain)-len(from)] + to
+			default:
+				rewritten[i] = &cookie
+				continue
+			}
+			if dot {
+				domain = "." + domain
+			}
+			cookie.Domain = domain
+		}
+		rewritten[i] = &cookie
+	}
+	return rewritten
+}
+
+// AsJarCookies returns copies of cookies suited for passing to the SetCookies
+// method of an http.CookieJar, where u is the URL of the response that set the
+// cookies, or of the site to which they apply. A jar ignores a cookie whose
+// domain does not match the host of u, so such cookies are omitted. The Raw and
+// Unparsed fields, which are not used by a jar, are cleared. Every other
+// attribute is retained, so that the jar sends each cookie under the same
+// conditions as a browser. cookies is not modified.
+func AsJarCookies(u *url.URL, cookies []*http.Cookie) []*http.Cookie {
+	host := strings.ToLower(u.Hostname())
+	jar := make([]*http.Cookie, 0, len(cookies))
+	for _, c := range cookies {
+		if domain := strings.ToLower(strings.TrimPrefix(c.Domain, ".")); domain != "" {
+			if host != domain && !strings.HasSuffix(host, "."+domain) {
+				continue
+			}
+		}
+		cookie := *c
+		cookie.Raw = ""
+		cookie.Unparsed = nil
+		jar = append(jar, &cookie)
+	}
+	return jar
+}
+
+// CookieInfo describes a cookie inspected by InspectCookies.
+type CookieInfo struct {
+	Name   string
+	Domain string
+	// HasExpiry is whether the cookie has an Expires or Max-Age attribute.
+	// Cookies written by WriteCookies may omit these attributes, in which
+	// case the expiry is unknown.
+	HasExpiry bool
+	// Expires is the time at which the cookie expires. Zero if HasExpiry is
+	// false. A Max-Age attribute is counted from the time of inspection.
+	Expires time.Time
+	// Remaining is the duration until the cookie expires, which is negative
+	// if the cookie has expired. Zero if HasExpiry is false.
+	Remaining time.Duration
+	// Session is whether the cookie holds a session.
+	Session bool
+}
+
+// CookieReport is the result of InspectCookies.
+type CookieReport struct {
+	// Cookies describes each inspected cookie, in order.
+	Cookies []CookieInfo
+	// HasSession is whether a session cookie was found.
+	HasSession bool
+	// SessionValidUntil is the earliest time at which a session cookie
+	// expires. Zero if there is no session cookie, or if its expiry is
+	// unknown.
+	SessionValidUntil time.Time
+}
+
+// InspectCookies reports the expiry of each cookie, and of the session held
+// by cookies.
+func InspectCookies(cookies []*http.Cookie) CookieReport {
+	return inspectCookies(cookies, time.Now())
+}
+
+// inspectCookies implements InspectCookies, relative to now.
+func inspectCookies(cookies []*http.Cookie, now time.Time) (report CookieReport) {
+	report.Cookies = make([]CookieInfo, len(cookies))
+	for i, c := range cookies {
+		info := CookieInfo{
+			Name:    c.Name,
+			Domain:  c.Domain,
+			Session: c.Name == SessionCookieName,
+		}
+		switch {
+		case c.MaxAge < 0:
+			info.HasExpiry = true
+			info.Expires = now
+		case c.MaxAge > 0:
+			info.HasExpiry = true
+			info.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+		case !c.Expires.IsZero():
+			info.HasExpiry = true
+			info.Expires = c.Expires
+		}
+		if info.HasExpiry {
+			info.Remaining = info.Expires.Sub(now)
+		}
+		if info.Session {
+			report.HasSession = true
+			if info.HasExpiry && (report.SessionValidUntil.IsZero() || info.Expires.Before(report.SessionValidUntil)) {
+				report.SessionValidUntil = info.Expires
+			}
+		}
+		report.Cookies[i] = info
+	}
+	return report
+}
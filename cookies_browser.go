@@ -0,0 +1,366 @@
+package rbxauth
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/anaminus/rbxauth/internal/sqlite"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// SessionCookieName is the name of the cookie that holds a session.
+const SessionCookieName = ".ROBLOSECURITY"
+
+// FromSecurityToken returns a list containing a session cookie with the given
+// value, scoped to the Roblox domain.
+func FromSecurityToken(token string) []*http.Cookie {
+	return []*http.Cookie{{
+		Name:     SessionCookieName,
+		Value:    token,
+		Domain:   ".roblox.com",
+		Path:     "/",
+		Secure:   true,
+		HttpOnly: true,
+	}}
+}
+
+// These errors are returned by ImportBrowserCookies.
+var (
+	// ErrUnknownBrowser is returned when the browser is not supported.
+	ErrUnknownBrowser = errors.New("unknown browser")
+	// ErrEncryptedUnsupported is returned when the cookie is encrypted in a
+	// way that cannot be decrypted, such as with a key held by the operating
+	// system's keyring.
+	ErrEncryptedUnsupported = errors.New("decrypting cookie is unsupported")
+	// ErrCookieNotFound is returned when the browser has no session cookie.
+	ErrCookieNotFound = errors.New("session cookie not found")
+)
+
+// Browsers lists the browsers supported by ImportBrowserCookies.
+var Browsers = []string{"firefox", "chrome", "chromium", "edge", "brave"}
+
+// ImportBrowserCookies reads the session cookie stored by a browser installed
+// on the current system. browser is one of the names in Browsers.
+//
+// profile selects the browser profile. It may be the path to the profile's
+// directory, or the name of a profile. If empty, the default profile is used.
+//
+// Cookies written by a running browser may not yet be visible. Chromium-based
+// browsers encrypt cookies; only the fixed key used on Linux without a keyring
+// is supported. Otherwise, ErrEncryptedUnsupported is returned.
+func ImportBrowserCookies(browser string, profile string) (cookies []*http.Cookie, err error) {
+	defer wrapOp("import cookies", &err)
+	browser = strings.ToLower(browser)
+	if browser == "firefox" {
+		dir, err := firefoxProfile(profile)
+		if err != nil {
+			return nil, err
+		}
+		return readFirefoxCookies(filepath.Join(dir, "cookies.sqlite"))
+	}
+	base, err := chromiumDir(browser)
+	if err != nil {
+		return nil, err
+	}
+	dir := profile
+	if dir == "" {
+		dir = "Default"
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(base, dir)
+	}
+	path := filepath.Join(dir, "Network", "Cookies")
+	if _, err := os.Stat(path); err != nil {
+		// Older versions store cookies in the profile directory.
+		path = filepath.Join(dir, "Cookies")
+	}
+	return readChromiumCookies(path)
+}
+
+// isSessionCookie returns whether a cookie with the given name and host is a
+// session cookie.
+func isSessionCookie(name, host string) bool {
+	host = strings.TrimPrefix(host, ".")
+	return name == SessionCookieName && (host == "roblox.com" || strings.HasSuffix(host, ".roblox.com"))
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Firefox
+
+// firefoxDir returns the directory containing Firefox profiles.
+func firefoxDir() (string, error) {
+	switch runtime.GOOS {
+	case "windows":
+		return filepath.Join(os.Getenv("APPDATA"), "Mozilla", "Firefox"), nil
+	case "darwin":
+		home, err := os.UserHomeDir()
+		return filepath.Join(home, "Library", "Application Support", "Firefox"), err
+	default:
+		home, err := os.UserHomeDir()
+		return filepath.Join(home, ".mozilla", "firefox"), err
+	}
+}
+
+// firefoxProfile returns the directory of the given Firefox profile.
+func firefoxProfile(profile string) (string, error) {
+	if profile != "" {
+		if info, err := os.Stat(profile); err == nil && info.IsDir() {
+			return profile, nil
+		}
+	}
+	base, err := firefoxDir()
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(filepath.Join(base, "profiles.ini"))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	// Parse sections of profiles.ini.
+	type section struct {
+		name string
+		keys map[string]string
+	}
+	var sections []*section
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			sections = append(sections, &section{name: line[1 : len(line)-1], keys: map[string]string{}})
+		case len(sections) > 0:
+			if i := strings.IndexByte(line, '='); i >= 0 {
+				sections[len(sections)-1].keys[line[:i]] = line[i+1:]
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	resolve := func(keys map[string]string) string {
+		path := filepath.FromSlash(keys["Path"])
+		if keys["IsRelative"] != "0" {
+			path = filepath.Join(base, path)
+		}
+		return path
+	}
+	var fallback string
+	for _, s := range sections {
+		if !strings.HasPrefix(s.name, "Profile") {
+			continue
+		}
+		path := resolve(s.keys)
+		if profile != "" {
+			if s.keys["Name"] == profile || filepath.Base(path) == profile {
+				return path, nil
+			}
+			continue
+		}
+		if s.keys["Default"] == "1" || fallback == "" {
+			fallback = path
+		}
+	}
+	if profile == "" {
+		// The default profile of an installation takes precedence.
+		for _, s := range sections {
+			if strings.HasPrefix(s.name, "Install") && s.keys["Default"] != "" {
+				return resolve(map[string]string{"Path": s.keys["Default"]}), nil
+			}
+		}
+		if fallback != "" {
+			return fallback, nil
+		}
+	}
+	return "", fmt.Errorf("firefox profile %q not found", profile)
+}
+
+// readFirefoxCookies reads session cookies from a Firefox cookie database.
+func readFirefoxCookies(path string) (cookies []*http.Cookie, err error) {
+	db, err := sqlite.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.Rows("moz_cookies")
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		name, _ := row["name"].(string)
+		host, _ := row["host"].(string)
+		if !isSessionCookie(name, host) {
+			continue
+		}
+		cookie := &http.Cookie{
+			Name:     name,
+			Domain:   host,
+			HttpOnly: row["isHttpOnly"] == int64(1),
+			Secure:   row["isSecure"] == int64(1),
+		}
+		cookie.Value, _ = row["value"].(string)
+		cookie.Path, _ = row["path"].(string)
+		if expiry, ok := row["expiry"].(int64); ok && expiry > 0 {
+			if expiry > 1e11 {
+				// Newer versions store milliseconds.
+				cookie.Expires = time.Unix(0, expiry*int64(time.Millisecond))
+			} else {
+				cookie.Expires = time.Unix(expiry, 0)
+			}
+		}
+		cookies = append(cookies, cookie)
+	}
+	if len(cookies) == 0 {
+		return nil, ErrCookieNotFound
+	}
+	return cookies, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Chromium
+
+// chromiumDir returns the user data directory of a Chromium-based browser.
+func chromiumDir(browser string) (string, error) {
+	var dirs map[string][]string
+	var base string
+	var err error
+	switch runtime.GOOS {
+	case "windows":
+		base = os.Getenv("LOCALAPPDATA")
+		dirs = map[string][]string{
+			"chrome":   {"Google", "Chrome", "User Data"},
+			"chromium": {"Chromium", "User Data"},
+			"edge":     {"Microsoft", "Edge", "User Data"},
+			"brave":    {"BraveSoftware", "Brave-Browser", "User Data"},
+		}
+	case "darwin":
+		base, err = os.UserHomeDir()
+		base = filepath.Join(base, "Library", "Application Support")
+		dirs = map[string][]string{
+			"chrome":   {"Google", "Chrome"},
+			"chromium": {"Chromium"},
+			"edge":     {"Microsoft Edge"},
+			"brave":    {"BraveSoftware", "Brave-Browser"},
+		}
+	default:
+		base, err = os.UserConfigDir()
+		dirs = map[string][]string{
+			"chrome":   {"google-chrome"},
+			"chromium": {"chromium"},
+			"edge":     {"microsoft-edge"},
+			"brave":    {"BraveSoftware", "Brave-Browser"},
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	dir, ok := dirs[browser]
+	if !ok {
+		return "", fmt.Errorf("%w %q", ErrUnknownBrowser, browser)
+	}
+	return filepath.Join(append([]string{base}, dir...)...), nil
+}
+
+// chromiumEpoch is the number of seconds between the epoch of timestamps
+// stored by Chromium (1601-01-01) and the Unix epoch.
+const chromiumEpoch = 11644473600
+
+// readChromiumCookies reads session cookies from a Chromium cookie database.
+func readChromiumCookies(path string) (cookies []*http.Cookie, err error) {
+	db, err := sqlite.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.Rows("cookies")
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		name, _ := row["name"].(string)
+		host, _ := row["host_key"].(string)
+		if !isSessionCookie(name, host) {
+			continue
+		}
+		cookie := &http.Cookie{
+			Name:     name,
+			Domain:   host,
+			HttpOnly: row["is_httponly"] == int64(1),
+			Secure:   row["is_secure"] == int64(1),
+		}
+		cookie.Value, _ = row["value"].(string)
+		cookie.Path, _ = row["path"].(string)
+		if cookie.Value == "" {
+			encrypted, _ := row["encrypted_value"].([]byte)
+			if cookie.Value, err = decryptChromium(encrypted); err != nil {
+				return nil, err
+			}
+		}
+		if expires, ok := row["expires_utc"].(int64); ok && expires > 0 {
+			cookie.Expires = time.Unix(expires/1e6-chromiumEpoch, expires%1e6*1e3)
+		}
+		cookies = append(cookies, cookie)
+	}
+	if len(cookies) == 0 {
+		return nil, ErrCookieNotFound
+	}
+	return cookies, nil
+}
+
+// decryptChromium decrypts a cookie value encrypted by Chromium. Only values
+// encrypted with the fixed key used on Linux when no keyring is available are
+// supported.
+func decryptChromium(value []byte) (string, error) {
+	if runtime.GOOS != "linux" || !bytes.HasPrefix(value, []byte("v10")) {
+		return "", ErrEncryptedUnsupported
+	}
+	value = value[3:]
+	if len(value) == 0 || len(value)%aes.BlockSize != 0 {
+		return "", ErrEncryptedUnsupported
+	}
+	key := pbkdf2.Key([]byte("peanuts"), []byte("saltysalt"), 1, 16, sha1.New)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	plain := make([]byte, len(value))
+	cipher.NewCBCDecrypter(block, bytes.Repeat([]byte{' '}, aes.BlockSize)).CryptBlocks(plain, value)
+
+	// Remove PKCS#7 padding.
+	n := int(plain[len(plain)-1])
+	if n == 0 || n > aes.BlockSize || n > len(plain) {
+		return "", ErrEncryptedUnsupported
+	}
+	plain = plain[:len(plain)-n]
+
+	// Newer versions prefix the value with a 32-byte hash of the domain.
+	if len(plain) >= 32 && !printable(plain[:32]) {
+		plain = plain[32:]
+	}
+	if !printable(plain) {
+		// Likely encrypted with a different key.
+		return "", ErrEncryptedUnsupported
+	}
+	return string(plain), nil
+}
+
+// printable returns whether b contains only printable ASCII characters.
+func printable(b []byte) bool {
+	for _, c := range b {
+		if c < 0x20 || c > 0x7E {
+			return false
+		}
+	}
+	return true
+}
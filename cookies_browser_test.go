@@ -0,0 +1,147 @@
+package rbxauth
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The databases under testdata/browser contain the following cookies, along
+// with cookies that are not session cookies:
+//
+//	firefox.sqlite:       two session cookies, with expiry in seconds and in
+//	                      milliseconds.
+//	firefox-empty.sqlite: no session cookie.
+//	chromium.sqlite:      a plain session cookie, and a session cookie
+//	                      encrypted with the fixed "v10" key.
+//	chromium-v11.sqlite:  a session cookie encrypted with a keyring key.
+
+// checkBrowserCookies compares cookies against want.
+func checkBrowserCookies(t *testing.T, cookies, want []*http.Cookie) {
+	t.Helper()
+	if len(cookies) != len(want) {
+		t.Fatalf("expected %d cookies, got %d", len(want), len(cookies))
+	}
+	for i, c := range cookies {
+		w := want[i]
+		if c.Name != w.Name || c.Value != w.Value || c.Domain != w.Domain || c.Path != w.Path ||
+			c.Secure != w.Secure || c.HttpOnly != w.HttpOnly || !c.Expires.Equal(w.Expires) {
+			t.Errorf("cookie %d: expected %+v, got %+v", i, w, c)
+		}
+	}
+}
+
+func TestReadFirefoxCookies(t *testing.T) {
+	cookies, err := readFirefoxCookies(filepath.Join("testdata", "browser", "firefox.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBrowserCookies(t, cookies, []*http.Cookie{
+		{
+			Name:     SessionCookieName,
+			Value:    "_|WARNING:-DO-NOT-SHARE-THIS.--firefox",
+			Domain:   ".roblox.com",
+			Path:     "/",
+			Secure:   true,
+			HttpOnly: true,
+			Expires:  time.Unix(1893456000, 0),
+		},
+		{
+			Name:    SessionCookieName,
+			Value:   "_|WARNING:-DO-NOT-SHARE-THIS.--millis",
+			Domain:  "www.roblox.com",
+			Path:    "/",
+			Expires: time.Unix(1893456000, 123*int64(time.Millisecond)),
+		},
+	})
+}
+
+func TestReadChromiumCookies(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("fixed-key decryption is only supported on linux")
+	}
+	cookies, err := readChromiumCookies(filepath.Join("testdata", "browser", "chromium.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBrowserCookies(t, cookies, []*http.Cookie{
+		{
+			Name:     SessionCookieName,
+			Value:    "_|WARNING:-DO-NOT-SHARE-THIS.--chromium",
+			Domain:   ".roblox.com",
+			Path:     "/",
+			Secure:   true,
+			HttpOnly: true,
+			Expires:  time.Unix(1893456000, 500000*1e3),
+		},
+		{
+			Name:   SessionCookieName,
+			Value:  "_|WARNING:-DO-NOT-SHARE-THIS.--encrypted",
+			Domain: "www.roblox.com",
+			Path:   "/",
+			Secure: true,
+		},
+	})
+}
+
+func TestReadBrowserCookiesErrors(t *testing.T) {
+	if _, err := readFirefoxCookies(filepath.Join("testdata", "browser", "firefox-empty.sqlite")); !errors.Is(err, ErrCookieNotFound) {
+		t.Errorf("firefox: expected ErrCookieNotFound, got %v", err)
+	}
+	if _, err := readChromiumCookies(filepath.Join("testdata", "browser", "chromium-v11.sqlite")); !errors.Is(err, ErrEncryptedUnsupported) {
+		t.Errorf("chromium: expected ErrEncryptedUnsupported, got %v", err)
+	}
+	// The tables of one browser are not present in the database of another.
+	if _, err := readChromiumCookies(filepath.Join("testdata", "browser", "firefox.sqlite")); err == nil {
+		t.Error("chromium reading firefox database: expected error")
+	}
+	if _, err := readFirefoxCookies(filepath.Join("testdata", "browser", "missing.sqlite")); !os.IsNotExist(err) {
+		t.Errorf("missing: expected not-exist error, got %v", err)
+	}
+}
+
+// copyFixture copies a database from testdata/browser to path.
+func copyFixture(t *testing.T, name, path string) {
+	t.Helper()
+	b, err := ioutil.ReadFile(filepath.Join("testdata", "browser", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImportBrowserCookiesProfile(t *testing.T) {
+	dir := t.TempDir()
+	copyFixture(t, "firefox.sqlite", filepath.Join(dir, "firefox", "cookies.sqlite"))
+	cookies, err := ImportBrowserCookies("Firefox", filepath.Join(dir, "firefox"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cookies) != 2 {
+		t.Errorf("firefox: expected 2 cookies, got %d", len(cookies))
+	}
+
+	// Older Chromium versions store cookies directly in the profile directory.
+	copyFixture(t, "chromium-v11.sqlite", filepath.Join(dir, "chrome", "Cookies"))
+	_, err = ImportBrowserCookies("chrome", filepath.Join(dir, "chrome"))
+	if !errors.Is(err, ErrEncryptedUnsupported) {
+		t.Errorf("chrome: expected ErrEncryptedUnsupported, got %v", err)
+	} else if !strings.HasPrefix(err.Error(), "import cookies: ") {
+		t.Errorf("chrome: expected prefix, got %q", err)
+	}
+
+	if _, err := ImportBrowserCookies("lynx", ""); !errors.Is(err, ErrUnknownBrowser) {
+		t.Errorf("lynx: expected ErrUnknownBrowser, got %v", err)
+	}
+}
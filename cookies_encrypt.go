@@ -0,0 +1,213 @@
+package rbxauth
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"unicode"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// These errors are returned when reading encrypted cookies.
+var (
+	// ErrDecrypt is returned when encrypted cookies cannot be authenticated,
+	// either because the passphrase is incorrect, or because the data was
+	// modified.
+	ErrDecrypt = errors.New("incorrect passphrase or corrupted data")
+	// ErrPassphraseRequired is returned when cookies are encrypted, but no
+	// passphrase is available.
+	ErrPassphraseRequired = errors.New("passphrase required")
+)
+
+// encMagic begins the header of encrypted cookies.
+const encMagic = "RBXAUTHENC"
+
+// encVersion is the version of the encrypted format.
+//
+// Version 1 has the following header, in which integers are big-endian:
+//
+//	magic   [10]byte // encMagic
+//	version uint8    // 1
+//	time    uint32   // Argon2id passes.
+//	memory  uint32   // Argon2id memory, in KiB.
+//	threads uint8    // Argon2id parallelism.
+//	salt    [16]byte
+//	nonce   [24]byte
+//
+// The header is followed by the cookies in the headers format, sealed with
+// XChaCha20-Poly1305. The header is authenticated as additional data.
+const encVersion = 1
+
+const (
+	encSaltSize   = 16
+	encHeaderSize = len(encMagic) + 1 + 4 + 4 + 1 + encSaltSize + chacha20poly1305.NonceSizeX
+
+	// Parameters used when writing.
+	encTime    = 3
+	encMemory  = 64 * 1024
+	encThreads = 4
+
+	// Limits of parameters accepted when reading, guarding against
+	// excessive resource use by a crafted header.
+	encMaxTime   = 16
+	encMaxMemory = encMemory * 4
+)
+
+// encKey derives a key from passphrase.
+func encKey(passphrase, salt []byte, time, memory uint32, threads uint8) []byte {
+	return argon2.IDKey(passphrase, salt, time, memory, threads, chacha20poly1305.KeySize)
+}
+
+// WriteCookiesEncrypted formats a list of cookies as with WriteCookies,
+// encrypting the result with a key derived from passphrase.
+func WriteCookiesEncrypted(w io.Writer, cookies []*http.Cookie, passphrase []byte) (err error) {
+	defer wrapOp("write cookies", &err)
+
+	var plain bytes.Buffer
+	if err := WriteCookies(&plain, cookies); err != nil {
+		return err
+	}
+	defer wipe(plain.Bytes())
+
+	header := make([]byte, encHeaderSize)
+	n := copy(header, encMagic)
+	header[n] = encVersion
+	n++
+	binary.BigEndian.PutUint32(header[n:], encTime)
+	n += 4
+	binary.BigEndian.PutUint32(header[n:], encMemory)
+	n += 4
+	header[n] = encThreads
+	n++
+	if _, err := io.ReadFull(rand.Reader, header[n:]); err != nil {
+		return err
+	}
+	salt := header[n : n+encSaltSize]
+	nonce := header[n+encSaltSize:]
+
+	key := encKey(passphrase, salt, encTime, encMemory, encThreads)
+	defer wipe(key)
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return err
+	}
+	out := aead.Seal(header, nonce, plain.Bytes(), header)
+	_, err = w.Write(out)
+	return err
+}
+
+// ReadCookiesEncrypted parses cookies written by WriteCookiesEncrypted, using
+// passphrase to decrypt them. Returns an error matching ErrDecrypt if the
+// passphrase is incorrect or the data has been modified.
+func ReadCookiesEncrypted(r io.Reader, passphrase []byte) (cookies []*http.Cookie, err error) {
+	defer wrapOp("read cookies", &err)
+
+	data, err := ioutil.ReadAll(limitCookies(r))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < encHeaderSize || string(data[:len(encMagic)]) != encMagic {
+		return nil, fmt.Errorf("%w: missing encryption header", ErrBadFormat)
+	}
+	n := len(encMagic)
+	if version := data[n]; version != encVersion {
+		return nil, fmt.Errorf("unsupported encryption version %d", version)
+	}
+	n++
+	time := binary.BigEndian.Uint32(data[n:])
+	n += 4
+	memory := binary.BigEndian.Uint32(data[n:])
+	n += 4
+	threads := data[n]
+	n++
+	if time == 0 || time > encMaxTime || memory == 0 || memory > encMaxMemory || threads == 0 {
+		return nil, ErrDecrypt
+	}
+	salt := data[n : n+encSaltSize]
+	nonce := data[n+encSaltSize : encHeaderSize]
+
+	key := encKey(passphrase, salt, time, memory, threads)
+	defer wipe(key)
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := aead.Open(nil, nonce, data[encHeaderSize:], data[:encHeaderSize])
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	defer wipe(plain)
+	return readCookies(bytes.NewReader(plain), nil)
+}
+
+// ReadCookiesAutoPassphrase is like ReadCookiesAuto, additionally detecting
+// cookies written by WriteCookiesEncrypted. If the cookies are encrypted, then
+// passphrase is called to get the passphrase used to decrypt them. The
+// returned passphrase is wiped after use.
+func ReadCookiesAutoPassphrase(r io.Reader, passphrase func() ([]byte, error)) (cookies []*http.Cookie, err error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(len(encMagic)); IsCookiesEncrypted(magic) {
+		if passphrase == nil {
+			return nil, fmt.Errorf("read cookies: %w", ErrPassphraseRequired)
+		}
+		p, err := passphrase()
+		if err != nil {
+			return nil, fmt.Errorf("read cookies: %w", err)
+		}
+		defer wipe(p)
+		return ReadCookiesEncrypted(br, p)
+	}
+	for {
+		c, _, err := br.ReadRune()
+		if err == io.EOF {
+			return []*http.Cookie{}, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("read cookies: %w", err)
+		}
+		if unicode.IsSpace(c) {
+			continue
+		}
+		br.UnreadRune()
+		if c == '[' {
+			return ReadCookiesJSON(br)
+		}
+		return ReadCookies(br)
+	}
+}
+
+// TerminalPassphrase prompts for a passphrase on stderr, reading it from stdin
+// without echoing. Returns ErrPassphraseRequired if stdin is not a terminal.
+func TerminalPassphrase() ([]byte, error) {
+	return TerminalPassphraseOpts(nil)
+}
+
+// TerminalPassphraseOpts is like TerminalPassphrase, with the read configured
+// by opts. A nil opts is the same as the zero value.
+func TerminalPassphraseOpts(opts *TerminalOptions) ([]byte, error) {
+	fd, ok := terminalFd(os.Stdin)
+	if !ok {
+		return nil, ErrPassphraseRequired
+	}
+	if opts == nil {
+		opts = &TerminalOptions{}
+	}
+	os.Stderr.WriteString("Enter passphrase: ")
+	b, err := readPassword(fd, *opts)
+	os.Stderr.WriteString("\n")
+	return b, err
+}
+
+// IsCookiesEncrypted returns whether b begins with the header written by
+// WriteCookiesEncrypted.
+func IsCookiesEncrypted(b []byte) bool {
+	return bytes.HasPrefix(b, []byte(encMagic))
+}
@@ -0,0 +1,145 @@
+package rbxauth
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// sealCookies encrypts plain as WriteCookiesEncrypted would, but with the
+// given Argon2id parameters.
+func sealCookies(t *testing.T, plain, passphrase []byte, time, memory uint32, threads uint8) []byte {
+	t.Helper()
+	header := make([]byte, encHeaderSize)
+	n := copy(header, encMagic)
+	header[n] = encVersion
+	n++
+	binary.BigEndian.PutUint32(header[n:], time)
+	n += 4
+	binary.BigEndian.PutUint32(header[n:], memory)
+	n += 4
+	header[n] = threads
+	n++
+	rand.Read(header[n:])
+	aead, err := chacha20poly1305.NewX(encKey(passphrase, header[n:n+encSaltSize], time, memory, threads))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return aead.Seal(header, header[n+encSaltSize:], plain, header)
+}
+
+func TestCookiesEncryptedRoundTrip(t *testing.T) {
+	cookies := []*http.Cookie{
+		{Name: ".ROBLOSECURITY", Value: "secret", Path: "/", HttpOnly: true},
+		{Name: "other", Value: "1"},
+	}
+	var buf bytes.Buffer
+	if err := WriteCookiesEncrypted(&buf, cookies, []byte("passphrase")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	data := buf.Bytes()
+	if !IsCookiesEncrypted(data) {
+		t.Error("output is not recognized as encrypted")
+	}
+	if bytes.Contains(data, []byte("secret")) {
+		t.Error("output contains plaintext")
+	}
+
+	got, err := ReadCookiesEncrypted(bytes.NewReader(data), []byte("passphrase"))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if len(got) != len(cookies) {
+		t.Fatalf("expected %d cookies, got %d", len(cookies), len(got))
+	}
+	for i, c := range got {
+		if c.Name != cookies[i].Name || c.Value != cookies[i].Value {
+			t.Errorf("cookie %d: expected %s=%s, got %s=%s", i, cookies[i].Name, cookies[i].Value, c.Name, c.Value)
+		}
+	}
+
+	got, err = ReadCookiesAutoPassphrase(bytes.NewReader(data), func() ([]byte, error) {
+		return []byte("passphrase"), nil
+	})
+	if err != nil || len(got) != len(cookies) {
+		t.Errorf("auto: expected %d cookies, got %d: %v", len(cookies), len(got), err)
+	}
+	if _, err := ReadCookiesAutoPassphrase(bytes.NewReader(data), nil); !errors.Is(err, ErrPassphraseRequired) {
+		t.Errorf("auto: expected ErrPassphraseRequired, got %v", err)
+	}
+}
+
+func TestCookiesEncryptedInvalid(t *testing.T) {
+	plain := []byte("Set-Cookie: a=1\n")
+	data := sealCookies(t, plain, []byte("passphrase"), 1, 8, 1)
+	if _, err := ReadCookiesEncrypted(bytes.NewReader(data), []byte("passphrase")); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	corrupt := func(i int) []byte {
+		b := append([]byte(nil), data...)
+		b[i] ^= 1
+		return b
+	}
+	// Offsets of the Argon2id parameters in the header.
+	const timeAt, memoryAt, threadsAt = len(encMagic) + 1, len(encMagic) + 5, len(encMagic) + 9
+	param := func(i int, v uint32) []byte {
+		b := append([]byte(nil), data...)
+		if i == threadsAt {
+			b[i] = byte(v)
+		} else {
+			binary.BigEndian.PutUint32(b[i:], v)
+		}
+		return b
+	}
+	for _, test := range []struct {
+		name       string
+		data       []byte
+		passphrase string
+		err        error
+	}{
+		{"wrong passphrase", data, "wrong", ErrDecrypt},
+		{"corrupted ciphertext", corrupt(len(data) - 1), "passphrase", ErrDecrypt},
+		{"corrupted salt", corrupt(encHeaderSize - chacha20poly1305.NonceSizeX - 1), "passphrase", ErrDecrypt},
+		{"truncated", data[:len(data)-1], "passphrase", ErrDecrypt},
+		{"header only", data[:encHeaderSize-1], "passphrase", ErrBadFormat},
+		{"not encrypted", plain, "passphrase", ErrBadFormat},
+		{"zero time", param(timeAt, 0), "passphrase", ErrDecrypt},
+		{"excessive time", param(timeAt, encMaxTime+1), "passphrase", ErrDecrypt},
+		{"zero memory", param(memoryAt, 0), "passphrase", ErrDecrypt},
+		{"excessive memory", param(memoryAt, encMaxMemory+1), "passphrase", ErrDecrypt},
+		{"zero threads", param(threadsAt, 0), "passphrase", ErrDecrypt},
+	} {
+		_, err := ReadCookiesEncrypted(bytes.NewReader(test.data), []byte(test.passphrase))
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.err, err)
+		}
+	}
+}
+
+func TestCookiesEncryptedMemoryLimit(t *testing.T) {
+	// A crafted header must not be able to force a large allocation.
+	if encMaxMemory > 256*1024 {
+		t.Errorf("memory limit of %d KiB exceeds 256 MiB", encMaxMemory)
+	}
+	if encMaxMemory < encMemory {
+		t.Errorf("memory limit of %d KiB is less than the %d KiB used when writing", encMaxMemory, encMemory)
+	}
+}
+
+func TestCookiesEncryptedErrorWrap(t *testing.T) {
+	data := sealCookies(t, []byte("bad\n"), []byte("passphrase"), 1, 8, 1)
+	_, err := ReadCookiesEncrypted(bytes.NewReader(data), []byte("passphrase"))
+	if !errors.Is(err, ErrBadFormat) {
+		t.Fatalf("expected ErrBadFormat, got %v", err)
+	}
+	if n := strings.Count(err.Error(), "read cookies"); n != 1 {
+		t.Errorf("operation appears %d times in %q", n, err)
+	}
+}
@@ -0,0 +1,114 @@
+package rbxauth
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+// The seed corpus of each target is in testdata/fuzz.
+
+// cookieList formats the name and value of each cookie for comparison.
+func cookieList(cookies []*http.Cookie) string {
+	var list []string
+	for _, c := range cookies {
+		list = append(list, c.Name+"="+c.Value)
+	}
+	return strings.Join(list, " ")
+}
+
+func FuzzReadCookies(f *testing.F) {
+	f.Fuzz(func(t *testing.T, data []byte) {
+		cookies, err := ReadCookies(bytes.NewReader(data))
+		if err != nil {
+			if !errors.Is(err, ErrBadFormat) && !errors.Is(err, ErrNoCookies) && !errors.Is(err, ErrCookiesTooLarge) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			return
+		}
+		if cookies == nil {
+			t.Fatal("expected non-nil list")
+		}
+		var buf bytes.Buffer
+		if err := WriteCookies(&buf, cookies); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		again, err := ReadCookies(&buf)
+		if err != nil {
+			t.Fatalf("read written cookies: %v\n%s", err, buf.Bytes())
+		}
+		if a, b := cookieList(cookies), cookieList(again); a != b {
+			t.Errorf("round trip: expected %q, got %q", a, b)
+		}
+	})
+}
+
+func FuzzReadCookiesJSON(f *testing.F) {
+	f.Fuzz(func(t *testing.T, data []byte) {
+		cookies, err := ReadCookiesJSON(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		var buf bytes.Buffer
+		if err := WriteCookiesJSON(&buf, cookies); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		again, err := ReadCookiesJSON(&buf)
+		if err != nil {
+			t.Fatalf("read written cookies: %v\n%s", err, buf.Bytes())
+		}
+		if len(again) != len(cookies) {
+			t.Fatalf("round trip: expected %d cookies, got %d", len(cookies), len(again))
+		}
+		for i, c := range cookies {
+			d := again[i]
+			if c.Name != d.Name || c.Value != d.Value || c.Domain != d.Domain || c.Path != d.Path ||
+				c.MaxAge != d.MaxAge || c.Secure != d.Secure || c.HttpOnly != d.HttpOnly ||
+				c.SameSite != d.SameSite || !c.Expires.Equal(d.Expires) {
+				t.Errorf("round trip of cookie %d: expected %+v, got %+v", i, *c, *d)
+			}
+		}
+	})
+}
+
+func FuzzReadCookiesAuto(f *testing.F) {
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// Without a passphrase, encrypted input is rejected before any key
+		// derivation.
+		cookies, err := ReadCookiesAutoPassphrase(bytes.NewReader(data), nil)
+		if IsCookiesEncrypted(data) {
+			if !errors.Is(err, ErrPassphraseRequired) {
+				t.Fatalf("encrypted: expected ErrPassphraseRequired, got %v", err)
+			}
+			return
+		}
+
+		// The result matches that of the detected format.
+		var want []*http.Cookie
+		var wantErr error
+		trimmed := bytes.TrimLeftFunc(data, unicode.IsSpace)
+		switch {
+		case len(trimmed) == 0:
+			want = []*http.Cookie{}
+		case trimmed[0] == '[':
+			want, wantErr = ReadCookiesJSON(bytes.NewReader(trimmed))
+		default:
+			want, wantErr = ReadCookies(bytes.NewReader(trimmed))
+		}
+		if (err == nil) != (wantErr == nil) {
+			t.Fatalf("expected error %v, got %v", wantErr, err)
+		}
+		if err != nil {
+			return
+		}
+		if cookies == nil {
+			t.Fatal("expected non-nil list")
+		}
+		if a, b := cookieList(want), cookieList(cookies); a != b {
+			t.Errorf("expected cookies %q, got %q", a, b)
+		}
+	})
+}
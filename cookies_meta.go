@@ -0,0 +1,98 @@
+package rbxauth
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers that hold the fields of a CookieMeta.
+const (
+	metaAccount = "X-Rbxauth-Account"
+	metaUserID  = "X-Rbxauth-User-Id"
+	metaCreated = "X-Rbxauth-Created"
+	metaHost    = "X-Rbxauth-Host"
+)
+
+// CookieMeta describes the origin of a list of cookies, so that a cookie file
+// can be identified long after it was written. Each field is optional.
+type CookieMeta struct {
+	// Account is the name of the account that was logged in.
+	Account string
+	// UserID is the ID of the account.
+	UserID int64
+	// Created is the time at which the cookies were received.
+	Created time.Time
+	// Host is the host of the authentication endpoint that issued the
+	// cookies.
+	Host string
+}
+
+// header returns meta as a number of headers, omitting zero fields.
+func (meta CookieMeta) header() http.Header {
+	h := http.Header{}
+	if meta.Account != "" {
+		h.Set(metaAccount, meta.Account)
+	}
+	if meta.UserID != 0 {
+		h.Set(metaUserID, strconv.FormatInt(meta.UserID, 10))
+	}
+	if !meta.Created.IsZero() {
+		h.Set(metaCreated, meta.Created.UTC().Format(time.RFC3339))
+	}
+	if meta.Host != "" {
+		h.Set(metaHost, meta.Host)
+	}
+	return h
+}
+
+// set sets the field corresponding to the header name to value. Headers that
+// do not correspond to a field are ignored. Returns false if value is
+// malformed.
+func (meta *CookieMeta) set(name, value string) bool {
+	switch {
+	case strings.EqualFold(name, metaAccount):
+		meta.Account = value
+	case strings.EqualFold(name, metaUserID):
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return false
+		}
+		meta.UserID = id
+	case strings.EqualFold(name, metaCreated):
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return false
+		}
+		meta.Created = t
+	case strings.EqualFold(name, metaHost):
+		meta.Host = value
+	}
+	return true
+}
+
+// WriteCookiesWithMeta is like WriteCookies, preceding the cookies with the
+// fields of meta, written as "X-Rbxauth-*" HTTP headers. Because ReadCookies
+// ignores headers other than Set-Cookie, the result can still be read by
+// ReadCookies.
+func WriteCookiesWithMeta(w io.Writer, cookies []*http.Cookie, meta CookieMeta) (err error) {
+	if err = meta.header().Write(w); err != nil {
+		return fmt.Errorf("write cookies: %w", err)
+	}
+	return WriteCookies(w, cookies)
+}
+
+// ReadCookiesWithMeta is like ReadCookies, additionally returning the metadata
+// written by WriteCookiesWithMeta. Fields missing from the input are zero.
+// Returns a *CookieFormatError if a metadata header is malformed.
+func ReadCookiesWithMeta(r io.Reader) (cookies []*http.Cookie, meta CookieMeta, err error) {
+	defer wrapOp("read cookies", &err)
+	cookies, err = readCookies(r, &meta)
+	if err != nil {
+		return nil, CookieMeta{}, err
+	}
+	return cookies, meta, nil
+}
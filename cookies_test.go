@@ -0,0 +1,440 @@
+package rbxauth
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadCookies(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		input string
+		// Name=Value of each cookie, separated by spaces.
+		cookies string
+		err     error
+	}{
+		{"empty", "", "", nil},
+		{"blank lines", "\n\r\n  \n", "", nil},
+		{"header", "Set-Cookie: a=1; Path=/\n", "a=1", nil},
+		{"header case", "set-cookie: a=1\n", "a=1", nil},
+		{"bare", "a=1; Path=/; HttpOnly\nb=2\n", "a=1 b=2", nil},
+		{"mixed", "Set-Cookie: a=1\nb=2\n", "a=1 b=2", nil},
+		{"LF", "Set-Cookie: a=1\nSet-Cookie: b=2\n", "a=1 b=2", nil},
+		{"CRLF", "Set-Cookie: a=1\r\nSet-Cookie: b=2\r\n", "a=1 b=2", nil},
+		{"no final newline", "Set-Cookie: a=1\r\nSet-Cookie: b=2", "a=1 b=2", nil},
+		{"folded space", "Set-Cookie: a=1;\n Path=/\n", "a=1", nil},
+		{"folded tab", "Set-Cookie:\r\n\ta=1\r\n", "a=1", nil},
+		{"other headers", "Content-Type: text/plain\nSet-Cookie: a=1\n", "a=1", nil},
+		{"only other headers", "Content-Type: text/plain\nX-Other: 1\n", "", ErrNoCookies},
+		{"JSON", `[{"name":"a","value":"1"}]`, "", ErrBadFormat},
+		{"JSON object", "{\n  \"name\": \"a\"\n}\n", "", ErrBadFormat},
+		{"garbage", "\x00\x01\x02\xff\xfe", "", ErrBadFormat},
+		{"bad header name", "Set Cookie: a=1\n", "", ErrBadFormat},
+		{"bad cookie", "Set-Cookie: =1\n", "", ErrBadFormat},
+		{"too large", strings.Repeat("a", MaxCookiesSize+1), "", ErrCookiesTooLarge},
+		{"too large lines", strings.Repeat("Set-Cookie: a=1\n", MaxCookiesSize/16+1), "", ErrCookiesTooLarge},
+	} {
+		cookies, err := ReadCookies(strings.NewReader(test.input))
+		if test.err != nil {
+			if !errors.Is(err, test.err) {
+				t.Errorf("%s: expected error %v, got %v", test.name, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if cookies == nil {
+			t.Errorf("%s: expected non-nil list", test.name)
+		}
+		var list []string
+		for _, c := range cookies {
+			list = append(list, c.Name+"="+c.Value)
+		}
+		if got := strings.Join(list, " "); got != test.cookies {
+			t.Errorf("%s: expected cookies %q, got %q", test.name, test.cookies, got)
+		}
+	}
+}
+
+func TestCookieFormatErrorLine(t *testing.T) {
+	_, err := ReadCookies(strings.NewReader("Set-Cookie: a=1\n\nSet-Cookie: b=2;\n Path=/\nbad\n"))
+	var ferr *CookieFormatError
+	if !errors.As(err, &ferr) {
+		t.Fatalf("expected CookieFormatError, got %v", err)
+	}
+	if ferr.Line != 5 || ferr.Text != "bad" {
+		t.Errorf("unexpected error %+v", *ferr)
+	}
+}
+
+func TestCookieFormatErrorTruncate(t *testing.T) {
+	for n := 0; n < 4; n++ {
+		// Place a multi-byte rune across the truncation point.
+		text := strings.Repeat("a", 30+n) + strings.Repeat("世", 10)
+		msg := (&CookieFormatError{Line: 1, Text: text}).Error()
+		// Quoting escapes a partial rune.
+		if strings.Contains(msg, `\x`) {
+			t.Errorf("offset %d: partial rune in %s", n, msg)
+		}
+		if !strings.Contains(msg, "...") {
+			t.Errorf("offset %d: text was not truncated: %q", n, msg)
+		}
+	}
+	msg := (&CookieFormatError{Line: 1, Text: "short"}).Error()
+	if strings.Contains(msg, "...") {
+		t.Errorf("short text was truncated: %q", msg)
+	}
+}
+
+func TestCookiesJSONRoundTrip(t *testing.T) {
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.FixedZone("", -7*60*60))
+	for _, test := range []struct {
+		name    string
+		cookies []*http.Cookie
+	}{
+		{"empty", []*http.Cookie{}},
+		{"nil", nil},
+		{"attributes", []*http.Cookie{{
+			Name:     ".ROBLOSECURITY",
+			Value:    "_|WARNING:-DO-NOT-SHARE-THIS.|_ABC",
+			Domain:   ".roblox.com",
+			Path:     "/",
+			Expires:  expires,
+			MaxAge:   3600,
+			Secure:   true,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		}}},
+		{"special characters", []*http.Cookie{
+			{Name: "quote", Value: `a"b\c`},
+			{Name: "markup", Value: "<a href='x'>&amp;</a>"},
+			{Name: "unicode", Value: "世界 \t"},
+			{Name: "empty", Value: ""},
+		}},
+		{"no expiry", []*http.Cookie{{Name: "a", Value: "1", SameSite: http.SameSiteStrictMode}}},
+	} {
+		var buf bytes.Buffer
+		if err := WriteCookiesJSON(&buf, test.cookies); err != nil {
+			t.Errorf("%s: write: %v", test.name, err)
+			continue
+		}
+		cookies, err := ReadCookiesJSON(&buf)
+		if err != nil {
+			t.Errorf("%s: read: %v", test.name, err)
+			continue
+		}
+		if cookies == nil {
+			t.Errorf("%s: expected non-nil list", test.name)
+		}
+		if len(cookies) != len(test.cookies) {
+			t.Errorf("%s: expected %d cookies, got %d", test.name, len(test.cookies), len(cookies))
+			continue
+		}
+		for i, c := range test.cookies {
+			d := cookies[i]
+			if c.Name != d.Name || c.Value != d.Value || c.Domain != d.Domain || c.Path != d.Path ||
+				c.MaxAge != d.MaxAge || c.Secure != d.Secure || c.HttpOnly != d.HttpOnly ||
+				c.SameSite != d.SameSite || !c.Expires.Equal(d.Expires) {
+				t.Errorf("%s: cookie %d: expected %+v, got %+v", test.name, i, *c, *d)
+			}
+		}
+	}
+}
+
+func TestWriteSecurityToken(t *testing.T) {
+	for _, test := range []struct {
+		token string
+		// Expected output of each format.
+		raw, env, dotenv string
+	}{
+		{
+			"_|WARNING:-DO-NOT-SHARE-THIS.--ABC123",
+			"_|WARNING:-DO-NOT-SHARE-THIS.--ABC123\n",
+			"export ROBLOSECURITY='_|WARNING:-DO-NOT-SHARE-THIS.--ABC123'\n",
+			"ROBLOSECURITY=_|WARNING:-DO-NOT-SHARE-THIS.--ABC123\n",
+		},
+		{
+			`it's "quoted"`,
+			"it's \"quoted\"\n",
+			`export ROBLOSECURITY='it'\''s "quoted"'` + "\n",
+			`ROBLOSECURITY="it's \"quoted\""` + "\n",
+		},
+		{
+			`''$HOME\`,
+			"''$HOME\\\n",
+			`export ROBLOSECURITY=''\'''\''$HOME\'` + "\n",
+			`ROBLOSECURITY="''\$HOME\\"` + "\n",
+		},
+	} {
+		cookies := []*http.Cookie{{Name: "other", Value: "1"}, {Name: SessionCookieName, Value: test.token}}
+		for format, want := range map[string]string{"raw": test.raw, "env": test.env, "dotenv": test.dotenv} {
+			var buf bytes.Buffer
+			if err := WriteSecurityToken(&buf, cookies, format); err != nil {
+				t.Errorf("%q %s: %v", test.token, format, err)
+				continue
+			}
+			if buf.String() != want {
+				t.Errorf("%q %s: expected %q, got %q", test.token, format, want, buf.String())
+			}
+		}
+	}
+}
+
+func TestWriteSecurityTokenShell(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no shell")
+	}
+	for _, token := range []string{`it's "quoted"`, `''$HOME\`, "a'b\nc", "`id`"} {
+		var buf bytes.Buffer
+		if err := WriteSecurityToken(&buf, FromSecurityToken(token), "env"); err != nil {
+			t.Fatal(err)
+		}
+		out, err := exec.Command(sh, "-c", buf.String()+`printf %s "$ROBLOSECURITY"`).Output()
+		if err != nil {
+			t.Errorf("%q: %v", token, err)
+			continue
+		}
+		if string(out) != token {
+			t.Errorf("%q: shell read %q", token, out)
+		}
+	}
+}
+
+func TestWriteSecurityTokenErrors(t *testing.T) {
+	for name, cookies := range map[string][]*http.Cookie{
+		"nil":   nil,
+		"other": {{Name: "other", Value: "1"}},
+		"empty": {{Name: SessionCookieName, Value: ""}},
+	} {
+		var buf bytes.Buffer
+		err := WriteSecurityToken(&buf, cookies, "raw")
+		if !errors.Is(err, ErrNoSecurityCookie) {
+			t.Errorf("%s: expected ErrNoSecurityCookie, got %v", name, err)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("%s: expected no output, got %q", name, buf.String())
+		}
+	}
+	if err := WriteSecurityToken(&bytes.Buffer{}, FromSecurityToken("token"), "json"); err == nil || errors.Is(err, ErrNoSecurityCookie) {
+		t.Errorf("unknown format: expected error, got %v", err)
+	}
+}
+
+func TestCookieMetaRoundTrip(t *testing.T) {
+	cookies := []*http.Cookie{{Name: SessionCookieName, Value: "token", Domain: "roblox.com", Path: "/", HttpOnly: true}, {Name: "other", Value: "1"}}
+	for _, meta := range []CookieMeta{
+		{},
+		{Account: "alice"},
+		{Account: "alice", UserID: 1, Created: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), Host: "auth.roblox.com"},
+		{UserID: -1, Created: time.Date(2020, 1, 2, 3, 4, 5, 0, time.FixedZone("", 7*60*60))},
+	} {
+		var buf bytes.Buffer
+		if err := WriteCookiesWithMeta(&buf, cookies, meta); err != nil {
+			t.Errorf("%+v: write: %v", meta, err)
+			continue
+		}
+		got, gotMeta, err := ReadCookiesWithMeta(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Errorf("%+v: read: %v", meta, err)
+			continue
+		}
+		if gotMeta.Account != meta.Account || gotMeta.UserID != meta.UserID || gotMeta.Host != meta.Host || !gotMeta.Created.Equal(meta.Created) {
+			t.Errorf("expected meta %+v, got %+v", meta, gotMeta)
+		}
+		if len(got) != len(cookies) || got[0].Value != "token" || got[0].Domain != "roblox.com" || !got[0].HttpOnly || got[1].Value != "1" {
+			t.Errorf("%+v: unexpected cookies %v", meta, got)
+		}
+
+		// ReadCookies ignores the metadata.
+		plain, err := ReadCookies(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Errorf("%+v: plain read: %v", meta, err)
+		} else if len(plain) != len(cookies) || plain[0].Value != "token" || plain[1].Value != "1" {
+			t.Errorf("%+v: plain read: unexpected cookies %v", meta, plain)
+		}
+	}
+
+	// Metadata is optional, and names are case-insensitive.
+	_, meta, err := ReadCookiesWithMeta(strings.NewReader("x-rbxauth-account: bob\nSet-Cookie: a=1\n"))
+	if err != nil || meta != (CookieMeta{Account: "bob"}) {
+		t.Errorf("lower case: unexpected meta %+v, %v", meta, err)
+	}
+	_, meta, err = ReadCookiesWithMeta(strings.NewReader("Set-Cookie: a=1\n"))
+	if err != nil || meta != (CookieMeta{}) {
+		t.Errorf("no meta: unexpected meta %+v, %v", meta, err)
+	}
+
+	// A malformed field is reported, but is ignored by ReadCookies.
+	for _, input := range []string{
+		metaUserID + ": alice\nSet-Cookie: a=1\n",
+		metaCreated + ": yesterday\nSet-Cookie: a=1\n",
+	} {
+		var ferr *CookieFormatError
+		if _, _, err := ReadCookiesWithMeta(strings.NewReader(input)); !errors.As(err, &ferr) || ferr.Line != 1 {
+			t.Errorf("%q: expected CookieFormatError on line 1, got %v", input, err)
+		}
+		if _, err := ReadCookies(strings.NewReader(input)); err != nil {
+			t.Errorf("%q: plain read: %v", input, err)
+		}
+	}
+}
+
+func TestCookieAttributesRoundTrip(t *testing.T) {
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	// Each combination of attributes is set by a separate cookie.
+	var headers []string
+	var want []*http.Cookie
+	for i, expiresAttr := range []string{"", expires.Format(http.TimeFormat), expires.Format(time.RFC850)} {
+		for j, sameSite := range []http.SameSite{0, http.SameSiteDefaultMode, http.SameSiteLaxMode, http.SameSiteStrictMode, http.SameSiteNoneMode} {
+			for k := 0; k < 16; k++ {
+				c := &http.Cookie{
+					Name:     fmt.Sprintf("c%d_%d_%d", i, j, k),
+					Value:    "v",
+					Secure:   k&1 != 0,
+					HttpOnly: k&2 != 0,
+					SameSite: sameSite,
+				}
+				header := c.Name + "=v"
+				if k&4 != 0 {
+					c.Domain, c.Path = "roblox.com", "/v1"
+					header += "; Domain=.roblox.com; Path=/v1"
+				}
+				if k&8 != 0 {
+					c.MaxAge = 3600
+					c.Unparsed = []string{"Priority=High"}
+					header += "; Max-Age=3600; Priority=High"
+				}
+				if expiresAttr != "" {
+					c.Expires = expires
+					header += "; Expires=" + expiresAttr
+				}
+				if c.Secure {
+					header += "; Secure"
+				}
+				if c.HttpOnly {
+					header += "; HttpOnly"
+				}
+				switch sameSite {
+				case http.SameSiteDefaultMode:
+					header += "; SameSite"
+				case http.SameSiteLaxMode:
+					header += "; SameSite=Lax"
+				case http.SameSiteStrictMode:
+					header += "; SameSite=Strict"
+				case http.SameSiteNoneMode:
+					header += "; SameSite=None"
+				}
+				headers = append(headers, header)
+				want = append(want, c)
+			}
+		}
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header()["Set-Cookie"] = headers
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"user":{"id":1,"name":"alice"}}`)
+	}))
+	defer srv.Close()
+
+	// check compares the attributes of each cookie to those that were set.
+	check := func(name string, cookies []*http.Cookie) {
+		t.Helper()
+		if len(cookies) != len(want) {
+			t.Fatalf("%s: expected %d cookies, got %d", name, len(want), len(cookies))
+		}
+		for i, w := range want {
+			c := cookies[i]
+			// A leading dot of the domain is insignificant, and is dropped
+			// when written.
+			if c.Name != w.Name || c.Value != w.Value || strings.TrimPrefix(c.Domain, ".") != w.Domain || c.Path != w.Path ||
+				!c.Expires.Equal(w.Expires) || c.MaxAge != w.MaxAge || c.Secure != w.Secure ||
+				c.HttpOnly != w.HttpOnly || c.SameSite != w.SameSite || strings.Join(c.Unparsed, "; ") != strings.Join(w.Unparsed, "; ") {
+				t.Errorf("%s: %s: expected %s, got %s", name, w.Name, formatCookie(w), formatCookie(c))
+			}
+		}
+	}
+
+	cfg := Config{AllowInsecure: true, LoginEndpoint: srv.URL}
+	cookies, _, err := cfg.Login("alice", []byte("pass"))
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	check("login", cookies)
+
+	var buf bytes.Buffer
+	if err := WriteCookies(&buf, cookies); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	read, err := ReadCookies(&buf)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	check("round trip", read)
+}
+
+func TestAsJarCookies(t *testing.T) {
+	cookies := []*http.Cookie{
+		{Name: "host", Value: "1", Raw: "host=1; Priority=High", Unparsed: []string{"Priority=High"}},
+		{Name: "domain", Value: "2", Domain: "roblox.com", Secure: true, HttpOnly: true, SameSite: http.SameSiteLaxMode},
+		{Name: "dot", Value: "3", Domain: ".Roblox.com", Path: "/"},
+		{Name: "other", Value: "4", Domain: "example.com"},
+		{Name: "suffix", Value: "5", Domain: "blox.com"},
+	}
+	u, _ := url.Parse("https://www.roblox.com/home")
+	jar := AsJarCookies(u, cookies)
+	var names []string
+	for _, c := range jar {
+		names = append(names, c.Name)
+		if c.Raw != "" || c.Unparsed != nil {
+			t.Errorf("%s: expected Raw and Unparsed to be cleared", c.Name)
+		}
+	}
+	if want := []string{"host", "domain", "dot"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("expected cookies %v, got %v", want, names)
+	}
+	if c := jar[1]; !c.Secure || !c.HttpOnly || c.SameSite != http.SameSiteLaxMode || c.Domain != "roblox.com" {
+		t.Errorf("expected attributes to be retained, got %+v", *c)
+	}
+	if cookies[0].Raw == "" || cookies[0].Unparsed == nil {
+		t.Error("cookies were modified")
+	}
+
+	// A jar sends each cookie under the same conditions.
+	j, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j.SetCookies(u, jar)
+	for _, test := range []struct {
+		url     string
+		cookies string
+	}{
+		{"https://www.roblox.com/", "host=1 domain=2 dot=3"},
+		{"http://www.roblox.com/", "host=1 dot=3"},
+		{"https://auth.roblox.com/", "domain=2 dot=3"},
+		{"https://example.com/", ""},
+	} {
+		u, _ := url.Parse(test.url)
+		var list []string
+		for _, c := range j.Cookies(u) {
+			list = append(list, c.Name+"="+c.Value)
+		}
+		if got := strings.Join(list, " "); got != test.cookies {
+			t.Errorf("%s: expected cookies %q, got %q", test.url, test.cookies, got)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package rbxauth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"strconv"
+	"time"
+)
+
+// DeviceInfo describes the device from which logins are made. When set as
+// Config.Device, it is included in each login request, which may reduce the
+// friction, such as captchas and forced two-step verification, applied by the
+// API to unrecognized clients.
+type DeviceInfo struct {
+	// Type is the type of device, such as "Desktop" or "Phone".
+	Type string
+	// OS is the operating system of the device.
+	OS string
+	// ID is an identifier of the device, provided by the caller. It should be
+	// stable across logins from the same device.
+	ID string
+
+	// Key is used to sign the secure authentication intent. If nil, the
+	// intent is omitted. The same key should be used across logins from the
+	// same device.
+	Key *ecdsa.PrivateKey
+	// ServerNonce is a nonce issued by the API, included in the signature of
+	// the intent. May be empty.
+	ServerNonce string
+}
+
+// NewDeviceKey generates a key suitable for DeviceInfo.Key.
+func NewDeviceKey() (*ecdsa.PrivateKey, error) {
+	return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+}
+
+// secureAuthIntent returns the secure authentication intent of the device at
+// the given time. Returns nil if the device has no key.
+//
+// The intent consists of the public key of the device, the time as a Unix
+// timestamp, and the server nonce. These are joined by "|", and the SHA-256
+// hash of the result is signed with the key.
+func (d *DeviceInfo) secureAuthIntent(now time.Time) (*secureAuthIntent, error) {
+	if d.Key == nil {
+		return nil, nil
+	}
+	if d.Key.Curve != elliptic.P256() {
+		return nil, errors.New("device key must use the P-256 curve")
+	}
+	pub, err := x509.MarshalPKIXPublicKey(&d.Key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	intent := &secureAuthIntent{
+		ClientPublicKey:      base64.StdEncoding.EncodeToString(pub),
+		ClientEpochTimestamp: now.Unix(),
+		ServerNonce:          d.ServerNonce,
+	}
+	hash := sha256.Sum256([]byte(intent.ClientPublicKey + "|" + strconv.FormatInt(intent.ClientEpochTimestamp, 10) + "|" + intent.ServerNonce))
+	r, s, err := ecdsa.Sign(rand.Reader, d.Key, hash[:])
+	if err != nil {
+		return nil, err
+	}
+	sig, err := asn1.Marshal(struct{ R, S *big.Int }{r, s})
+	if err != nil {
+		return nil, err
+	}
+	intent.SAISignature = base64.StdEncoding.EncodeToString(sig)
+	return intent, nil
+}
+
+// deviceMeta returns the metadata of the device.
+func (d *DeviceInfo) deviceMeta() *deviceMeta {
+	if d.Type == "" && d.OS == "" && d.ID == "" {
+		return nil
+	}
+	return &deviceMeta{DeviceType: d.Type, OS: d.OS, DeviceID: d.ID}
+}
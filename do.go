@@ -0,0 +1,75 @@
+package rbxauth
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"reflect"
+)
+
+// DoJSON sends req to an arbitrary endpoint of the API, with the same
+// conventions used by the other methods of the Config. cookies, such as those
+// of a session, are attached to the request. The CSRF token is sent and
+// renewed as needed, and the request is retried according to MaxRetries. The
+// request body, if any, must be able to be read more than once, or it is
+// buffered.
+//
+// The response body is decoded as JSON into out, which may be nil to discard
+// the body. If the response contains an array of API errors, then the
+// returned error contains each ErrorResponse, regardless of out. A response
+// with an unsuccessful status returns a *StatusError, and a response that is
+// not JSON returns an error describing the content.
+//
+// The response is returned, if received, even when an error occurs. Its body
+// has already been read and closed.
+func (c Config) DoJSON(req *http.Request, cookies []*http.Cookie, out interface{}) (resp *http.Response, err error) {
+	defer wrapOp("do", &err)
+	req = cloneRequest(req)
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", "application/json")
+	}
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	return c.requestAPI("do", req, &doResponse{out: out})
+}
+
+// doResponse decodes a response into an arbitrary value, while also extracting
+// API errors.
+type doResponse struct {
+	out interface{}
+	errorsResponse
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (r *doResponse) UnmarshalJSON(b []byte) error {
+	// The body may not be an object, in which case there are no errors.
+	json.Unmarshal(b, &r.errorsResponse)
+	if r.out == nil {
+		return nil
+	}
+	return json.NewDecoder(bytes.NewReader(b)).Decode(r.out)
+}
+
+// reset implements resetter, retaining the destination of the response.
+func (r *doResponse) reset() {
+	r.errorsResponse = errorsResponse{}
+	resetResponse(r.out)
+}
+
+// resetter is implemented by a response that resets itself before being
+// decoded again.
+type resetter interface {
+	reset()
+}
+
+// resetResponse resets apiResp in case it was decoded by a previous attempt.
+func resetResponse(apiResp interface{}) {
+	if r, ok := apiResp.(resetter); ok {
+		r.reset()
+		return
+	}
+	if v := reflect.ValueOf(apiResp); v.Kind() == reflect.Ptr && !v.IsNil() {
+		v.Elem().Set(reflect.Zero(v.Elem().Type()))
+	}
+}
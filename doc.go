@@ -3,4 +3,30 @@
 //
 // https://auth.roblox.com/docs
 //
+// Small programs can log in with the default endpoints through the
+// package-level helpers, without constructing a Config:
+//
+//	sess, err := rbxauth.LoginInteractive(ctx)
+//	if err != nil {
+//		return err
+//	}
+//	if err := sess.Save("session.cookies"); err != nil {
+//		return err
+//	}
+//
+// A later run resumes the session:
+//
+//	sess, err := rbxauth.LoadSession("session.cookies")
+//
+// Login logs in without prompting, returning a *TwoStepError if a code is
+// required:
+//
+//	sess, err := rbxauth.Login(ctx, username, password)
+//	var tserr *rbxauth.TwoStepError
+//	if errors.As(err, &tserr) {
+//		sess, err = tserr.Step.VerifySessionContext(ctx, code, false)
+//	}
+//
+// Config exposes every endpoint and option, and is used for anything beyond
+// these helpers.
 package rbxauth
@@ -0,0 +1,105 @@
+package rbxauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// These errors classify failures to send a verification email.
+var (
+	ErrNoEmail       = errors.New("account has no email")
+	ErrTooManyEmails = errors.New("too many verification emails sent")
+)
+
+// emailErrorCodes maps error codes returned by the email verification endpoint
+// to a kind.
+//
+//	1: No email is associated with the account.
+//	6: Too many attempts. Please wait a bit. (status 429)
+var emailErrorCodes = map[int]error{
+	1: ErrNoEmail,
+	6: ErrTooManyEmails,
+}
+
+// EmailInfo describes the email associated with an account.
+type EmailInfo struct {
+	// Address is the email address, which is partially masked by the API.
+	// Empty if the account has no email.
+	Address string
+	// Verified is whether the email has been verified.
+	Verified bool
+}
+
+// EmailStatus returns the email associated with the account of the session
+// represented by the given cookies. Returns ErrUnauthenticated if the session
+// is not valid.
+func (c Config) EmailStatus(cookies []*http.Cookie) (*EmailInfo, error) {
+	return c.EmailStatusContext(context.Background(), cookies)
+}
+
+// EmailStatusContext is like EmailStatus, but with a context.
+func (c Config) EmailStatusContext(ctx context.Context, cookies []*http.Cookie) (info *EmailInfo, err error) {
+	defer wrapOp("email status", &err)
+
+	endpoint := c.EmailEndpoint
+	if endpoint == "" {
+		endpoint = DefaultEmailEndpoint
+	}
+	req, err := c.newRequest(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+
+	var apiResp emailResponse
+	if _, err = c.requestAPI("email", req, &apiResp); err != nil {
+		if code, ok := HTTPStatus(err); ok && code == http.StatusUnauthorized {
+			return nil, ErrUnauthenticated
+		}
+		return nil, err
+	}
+	return &EmailInfo{
+		Address:  apiResp.EmailAddress,
+		Verified: apiResp.Verified,
+	}, nil
+}
+
+// SendEmailVerification sends a verification email to the email associated
+// with the account of the session represented by the given cookies.
+//
+// The returned error matches ErrNoEmail if the account has no email, and
+// ErrTooManyEmails if too many emails have been sent recently.
+func (c Config) SendEmailVerification(cookies []*http.Cookie) error {
+	return c.SendEmailVerificationContext(context.Background(), cookies)
+}
+
+// SendEmailVerificationContext is like SendEmailVerification, but with a
+// context.
+func (c Config) SendEmailVerificationContext(ctx context.Context, cookies []*http.Cookie) (err error) {
+	defer wrapOp("send email verification", &err)
+
+	endpoint := c.EmailVerifyEndpoint
+	if endpoint == "" {
+		endpoint = DefaultEmailVerifyEndpoint
+	}
+	req, err := c.newRequest(ctx, "POST", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+
+	if _, err = c.requestAPI("email-verify", req, &errorsResponse{}); err != nil {
+		if code, ok := HTTPStatus(err); ok && code == http.StatusUnauthorized {
+			return ErrUnauthenticated
+		}
+		return classify(err, emailErrorCodes)
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package rbxauth
+
+import (
+	"errors"
+	"fmt"
+)
+
+// These errors classify common failures reported by the API. They are matched
+// using errors.Is, while errors.As can still be used to extract the underlying
+// ErrorResponse.
+var (
+	ErrBadCredentials  = errors.New("incorrect credentials")
+	ErrCaptchaRequired = errors.New("captcha required")
+	ErrAccountLocked   = errors.New("account locked")
+	ErrTooManyAttempts = errors.New("too many attempts")
+	ErrPinLocked       = errors.New("account PIN locked")
+	ErrPinNotSet       = errors.New("account PIN not set")
+	ErrIncorrectPIN    = errors.New("incorrect PIN")
+
+	// ErrInvalidCode indicates that a verification code was incorrect. The
+	// Step remains valid, so another code may be verified.
+	ErrInvalidCode = errors.New("invalid verification code")
+	// ErrTooManyCodeAttempts indicates that too many incorrect verification
+	// codes were entered.
+	ErrTooManyCodeAttempts = errors.New("too many verification attempts")
+)
+
+// kinds lists each error that can be returned by Classify.
+var kinds = []error{
+	ErrBadCredentials,
+	ErrCaptchaRequired,
+	ErrAccountLocked,
+	ErrTooManyAttempts,
+	ErrPinLocked,
+	ErrPinNotSet,
+	ErrIncorrectPIN,
+	ErrInvalidCode,
+	ErrTooManyCodeAttempts,
+	ErrStepExpired,
+	ErrInvalidAuthTicket,
+	ErrAuthTicketExpired,
+	ErrNoEmail,
+	ErrTooManyEmails,
+	ErrInvalidSocialToken,
+	ErrUsernameTaken,
+	ErrInvalidBirthday,
+	ErrPasswordWeak,
+	ErrWrongAnswer,
+	ErrQuestionsLocked,
+	ErrAccountNotFound,
+	ErrAlreadyLoggedOut,
+	ErrAccountThrottled,
+	ErrParentalConsentRequired,
+	ErrAgeRestricted,
+	ErrTwoStepRequired,
+}
+
+// Classify returns the error from the Err variables that matches err, or nil
+// if err does not match any of them.
+func Classify(err error) error {
+	for _, kind := range kinds {
+		if errors.Is(err, kind) {
+			return kind
+		}
+	}
+	return nil
+}
+
+// ErrorCodes returns the code of each ErrorResponse reported by the API in
+// err's chain. Returns nil if err does not contain an ErrorResponse.
+func ErrorCodes(err error) []int {
+	errs := AllErrors(err)
+	if errs == nil {
+		return nil
+	}
+	codes := make([]int, len(errs))
+	for i, e := range errs {
+		codes[i] = e.Code
+	}
+	return codes
+}
+
+// AllErrors returns each ErrorResponse reported by the API in err's chain,
+// including those after the first when the API reports several. Returns nil
+// if err does not contain an ErrorResponse.
+func AllErrors(err error) []ErrorResponse {
+	var errsResp errorsResponse
+	if errors.As(err, &errsResp) && len(errsResp.Errors) > 0 {
+		return append([]ErrorResponse(nil), errsResp.Errors...)
+	}
+	var errResp ErrorResponse
+	if errors.As(err, &errResp) {
+		return []ErrorResponse{errResp}
+	}
+	return nil
+}
+
+// HasCode returns whether any ErrorResponse reported by the API in err's
+// chain has the given code. It is equivalent to
+//
+//	errors.Is(err, ErrorResponse{Code: code})
+func HasCode(err error, code int) bool {
+	return errors.Is(err, ErrorResponse{Code: code})
+}
+
+// loginErrorCodes maps error codes returned by the login endpoint to a kind.
+// Code 2 (captcha required) is handled by CaptchaError.
+//
+//	1: Incorrect username or password.
+//	4: Account has been locked. Please request a password reset.
+//	7: Too many attempts. Please wait a bit. (status 429)
+//	15: Too many attempts. Please wait a bit.
+//	16: Parental consent is required to log in.
+//	17: This account cannot log in due to age restrictions.
+var loginErrorCodes = map[int]error{
+	1:  ErrBadCredentials,
+	4:  ErrAccountLocked,
+	7:  ErrTooManyAttempts,
+	15: ErrTooManyAttempts,
+	16: ErrParentalConsentRequired,
+	17: ErrAgeRestricted,
+}
+
+// verifyErrorCodes maps error codes returned by the two-step verification
+// endpoint to a kind.
+//
+//	5: Invalid two step verification ticket.
+//	6: Invalid two step verification code.
+//	7: Too many attempts. Please wait a bit. (status 429)
+var verifyErrorCodes = map[int]error{
+	5: ErrStepExpired,
+	6: ErrInvalidCode,
+	7: ErrTooManyCodeAttempts,
+}
+
+// challengeErrorCodes maps error codes returned by the two-step challenge
+// endpoints to a kind.
+//
+//	1: Invalid challenge ID.
+//	5: Too many requests. (status 429)
+//	10: The code is invalid.
+var challengeErrorCodes = map[int]error{
+	1:  ErrStepExpired,
+	5:  ErrTooManyCodeAttempts,
+	10: ErrInvalidCode,
+}
+
+// kindError associates an error with a kind.
+type kindError struct {
+	kind error
+	err  error
+}
+
+// Error implements the error interface.
+func (err *kindError) Error() string {
+	return err.err.Error()
+}
+
+// Unwrap implements the Unwrap interface.
+func (err *kindError) Unwrap() error {
+	return err.err
+}
+
+// Is returns whether target is the kind of the error.
+func (err *kindError) Is(target error) bool {
+	return err.kind == target
+}
+
+// classify wraps err in a kindError if err contains an ErrorResponse whose code
+// is mapped to a kind in codes. Returns err otherwise.
+func classify(err error, codes map[int]error) error {
+	var errResp ErrorResponse
+	if !errors.As(err, &errResp) {
+		return err
+	}
+	if kind, ok := codes[errResp.Code]; ok {
+		return &kindError{kind: kind, err: err}
+	}
+	return err
+}
+
+// wrapOp wraps the error pointed to by err with the name of an operation, if
+// the error is not nil. It is intended to be deferred.
+func wrapOp(op string, err *error) {
+	if *err != nil {
+		*err = fmt.Errorf("%s: %w", op, *err)
+	}
+}
@@ -0,0 +1,303 @@
+package rbxauth_test
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/anaminus/rbxauth"
+	"github.com/anaminus/rbxauth/rbxauthtest"
+)
+
+// codeTest is an API error code reported with a status, and the kind to
+// which it is classified.
+type codeTest struct {
+	status int
+	code   int
+	kind   error
+}
+
+// checkCode checks that err, caused by the API error of test, is classified
+// as the kind of test while retaining the underlying ErrorResponse.
+func checkCode(t *testing.T, test codeTest, err error) {
+	t.Helper()
+	if err == nil {
+		t.Fatalf("code %d: expected error", test.code)
+	}
+	if kind := rbxauth.Classify(err); kind != test.kind {
+		t.Errorf("code %d: expected kind %v, got %v", test.code, test.kind, kind)
+	}
+	if test.kind != nil && !errors.Is(err, test.kind) {
+		t.Errorf("code %d: error does not match %v", test.code, test.kind)
+	}
+	var errResp rbxauth.ErrorResponse
+	if !errors.As(err, &errResp) {
+		t.Fatalf("code %d: error does not contain ErrorResponse: %v", test.code, err)
+	}
+	if errResp.Code != test.code || errResp.Message != "message" {
+		t.Errorf("code %d: unexpected ErrorResponse %+v", test.code, errResp)
+	}
+	if status, ok := rbxauth.HTTPStatus(err); !ok || status != test.status {
+		t.Errorf("code %d: expected status %d, got %d", test.code, test.status, status)
+	}
+}
+
+func TestLoginErrorCodes(t *testing.T) {
+	for _, test := range []codeTest{
+		{403, 1, rbxauth.ErrBadCredentials},
+		{403, 2, rbxauth.ErrCaptchaRequired},
+		{403, 4, rbxauth.ErrAccountLocked},
+		{429, 7, rbxauth.ErrTooManyAttempts},
+		{403, 15, rbxauth.ErrTooManyAttempts},
+		{403, 16, rbxauth.ErrParentalConsentRequired},
+		{403, 17, rbxauth.ErrAgeRestricted},
+		{400, 99, nil},
+	} {
+		srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass"})
+		srv.Fail(rbxauthtest.LoginPath, test.status, test.code, "message")
+		_, _, err := srv.Config().Login("alice", []byte("pass"))
+		checkCode(t, test, err)
+	}
+}
+
+func TestVerifyErrorCodes(t *testing.T) {
+	for _, test := range []codeTest{
+		{400, 5, rbxauth.ErrStepExpired},
+		{400, 6, rbxauth.ErrInvalidCode},
+		{429, 7, rbxauth.ErrTooManyCodeAttempts},
+		{400, 99, nil},
+	} {
+		srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass", TwoStep: true})
+		cfg := srv.Config()
+		cfg.LegacyTwoStep = true
+		step := loginStep(t, cfg)
+		srv.Fail(rbxauthtest.VerifyPath, test.status, test.code, "message")
+		_, err := step.Verify("", false)
+		checkCode(t, test, err)
+	}
+}
+
+func TestChallengeErrorCodes(t *testing.T) {
+	for _, test := range []codeTest{
+		{400, 1, rbxauth.ErrStepExpired},
+		{429, 5, rbxauth.ErrTooManyCodeAttempts},
+		{400, 10, rbxauth.ErrInvalidCode},
+		{400, 99, nil},
+	} {
+		srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass", TwoStep: true})
+		step := loginStep(t, srv.Config())
+		srv.Fail(rbxauthtest.ChallengePath, test.status, test.code, "message")
+		_, err := step.Verify("", false)
+		checkCode(t, test, err)
+	}
+}
+
+func TestStatusErrorWrapped(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass"})
+	srv.Fail(rbxauthtest.LoginPath, 403, 1, "message")
+	_, _, err := srv.Config().Login("alice", []byte("pass"))
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	for i, err := range []error{
+		err,
+		fmt.Errorf("outer: %w", err),
+		fmt.Errorf("outermost: %w", fmt.Errorf("outer: %w", err)),
+	} {
+		var serr *rbxauth.StatusError
+		if !errors.As(err, &serr) {
+			t.Errorf("%d: errors.As: no StatusError in %v", i, err)
+		} else if serr.Code != 403 {
+			t.Errorf("%d: errors.As: expected code 403, got %d", i, serr.Code)
+		}
+		if !errors.Is(err, rbxauth.StatusError{Code: 403}) || !errors.Is(err, &rbxauth.StatusError{Code: 403}) {
+			t.Errorf("%d: errors.Is: does not match status 403: %v", i, err)
+		}
+		if errors.Is(err, rbxauth.StatusError{Code: 500}) || errors.Is(err, (*rbxauth.StatusError)(nil)) {
+			t.Errorf("%d: errors.Is: matches another status: %v", i, err)
+		}
+		// The StatusError is unwrapped to its error response.
+		var errResp rbxauth.ErrorResponse
+		if !errors.As(err, &errResp) || errResp.Code != 1 {
+			t.Errorf("%d: errors.As: no ErrorResponse in %v", i, err)
+		}
+		if !errors.Is(err, rbxauth.ErrBadCredentials) {
+			t.Errorf("%d: errors.Is: does not match ErrBadCredentials: %v", i, err)
+		}
+		if status, ok := rbxauth.HTTPStatus(err); !ok || status != 403 {
+			t.Errorf("%d: HTTPStatus: expected 403, got %d", i, status)
+		}
+	}
+}
+
+func TestMultipleErrors(t *testing.T) {
+	const body = `{"errors":[` +
+		`{"code":9,"message":"first"},` +
+		`{"code":1,"message":"second"},` +
+		`{"code":12,"message":"third"}]}`
+	want := []rbxauth.ErrorResponse{
+		{Code: 9, Message: "first"},
+		{Code: 1, Message: "second"},
+		{Code: 12, Message: "third"},
+	}
+	srv := serveResponse(t, 400, "application/json", body)
+	cfg := rbxauth.Config{AllowInsecure: true, LoginEndpoint: srv.URL, LogoutEndpoint: srv.URL}
+
+	_, _, loginErr := cfg.Login("alice", []byte("pass"))
+	logoutErr := cfg.Logout(rbxauth.FromSecurityToken("token"))
+	var serr *rbxauth.StatusError
+	if !errors.As(loginErr, &serr) {
+		t.Fatalf("expected StatusError, got %v", loginErr)
+	}
+	for name, err := range map[string]error{
+		"login":   loginErr,
+		"logout":  logoutErr,
+		"status":  serr,
+		"wrapped": fmt.Errorf("outermost: %w", fmt.Errorf("outer: %w", loginErr)),
+	} {
+		if err == nil {
+			t.Errorf("%s: expected error", name)
+			continue
+		}
+		if (name == "login" || name == "logout") && !strings.HasPrefix(err.Error(), name+": ") {
+			t.Errorf("%s: expected prefix, got %v", name, err)
+		}
+		if status, ok := rbxauth.HTTPStatus(err); !ok || status != 400 {
+			t.Errorf("%s: expected status 400, got %v", name, err)
+		}
+		// Each error is reachable, not just the first.
+		for _, e := range want {
+			if !rbxauth.HasCode(err, e.Code) {
+				t.Errorf("%s: HasCode(%d) is false", name, e.Code)
+			}
+			if !errors.Is(err, rbxauth.ErrorResponse{Code: e.Code}) {
+				t.Errorf("%s: does not match code %d", name, e.Code)
+			}
+			// The message is not compared.
+			if !errors.Is(err, rbxauth.ErrorResponse{Code: e.Code, Message: "other"}) {
+				t.Errorf("%s: does not match code %d with another message", name, e.Code)
+			}
+		}
+		if rbxauth.HasCode(err, 5) || errors.Is(err, rbxauth.ErrorResponse{Code: 5}) {
+			t.Errorf("%s: matches absent code 5", name)
+		}
+		if got := rbxauth.AllErrors(err); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: expected errors %+v, got %+v", name, want, got)
+		}
+		if got := rbxauth.ErrorCodes(err); !reflect.DeepEqual(got, []int{9, 1, 12}) {
+			t.Errorf("%s: expected codes [9 1 12], got %v", name, got)
+		}
+		// errors.As finds the first.
+		var errResp rbxauth.ErrorResponse
+		if !errors.As(err, &errResp) || errResp != want[0] {
+			t.Errorf("%s: expected first error, got %+v", name, errResp)
+		}
+	}
+
+	// A lone ErrorResponse is a list of one.
+	err := fmt.Errorf("outer: %w", &rbxauth.StatusError{Code: 403, Err: rbxauth.ErrorResponse{Code: 3, Message: "m"}})
+	if got := rbxauth.AllErrors(err); len(got) != 1 || got[0].Code != 3 {
+		t.Errorf("single: unexpected errors %+v", got)
+	}
+	if !rbxauth.HasCode(err, 3) || rbxauth.HasCode(err, 4) {
+		t.Error("single: unexpected HasCode")
+	}
+	for _, err := range []error{nil, errors.New("other"), &rbxauth.StatusError{Code: 500}} {
+		if got := rbxauth.AllErrors(err); got != nil {
+			t.Errorf("%v: expected no errors, got %+v", err, got)
+		}
+		if rbxauth.HasCode(err, 0) {
+			t.Errorf("%v: expected no code", err)
+		}
+	}
+}
+
+func TestOpPrefixes(t *testing.T) {
+	account := rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass", TwoStep: true, Code: "123456"}
+	// step returns a step of a login with the legacy API.
+	step := func(t *testing.T, srv *rbxauthtest.Server) *rbxauth.Step {
+		cfg := srv.Config()
+		cfg.LegacyTwoStep = true
+		cfg.ResendCooldown = -1
+		return loginStep(t, cfg)
+	}
+	// session returns the cookies of a session of an account without
+	// two-step verification.
+	session := func(t *testing.T, srv *rbxauthtest.Server) []*http.Cookie {
+		srv.AddAccount(rbxauthtest.Account{ID: 2, Name: "bob", Password: "pass"})
+		cookies, _, err := srv.Config().Login("bob", []byte("pass"))
+		if err != nil {
+			t.Fatalf("login: %v", err)
+		}
+		return cookies
+	}
+	for _, test := range []struct {
+		name string
+		path string
+		// run fails due to a failed request to path.
+		run func(t *testing.T, srv *rbxauthtest.Server) error
+		// prefix is the expected prefix of the error, followed by the
+		// status.
+		prefix string
+	}{
+		{"Login", rbxauthtest.LoginPath, func(t *testing.T, srv *rbxauthtest.Server) error {
+			_, _, err := srv.Config().Login("alice", []byte("pass"))
+			return err
+		}, "login: "},
+		{"LoginID", rbxauthtest.UserIDPath, func(t *testing.T, srv *rbxauthtest.Server) error {
+			_, _, err := srv.Config().LoginID(1, []byte("pass"))
+			return err
+		}, "user from ID: "},
+		{"Logout", rbxauthtest.LogoutPath, func(t *testing.T, srv *rbxauthtest.Server) error {
+			return srv.Config().Logout(session(t, srv))
+		}, "logout: "},
+		{"Verify", rbxauthtest.VerifyPath, func(t *testing.T, srv *rbxauthtest.Server) error {
+			_, err := step(t, srv).Verify("123456", false)
+			return err
+		}, "verify: "},
+		{"Resend", rbxauthtest.ResendPath, func(t *testing.T, srv *rbxauthtest.Server) error {
+			return step(t, srv).Resend()
+		}, "resend: "},
+		{"GetUserID", rbxauthtest.UsernamesPath, func(t *testing.T, srv *rbxauthtest.Server) error {
+			_, err := srv.Config().GetUserID("alice")
+			return err
+		}, "user ID from name: "},
+		{"Authenticated", rbxauthtest.AuthenticatedPath, func(t *testing.T, srv *rbxauthtest.Server) error {
+			_, err := srv.Config().Authenticated(session(t, srv))
+			return err
+		}, "authenticated: "},
+		{"PromptCred", rbxauthtest.LoginPath, func(t *testing.T, srv *rbxauthtest.Server) error {
+			s := &rbxauth.Stream{Config: srv.Config(), Reader: strings.NewReader("pass\n"), Writer: ioutil.Discard}
+			_, _, err := s.PromptCred(rbxauth.Cred{Type: "Username", Ident: "alice"})
+			return err
+		}, "prompt: login: "},
+		{"PromptID", rbxauthtest.UserIDPath, func(t *testing.T, srv *rbxauthtest.Server) error {
+			s := &rbxauth.Stream{Config: srv.Config(), Reader: strings.NewReader("pass\n"), Writer: ioutil.Discard}
+			_, _, err := s.PromptID(1)
+			return err
+		}, "prompt: user from ID: "},
+	} {
+		srv := newServer(t, account)
+		srv.Fail(test.path, 400, 99, "message")
+		err := test.run(t, srv)
+		if err == nil {
+			t.Errorf("%s: expected error", test.name)
+			continue
+		}
+		if prefix := test.prefix + "http status 400"; !strings.HasPrefix(err.Error(), prefix) {
+			t.Errorf("%s: expected prefix %q, got %q", test.name, prefix, err)
+		}
+		var serr *rbxauth.StatusError
+		if !errors.As(err, &serr) || serr.Code != 400 {
+			t.Errorf("%s: no StatusError in %v", test.name, err)
+		}
+		var errResp rbxauth.ErrorResponse
+		if !errors.As(err, &errResp) || errResp.Code != 99 || errResp.Message != "message" {
+			t.Errorf("%s: no ErrorResponse in %v", test.name, err)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package rbxauth
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// fixtureVersion is the version of the fixture written by a Recorder.
+const fixtureVersion = 1
+
+// fixture is the document written by a Recorder and read by ReplayClient.
+type fixture struct {
+	// Version is the format of the document.
+	Version int `json:"version"`
+	// Exchanges lists each exchange in the order it was made.
+	Exchanges []fixtureExchange `json:"exchanges"`
+}
+
+// fixtureExchange is a single recorded exchange.
+type fixtureExchange struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	// Body is the normalized body of the request.
+	Body string `json:"body,omitempty"`
+
+	Status       int         `json:"status,omitempty"`
+	Header       http.Header `json:"header,omitempty"`
+	ResponseBody string      `json:"responseBody,omitempty"`
+	// Error is the error that occurred instead of receiving a response.
+	Error string `json:"error,omitempty"`
+}
+
+// redactedCookie replaces the value of each cookie set by a recorded
+// response.
+const redactedCookie = "redacted"
+
+// normalizeBody returns the form of a request body that is recorded and
+// matched. A JSON body is re-encoded with sorted keys, and with the fields of
+// redactedFields redacted as with DumpRequests. Any other body is returned as
+// is.
+func normalizeBody(body []byte) string {
+	var v interface{}
+	if len(bytes.TrimSpace(body)) == 0 || json.Unmarshal(body, &v) != nil {
+		return string(body)
+	}
+	redact(v)
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// readRequestBody returns the body of req, and a request with an unread copy
+// of the body.
+func readRequestBody(req *http.Request) (*http.Request, []byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, nil, nil
+	}
+	if req.GetBody != nil {
+		r, err := req.GetBody()
+		if err != nil {
+			return req, nil, err
+		}
+		defer r.Close()
+		body, err := ioutil.ReadAll(r)
+		return req, body, err
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return req, nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return req, body, nil
+}
+
+// Recorder records each exchange made by a Config returned by Config.Record.
+type Recorder struct {
+	w    io.Writer
+	base *http.Client
+	err  error
+
+	mu        sync.Mutex
+	exchanges []fixtureExchange
+	closed    bool
+}
+
+// Record returns a copy of the Config whose requests are recorded, along with
+// the Recorder that records them. Requests are made with the client of the
+// original Config. Once the Config is no longer used, Recorder.Close writes
+// the recorded exchanges to w as a fixture, which can be served by
+// ReplayClient.
+//
+// The fixture is intended to be committed alongside tests, so secrets are
+// redacted as they are recorded:
+//
+//   - Request headers, including the Cookie and X-CSRF-TOKEN headers, are not
+//     recorded.
+//   - Passwords, PINs, and verification codes within a JSON request body are
+//     replaced with placeholders, as with DumpRequests.
+//   - The value of each cookie set by a response is replaced with a
+//     placeholder.
+//
+// Everything else is recorded as is, including URLs, user IDs and names,
+// tickets, CSRF tokens received from the API, and response bodies. Unredacted
+// copies of request bodies are wiped after being recorded.
+func (c Config) Record(w io.Writer) (Config, *Recorder) {
+	base, err := c.httpClient()
+	rec := &Recorder{w: w, base: base, err: err}
+	var client http.Client
+	if base != nil {
+		client = *base
+	}
+	client.Transport = rec
+	// Retain the timeout that applied to the original client.
+	c.Timeout = c.timeout()
+	c.Client = &client
+	c.ProxyURL = ""
+	return c, rec
+}
+
+// RoundTrip implements http.RoundTripper by making the request with the
+// client of the recorded Config, and recording the exchange.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	req, body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	ex := fixtureExchange{
+		Method: req.Method,
+		URL:    req.URL.String(),
+		Body:   normalizeBody(body),
+	}
+	wipe(body)
+
+	transport := r.base.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		ex.Error = err.Error()
+		r.add(ex)
+		return nil, err
+	}
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+	ex.Status = resp.StatusCode
+	ex.Header = resp.Header.Clone()
+	if cookies := ex.Header["Set-Cookie"]; len(cookies) > 0 {
+		for i, cookie := range cookies {
+			cookies[i] = redactCookie(cookie)
+		}
+	}
+	ex.ResponseBody = string(respBody)
+	r.add(ex)
+	return resp, nil
+}
+
+// redactCookie replaces the value within a Set-Cookie header with
+// redactedCookie.
+func redactCookie(header string) string {
+	eq := strings.IndexByte(header, '=')
+	if eq < 0 {
+		return header
+	}
+	end := strings.IndexByte(header, ';')
+	if end < eq {
+		end = len(header)
+	}
+	return header[:eq+1] + redactedCookie + header[end:]
+}
+
+// add appends ex to the recorded exchanges.
+func (r *Recorder) add(ex fixtureExchange) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.exchanges = append(r.exchanges, ex)
+}
+
+// Close writes the exchanges recorded so far to the writer passed to
+// Config.Record. Subsequent exchanges are still made, but are not written.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	doc := fixture{Version: fixtureVersion, Exchanges: r.exchanges}
+	if doc.Exchanges == nil {
+		doc.Exchanges = []fixtureExchange{}
+	}
+	je := json.NewEncoder(r.w)
+	je.SetIndent("", "\t")
+	if err := je.Encode(doc); err != nil {
+		return fmt.Errorf("write fixture: %w", err)
+	}
+	return nil
+}
+
+// ErrUnexpectedRequest is returned by a client from ReplayClient when a request
+// does not match any remaining exchange of the fixture.
+var ErrUnexpectedRequest = errors.New("unexpected request")
+
+// replayer serves the exchanges of a fixture.
+type replayer struct {
+	err error
+
+	mu        sync.Mutex
+	exchanges []fixtureExchange
+	used      []bool
+}
+
+// ReplayClient returns a client that serves the responses of a fixture
+// written by a Recorder, read from r. It is intended to be used as the Client
+// of a Config, so that a recorded login can be repeated without a server.
+//
+// A request is served by the first exchange not yet served that has the same
+// method, URL, and normalized body. Redacted fields of a request body are
+// normalized in the same way as when recording, so that, for example, any
+// verification code matches a recorded code. A request that matches no
+// exchange returns an error matching ErrUnexpectedRequest. If the fixture
+// cannot be read, then every request returns the error.
+func ReplayClient(r io.Reader) *http.Client {
+	var rp replayer
+	var doc fixture
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		rp.err = fmt.Errorf("read fixture: %w", err)
+	} else if doc.Version != fixtureVersion {
+		rp.err = fmt.Errorf("fixture version %d is not supported", doc.Version)
+	} else {
+		rp.exchanges = doc.Exchanges
+		rp.used = make([]bool, len(doc.Exchanges))
+	}
+	return &http.Client{Transport: &rp}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (rp *replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if rp.err != nil {
+		return nil, rp.err
+	}
+	_, body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	url, normalized := req.URL.String(), normalizeBody(body)
+	wipe(body)
+
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	for i, ex := range rp.exchanges {
+		if rp.used[i] || ex.Method != req.Method || ex.URL != url || ex.Body != normalized {
+			continue
+		}
+		rp.used[i] = true
+		if ex.Error != "" {
+			return nil, errors.New(ex.Error)
+		}
+		header := ex.Header.Clone()
+		if header == nil {
+			header = http.Header{}
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", ex.Status, http.StatusText(ex.Status)),
+			StatusCode:    ex.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          ioutil.NopCloser(strings.NewReader(ex.ResponseBody)),
+			ContentLength: int64(len(ex.ResponseBody)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: %s %s", ErrUnexpectedRequest, req.Method, url)
+}
@@ -0,0 +1,81 @@
+package rbxauth_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/anaminus/rbxauth"
+	"github.com/anaminus/rbxauth/rbxauthtest"
+)
+
+func TestRecordReplay(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{
+		ID: 1, Name: "alice", Password: "hunter2-secret",
+		TwoStep: true, MediaType: string(rbxauth.MediaEmail), Code: "987654",
+	})
+	cfg := srv.Config()
+	var buf bytes.Buffer
+	rec, recorder := cfg.Record(&buf)
+	_, step, err := rec.Login("alice", []byte("hunter2-secret"))
+	if err != nil || step == nil {
+		t.Fatalf("record: login: expected step, got %v", err)
+	}
+	cookies, err := step.Verify("987654", false)
+	if err != nil {
+		t.Fatalf("record: verify: %v", err)
+	}
+	checkSession(t, cfg, cookies, rbxauth.UserInfo{ID: 1, Name: "alice"})
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("record: close: %v", err)
+	}
+
+	// Secrets are redacted.
+	fixture := buf.String()
+	for _, secret := range []string{"hunter2-secret", "987654", cookieValue(cookies, rbxauthtest.SessionCookieName)} {
+		if strings.Contains(fixture, secret) {
+			t.Errorf("fixture contains secret %q", secret)
+		}
+	}
+
+	// The login is repeated by a fresh Config without the server.
+	srv.Close()
+	replay := srv.Config()
+	replay.Client = rbxauth.ReplayClient(strings.NewReader(fixture))
+	_, step, err = replay.Login("alice", []byte("hunter2-secret"))
+	if err != nil || step == nil {
+		t.Fatalf("replay: login: expected step, got %v", err)
+	}
+	if step.MediaType != rbxauth.MediaEmail {
+		t.Errorf("replay: expected media type %s, got %s", rbxauth.MediaEmail, step.MediaType)
+	}
+	// The code is redacted, so any code matches the recorded exchange.
+	replayed, err := step.Verify("000000", false)
+	if err != nil {
+		t.Fatalf("replay: verify: %v", err)
+	}
+	if v := cookieValue(replayed, rbxauthtest.SessionCookieName); v != "redacted" {
+		t.Errorf("replay: expected redacted session cookie, got %q", v)
+	}
+
+	// Each exchange is served once.
+	if _, _, err := replay.Login("alice", []byte("hunter2-secret")); !errors.Is(err, rbxauth.ErrUnexpectedRequest) {
+		t.Errorf("served: expected ErrUnexpectedRequest, got %v", err)
+	}
+
+	// Requests that were not recorded are unexpected.
+	replay = srv.Config()
+	replay.Client = rbxauth.ReplayClient(strings.NewReader(fixture))
+	if _, _, err := replay.Login("bob", []byte("hunter2-secret")); !errors.Is(err, rbxauth.ErrUnexpectedRequest) {
+		t.Errorf("unexpected: expected ErrUnexpectedRequest, got %v", err)
+	}
+	if _, err := replay.Authenticated(cookies); !errors.Is(err, rbxauth.ErrUnexpectedRequest) {
+		t.Errorf("unrecorded: expected ErrUnexpectedRequest, got %v", err)
+	}
+
+	replay.Client = rbxauth.ReplayClient(strings.NewReader(`{"version":2,"exchanges":[]}`))
+	if _, _, err := replay.Login("alice", []byte("hunter2-secret")); err == nil || !strings.Contains(err.Error(), "version 2") {
+		t.Errorf("version: expected unsupported version, got %v", err)
+	}
+}
@@ -1,8 +1,11 @@
 module github.com/anaminus/rbxauth
 
-go 1.13
+go 1.18
 
 require (
 	github.com/anaminus/but v0.2.0
 	golang.org/x/crypto v0.0.0-20190923035154-9ee001bba392
+	golang.org/x/term v0.0.0-20201126162022-7de9c90e9dd1
 )
+
+require golang.org/x/sys v0.0.0-20201119102817-f84b799fce68 // indirect
@@ -0,0 +1,326 @@
+package rbxauth
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// defaultHost is the host from which the subdomain of each default endpoint
+// is derived.
+const defaultHost = "roblox.com"
+
+// endpointField describes an endpoint field of a Config.
+type endpointField struct {
+	// name is the name of the field.
+	name string
+	// field points to the field.
+	field *string
+	// def is the default of the field, or empty if the field has no default.
+	def string
+	// verbs lists the format verbs that the endpoint must contain, in order.
+	verbs string
+}
+
+// endpointFields returns each endpoint field of c.
+func (c *Config) endpointFields() []endpointField {
+	return []endpointField{
+		{"LoginEndpoint", &c.LoginEndpoint, DefaultLoginEndpoint, ""},
+		{"LogoutEndpoint", &c.LogoutEndpoint, DefaultLogoutEndpoint, ""},
+		{"LogoutAllEndpoint", &c.LogoutAllEndpoint, DefaultLogoutAllEndpoint, ""},
+		{"ValidatePasswordEndpoint", &c.ValidatePasswordEndpoint, DefaultValidatePasswordEndpoint, ""},
+		{"ChangePasswordEndpoint", &c.ChangePasswordEndpoint, DefaultChangePasswordEndpoint, ""},
+		{"MetadataEndpoint", &c.MetadataEndpoint, DefaultMetadataEndpoint, ""},
+		{"AuthTicketEndpoint", &c.AuthTicketEndpoint, DefaultAuthTicketEndpoint, ""},
+		{"RedeemAuthTicketEndpoint", &c.RedeemAuthTicketEndpoint, DefaultRedeemAuthTicketEndpoint, ""},
+		{"EmailEndpoint", &c.EmailEndpoint, DefaultEmailEndpoint, ""},
+		{"EmailVerifyEndpoint", &c.EmailVerifyEndpoint, DefaultEmailVerifyEndpoint, ""},
+		{"SocialLoginEndpoint", &c.SocialLoginEndpoint, DefaultSocialLoginEndpoint, "s"},
+		{"SignupEndpoint", &c.SignupEndpoint, DefaultSignupEndpoint, ""},
+		{"ValidateUsernameEndpoint", &c.ValidateUsernameEndpoint, DefaultValidateUsernameEndpoint, ""},
+		{"RecommendUsernamesEndpoint", &c.RecommendUsernamesEndpoint, DefaultRecommendUsernamesEndpoint, ""},
+		{"VerifyEndpoint", &c.VerifyEndpoint, DefaultVerifyEndpoint, ""},
+		{"ResendEndpoint", &c.ResendEndpoint, DefaultResendEndpoint, ""},
+		{"UserIDEndpoint", &c.UserIDEndpoint, DefaultUserIDEndpoint, "d"},
+		{"LegacyUserIDEndpoint", &c.LegacyUserIDEndpoint, "", "d"},
+		{"AuthenticatedEndpoint", &c.AuthenticatedEndpoint, DefaultAuthenticatedEndpoint, ""},
+		{"RefreshEndpoint", &c.RefreshEndpoint, DefaultRefreshEndpoint, ""},
+		{"UsernameLookupEndpoint", &c.UsernameLookupEndpoint, DefaultUsernameLookupEndpoint, ""},
+		{"TwoStepChallengeEndpoint", &c.TwoStepChallengeEndpoint, DefaultTwoStepChallengeEndpoint, "ds"},
+		{"TwoStepLoginEndpoint", &c.TwoStepLoginEndpoint, DefaultTwoStepLoginEndpoint, "d"},
+		{"IdentityVerificationEndpoint", &c.IdentityVerificationEndpoint, DefaultIdentityVerificationEndpoint, ""},
+		{"SecurityQuestionsEndpoint", &c.SecurityQuestionsEndpoint, DefaultSecurityQuestionsEndpoint, ""},
+		{"UnlockPINEndpoint", &c.UnlockPINEndpoint, DefaultUnlockPINEndpoint, ""},
+		{"LockPINEndpoint", &c.LockPINEndpoint, DefaultLockPINEndpoint, ""},
+		{"PasswordResetSendEndpoint", &c.PasswordResetSendEndpoint, DefaultPasswordResetSendEndpoint, ""},
+		{"PasswordResetVerifyEndpoint", &c.PasswordResetVerifyEndpoint, DefaultPasswordResetVerifyEndpoint, ""},
+		{"PasswordResetEndpoint", &c.PasswordResetEndpoint, DefaultPasswordResetEndpoint, ""},
+		{"TokenEndpoint", &c.TokenEndpoint, "", ""},
+	}
+}
+
+// NewConfig returns a Config with each endpoint derived from host, such as a
+// test site. The subdomain of each default endpoint is retained, so that, for
+// example, the login endpoint of "sitetest1.robloxlabs.com" is
+// "https://auth.sitetest1.robloxlabs.com/v2/login". host may include a port,
+// but not a scheme or path. If host is empty, the endpoints are left empty, so
+// that the defaults are used.
+//
+// The Config has a TokenCache, so that the CSRF token is retained between
+// calls.
+func NewConfig(host string) (cfg Config, err error) {
+	cfg.TokenCache = &TokenCache{}
+	if host == "" {
+		return cfg, nil
+	}
+	if err := validateHost(host); err != nil {
+		return cfg, fmt.Errorf("invalid host %q: %w", host, err)
+	}
+	for _, e := range cfg.endpointFields() {
+		if e.def != "" {
+			*e.field = deriveEndpoint(e.def, host)
+		}
+	}
+	return cfg, nil
+}
+
+// validateHost returns an error if host is not a host name with an optional
+// port.
+func validateHost(host string) error {
+	if strings.Contains(host, "://") {
+		return errors.New("must not include a scheme")
+	}
+	if strings.ContainsAny(host, "/?#") {
+		return errors.New("must not include a path")
+	}
+	name := host
+	if strings.Contains(host, ":") {
+		var port string
+		var err error
+		if name, port, err = net.SplitHostPort(host); err != nil {
+			return err
+		}
+		if n, err := strconv.ParseUint(port, 10, 16); err != nil || n == 0 {
+			return fmt.Errorf("invalid port %q", port)
+		}
+	}
+	if name == "" {
+		return errors.New("missing host name")
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return fmt.Errorf("invalid label %q", label)
+		}
+		for _, r := range label {
+			if !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r == '-') {
+				return fmt.Errorf("invalid character %q", r)
+			}
+		}
+	}
+	return nil
+}
+
+// deriveEndpoint replaces defaultHost in the host of endpoint with host,
+// retaining the subdomain.
+func deriveEndpoint(endpoint, host string) string {
+	const scheme = "https://"
+	rest := strings.TrimPrefix(endpoint, scheme)
+	path := ""
+	if i := strings.IndexByte(rest, '/'); i >= 0 {
+		rest, path = rest[:i], rest[i:]
+	}
+	sub := strings.TrimSuffix(rest, defaultHost)
+	return scheme + sub + host + path
+}
+
+// EndpointError describes an invalid endpoint of a Config.
+type EndpointError struct {
+	// Field is the name of the Config field containing the endpoint.
+	Field string
+	// URL is the value of the field.
+	URL string
+	// Err describes the problem with the endpoint.
+	Err error
+}
+
+// Error implements the error interface.
+func (err *EndpointError) Error() string {
+	return fmt.Sprintf("%s %q: %s", err.Field, err.URL, err.Err)
+}
+
+// Unwrap implements the Unwrap interface.
+func (err *EndpointError) Unwrap() error {
+	return err.Err
+}
+
+// ConfigError is returned by Config.Validate, listing each invalid endpoint.
+type ConfigError []*EndpointError
+
+// Error implements the error interface.
+func (err ConfigError) Error() string {
+	s := make([]string, len(err))
+	for i, e := range err {
+		s[i] = e.Error()
+	}
+	return "invalid config: " + strings.Join(s, "; ")
+}
+
+// Validate checks each endpoint that is not empty, returning a ConfigError
+// that lists every problem found. An endpoint must be an absolute https URL,
+// or http if AllowInsecure is set. An endpoint that is formatted with a value,
+// such as UserIDEndpoint, must contain exactly the expected format verbs.
+//
+// Methods that make requests call Validate before the first request, so that
+// a malformed endpoint is reported as such. The results of recently used
+// Configs are cached, so that a Config is typically validated only once.
+func (c Config) Validate() error {
+	var errs ConfigError
+	for _, e := range c.endpointFields() {
+		if *e.field == "" {
+			continue
+		}
+		if err := validateEndpoint(*e.field, e.verbs, c.AllowInsecure); err != nil {
+			errs = append(errs, &EndpointError{Field: e.name, URL: *e.field, Err: err})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// maxValidations is the number of results cached by validate. A program
+// typically uses few distinct Configs, but one that derives a Config per
+// request must not grow the cache without bound.
+const maxValidations = 64
+
+// validations caches the result of validating the endpoints of a Config,
+// keyed by validationKey. When full, the oldest result is evicted.
+var validations struct {
+	mu      sync.Mutex
+	results map[string]error
+	// keys lists each key of results, from oldest to newest.
+	keys []string
+}
+
+// validationKey returns a key that identifies the values validated by
+// Validate.
+func (c *Config) validationKey() string {
+	var b strings.Builder
+	if c.AllowInsecure {
+		b.WriteByte('!')
+	}
+	for _, e := range c.endpointFields() {
+		b.WriteString(*e.field)
+		b.WriteByte(0)
+	}
+	return b.String()
+}
+
+// validate is like Validate, but caches the result.
+func (c *Config) validate() error {
+	key := c.validationKey()
+	validations.mu.Lock()
+	defer validations.mu.Unlock()
+	if err, ok := validations.results[key]; ok {
+		return err
+	}
+	err := c.Validate()
+	if validations.results == nil {
+		validations.results = make(map[string]error, maxValidations)
+	}
+	if len(validations.keys) >= maxValidations {
+		delete(validations.results, validations.keys[0])
+		validations.keys = append(validations.keys[:0], validations.keys[1:]...)
+	}
+	validations.results[key] = err
+	validations.keys = append(validations.keys, key)
+	return err
+}
+
+// validateEndpoint returns an error if endpoint is not an absolute URL with
+// the given format verbs.
+func validateEndpoint(endpoint, verbs string, insecure bool) error {
+	if strings.TrimSpace(endpoint) != endpoint {
+		return errors.New("contains leading or trailing space")
+	}
+	if verbs != "" {
+		found, err := formatVerbs(endpoint)
+		if err != nil {
+			return err
+		}
+		if found != verbs {
+			return fmt.Errorf("must contain format verbs %s, found %s", verbList(verbs), verbList(found))
+		}
+		// Check the URL that results from formatting.
+		args := make([]interface{}, len(verbs))
+		for i, v := range verbs {
+			if v == 'd' {
+				args[i] = 1
+			} else {
+				args[i] = "s"
+			}
+		}
+		endpoint = fmt.Sprintf(endpoint, args...)
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			err = uerr.Err
+		}
+		return err
+	}
+	switch {
+	case u.Scheme == "https":
+	case u.Scheme == "http" && insecure:
+	case u.Scheme == "http":
+		return errors.New("scheme must be https unless AllowInsecure is set")
+	case u.Scheme == "":
+		return errors.New("missing scheme")
+	default:
+		return fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return errors.New("missing host")
+	}
+	return nil
+}
+
+// formatVerbs returns the verb of each formatting directive in s, in order.
+func formatVerbs(s string) (string, error) {
+	var verbs []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			continue
+		}
+		i++
+		// Skip flags, width, and precision.
+		for i < len(s) && strings.IndexByte("+-# 0123456789.*[]", s[i]) >= 0 {
+			i++
+		}
+		if i >= len(s) {
+			return "", errors.New("incomplete format verb")
+		}
+		if s[i] != '%' {
+			verbs = append(verbs, s[i])
+		}
+	}
+	return string(verbs), nil
+}
+
+// verbList formats verbs for an error message.
+func verbList(verbs string) string {
+	if verbs == "" {
+		return "none"
+	}
+	s := make([]string, len(verbs))
+	for i, v := range verbs {
+		s[i] = "%" + string(v)
+	}
+	return strings.Join(s, ", ")
+}
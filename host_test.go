@@ -0,0 +1,138 @@
+package rbxauth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestValidateCache(t *testing.T) {
+	validations.mu.Lock()
+	validations.results, validations.keys = nil, nil
+	validations.mu.Unlock()
+
+	config := func(i int) *Config {
+		return &Config{LoginEndpoint: fmt.Sprintf("https://host%d.test/v2/login", i)}
+	}
+	bad := &Config{LoginEndpoint: "ftp://host.test/v2/login"}
+	var cerr ConfigError
+	if err := bad.validate(); !errors.As(err, &cerr) {
+		t.Fatalf("expected ConfigError, got %v", err)
+	}
+	// The cached result is returned.
+	if err := bad.validate(); !errors.As(err, &cerr) {
+		t.Fatalf("cached: expected ConfigError, got %v", err)
+	}
+
+	const n = maxValidations * 3
+	for i := 0; i < n; i++ {
+		if err := config(i).validate(); err != nil {
+			t.Fatalf("config %d: %v", i, err)
+		}
+	}
+	if len(validations.results) != maxValidations || len(validations.keys) != maxValidations {
+		t.Fatalf("expected %d cached results, got %d results and %d keys",
+			maxValidations, len(validations.results), len(validations.keys))
+	}
+	// The oldest results are evicted.
+	if _, ok := validations.results[bad.validationKey()]; ok {
+		t.Error("oldest result was not evicted")
+	}
+	if _, ok := validations.results[config(n-1).validationKey()]; !ok {
+		t.Error("newest result is not cached")
+	}
+	for _, key := range validations.keys {
+		if _, ok := validations.results[key]; !ok {
+			t.Errorf("listed key %q is not cached", key)
+		}
+	}
+
+	// An evicted result is validated again.
+	if err := bad.validate(); !errors.As(err, &cerr) {
+		t.Errorf("evicted: expected ConfigError, got %v", err)
+	}
+}
+
+func TestNewConfig(t *testing.T) {
+	for _, test := range []struct {
+		host string
+		// Expected endpoints.
+		login, userID, challenge string
+	}{
+		{
+			"roblox.com",
+			DefaultLoginEndpoint,
+			DefaultUserIDEndpoint,
+			DefaultTwoStepChallengeEndpoint,
+		},
+		{
+			"sitetest1.robloxlabs.com",
+			"https://auth.sitetest1.robloxlabs.com/v2/login",
+			"https://users.sitetest1.robloxlabs.com/v1/users/%d",
+			"https://twostepverification.sitetest1.robloxlabs.com/v1/users/%d/challenges/%s",
+		},
+		{
+			"localhost:8443",
+			"https://auth.localhost:8443/v2/login",
+			"https://users.localhost:8443/v1/users/%d",
+			"https://twostepverification.localhost:8443/v1/users/%d/challenges/%s",
+		},
+	} {
+		cfg, err := NewConfig(test.host)
+		if err != nil {
+			t.Errorf("%s: %v", test.host, err)
+			continue
+		}
+		if cfg.TokenCache == nil {
+			t.Errorf("%s: expected token cache", test.host)
+		}
+		for name, e := range map[string][2]string{
+			"login":     {cfg.LoginEndpoint, test.login},
+			"user ID":   {cfg.UserIDEndpoint, test.userID},
+			"challenge": {cfg.TwoStepChallengeEndpoint, test.challenge},
+		} {
+			if e[0] != e[1] {
+				t.Errorf("%s: expected %s endpoint %q, got %q", test.host, name, e[1], e[0])
+			}
+		}
+		for _, e := range cfg.endpointFields() {
+			if e.def == "" && *e.field != "" {
+				t.Errorf("%s: expected %s to be empty, got %q", test.host, e.name, *e.field)
+			} else if e.def != "" && !strings.Contains(*e.field, test.host+"/") {
+				t.Errorf("%s: %s not derived: %q", test.host, e.name, *e.field)
+			}
+		}
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("%s: %v", test.host, err)
+		}
+	}
+
+	cfg, err := NewConfig("")
+	if err != nil || cfg.LoginEndpoint != "" || cfg.TokenCache == nil {
+		t.Errorf("empty: expected default endpoints, got %q, %v", cfg.LoginEndpoint, err)
+	}
+
+	for _, host := range []string{
+		"https://roblox.com",
+		"roblox.com/",
+		"roblox.com/path",
+		"roblox.com?q",
+		"roblox.com:",
+		"roblox.com:0",
+		"roblox.com:65536",
+		"roblox.com:port",
+		":8443",
+		"roblox..com",
+		".roblox.com",
+		"-roblox.com",
+		"roblox-.com",
+		"rob lox.com",
+		"röblox.com",
+		"[::1]",
+	} {
+		if _, err := NewConfig(host); err == nil || !strings.Contains(err.Error(), "invalid host") {
+			t.Errorf("%q: expected invalid host, got %v", host, err)
+		}
+	}
+}
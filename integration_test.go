@@ -0,0 +1,172 @@
+//go:build integration
+// +build integration
+
+package rbxauth_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/anaminus/rbxauth"
+)
+
+// Environment variables that configure the integration tests, which run
+// against the live API with a dedicated test account. Run with:
+//
+//	RBXAUTH_TEST_USERNAME=... RBXAUTH_TEST_PASSWORD=... go test -tags integration
+const (
+	// Required. The credentials of the test account.
+	envUsername = "RBXAUTH_TEST_USERNAME"
+	envPassword = "RBXAUTH_TEST_PASSWORD"
+	// Optional. The authenticator secret of the test account, used to
+	// complete two-step verification.
+	envTOTPSecret = "RBXAUTH_TEST_TOTP_SECRET"
+	// Optional. The host from which endpoints are derived, such as a test
+	// site. The production API is used if empty.
+	envHost = "RBXAUTH_TEST_HOST"
+)
+
+// redactTB replaces each secret in the output of a test.
+type redactTB struct {
+	testing.TB
+	secrets []string
+}
+
+func (t redactTB) redact(s string) string {
+	for _, secret := range t.secrets {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, "[REDACTED]")
+		}
+	}
+	return s
+}
+
+func (t redactTB) Error(args ...interface{}) {
+	t.TB.Helper()
+	t.TB.Error(t.redact(fmt.Sprint(args...)))
+}
+
+func (t redactTB) Fatal(args ...interface{}) {
+	t.TB.Helper()
+	t.TB.Fatal(t.redact(fmt.Sprint(args...)))
+}
+
+func (t redactTB) Log(args ...interface{}) {
+	t.TB.Helper()
+	t.TB.Log(t.redact(fmt.Sprint(args...)))
+}
+
+func (t redactTB) Skip(args ...interface{}) {
+	t.TB.Helper()
+	t.TB.Skip(t.redact(fmt.Sprint(args...)))
+}
+
+func (t redactTB) Errorf(format string, args ...interface{}) {
+	t.TB.Helper()
+	t.TB.Error(t.redact(fmt.Sprintf(format, args...)))
+}
+
+func (t redactTB) Fatalf(format string, args ...interface{}) {
+	t.TB.Helper()
+	t.TB.Fatal(t.redact(fmt.Sprintf(format, args...)))
+}
+
+func (t redactTB) Logf(format string, args ...interface{}) {
+	t.TB.Helper()
+	t.TB.Log(t.redact(fmt.Sprintf(format, args...)))
+}
+
+func (t redactTB) Skipf(format string, args ...interface{}) {
+	t.TB.Helper()
+	t.TB.Skip(t.redact(fmt.Sprintf(format, args...)))
+}
+
+// liveAccount returns a Config for the live API and the credentials of the
+// test account, skipping the test if they are not configured. The returned
+// TB redacts the credentials from the output of the test.
+func liveAccount(t *testing.T) (redactTB, rbxauth.Config, rbxauth.Cred, []byte) {
+	username, password := os.Getenv(envUsername), os.Getenv(envPassword)
+	if username == "" || password == "" {
+		t.Skipf("%s and %s must be set", envUsername, envPassword)
+	}
+	secret := os.Getenv(envTOTPSecret)
+	tb := redactTB{TB: t, secrets: []string{password, secret, username}}
+	cfg, err := rbxauth.NewConfig(os.Getenv(envHost))
+	if err != nil {
+		tb.Fatalf("config: %v", err)
+	}
+	cfg.WipePassword = false
+	if secret != "" {
+		cfg.CodeProvider = rbxauth.TOTPCodeProvider(secret, nil)
+	}
+	return tb, cfg, rbxauth.Cred{Type: "Username", Ident: username}, []byte(password)
+}
+
+// liveLogin logs into the test account, completing two-step verification if
+// possible. The session is logged out when the test finishes.
+func liveLogin(t *testing.T) (redactTB, rbxauth.Config, rbxauth.Cred, []byte, *rbxauth.LoginResult) {
+	tb, cfg, cred, password := liveAccount(t)
+	result, err := cfg.LoginCredResult(context.Background(), cred, password, nil)
+	if errors.Is(err, rbxauth.ErrCaptchaRequired) {
+		tb.Skipf("login requires a captcha: %v", err)
+	}
+	if err != nil {
+		tb.Fatalf("login: %v", err)
+	}
+	if len(result.Cookies) > 0 {
+		t.Cleanup(func() { cfg.Logout(result.Cookies) })
+	}
+	return tb, cfg, cred, password, result
+}
+
+func TestLiveSession(t *testing.T) {
+	tb, cfg, cred, _, result := liveLogin(t)
+	if result.Step != nil {
+		if cfg.CodeProvider == nil {
+			tb.Skipf("two-step verification required, and %s is not set", envTOTPSecret)
+		}
+		code, err := cfg.CodeProvider(string(result.Step.MediaType))
+		if err != nil {
+			tb.Fatalf("code: %v", err)
+		}
+		if result.Cookies, err = result.Step.Verify(code, false); err != nil {
+			tb.Fatalf("verify: %v", err)
+		}
+	}
+	if result.Challenge != nil || result.Questions != nil {
+		tb.Skip("login requires approval or security questions")
+	}
+	checkSession(tb, cfg, result.Cookies, rbxauth.UserInfo{Name: cred.Ident})
+	checkLogout(tb, cfg, result.Cookies)
+}
+
+func TestLiveBadPassword(t *testing.T) {
+	tb, cfg, cred, password := liveAccount(t)
+	_, _, err := cfg.LoginCred(cred, append(password, "-wrong"...))
+	if errors.Is(err, rbxauth.ErrCaptchaRequired) {
+		tb.Skipf("login requires a captcha: %v", err)
+	}
+	if !errors.Is(err, rbxauth.ErrBadCredentials) {
+		tb.Errorf("expected ErrBadCredentials, got %v", err)
+	}
+}
+
+func TestLiveResend(t *testing.T) {
+	tb, _, _, _, result := liveLogin(t)
+	if result.Step == nil {
+		tb.Skip("account does not require two-step verification")
+	}
+	err := result.Step.Resend()
+	switch {
+	case errors.Is(err, rbxauth.ErrResendUnsupported):
+		tb.Skipf("codes of media type %s cannot be resent", result.Step.MediaType)
+	case errors.Is(err, rbxauth.ErrResendCooldown):
+		tb.Skipf("resend cooldown: %v", err)
+	case err != nil:
+		tb.Errorf("resend: %v", err)
+	}
+}
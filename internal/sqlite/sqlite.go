@@ -0,0 +1,382 @@
+// The sqlite package implements a minimal reader of SQLite database files,
+// sufficient for reading rows from simple tables.
+//
+// The entire database is read into memory. Write-ahead logs are not read, so
+// changes not yet checkpointed into the main database file are not visible.
+package sqlite
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"strings"
+)
+
+// ErrFormat is returned when a file is not a valid SQLite database.
+var ErrFormat = errors.New("not a valid SQLite database")
+
+// ErrNoTable is returned when a table does not exist.
+var ErrNoTable = errors.New("no such table")
+
+const headerMagic = "SQLite format 3\x00"
+
+// DB is a read-only SQLite database.
+type DB struct {
+	data     []byte
+	pageSize int
+	usable   int
+}
+
+// Open reads the database file at path.
+func Open(path string) (*DB, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return New(data)
+}
+
+// New returns a DB that reads from data.
+func New(data []byte) (*DB, error) {
+	if len(data) < 100 || string(data[:16]) != headerMagic {
+		return nil, ErrFormat
+	}
+	pageSize := int(binary.BigEndian.Uint16(data[16:18]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	if pageSize < 512 || pageSize&(pageSize-1) != 0 {
+		return nil, ErrFormat
+	}
+	if enc := binary.BigEndian.Uint32(data[56:60]); enc > 1 {
+		return nil, fmt.Errorf("unsupported text encoding %d", enc)
+	}
+	return &DB{
+		data:     data,
+		pageSize: pageSize,
+		usable:   pageSize - int(data[20]),
+	}, nil
+}
+
+// Row maps the name of each column to its value. A value is one of nil,
+// int64, float64, string, or []byte.
+type Row map[string]interface{}
+
+// Rows returns each row of the given table.
+func (db *DB) Rows(table string) (rows []Row, err error) {
+	var root int
+	var columns []string
+	var rowidColumn int
+	err = db.walk(1, func(rowid int64, values []interface{}) error {
+		if len(values) < 5 || values[0] != "table" {
+			return nil
+		}
+		if name, _ := values[1].(string); !strings.EqualFold(name, table) {
+			return nil
+		}
+		page, _ := values[3].(int64)
+		sql, _ := values[4].(string)
+		root = int(page)
+		columns, rowidColumn = parseColumns(sql)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if root == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoTable, table)
+	}
+	err = db.walk(root, func(rowid int64, values []interface{}) error {
+		row := make(Row, len(columns))
+		for i, name := range columns {
+			switch {
+			case i == rowidColumn:
+				// The rowid alias is stored as NULL.
+				row[name] = rowid
+			case i < len(values):
+				row[name] = values[i]
+			default:
+				row[name] = nil
+			}
+		}
+		rows = append(rows, row)
+		return nil
+	})
+	return rows, err
+}
+
+// page returns the content of page n, which starts at 1.
+func (db *DB) page(n int) ([]byte, error) {
+	start := (n - 1) * db.pageSize
+	if n < 1 || start+db.pageSize > len(db.data) {
+		return nil, fmt.Errorf("%w: page %d out of range", ErrFormat, n)
+	}
+	return db.data[start : start+db.pageSize], nil
+}
+
+// walk calls fn with each record of the table b-tree rooted at page n.
+func (db *DB) walk(n int, fn func(rowid int64, values []interface{}) error) error {
+	return db.walkDepth(n, fn, 0)
+}
+
+// maxDepth bounds the depth of a b-tree, guarding against cycles.
+const maxDepth = 64
+
+func (db *DB) walkDepth(n int, fn func(rowid int64, values []interface{}) error, depth int) error {
+	if depth > maxDepth {
+		return fmt.Errorf("%w: b-tree too deep", ErrFormat)
+	}
+	page, err := db.page(n)
+	if err != nil {
+		return err
+	}
+	// The first page is preceded by the file header.
+	offset := 0
+	if n == 1 {
+		offset = 100
+	}
+	header := page[offset:]
+	if len(header) < 8 {
+		return ErrFormat
+	}
+	cells := int(binary.BigEndian.Uint16(header[3:5]))
+	switch header[0] {
+	case 0x05: // Interior table.
+		if len(header) < 12+cells*2 {
+			return ErrFormat
+		}
+		for i := 0; i < cells; i++ {
+			ptr := int(binary.BigEndian.Uint16(header[12+i*2:]))
+			if ptr+4 > len(page) {
+				return ErrFormat
+			}
+			child := int(binary.BigEndian.Uint32(page[ptr:]))
+			if err := db.walkDepth(child, fn, depth+1); err != nil {
+				return err
+			}
+		}
+		right := int(binary.BigEndian.Uint32(header[8:12]))
+		return db.walkDepth(right, fn, depth+1)
+	case 0x0D: // Leaf table.
+		if len(header) < 8+cells*2 {
+			return ErrFormat
+		}
+		for i := 0; i < cells; i++ {
+			ptr := int(binary.BigEndian.Uint16(header[8+i*2:]))
+			if ptr >= len(page) {
+				return ErrFormat
+			}
+			rowid, payload, err := db.leafCell(page[ptr:])
+			if err != nil {
+				return err
+			}
+			values, err := parseRecord(payload)
+			if err != nil {
+				return err
+			}
+			if err := fn(rowid, values); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: unexpected page type %#x", ErrFormat, header[0])
+}
+
+// leafCell returns the rowid and full payload of a table leaf cell.
+func (db *DB) leafCell(cell []byte) (rowid int64, payload []byte, err error) {
+	size, n := varint(cell)
+	if n == 0 {
+		return 0, nil, ErrFormat
+	}
+	cell = cell[n:]
+	id, n := varint(cell)
+	if n == 0 {
+		return 0, nil, ErrFormat
+	}
+	cell = cell[n:]
+	rowid = int64(id)
+
+	total := int(size)
+	if total < 0 || total > len(db.data) {
+		return 0, nil, ErrFormat
+	}
+	local := db.localSize(total)
+	if local > len(cell) {
+		return 0, nil, ErrFormat
+	}
+	if local == total {
+		return rowid, cell[:total], nil
+	}
+
+	// Follow overflow pages.
+	var buf bytes.Buffer
+	buf.Write(cell[:local])
+	if local+4 > len(cell) {
+		return 0, nil, ErrFormat
+	}
+	next := int(binary.BigEndian.Uint32(cell[local:]))
+	for pages := 0; buf.Len() < total; pages++ {
+		if next == 0 || pages > len(db.data)/db.pageSize {
+			return 0, nil, fmt.Errorf("%w: truncated overflow", ErrFormat)
+		}
+		page, err := db.page(next)
+		if err != nil {
+			return 0, nil, err
+		}
+		next = int(binary.BigEndian.Uint32(page))
+		chunk := page[4:db.usable]
+		if remaining := total - buf.Len(); len(chunk) > remaining {
+			chunk = chunk[:remaining]
+		}
+		buf.Write(chunk)
+	}
+	return rowid, buf.Bytes(), nil
+}
+
+// localSize returns the number of bytes of a table leaf payload of the given
+// size that are stored on the page.
+func (db *DB) localSize(size int) int {
+	u := db.usable
+	x := u - 35
+	if size <= x {
+		return size
+	}
+	m := ((u-12)*32)/255 - 23
+	k := m + (size-m)%(u-4)
+	if k <= x {
+		return k
+	}
+	return m
+}
+
+// varint decodes a SQLite variable-length integer. Returns the number of bytes
+// read, or 0 if b is too short.
+func varint(b []byte) (v uint64, n int) {
+	for i := 0; i < 9; i++ {
+		if i >= len(b) {
+			return 0, 0
+		}
+		if i == 8 {
+			return v<<8 | uint64(b[i]), 9
+		}
+		v = v<<7 | uint64(b[i]&0x7F)
+		if b[i]&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	return v, 9
+}
+
+// parseRecord decodes the values of a record.
+func parseRecord(payload []byte) (values []interface{}, err error) {
+	hsize, n := varint(payload)
+	if n == 0 || hsize < uint64(n) || hsize > uint64(len(payload)) {
+		return nil, ErrFormat
+	}
+	header := payload[n:hsize]
+	body := payload[hsize:]
+	for len(header) > 0 {
+		typ, n := varint(header)
+		if n == 0 {
+			return nil, ErrFormat
+		}
+		header = header[n:]
+
+		var size int
+		switch {
+		case typ <= 4:
+			size = int(typ)
+		case typ == 5:
+			size = 6
+		case typ == 6, typ == 7:
+			size = 8
+		case typ == 8, typ == 9:
+			size = 0
+		case typ >= 12:
+			size = int((typ - 12) / 2)
+		default:
+			return nil, fmt.Errorf("%w: reserved serial type %d", ErrFormat, typ)
+		}
+		if size > len(body) {
+			return nil, ErrFormat
+		}
+		b := body[:size]
+		body = body[size:]
+
+		switch {
+		case typ == 0:
+			values = append(values, nil)
+		case typ >= 1 && typ <= 6:
+			// Big-endian two's complement integer.
+			var v int64
+			if b[0]&0x80 != 0 {
+				v = -1
+			}
+			for _, c := range b {
+				v = v<<8 | int64(c)
+			}
+			values = append(values, v)
+		case typ == 7:
+			values = append(values, math.Float64frombits(binary.BigEndian.Uint64(b)))
+		case typ == 8:
+			values = append(values, int64(0))
+		case typ == 9:
+			values = append(values, int64(1))
+		case typ%2 == 0:
+			values = append(values, append([]byte(nil), b...))
+		default:
+			values = append(values, string(b))
+		}
+	}
+	return values, nil
+}
+
+// parseColumns returns the names of the columns declared by a CREATE TABLE
+// statement, and the index of the column that is an alias for the rowid, or -1
+// if there is no such column.
+func parseColumns(sql string) (columns []string, rowid int) {
+	rowid = -1
+	i := strings.IndexByte(sql, '(')
+	j := strings.LastIndexByte(sql, ')')
+	if i < 0 || j < i {
+		return nil, rowid
+	}
+	var defs []string
+	depth, start := 0, i+1
+	for k := i + 1; k < j; k++ {
+		switch sql[k] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				defs = append(defs, sql[start:k])
+				start = k + 1
+			}
+		}
+	}
+	defs = append(defs, sql[start:j])
+	for _, def := range defs {
+		fields := strings.Fields(def)
+		if len(fields) == 0 {
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "CONSTRAINT", "PRIMARY", "UNIQUE", "CHECK", "FOREIGN":
+			continue
+		}
+		if len(fields) >= 4 &&
+			strings.EqualFold(fields[1], "INTEGER") &&
+			strings.EqualFold(fields[2], "PRIMARY") &&
+			strings.EqualFold(fields[3], "KEY") {
+			rowid = len(columns)
+		}
+		columns = append(columns, strings.Trim(fields[0], "\"`[]'"))
+	}
+	return columns, rowid
+}
@@ -0,0 +1,117 @@
+package rbxauth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// LogEvent describes a single HTTP exchange made with the API. Request and
+// response bodies, passwords, tokens, and cookie values are never included.
+type LogEvent struct {
+	// Method is the method of the request.
+	Method string
+	// URL is the URL of the request.
+	URL string
+	// Status is the status code of the response, or 0 if no response was
+	// received.
+	Status int
+	// TokenSent is whether the request included a CSRF token.
+	TokenSent bool
+	// TokenReceived is whether the response included a CSRF token.
+	TokenReceived bool
+	// Cookies lists the names of cookies set by the response.
+	Cookies []string
+	// Elapsed is the duration of the exchange.
+	Elapsed time.Duration
+	// Codes lists the error codes returned by the API.
+	Codes []int
+	// Retry is whether the request will be sent again due to failed token
+	// validation.
+	Retry bool
+	// Err is the error that occurred during the exchange, if any.
+	Err error
+}
+
+// String returns a human-readable representation of the event.
+func (e LogEvent) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s", e.Method, e.URL)
+	if e.Status != 0 {
+		fmt.Fprintf(&b, " %d", e.Status)
+	}
+	fmt.Fprintf(&b, " (%s", e.Elapsed.Round(time.Millisecond))
+	if e.TokenSent {
+		b.WriteString(", token sent")
+	}
+	if e.TokenReceived {
+		b.WriteString(", token received")
+	}
+	b.WriteString(")")
+	if len(e.Cookies) > 0 {
+		fmt.Fprintf(&b, " cookies %s", strings.Join(e.Cookies, ","))
+	}
+	if len(e.Codes) > 0 {
+		fmt.Fprintf(&b, " codes %v", e.Codes)
+	}
+	if e.Retry {
+		b.WriteString(" retrying")
+	} else if e.Err != nil {
+		fmt.Fprintf(&b, ": %s", e.Err)
+	}
+	return b.String()
+}
+
+// redactedFields lists the fields of request bodies that are redacted by
+// dumpRequest.
+var redactedFields = map[string]bool{
+	"password":          true,
+	"currentPassword":   true,
+	"newPassword":       true,
+	"passwordRepeated":  true,
+	"pin":               true,
+	"code":              true,
+	"authorizationCode": true,
+}
+
+// redact replaces the values of redactedFields within v.
+func redact(v interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			if redactedFields[k] {
+				v[k] = "***"
+				continue
+			}
+			redact(e)
+		}
+	case []interface{}:
+		for _, e := range v {
+			redact(e)
+		}
+	}
+}
+
+// dumpRequest writes the method, URL, and body of req to DumpRequests. Fields
+// of a JSON body that hold secrets are redacted.
+func (c *Config) dumpRequest(req *http.Request) {
+	fmt.Fprintf(c.DumpRequests, "%s %s\n", req.Method, req.URL)
+	if req.GetBody == nil {
+		return
+	}
+	r, err := req.GetBody()
+	if err != nil {
+		return
+	}
+	defer r.Close()
+	var body interface{}
+	if err := json.NewDecoder(r).Decode(&body); err != nil {
+		fmt.Fprintln(c.DumpRequests, "(body is not JSON)")
+		return
+	}
+	redact(body)
+	b, _ := json.MarshalIndent(body, "", "\t")
+	fmt.Fprintf(c.DumpRequests, "%s\n", b)
+}
@@ -0,0 +1,330 @@
+package rbxauth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Messages is a catalog of the prompts, warnings, and status lines written by
+// a Stream, including those passed to Prompter.Notify by an interactive login.
+// Replacing the catalog of a Stream translates its output.
+//
+// A message that receives arguments is a format string, whose verbs are
+// documented by its field. Arguments may be reordered with explicit indexes,
+// such as "%[2]s %[1]d", in which case a literal percent sign is written as
+// "%%". A message without arguments is written as is. Prompts do not end with
+// a newline, while other messages are written as a line.
+type Messages struct {
+	// CaptchaWarning warns that a login is likely to fail because a captcha
+	// is enforced.
+	CaptchaWarning string
+	// NotTerminal warns that the password will not be masked.
+	NotTerminal string
+
+	// AskCredType prompts for a credential type of a login.
+	AskCredType string
+	// UnknownCredType reports that the entered credential type (%q) is
+	// unknown.
+	UnknownCredType string
+	// AskUsername, AskEmail, and AskPhoneNumber prompt for an identifier of
+	// the corresponding type. AskAnyIdent prompts for an identifier whose
+	// type is detected. AskIdent prompts for an identifier of any other type
+	// (%s).
+	AskUsername    string
+	AskEmail       string
+	AskPhoneNumber string
+	AskAnyIdent    string
+	AskIdent       string
+	// AskUsernameOrID asks whether an identifier (%s) is a username or a
+	// user ID.
+	AskUsernameOrID string
+	// ConfirmCredType asks whether a detected credential type (%s) is
+	// correct, to be answered with Yes or No.
+	ConfirmCredType string
+	// AskPassword prompts for a password. AskPasswordFor prompts for the
+	// password of an identifier (%s).
+	AskPassword    string
+	AskPasswordFor string
+	// IncorrectPassword reports that the password was incorrect, followed by
+	// the number of the next attempt (%d) and the number of attempts (%d).
+	IncorrectPassword string
+	// ParentalConsent reports that a login requires parental consent, and
+	// AgeRestricted that a login is refused due to the age or region of the
+	// account. ConsentURL follows either with the page (%s) where the
+	// problem is resolved, if known.
+	ParentalConsent string
+	AgeRestricted   string
+	ConsentURL      string
+
+	// CodeSentEmail, CodeSentSMS, CodeAuthenticator, and CodeRecovery
+	// report how the two-step verification code is received, followed by the
+	// duration until the code expires (%s). CodeSent is used for any other
+	// media type (%s), followed by the duration (%s).
+	CodeSentEmail     string
+	CodeSentSMS       string
+	CodeAuthenticator string
+	CodeRecovery      string
+	CodeSent          string
+	// AskCode prompts for a verification code.
+	AskCode string
+	// IncorrectCode reports that a verification code was incorrect.
+	IncorrectCode string
+	// CodeResent reports that a code was resent via a media type (%s).
+	CodeResent string
+	// ResendUnsupported reports that a code cannot be resent via a media type
+	// (%s).
+	ResendUnsupported string
+	// ResendWait reports the duration (%s) to wait before resending a code.
+	// ResendWaitUnknown is used when the duration is not known.
+	ResendWait        string
+	ResendWaitUnknown string
+	// AskRememberDevice asks whether the device should be remembered, to be
+	// answered with Yes or No.
+	AskRememberDevice string
+
+	// ApproveLogin reports that a login is waiting to be approved.
+	ApproveLogin string
+
+	// AskItems prompts for the numbers of the items selected as the answer
+	// to a security question. InvalidItem reports that an entered item (%q)
+	// is invalid. IncorrectAnswer reports that the answer was incorrect.
+	AskItems        string
+	InvalidItem     string
+	IncorrectAnswer string
+
+	// PendingPasswordReset, PendingTermsAcceptance, and
+	// PendingEmailVerification warn that the user must take the
+	// corresponding PendingAction before the session can be used fully.
+	PendingPasswordReset     string
+	PendingTermsAcceptance   string
+	PendingEmailVerification string
+
+	// AskPIN prompts for the account PIN.
+	AskPIN string
+	// PINUnlocked reports the time (%s) until which the account is unlocked.
+	PINUnlocked string
+	// IncorrectPIN reports that the PIN was incorrect.
+	IncorrectPIN string
+	// PINNotSet reports that the account does not have a PIN.
+	PINNotSet string
+	// PINLocked reports that the account could not be unlocked, followed by
+	// the error (%v).
+	PINLocked string
+
+	// AskUserID prompts for a user ID. InvalidUserID reports that an entered
+	// user ID (%q) is invalid.
+	AskUserID     string
+	InvalidUserID string
+
+	// AskResetCredType prompts for the credential type of a password reset.
+	AskResetCredType string
+	// ResetCodeSentEmail reports that a password reset code was sent by
+	// email. ResetCodeSent reports that it was sent via another media type
+	// (%s), and ResetCodeResent that it was resent via a media type (%s).
+	ResetCodeSentEmail string
+	ResetCodeSent      string
+	ResetCodeResent    string
+	// MultipleAccounts introduces the list of accounts associated with the
+	// credentials of a password reset. AskResetUser prompts for one of the
+	// accounts, with the first as the default (%d).
+	MultipleAccounts string
+	AskResetUser     string
+	// AskNewPassword and ConfirmNewPassword prompt for a new password and
+	// its confirmation. PasswordMismatch reports that they differ, and
+	// PasswordNotAcceptable that the password was rejected.
+	AskNewPassword        string
+	ConfirmNewPassword    string
+	PasswordMismatch      string
+	PasswordNotAcceptable string
+
+	// Yes and No list the answers to a yes-or-no prompt that are accepted as
+	// affirmative and negative, compared case-insensitively.
+	Yes []string
+	No  []string
+}
+
+// DefaultMessages is the catalog used by a Stream when Stream.Messages is nil.
+var DefaultMessages = Messages{
+	CaptchaWarning: "Warning: captcha is enforced, login is likely to fail",
+	NotTerminal:    "Warning: input is not a terminal, so the password will not be masked",
+
+	AskCredType:       "Enter credential type ((Username), Email, PhoneNumber): ",
+	UnknownCredType:   "Unknown credential type %q",
+	AskUsername:       "Enter username: ",
+	AskEmail:          "Enter email: ",
+	AskPhoneNumber:    "Enter phone number: ",
+	AskAnyIdent:       "Enter username, email, or phone number: ",
+	AskIdent:          "Enter %s: ",
+	AskUsernameOrID:   "Is %s a username or a user ID? ((username), id): ",
+	ConfirmCredType:   "Detected %s, correct? ((yes), no): ",
+	AskPassword:       "Enter password: ",
+	AskPasswordFor:    "Enter password for %s: ",
+	IncorrectPassword: "Incorrect password, try again (attempt %d/%d)",
+	ParentalConsent:   "This account requires consent from a parent before it can log in",
+	AgeRestricted:     "This account cannot log in until its age is verified, or cannot log in from this region",
+	ConsentURL:        "Visit %s to continue",
+
+	CodeSentEmail:     "Two-step verification code sent to your email address (expires in %s)",
+	CodeSentSMS:       "Two-step verification code sent via SMS (expires in %s)",
+	CodeAuthenticator: "Open your authenticator app for the two-step verification code (expires in %s)",
+	CodeRecovery:      "Enter a recovery code for two-step verification (expires in %s)",
+	CodeSent:          "Two-step verification code sent via %s (expires in %s)",
+	AskCode:           "Enter code (leave empty to resend): ",
+	IncorrectCode:     "Incorrect code, try again",
+	CodeResent:        "Resent verification code via %s",
+	ResendUnsupported: "Code cannot be resent via %s",
+	ResendWait:        "Wait %s before resending",
+	ResendWaitUnknown: "Wait before resending",
+	AskRememberDevice: "Remember device? ((no), yes): ",
+
+	ApproveLogin: "Check your email and approve the login, waiting...",
+
+	AskItems:        "Enter the numbers of the selected items: ",
+	InvalidItem:     "Invalid item %q",
+	IncorrectAnswer: "Incorrect answer, try again",
+
+	PendingPasswordReset:     "Warning: the password of the account must be reset before the session can be used fully",
+	PendingTermsAcceptance:   "Warning: the terms of use must be accepted before the session can be used fully",
+	PendingEmailVerification: "Warning: the email address of the account must be verified before the session can be used fully",
+
+	AskPIN:       "Enter account PIN (leave empty to skip): ",
+	PINUnlocked:  "Account unlocked until %s",
+	IncorrectPIN: "Incorrect PIN",
+	PINNotSet:    "Account does not have a PIN",
+	PINLocked:    "Account remains locked: %v",
+
+	AskUserID:     "Enter user ID: ",
+	InvalidUserID: "Invalid user ID %q",
+
+	AskResetCredType:      "Enter credential type ((Email), PhoneNumber): ",
+	ResetCodeSentEmail:    "Password reset code sent to your email address",
+	ResetCodeSent:         "Password reset code sent via %s",
+	ResetCodeResent:       "Resent password reset code via %s",
+	MultipleAccounts:      "Multiple accounts are associated with the credentials:",
+	AskResetUser:          "Enter the user ID of the account ((%d)): ",
+	AskNewPassword:        "Enter new password: ",
+	ConfirmNewPassword:    "Confirm new password: ",
+	PasswordMismatch:      "Passwords do not match, try again",
+	PasswordNotAcceptable: "Password is not acceptable, try again",
+
+	Yes: []string{"yes", "y"},
+	No:  []string{"no", "n"},
+}
+
+// LoadMessages reads a catalog encoded as a JSON object from r. Each key is
+// the name of a field of Messages. Messages missing from the object are those
+// of DefaultMessages.
+//
+// Returns an error if a key is unknown, or if a message does not receive the
+// same arguments as the corresponding default.
+func LoadMessages(r io.Reader) (*Messages, error) {
+	m := DefaultMessages
+	m.Yes = append([]string(nil), m.Yes...)
+	m.No = append([]string(nil), m.No...)
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&m); err != nil {
+		return nil, fmt.Errorf("load messages: %w", err)
+	}
+	v := reflect.ValueOf(&m).Elem()
+	def := reflect.ValueOf(&DefaultMessages).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if v.Field(i).Kind() != reflect.String {
+			continue
+		}
+		want, _ := formatVerbs(def.Field(i).String())
+		if want == "" {
+			continue
+		}
+		got, err := formatVerbs(v.Field(i).String())
+		if err == nil && sortVerbs(got) != sortVerbs(want) {
+			err = fmt.Errorf("must contain format verbs %s, found %s", verbList(want), verbList(got))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("load messages: %s: %w", v.Type().Field(i).Name, err)
+		}
+	}
+	return &m, nil
+}
+
+// sortVerbs returns verbs in sorted order, so that verbs can be compared
+// regardless of their order.
+func sortVerbs(verbs string) string {
+	b := []byte(verbs)
+	sort.Slice(b, func(i, j int) bool { return b[i] < b[j] })
+	return string(b)
+}
+
+// answer returns whether text is one of Yes or No. If text is empty, then def
+// is returned. Returns false for ok if text is neither.
+func (m *Messages) answer(text string, def bool) (yes, ok bool) {
+	if text = strings.TrimSpace(text); text == "" {
+		return def, true
+	}
+	for _, s := range m.Yes {
+		if strings.EqualFold(text, s) {
+			return true, true
+		}
+	}
+	for _, s := range m.No {
+		if strings.EqualFold(text, s) {
+			return false, true
+		}
+	}
+	return false, false
+}
+
+// codeSent returns the message reporting that a code was sent via media,
+// expiring after remaining.
+func (m *Messages) codeSent(media MediaType, remaining time.Duration) string {
+	remaining = remaining.Round(time.Second)
+	switch media {
+	case MediaEmail:
+		return fmt.Sprintf(m.CodeSentEmail, remaining)
+	case MediaSMS:
+		return fmt.Sprintf(m.CodeSentSMS, remaining)
+	case MediaAuthenticator:
+		return fmt.Sprintf(m.CodeAuthenticator, remaining)
+	case MediaRecoveryCode:
+		return fmt.Sprintf(m.CodeRecovery, remaining)
+	}
+	return fmt.Sprintf(m.CodeSent, media, remaining)
+}
+
+// pendingAction returns the message warning of action, or an empty string if
+// the action is unknown.
+func (m *Messages) pendingAction(action PendingAction) string {
+	switch action {
+	case PendingPasswordReset:
+		return m.PendingPasswordReset
+	case PendingTermsAcceptance:
+		return m.PendingTermsAcceptance
+	case PendingEmailVerification:
+		return m.PendingEmailVerification
+	}
+	return ""
+}
+
+// MessagePrompter is implemented by a Prompter that provides the catalog of
+// the messages passed to its Notify method by an interactive login.
+type MessagePrompter interface {
+	Prompter
+	// PromptMessages returns the catalog of messages.
+	PromptMessages() *Messages
+}
+
+// messagesOf returns the catalog of p, or DefaultMessages if p does not
+// provide one.
+func messagesOf(p Prompter) *Messages {
+	if p, ok := p.(MessagePrompter); ok {
+		if m := p.PromptMessages(); m != nil {
+			return m
+		}
+	}
+	return &DefaultMessages
+}
@@ -0,0 +1,141 @@
+package rbxauth
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// DefaultMetadataTTL is the duration for which metadata is cached when
+// Config.MetadataTTL is zero.
+const DefaultMetadataTTL = 10 * time.Minute
+
+// AuthMetadata describes settings of the authentication API, such as whether
+// a login is likely to require a captcha.
+type AuthMetadata struct {
+	// IsUpdateUsernameEnabled is whether usernames can be changed.
+	IsUpdateUsernameEnabled bool
+	// FtuxAvatarAssetMap configures the avatar of a new user.
+	FtuxAvatarAssetMap string
+	// CaptchaEnforced is whether a captcha is enforced when logging in.
+	CaptchaEnforced bool
+	// TwoStepV2 is whether the newer two-step verification flow is used.
+	TwoStepV2 bool
+
+	// Raw contains fields of the response not otherwise known.
+	Raw map[string]json.RawMessage
+}
+
+// metadataFields lists the fields of AuthMetadata as they appear in the
+// response.
+type metadataFields struct {
+	IsUpdateUsernameEnabled bool   `json:"isUpdateUsernameEnabled"`
+	FtuxAvatarAssetMap      string `json:"ftuxAvatarAssetMap"`
+	CaptchaEnforced         bool   `json:"isCaptchaEnforced"`
+	TwoStepV2               bool   `json:"isTwoStepVerificationV2Enabled"`
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. Fields that are not
+// known are retained in Raw.
+func (m *AuthMetadata) UnmarshalJSON(b []byte) error {
+	var fields metadataFields
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	for _, name := range []string{
+		"isUpdateUsernameEnabled",
+		"ftuxAvatarAssetMap",
+		"isCaptchaEnforced",
+		"isTwoStepVerificationV2Enabled",
+	} {
+		delete(raw, name)
+	}
+	if len(raw) == 0 {
+		raw = nil
+	}
+	*m = AuthMetadata{
+		IsUpdateUsernameEnabled: fields.IsUpdateUsernameEnabled,
+		FtuxAvatarAssetMap:      fields.FtuxAvatarAssetMap,
+		CaptchaEnforced:         fields.CaptchaEnforced,
+		TwoStepV2:               fields.TwoStepV2,
+		Raw:                     raw,
+	}
+	return nil
+}
+
+// MetadataCache holds the result of Config.Metadata so that it can be shared
+// between requests.
+type MetadataCache struct {
+	mu      sync.Mutex
+	meta    *AuthMetadata
+	expires time.Time
+}
+
+// get returns the cached metadata if it has not expired at now.
+func (m *MetadataCache) get(now time.Time) *AuthMetadata {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.meta == nil || !now.Before(m.expires) {
+		return nil
+	}
+	return m.meta
+}
+
+// set caches meta until expires.
+func (m *MetadataCache) set(meta *AuthMetadata, expires time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.meta = meta
+	m.expires = expires
+}
+
+// Reset discards the cached metadata.
+func (m *MetadataCache) Reset() {
+	m.set(nil, time.Time{})
+}
+
+// Metadata returns settings of the authentication API. If MetadataCache is
+// set, the result is cached for MetadataTTL. The returned value must not be
+// modified.
+func (c Config) Metadata() (*AuthMetadata, error) {
+	return c.MetadataContext(context.Background())
+}
+
+// MetadataContext is like Metadata, but with a context.
+func (c Config) MetadataContext(ctx context.Context) (meta *AuthMetadata, err error) {
+	defer wrapOp("metadata", &err)
+
+	if c.MetadataCache != nil {
+		if meta := c.MetadataCache.get(c.now()); meta != nil {
+			return meta, nil
+		}
+	}
+
+	endpoint := c.MetadataEndpoint
+	if endpoint == "" {
+		endpoint = DefaultMetadataEndpoint
+	}
+	req, err := c.newRequest(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	meta = &AuthMetadata{}
+	if _, err = c.requestAPI("metadata", req, meta); err != nil {
+		return nil, err
+	}
+	if c.MetadataCache != nil {
+		ttl := c.MetadataTTL
+		if ttl <= 0 {
+			ttl = DefaultMetadataTTL
+		}
+		c.MetadataCache.set(meta, c.now().Add(ttl))
+	}
+	return meta, nil
+}
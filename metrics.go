@@ -0,0 +1,83 @@
+package rbxauth
+
+import (
+	"errors"
+	"time"
+)
+
+// Metrics receives measurements from a Config. Implementations must be safe
+// for concurrent use. Measurements never include credentials, tokens, or
+// cookie values.
+type Metrics interface {
+	// ObserveRequest is called after each exchange made with the API,
+	// including exchanges retried due to failed token validation. op names
+	// the operation, such as "login" or "verify". status is the HTTP status
+	// of the response, or 0 if no response was received. dur is the duration
+	// of the exchange. err is the error that resulted from the exchange, if
+	// any. If the exchange will be retried, err matches ErrTokenRetry.
+	ObserveRequest(op string, status int, dur time.Duration, err error)
+
+	// ObserveEvent is called when a notable event occurs, such as a login
+	// requiring two-step verification. event is one of the Event constants.
+	ObserveEvent(event string)
+}
+
+// These events are passed to Metrics.ObserveEvent.
+const (
+	// EventTwoStepRequired indicates that a login required two-step
+	// verification.
+	EventTwoStepRequired = "twostep-required"
+	// EventApprovalRequired indicates that a login required approval from
+	// another device.
+	EventApprovalRequired = "approval-required"
+	// EventQuestionsRequired indicates that a login required security
+	// questions to be answered.
+	EventQuestionsRequired = "questions-required"
+	// EventResend indicates that a verification code was resent.
+	EventResend = "resend"
+	// EventVerifySuccess indicates that a verification code was accepted.
+	EventVerifySuccess = "verify-success"
+	// EventVerifyFailure indicates that a verification code was rejected, or
+	// could not be verified.
+	EventVerifyFailure = "verify-failure"
+)
+
+// ErrTokenRetry is matched by the error passed to Metrics.ObserveRequest when
+// an exchange failed token validation, and will be retried with a new token.
+var ErrTokenRetry = errors.New("token validation failed")
+
+// tokenRetryError marks an exchange that will be retried.
+type tokenRetryError struct {
+	err error
+}
+
+func (e *tokenRetryError) Error() string {
+	if e.err == nil {
+		return ErrTokenRetry.Error()
+	}
+	return ErrTokenRetry.Error() + ": " + e.err.Error()
+}
+
+func (e *tokenRetryError) Is(target error) bool {
+	return target == ErrTokenRetry
+}
+
+func (e *tokenRetryError) Unwrap() error {
+	return e.err
+}
+
+// NopMetrics is a Metrics that discards all measurements.
+type NopMetrics struct{}
+
+// ObserveRequest implements Metrics.
+func (NopMetrics) ObserveRequest(op string, status int, dur time.Duration, err error) {}
+
+// ObserveEvent implements Metrics.
+func (NopMetrics) ObserveEvent(event string) {}
+
+// observeEvent passes event to the Metrics of c, if any.
+func (c *Config) observeEvent(event string) {
+	if c.Metrics != nil {
+		c.Metrics.ObserveEvent(event)
+	}
+}
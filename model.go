@@ -1,6 +1,8 @@
 package rbxauth
 
 import (
+	"bytes"
+	"encoding/json"
 	"strconv"
 	"strings"
 )
@@ -10,6 +12,9 @@ type ErrorResponse struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
 	Field   string `json:"field,omitempty"`
+	// FieldData contains additional data associated with the error, usually
+	// encoded as a JSON string.
+	FieldData string `json:"fieldData,omitempty"`
 }
 
 // Error implements the error interface.
@@ -17,9 +22,33 @@ func (err ErrorResponse) Error() string {
 	return "response code " + strconv.Itoa(err.Code) + ": " + err.Message
 }
 
+// Is returns whether target is an ErrorResponse with the same code,
+// regardless of message.
+func (err ErrorResponse) Is(target error) bool {
+	t, ok := target.(ErrorResponse)
+	return ok && t.Code == err.Code
+}
+
 // errorsResponse implements the errors response model of the API.
 type errorsResponse struct {
-	Errors []ErrorResponse `json:"errors,omitempty"`
+	Errors errorList `json:"errors,omitempty"`
+}
+
+// errorList is a list of ErrorResponses. Some endpoints report a single error
+// as an object rather than an array, which is decoded as a list of one.
+type errorList []ErrorResponse
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (l *errorList) UnmarshalJSON(b []byte) error {
+	if b = bytes.TrimSpace(b); len(b) > 0 && b[0] == '{' {
+		var e ErrorResponse
+		if err := json.Unmarshal(b, &e); err != nil {
+			return err
+		}
+		*l = errorList{e}
+		return nil
+	}
+	return json.Unmarshal(b, (*[]ErrorResponse)(l))
 }
 
 // Error implements the error interface.
@@ -40,6 +69,17 @@ func (err errorsResponse) Unwrap() error {
 	return err.Errors[0]
 }
 
+// Is returns whether target is an ErrorResponse with the same code as any
+// error in the list, so that errors after the first can be matched.
+func (err errorsResponse) Is(target error) bool {
+	for _, e := range err.Errors {
+		if e.Is(target) {
+			return true
+		}
+	}
+	return false
+}
+
 // errResp returns the errorsResponse.
 func (err errorsResponse) errResp() errorsResponse {
 	return err
@@ -49,15 +89,94 @@ func (err errorsResponse) errResp() errorsResponse {
 type loginRequest struct {
 	CredType        string `json:"ctype,omitempty"`
 	CredValue       string `json:"cvalue,omitempty"`
-	Password        string `json:"password,omitempty"`
+	Password        []byte `json:"-"` // Marshaled by MarshalJSON.
 	CaptchaToken    string `json:"captchaToken,omitempty"`
+	CaptchaID       string `json:"captchaId,omitempty"`
 	CaptchaProvider string `json:"captchaProvider,omitempty"`
+
+	DeviceMeta       *deviceMeta       `json:"deviceMeta,omitempty"`
+	SecureAuthIntent *secureAuthIntent `json:"secureAuthenticationIntent,omitempty"`
+}
+
+// deviceMeta implements the request model of the metadata of a device.
+type deviceMeta struct {
+	DeviceType string `json:"deviceType,omitempty"`
+	OS         string `json:"os,omitempty"`
+	DeviceID   string `json:"deviceId,omitempty"`
+}
+
+// secureAuthIntent implements the SecureAuthenticationIntentModel API model.
+type secureAuthIntent struct {
+	ClientPublicKey      string `json:"clientPublicKey"`
+	ClientEpochTimestamp int64  `json:"clientEpochTimestamp"`
+	SAISignature         string `json:"saiSignature"`
+	ServerNonce          string `json:"serverNonce"`
+}
+
+// socialLoginRequest implements the request model of a social login.
+type socialLoginRequest struct {
+	AuthorizationCode string `json:"authorizationCode"`
+}
+
+// signupRequest implements the SignupRequest API model.
+type signupRequest struct {
+	Username        string   `json:"username"`
+	Password        []byte   `json:"-"` // Marshaled by MarshalJSON.
+	Birthday        string   `json:"birthday"`
+	Gender          string   `json:"gender,omitempty"`
+	IsTosAgreed     bool     `json:"isTosAgreementBoxChecked"`
+	AgreementIDs    []string `json:"agreementIds"`
+	CaptchaToken    string   `json:"captchaToken,omitempty"`
+	CaptchaProvider string   `json:"captchaProvider,omitempty"`
+}
+
+// signupResponse implements the SignupResponse API model.
+type signupResponse struct {
+	UserID         int64 `json:"userId"`
+	StarterPlaceID int64 `json:"starterPlaceId,omitempty"`
+	errorsResponse
+}
+
+// usernameValidationRequest implements the UsernameValidationRequest API
+// model.
+type usernameValidationRequest struct {
+	Username string `json:"username"`
+	Birthday string `json:"birthday,omitempty"`
+	Context  string `json:"context"`
+}
+
+// usernameValidationResponse implements the UsernameValidationResponse API
+// model.
+type usernameValidationResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	errorsResponse
+}
+
+// usernameRecommendationRequest implements the request model of the username
+// recommendation endpoint.
+type usernameRecommendationRequest struct {
+	Username string `json:"username"`
+}
+
+// usernameRecommendationResponse implements the response model of the
+// username recommendation endpoint.
+type usernameRecommendationResponse struct {
+	DidGenerateNewUsername bool     `json:"didGenerateNewUsername"`
+	SuggestedUsernames     []string `json:"suggestedUsernames"`
+	errorsResponse
 }
 
 // loginResponse implements the LoginResponse API model.
 type loginResponse struct {
-	User                    *userResponseV2                  `json:"user,omitempty"`
-	TwoStepVerificationData *twoStepVerificationSentResponse `json:"twoStepVerificationData,omitempty"`
+	User                            *userResponseV2                  `json:"user,omitempty"`
+	TwoStepVerificationData         *twoStepVerificationSentResponse `json:"twoStepVerificationData,omitempty"`
+	IdentityVerificationLoginTicket string                           `json:"identityVerificationLoginTicket,omitempty"`
+	SecurityQuestionChallengeID     string                           `json:"securityQuestionChallengeId,omitempty"`
+	// Actions the user must take before the session can be used fully.
+	ShouldResetPassword bool `json:"shouldResetPassword,omitempty"`
+	ShouldAcceptTerms   bool `json:"shouldAcceptTerms,omitempty"`
+	ShouldUpdateEmail   bool `json:"shouldUpdateEmail,omitempty"`
 	errorsResponse
 }
 
@@ -76,7 +195,38 @@ type twoStepVerificationSentResponse struct {
 	Ticket string `json:"ticket,omitempty"`
 }
 
-// userResponse implements the response to a UserIDEndpoint request.
+// userResponseV1 implements the response to a UserIDEndpoint request.
+type userResponseV1 struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+	IsBanned    bool   `json:"isBanned"`
+	errorsResponse
+}
+
+// multiGetByUsernameRequest implements the MultiGetByUsernameRequest API model.
+type multiGetByUsernameRequest struct {
+	Usernames          []string `json:"usernames"`
+	ExcludeBannedUsers bool     `json:"excludeBannedUsers"`
+}
+
+// multiGetByUsernameResponse implements the response to a
+// UsernameLookupEndpoint request.
+type multiGetByUsernameResponse struct {
+	Data []multiGetUserByNameResponse `json:"data"`
+	errorsResponse
+}
+
+// multiGetUserByNameResponse implements the MultiGetUserByNameResponse API
+// model.
+type multiGetUserByNameResponse struct {
+	RequestedUsername string `json:"requestedUsername"`
+	ID                int64  `json:"id"`
+	Name              string `json:"name"`
+	DisplayName       string `json:"displayName"`
+}
+
+// userResponse implements the response to a LegacyUserIDEndpoint request.
 type userResponse struct {
 	ID          int64   `json:"Id"`
 	Username    string  `json:"Username"`
@@ -86,6 +236,15 @@ type userResponse struct {
 	errorsResponse
 }
 
+// authenticatedUserResponse implements the AuthenticatedUserResponse API
+// model.
+type authenticatedUserResponse struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+	errorsResponse
+}
+
 // twoStepVerificationVerifyRequest implements the
 // TwoStepVerificationVerifyRequest API model.
 type twoStepVerificationVerifyRequest struct {
@@ -101,3 +260,157 @@ type twoStepVerificationTicketRequest struct {
 	Ticket     string `json:"ticket,omitempty"`
 	ActionType string `json:"actionType,omitempty"`
 }
+
+// twoStepChallengeVerifyRequest implements the request model of the
+// two-step verification challenge verify API.
+type twoStepChallengeVerifyRequest struct {
+	ChallengeID string `json:"challengeId"`
+	ActionType  string `json:"actionType"`
+	Code        string `json:"code"`
+}
+
+// twoStepChallengeVerifyResponse implements the response model of the
+// two-step verification challenge verify API.
+type twoStepChallengeVerifyResponse struct {
+	VerificationToken string `json:"verificationToken"`
+	errorsResponse
+}
+
+// twoStepChallengeSendRequest implements the request model of the two-step
+// verification challenge send-code API.
+type twoStepChallengeSendRequest struct {
+	ChallengeID string `json:"challengeId"`
+	ActionType  string `json:"actionType"`
+}
+
+// twoStepLoginRequest implements the request model used to complete a login
+// with a two-step verification token.
+type twoStepLoginRequest struct {
+	ChallengeID       string `json:"challengeId"`
+	VerificationToken string `json:"verificationToken"`
+	RememberDevice    bool   `json:"rememberDevice"`
+}
+
+// identityVerificationRequest identifies a pending identity verification.
+type identityVerificationRequest struct {
+	Ticket string `json:"ticket"`
+}
+
+// identityVerificationStatusResponse reports the status of a pending identity
+// verification.
+type identityVerificationStatusResponse struct {
+	Status string `json:"status"`
+	errorsResponse
+}
+
+// securityQuestionRequest identifies a security question challenge.
+type securityQuestionRequest struct {
+	ChallengeID string `json:"challengeId"`
+}
+
+// securityQuestionResponse contains the question of a security question
+// challenge.
+type securityQuestionResponse struct {
+	Prompt  string   `json:"prompt"`
+	Choices []string `json:"choices"`
+	errorsResponse
+}
+
+// securityAnswerRequest answers a security question challenge.
+type securityAnswerRequest struct {
+	ChallengeID string   `json:"challengeId"`
+	Answers     []string `json:"answers"`
+}
+
+// pinRequest implements the AccountPinRequest API model.
+type pinRequest struct {
+	PIN string `json:"pin"`
+}
+
+// pinResponse implements the AccountPinResponse API model.
+type pinResponse struct {
+	IsEnabled bool `json:"isEnabled"`
+	// Number of seconds until the PIN is locked again.
+	UnlockedUntil float64 `json:"unlockedUntil"`
+	errorsResponse
+}
+
+// passwordValidationRequest implements the PasswordValidationModel API model.
+type passwordValidationRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// passwordValidationResponse implements the PasswordValidationResponse API
+// model.
+type passwordValidationResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	errorsResponse
+}
+
+// changePasswordRequest implements the PasswordChangeModel API model. It is
+// marshaled by MarshalJSON, so that the passwords can be wiped.
+type changePasswordRequest struct {
+	CurrentPassword []byte
+	NewPassword     []byte
+}
+
+// passwordResetSendRequest implements the request model used to send the
+// code of a password reset.
+type passwordResetSendRequest struct {
+	TargetType string `json:"targetType"`
+	Target     string `json:"target"`
+}
+
+// passwordResetSendResponse implements the response model of sending the code
+// of a password reset.
+type passwordResetSendResponse struct {
+	Nonce string `json:"nonce"`
+	errorsResponse
+}
+
+// passwordResetVerifyRequest implements the request model used to verify the
+// code of a password reset.
+type passwordResetVerifyRequest struct {
+	TargetType string `json:"targetType"`
+	Nonce      string `json:"nonce"`
+	Code       string `json:"code"`
+}
+
+// passwordResetVerifyResponse implements the response model of verifying the
+// code of a password reset.
+type passwordResetVerifyResponse struct {
+	UserTickets []passwordResetUserTicket `json:"userTickets"`
+	errorsResponse
+}
+
+// passwordResetUserTicket implements the ticket that permits the password of
+// an account to be reset.
+type passwordResetUserTicket struct {
+	UserID int64  `json:"userId"`
+	Ticket string `json:"ticket"`
+}
+
+// passwordResetRequest implements the request model used to set a new
+// password. It is marshaled by MarshalJSON, so that the password can be
+// wiped.
+type passwordResetRequest struct {
+	TargetType string
+	Ticket     string
+	UserID     int64
+	Password   []byte
+}
+
+// authTicketRedeemRequest implements the AuthenticationTicketRedeemRequest API
+// model.
+type authTicketRedeemRequest struct {
+	AuthenticationTicket string `json:"authenticationTicket"`
+}
+
+// emailResponse implements the EmailResponse API model.
+type emailResponse struct {
+	EmailAddress string `json:"emailAddress"`
+	Verified     bool   `json:"verified"`
+	errorsResponse
+}
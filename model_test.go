@@ -0,0 +1,163 @@
+package rbxauth
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// models lists each struct type of the package that is encoded as JSON.
+var models = []interface{}{
+	ErrorResponse{},
+	errorsResponse{},
+	loginRequest{},
+	deviceMeta{},
+	secureAuthIntent{},
+	socialLoginRequest{},
+	signupRequest{},
+	signupResponse{},
+	usernameValidationRequest{},
+	usernameValidationResponse{},
+	usernameRecommendationRequest{},
+	usernameRecommendationResponse{},
+	loginResponse{},
+	userResponseV2{},
+	twoStepVerificationSentResponse{},
+	userResponseV1{},
+	multiGetByUsernameRequest{},
+	multiGetByUsernameResponse{},
+	multiGetUserByNameResponse{},
+	userResponse{},
+	authenticatedUserResponse{},
+	twoStepVerificationVerifyRequest{},
+	twoStepVerificationTicketRequest{},
+	twoStepChallengeVerifyRequest{},
+	twoStepChallengeVerifyResponse{},
+	twoStepChallengeSendRequest{},
+	twoStepLoginRequest{},
+	identityVerificationRequest{},
+	identityVerificationStatusResponse{},
+	securityQuestionRequest{},
+	securityQuestionResponse{},
+	securityAnswerRequest{},
+	pinRequest{},
+	pinResponse{},
+	passwordValidationRequest{},
+	passwordValidationResponse{},
+	changePasswordRequest{},
+	passwordResetSendRequest{},
+	passwordResetSendResponse{},
+	passwordResetVerifyRequest{},
+	passwordResetVerifyResponse{},
+	passwordResetUserTicket{},
+	passwordResetRequest{},
+	authTicketRedeemRequest{},
+	emailResponse{},
+
+	challengeMetadata{},
+	continuationMetadata{},
+	jsonCookie{},
+	fixture{},
+	fixtureExchange{},
+	metadataFields{},
+	stepJSON{},
+	sessionFile{},
+}
+
+// TestModelsListed checks that models includes each struct type declared in
+// model.go, and each struct type elsewhere with a json tag, so that new models
+// are audited by TestModelTags.
+func TestModelsListed(t *testing.T) {
+	listed := map[string]bool{}
+	for _, m := range models {
+		listed[reflect.TypeOf(m).Name()] = true
+	}
+
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, ".", func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range pkgs["rbxauth"].Files {
+		isModel := fset.Position(file.Package).Filename == "model.go"
+		ast.Inspect(file, func(n ast.Node) bool {
+			spec, ok := n.(*ast.TypeSpec)
+			if !ok {
+				return true
+			}
+			st, ok := spec.Type.(*ast.StructType)
+			if !ok {
+				return true
+			}
+			tagged := false
+			for _, field := range st.Fields.List {
+				if field.Tag != nil && strings.Contains(field.Tag.Value, "json") {
+					tagged = true
+				}
+			}
+			if (isModel || tagged) && !listed[spec.Name.Name] {
+				t.Errorf("%s: model %s is not listed", fset.Position(spec.Pos()), spec.Name.Name)
+			}
+			return true
+		})
+	}
+}
+
+// TestModelTags checks the json tags of each model, and of the structs they
+// contain. Fields of a model must be tagged, while fields of other structs,
+// such as UserInfo, may use their Go names.
+func TestModelTags(t *testing.T) {
+	listed := map[reflect.Type]bool{}
+	for _, m := range models {
+		listed[reflect.TypeOf(m)] = true
+	}
+	marshaler := reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	visited := map[reflect.Type]bool{}
+	var check func(typ reflect.Type)
+	check = func(typ reflect.Type) {
+		for typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array || typ.Kind() == reflect.Map {
+			typ = typ.Elem()
+		}
+		if typ.Kind() != reflect.Struct || typ.PkgPath() != reflect.TypeOf(ErrorResponse{}).PkgPath() || visited[typ] {
+			return
+		}
+		visited[typ] = true
+		// Fields need not be tagged by a struct that is not a model, or by a
+		// model with its own encoding.
+		exempt := !listed[typ] || typ.Implements(marshaler) || reflect.PtrTo(typ).Implements(marshaler)
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			tag := string(field.Tag)
+			name := typ.Name() + "." + field.Name
+			if strings.Contains(tag, "json: ") || strings.Contains(tag, "json :") {
+				t.Errorf("%s: malformed tag `%s`", name, tag)
+			}
+			value, ok := field.Tag.Lookup("json")
+			if strings.Contains(tag, "json") && !ok {
+				t.Errorf("%s: unparsable tag `%s`", name, tag)
+			}
+			switch {
+			case field.Anonymous:
+			case field.PkgPath != "":
+				if ok {
+					t.Errorf("%s: unexported field is tagged `%s`", name, tag)
+				}
+			case !ok && !exempt:
+				t.Errorf("%s: exported field is not tagged", name)
+			case ok && strings.HasPrefix(value, ",") && !exempt:
+				t.Errorf("%s: tag `%s` does not name the field", name, tag)
+			}
+			check(field.Type)
+		}
+	}
+	for _, m := range models {
+		check(reflect.TypeOf(m))
+	}
+}
@@ -0,0 +1,249 @@
+package rbxauth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// SecurePassword holds a password that can be wiped from memory once it is no
+// longer needed. It can be passed anywhere a password is accepted as a byte
+// slice.
+type SecurePassword []byte
+
+// Wipe overwrites the password with zeros.
+func (p SecurePassword) Wipe() {
+	wipe(p)
+}
+
+// wipe overwrites b with zeros.
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// MarshalJSON implements the json.Marshaler interface. The password is written
+// directly into the result without creating intermediate copies, so that the
+// caller can wipe the result after use. Note that json.Marshal copies the
+// result, so this method should be called directly instead.
+func (r *loginRequest) MarshalJSON() ([]byte, error) {
+	type fields loginRequest
+	b, err := json.Marshal((*fields)(r))
+	if err != nil || r.Password == nil {
+		return b, err
+	}
+	return splicePassword(b, r.Password), nil
+}
+
+// MarshalJSON implements the json.Marshaler interface. Like
+// loginRequest.MarshalJSON, the password is written directly into the result.
+func (r *signupRequest) MarshalJSON() ([]byte, error) {
+	type fields signupRequest
+	b, err := json.Marshal((*fields)(r))
+	if err != nil || r.Password == nil {
+		return b, err
+	}
+	return splicePassword(b, r.Password), nil
+}
+
+// splicePassword returns the JSON object b with a password field spliced into
+// the end. b is wiped.
+func splicePassword(b, password []byte) []byte {
+	var buf bytes.Buffer
+	buf.Grow(len(b) + len(password)*2 + 16)
+	buf.Write(b[:len(b)-1])
+	if len(b) > 2 {
+		buf.WriteByte(',')
+	}
+	buf.WriteString(`"password":"`)
+	writeJSONString(&buf, password)
+	buf.WriteString(`"}`)
+	wipe(b)
+	return buf.Bytes()
+}
+
+// writeJSONString writes s to buf as the contents of a JSON string, escaping
+// characters as needed.
+func writeJSONString(buf *bytes.Buffer, s []byte) {
+	const hex = "0123456789abcdef"
+	for _, c := range s {
+		switch {
+		case c == '"' || c == '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		case c < 0x20:
+			buf.WriteString(`\u00`)
+			buf.WriteByte(hex[c>>4])
+			buf.WriteByte(hex[c&0xF])
+		default:
+			buf.WriteByte(c)
+		}
+	}
+}
+
+// MarshalJSON implements the json.Marshaler interface. Like
+// loginRequest.MarshalJSON, the passwords are written directly into the
+// result.
+func (r *changePasswordRequest) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(len(r.CurrentPassword)*2 + len(r.NewPassword)*2 + 48)
+	buf.WriteString(`{"currentPassword":"`)
+	writeJSONString(&buf, r.CurrentPassword)
+	buf.WriteString(`","newPassword":"`)
+	writeJSONString(&buf, r.NewPassword)
+	buf.WriteString(`"}`)
+	return buf.Bytes(), nil
+}
+
+// MarshalJSON implements the json.Marshaler interface. Like
+// loginRequest.MarshalJSON, the password is written directly into the result,
+// both as the password and its repetition.
+func (r *passwordResetRequest) MarshalJSON() ([]byte, error) {
+	fields, _ := json.Marshal(&struct {
+		TargetType string `json:"targetType"`
+		Ticket     string `json:"ticket"`
+		UserID     int64  `json:"userId"`
+	}{r.TargetType, r.Ticket, r.UserID})
+	var buf bytes.Buffer
+	buf.Grow(len(fields) + len(r.Password)*4 + 48)
+	buf.Write(fields[:len(fields)-1])
+	buf.WriteString(`,"password":"`)
+	writeJSONString(&buf, r.Password)
+	buf.WriteString(`","passwordRepeated":"`)
+	writeJSONString(&buf, r.Password)
+	buf.WriteString(`"}`)
+	return buf.Bytes(), nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// These errors classify the reasons a password is rejected.
+var (
+	ErrPasswordTooShort       = errors.New("password is too short")
+	ErrPasswordSameAsUsername = errors.New("password is the same as the username")
+	ErrPasswordCommon         = errors.New("password is commonly used")
+	ErrPasswordWeak           = errors.New("password is too weak")
+)
+
+// passwordReasons maps the codes returned by the password validation endpoint
+// to a kind.
+var passwordReasons = map[string]error{
+	"ShortPassword":          ErrPasswordTooShort,
+	"PasswordSameAsUsername": ErrPasswordSameAsUsername,
+	"ForbiddenPassword":      ErrPasswordCommon,
+	"DumbStrings":            ErrPasswordCommon,
+	"WeakPassword":           ErrPasswordWeak,
+	"WeakPasswordError":      ErrPasswordWeak,
+}
+
+// PasswordError is returned when a password is rejected by the API.
+type PasswordError struct {
+	// Reason is the code reported by the API.
+	Reason string
+	// Message is a description of the reason.
+	Message string
+}
+
+// Error implements the error interface.
+func (err *PasswordError) Error() string {
+	if err.Message == "" {
+		return "invalid password: " + err.Reason
+	}
+	return "invalid password: " + err.Message
+}
+
+// Is returns whether target is the kind of the reason. Any PasswordError
+// matches ErrPasswordWeak.
+func (err *PasswordError) Is(target error) bool {
+	return target == ErrPasswordWeak || passwordReasons[err.Reason] == target
+}
+
+// changePasswordErrorCodes maps error codes returned by the password change
+// endpoint to a kind.
+//
+//	2: The new password is invalid.
+//	7: Too many attempts. Please wait a bit. (status 429)
+//	8: The current password is incorrect.
+var changePasswordErrorCodes = map[int]error{
+	2: ErrPasswordWeak,
+	7: ErrTooManyAttempts,
+	8: ErrBadCredentials,
+}
+
+// ValidatePassword checks whether password is acceptable as the password of
+// an account with the given username. Returns nil if the password is valid.
+// Otherwise, the returned error contains a *PasswordError, which can be
+// matched against the ErrPassword variables.
+func (c Config) ValidatePassword(username, password string) error {
+	return c.ValidatePasswordContext(context.Background(), username, password)
+}
+
+// ValidatePasswordContext is like ValidatePassword, but with a context.
+func (c Config) ValidatePasswordContext(ctx context.Context, username, password string) (err error) {
+	defer wrapOp("validate password", &err)
+
+	body, _ := json.Marshal(&passwordValidationRequest{Username: username, Password: password})
+	endpoint := c.ValidatePasswordEndpoint
+	if endpoint == "" {
+		endpoint = DefaultValidatePasswordEndpoint
+	}
+	req, err := c.newRequest(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	var apiResp passwordValidationResponse
+	if _, err = c.requestAPI("validate-password", req, &apiResp); err != nil {
+		return err
+	}
+	if apiResp.Code != "ValidPassword" {
+		return &PasswordError{Reason: apiResp.Code, Message: apiResp.Message}
+	}
+	return nil
+}
+
+// ChangePassword changes the password of the account associated with the
+// given cookies. Returns the cookies updated with any reissued by the API.
+//
+// The returned error matches ErrBadCredentials if current is incorrect, and
+// ErrPasswordWeak if new is not acceptable.
+func (c Config) ChangePassword(cookies []*http.Cookie, current, new []byte) ([]*http.Cookie, error) {
+	return c.ChangePasswordContext(context.Background(), cookies, current, new)
+}
+
+// ChangePasswordContext is like ChangePassword, but with a context.
+func (c Config) ChangePasswordContext(ctx context.Context, cookies []*http.Cookie, current, new []byte) (newCookies []*http.Cookie, err error) {
+	defer wrapOp("change password", &err)
+
+	apiReq := changePasswordRequest{CurrentPassword: current, NewPassword: new}
+	body, _ := apiReq.MarshalJSON()
+	defer wipe(body)
+
+	endpoint := c.ChangePasswordEndpoint
+	if endpoint == "" {
+		endpoint = DefaultChangePasswordEndpoint
+	}
+	req, err := c.newRequest(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+
+	resp, err := c.requestAPI("change-password", req, &errorsResponse{})
+	if err != nil {
+		if code, ok := HTTPStatus(err); ok && code == http.StatusUnauthorized {
+			return nil, ErrUnauthenticated
+		}
+		return nil, classify(err, changePasswordErrorCodes)
+	}
+	return mergeCookies(cookies, responseCookies(resp), c.now()), nil
+}
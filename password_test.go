@@ -0,0 +1,257 @@
+package rbxauth_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/anaminus/rbxauth"
+	"github.com/anaminus/rbxauth/rbxauthtest"
+)
+
+// bodyRecorder records the body of each login request. The recorded bodies
+// share memory with the bodies sent by the client.
+type bodyRecorder struct {
+	transport http.RoundTripper
+
+	mu     sync.Mutex
+	bodies []func() (io.ReadCloser, error)
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (r *bodyRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Path == rbxauthtest.LoginPath && req.GetBody != nil {
+		r.mu.Lock()
+		r.bodies = append(r.bodies, req.GetBody)
+		r.mu.Unlock()
+	}
+	return r.transport.RoundTrip(req)
+}
+
+// check fails if any recorded body is not wiped.
+func (r *bodyRecorder) check(t *testing.T, password string) {
+	t.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.bodies) == 0 {
+		t.Fatal("no login requests")
+	}
+	for i, get := range r.bodies {
+		rc, err := get()
+		if err != nil {
+			t.Fatalf("body %d: %v", i, err)
+		}
+		b, _ := ioutil.ReadAll(rc)
+		rc.Close()
+		if len(b) == 0 || bytes.Contains(b, []byte(password)) || len(bytes.Trim(b, "\x00")) != 0 {
+			t.Errorf("body %d was not wiped: %q", i, b)
+		}
+	}
+}
+
+// isWiped returns whether b contains only zeros.
+func isWiped(b []byte) bool {
+	return len(bytes.Trim(b, "\x00")) == 0
+}
+
+// bufferReader reads from r, retaining each buffer passed to Read.
+type bufferReader struct {
+	r    io.Reader
+	bufs [][]byte
+}
+
+func (r *bufferReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.bufs = append(r.bufs, p[:n])
+	}
+	return n, err
+}
+
+// check fails if any retained buffer still contains the password.
+func (r *bufferReader) check(t *testing.T, password string) {
+	t.Helper()
+	if len(r.bufs) == 0 {
+		t.Fatal("nothing was read")
+	}
+	for i, b := range r.bufs {
+		if bytes.Contains(b, []byte(password)) {
+			t.Errorf("buffer %d was not wiped: %q", i, b)
+		}
+	}
+}
+
+func TestWipePassword(t *testing.T) {
+	const password = "hunter2-secret"
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: password})
+
+	for _, wipePassword := range []bool{false, true} {
+		cfg := srv.Config()
+		rec := &bodyRecorder{transport: cfg.Client.Transport}
+		cfg.Client = &http.Client{Transport: rec}
+		cfg.WipePassword = wipePassword
+
+		pass := rbxauth.SecurePassword(password)
+		if _, _, err := cfg.Login("alice", pass); err != nil {
+			t.Fatalf("wipe %t: login: %v", wipePassword, err)
+		}
+		// The body containing the password is always wiped.
+		rec.check(t, password)
+		// The password of the caller is wiped only when requested.
+		if wipePassword != isWiped(pass) {
+			t.Errorf("wipe %t: unexpected password %q", wipePassword, pass)
+		}
+		pass.Wipe()
+		if !isWiped(pass) {
+			t.Errorf("Wipe: unexpected password %q", pass)
+		}
+	}
+}
+
+func TestStreamWipePassword(t *testing.T) {
+	const password = "hunter2-secret"
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: password})
+	file := filepath.Join(t.TempDir(), "password")
+	if err := ioutil.WriteFile(file, []byte(password+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cred := rbxauth.Cred{Type: "Username", Ident: "alice"}
+
+	for _, test := range []struct {
+		name   string
+		source func(*rbxauth.Stream, io.Reader)
+		open   func() (io.Reader, func())
+	}{
+		{
+			name:   "prompt",
+			source: func(s *rbxauth.Stream, r io.Reader) { s.Reader = r },
+			open: func() (io.Reader, func()) {
+				return strings.NewReader(password + "\n"), func() {}
+			},
+		},
+		{
+			name:   "input",
+			source: func(s *rbxauth.Stream, r io.Reader) { s.Input = r },
+			open: func() (io.Reader, func()) {
+				return strings.NewReader(password + "\n"), func() {}
+			},
+		},
+		{
+			name:   "file",
+			source: func(s *rbxauth.Stream, r io.Reader) { s.PasswordReader = r },
+			open: func() (io.Reader, func()) {
+				f, err := os.Open(file)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return f, func() { f.Close() }
+			},
+		},
+	} {
+		cfg := srv.Config()
+		rec := &bodyRecorder{transport: cfg.Client.Transport}
+		cfg.Client = &http.Client{Transport: rec}
+		r, done := test.open()
+		br := &bufferReader{r: r}
+		s := &rbxauth.Stream{Config: cfg, Writer: ioutil.Discard, Quiet: true}
+		if s.Reader == nil {
+			s.Reader = strings.NewReader("")
+		}
+		test.source(s, br)
+		_, cookies, err := s.PromptCred(cred)
+		done()
+		if err != nil {
+			t.Errorf("%s: login: %v", test.name, err)
+			continue
+		}
+		if len(cookies) == 0 {
+			t.Errorf("%s: expected cookies", test.name)
+		}
+		br.check(t, password)
+		rec.check(t, password)
+	}
+}
+
+func TestValidatePassword(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass"})
+	cfg := srv.Config()
+	for _, test := range []struct {
+		password string
+		reason   string
+		err      error
+	}{
+		{"correct horse", "", nil},
+		{"short", "ShortPassword", rbxauth.ErrPasswordTooShort},
+		{"password", "ForbiddenPassword", rbxauth.ErrPasswordCommon},
+	} {
+		err := cfg.ValidatePassword("alice", test.password)
+		if test.err == nil {
+			if err != nil {
+				t.Errorf("%q: expected valid, got %v", test.password, err)
+			}
+			continue
+		}
+		var perr *rbxauth.PasswordError
+		if !errors.As(err, &perr) || perr.Reason != test.reason {
+			t.Errorf("%q: expected reason %s, got %v", test.password, test.reason, err)
+			continue
+		}
+		if !errors.Is(err, test.err) || !errors.Is(err, rbxauth.ErrPasswordWeak) {
+			t.Errorf("%q: expected %v, got %v", test.password, test.err, err)
+		}
+	}
+	err := cfg.ValidatePassword("alicealice", "AliceAlice")
+	if !errors.Is(err, rbxauth.ErrPasswordSameAsUsername) {
+		t.Errorf("same as username: expected ErrPasswordSameAsUsername, got %v", err)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass"})
+	cfg := srv.Config()
+	cookies, _, err := cfg.Login("alice", []byte("pass"))
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+
+	// A weak password is rejected.
+	if _, err := cfg.ChangePassword(cookies, []byte("pass"), []byte("short")); !errors.Is(err, rbxauth.ErrPasswordWeak) {
+		t.Errorf("weak: expected ErrPasswordWeak, got %v", err)
+	}
+	// An incorrect current password is rejected.
+	if _, err := cfg.ChangePassword(cookies, []byte("wrong"), []byte("correct horse")); !errors.Is(err, rbxauth.ErrBadCredentials) {
+		t.Errorf("wrong current: expected ErrBadCredentials, got %v", err)
+	}
+	// Neither changed the password.
+	checkSession(t, cfg, cookies, rbxauth.UserInfo{ID: 1})
+	if _, _, err := cfg.Login("alice", []byte("pass")); err != nil {
+		t.Fatalf("unchanged: %v", err)
+	}
+
+	newCookies, err := cfg.ChangePassword(cookies, []byte("pass"), []byte("correct horse"))
+	if err != nil {
+		t.Fatalf("change: %v", err)
+	}
+	if a, b := cookieValue(cookies, rbxauthtest.SessionCookieName), cookieValue(newCookies, rbxauthtest.SessionCookieName); b == "" || a == b {
+		t.Errorf("expected reissued session, got %q", b)
+	}
+	checkSession(t, cfg, newCookies, rbxauth.UserInfo{ID: 1, Name: "alice"})
+	if _, _, err := cfg.Login("alice", []byte("pass")); !errors.Is(err, rbxauth.ErrBadCredentials) {
+		t.Errorf("old password: expected ErrBadCredentials, got %v", err)
+	}
+	if _, _, err := cfg.Login("alice", []byte("correct horse")); err != nil {
+		t.Errorf("new password: %v", err)
+	}
+
+	// The previous session was ended.
+	if _, err := cfg.ChangePassword(cookies, []byte("correct horse"), []byte("battery staple")); !errors.Is(err, rbxauth.ErrUnauthenticated) {
+		t.Errorf("stale: expected ErrUnauthenticated, got %v", err)
+	}
+}
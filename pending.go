@@ -0,0 +1,32 @@
+package rbxauth
+
+// PendingAction is an action that the user must take after logging in. Until
+// the action is taken, some requests made with the session may be rejected.
+type PendingAction string
+
+// Actions reported by a login.
+const (
+	// PendingPasswordReset indicates that the password must be reset.
+	PendingPasswordReset PendingAction = "PasswordReset"
+	// PendingTermsAcceptance indicates that the terms of use must be accepted
+	// again.
+	PendingTermsAcceptance PendingAction = "TermsAcceptance"
+	// PendingEmailVerification indicates that the email address must be
+	// updated or verified.
+	PendingEmailVerification PendingAction = "EmailVerification"
+)
+
+// pendingActions returns the actions reported by a login response, or nil if
+// there are none.
+func pendingActions(apiResp *loginResponse) (actions []PendingAction) {
+	if apiResp.ShouldResetPassword {
+		actions = append(actions, PendingPasswordReset)
+	}
+	if apiResp.ShouldAcceptTerms {
+		actions = append(actions, PendingTermsAcceptance)
+	}
+	if apiResp.ShouldUpdateEmail {
+		actions = append(actions, PendingEmailVerification)
+	}
+	return actions
+}
@@ -0,0 +1,104 @@
+package rbxauth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// pinErrorCodes maps error codes returned by the PIN endpoints to a kind.
+//
+//	1: Account PIN is not set.
+//	2: Incorrect PIN.
+//	3: Too many attempts. Please wait a bit. (status 429)
+//	4: Account PIN is locked.
+var pinErrorCodes = map[int]error{
+	1: ErrPinNotSet,
+	2: ErrIncorrectPIN,
+	3: ErrTooManyAttempts,
+	4: ErrPinLocked,
+}
+
+// IsPinLocked returns whether err indicates that an operation failed because
+// the account PIN is locked. In addition to ErrPinLocked, err is matched if it
+// contains an ErrorResponse with a message reporting a locked PIN, as returned
+// by endpoints that require the PIN to be unlocked.
+func IsPinLocked(err error) bool {
+	if errors.Is(err, ErrPinLocked) {
+		return true
+	}
+	var errResp ErrorResponse
+	if errors.As(err, &errResp) {
+		return strings.Contains(strings.ToLower(errResp.Message), "pin is locked")
+	}
+	return false
+}
+
+// UnlockPIN unlocks the account PIN of the session represented by the given
+// cookies. Returns the time at which the account will be locked again.
+//
+// The returned error matches ErrIncorrectPIN if the PIN is incorrect,
+// ErrTooManyAttempts if too many incorrect PINs were entered, and ErrPinNotSet
+// if the account does not have a PIN.
+func (c Config) UnlockPIN(cookies []*http.Cookie, pin string) (unlockedUntil time.Time, err error) {
+	return c.UnlockPINContext(context.Background(), cookies, pin)
+}
+
+// UnlockPINContext is like UnlockPIN, but with a context.
+func (c Config) UnlockPINContext(ctx context.Context, cookies []*http.Cookie, pin string) (unlockedUntil time.Time, err error) {
+	defer wrapOp("unlock PIN", &err)
+
+	body, _ := json.Marshal(&pinRequest{PIN: pin})
+	endpoint := c.UnlockPINEndpoint
+	if endpoint == "" {
+		endpoint = DefaultUnlockPINEndpoint
+	}
+	req, err := c.newRequest(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return time.Time{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+
+	var apiResp pinResponse
+	if _, err = c.requestAPI("unlock-pin", req, &apiResp); err != nil {
+		return time.Time{}, classify(err, pinErrorCodes)
+	}
+	return c.now().Add(time.Duration(apiResp.UnlockedUntil * float64(time.Second))), nil
+}
+
+// LockPIN locks the account PIN of the session represented by the given
+// cookies.
+func (c Config) LockPIN(cookies []*http.Cookie) error {
+	return c.LockPINContext(context.Background(), cookies)
+}
+
+// LockPINContext is like LockPIN, but with a context.
+func (c Config) LockPINContext(ctx context.Context, cookies []*http.Cookie) (err error) {
+	defer wrapOp("lock PIN", &err)
+
+	endpoint := c.LockPINEndpoint
+	if endpoint == "" {
+		endpoint = DefaultLockPINEndpoint
+	}
+	req, err := c.newRequest(ctx, "POST", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+
+	if _, err = c.requestAPI("lock-pin", req, &errorsResponse{}); err != nil {
+		return classify(err, pinErrorCodes)
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package rbxauth_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anaminus/rbxauth"
+	"github.com/anaminus/rbxauth/rbxauthtest"
+)
+
+func TestUnlockPIN(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass", PIN: "1234"})
+	cfg := srv.Config()
+	var clock fakeClock
+	clock.install(&cfg)
+	cookies, _, err := cfg.Login("alice", []byte("pass"))
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+
+	until, err := cfg.UnlockPIN(cookies, "1234")
+	if err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+	if want := clock.now.Add(rbxauthtest.PINUnlockDuration * time.Second); !until.Equal(want) {
+		t.Errorf("expected unlocked until %v, got %v", want, until)
+	}
+	if err := cfg.LockPIN(cookies); err != nil {
+		t.Errorf("lock: %v", err)
+	}
+
+	// A correct PIN resets the count of attempts.
+	for i := 0; i < rbxauthtest.MaxPINAttempts-1; i++ {
+		if _, err := cfg.UnlockPIN(cookies, "0000"); !errors.Is(err, rbxauth.ErrIncorrectPIN) {
+			t.Fatalf("wrong PIN %d: expected ErrIncorrectPIN, got %v", i+1, err)
+		}
+	}
+	if _, err := cfg.UnlockPIN(cookies, "1234"); err != nil {
+		t.Fatalf("unlock after wrong PINs: %v", err)
+	}
+
+	// Too many wrong PINs lock out even the correct PIN.
+	for i := 0; i < rbxauthtest.MaxPINAttempts; i++ {
+		if _, err := cfg.UnlockPIN(cookies, "0000"); !errors.Is(err, rbxauth.ErrIncorrectPIN) {
+			t.Fatalf("lockout %d: expected ErrIncorrectPIN, got %v", i+1, err)
+		}
+	}
+	_, err = cfg.UnlockPIN(cookies, "1234")
+	if !errors.Is(err, rbxauth.ErrTooManyAttempts) {
+		t.Errorf("locked out: expected ErrTooManyAttempts, got %v", err)
+	}
+	if status, _ := rbxauth.HTTPStatus(err); status != 429 {
+		t.Errorf("locked out: expected status 429, got %v", err)
+	}
+	if !strings.HasPrefix(err.Error(), "unlock PIN: ") {
+		t.Errorf("locked out: unexpected error %q", err)
+	}
+
+	// The session is required.
+	if _, err := cfg.UnlockPIN(nil, "1234"); err == nil {
+		t.Error("no session: expected error")
+	} else if status, _ := rbxauth.HTTPStatus(err); status != 401 {
+		t.Errorf("no session: expected status 401, got %v", err)
+	}
+}
+
+func TestUnlockPINNotSet(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass"})
+	cfg := srv.Config()
+	cookies, _, err := cfg.Login("alice", []byte("pass"))
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if _, err := cfg.UnlockPIN(cookies, "1234"); !errors.Is(err, rbxauth.ErrPinNotSet) {
+		t.Errorf("expected ErrPinNotSet, got %v", err)
+	}
+}
+
+func TestIsPinLocked(t *testing.T) {
+	for _, test := range []struct {
+		err    error
+		locked bool
+	}{
+		{nil, false},
+		{rbxauth.ErrPinLocked, true},
+		{fmt.Errorf("upload: %w", rbxauth.ErrPinLocked), true},
+		{&rbxauth.StatusError{Code: 403, Err: rbxauth.ErrorResponse{Code: 0, Message: "Account PIN is locked."}}, true},
+		{&rbxauth.StatusError{Code: 403, Err: rbxauth.ErrorResponse{Code: 0, Message: "Forbidden"}}, false},
+		{rbxauth.ErrIncorrectPIN, false},
+	} {
+		if locked := rbxauth.IsPinLocked(test.err); locked != test.locked {
+			t.Errorf("%v: expected %t, got %t", test.err, test.locked, locked)
+		}
+	}
+}
+
+func TestStreamPromptPIN(t *testing.T) {
+	m := rbxauth.DefaultMessages
+	for _, test := range []struct {
+		name  string
+		input string
+		// messages are the expected messages, in order.
+		messages []string
+	}{
+		{"success", "1234\n", []string{"Account unlocked until"}},
+		{"wrong then success", "0000\n1234\n", []string{m.IncorrectPIN, "Account unlocked until"}},
+		{"skipped", "\n", nil},
+		{"lockout", "0000\n0000\n0000\n1234\n", []string{m.IncorrectPIN, m.IncorrectPIN, m.IncorrectPIN, "Account remains locked"}},
+	} {
+		srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass", PIN: "1234"})
+		var out strings.Builder
+		s := &rbxauth.Stream{
+			Config:    srv.Config(),
+			Reader:    strings.NewReader("pass\n" + test.input),
+			Writer:    &out,
+			Quiet:     true,
+			PromptPIN: true,
+		}
+		// Failing to unlock does not fail the login.
+		_, cookies, err := s.PromptCred(rbxauth.Cred{Type: "Username", Ident: "alice"})
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if len(cookies) == 0 {
+			t.Errorf("%s: expected cookies", test.name)
+		}
+		output := out.String()
+		if n := strings.Count(output, m.AskPIN); n != strings.Count(test.input, "\n") {
+			t.Errorf("%s: expected %d PIN prompts, got %d", test.name, strings.Count(test.input, "\n"), n)
+		}
+		for _, msg := range test.messages {
+			i := strings.Index(output, msg)
+			if i < 0 {
+				t.Errorf("%s: expected message %q in %q", test.name, msg, out.String())
+				break
+			}
+			output = output[i+len(msg):]
+		}
+		if test.messages == nil && strings.Contains(output, "unlocked") {
+			t.Errorf("%s: unexpected output %q", test.name, output)
+		}
+	}
+}
@@ -0,0 +1,506 @@
+package rbxauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// CodeAction indicates what is done with the result of Prompter.AskCode.
+type CodeAction int
+
+const (
+	// CodeSubmit causes the code to be verified.
+	CodeSubmit CodeAction = iota
+	// CodeResend causes the code to be resent. The returned code is ignored.
+	CodeResend
+)
+
+// Prompter receives input from a user during an interactive login. Stream is
+// a Prompter that reads lines from a stream; other implementations allow the
+// same login flow to be driven by, for example, a graphical interface.
+type Prompter interface {
+	// AskCredType returns the type of credential to log in with.
+	AskCredType() (string, error)
+	// AskIdent returns the identifier of the credential of the given type.
+	AskIdent(credType string) (string, error)
+	// AskPassword returns the password of the account with the given
+	// identifier. The returned slice is wiped after use.
+	AskPassword(ident string) ([]byte, error)
+	// AskCode returns a two-step verification code sent via the given media
+	// type, or requests that the code be resent.
+	AskCode(mediaType string) (code string, action CodeAction, err error)
+	// AskRememberDevice returns whether the current device should be
+	// remembered for future authentication.
+	AskRememberDevice() (bool, error)
+	// Notify displays an informational message.
+	Notify(msg string)
+}
+
+// PINPrompter is implemented by a Prompter that can unlock the account PIN
+// after a successful login.
+type PINPrompter interface {
+	Prompter
+	// AskPIN returns the account PIN. An empty PIN skips unlocking. The
+	// returned slice is wiped after use.
+	AskPIN() ([]byte, error)
+}
+
+// QuestionPrompter is implemented by a Prompter that can answer the security
+// questions of a login.
+type QuestionPrompter interface {
+	Prompter
+	// AskQuestion returns the choices of q that are selected as the answer.
+	AskQuestion(q *SecurityQuestion) ([]string, error)
+}
+
+// PasswordRetrier is implemented by a Prompter that can prompt for the
+// password again when the login fails due to incorrect credentials.
+type PasswordRetrier interface {
+	Prompter
+	// PasswordAttempts returns the number of times the password may be
+	// prompted, including the first. The password is prompted once if less
+	// than 2.
+	PasswordAttempts() int
+}
+
+// CredTypeConfirmer is implemented by a Prompter that can confirm a
+// credential type detected from an identifier, when the Auto type is used.
+type CredTypeConfirmer interface {
+	Prompter
+	// ConfirmCredType returns the credential type of ident, given the
+	// detected type. If ambiguous, ident may be either a Username or a
+	// UserID.
+	ConfirmCredType(ident, detected string, ambiguous bool) (string, error)
+}
+
+// LoginWithPrompter performs an interactive login, receiving input from p.
+// Handles multi-step verification, if necessary. If cred.Type and/or
+// cred.Ident are empty, then they will be prompted as well. If p implements
+// PINPrompter, then the account PIN is prompted after logging in.
+//
+// If the login requires security questions to be answered, then p must
+// implement QuestionPrompter. Otherwise, an error matching
+// ErrQuestionsRequired is returned.
+//
+// If p implements PasswordRetrier, then the password is prompted again after
+// incorrect credentials, up to the number of attempts it returns.
+//
+// If cred.Type is Auto, then the type is detected from the identifier with
+// DetectCredType. If p implements CredTypeConfirmer, then the detected type is
+// confirmed.
+//
+// Returns the updated cred and cookies, or any error that may have occurred.
+func (c Config) LoginWithPrompter(p Prompter, cred Cred) (Cred, []*http.Cookie, error) {
+	return c.LoginWithPrompterContext(context.Background(), p, cred)
+}
+
+// LoginWithPrompterContext is like LoginWithPrompter, but with a context that
+// bounds each request made during the login.
+func (c Config) LoginWithPrompterContext(ctx context.Context, p Prompter, cred Cred) (Cred, []*http.Cookie, error) {
+	cred, result, err := c.loginWithPrompter(ctx, p, cred)
+	if err != nil {
+		return cred, nil, err
+	}
+	return cred, result.Cookies, nil
+}
+
+// loginWithPrompter implements LoginWithPrompter, returning the result of the
+// login. The Cookies of the result are those of the completed login, while
+// Step and Challenge are those that were completed, if any.
+func (c Config) loginWithPrompter(ctx context.Context, p Prompter, cred Cred) (credout Cred, result *LoginResult, err error) {
+	defer wrapOp("prompt", &err)
+
+	switch cred.Type {
+	case Username, Email, PhoneNumber, Auto, "":
+	default:
+		return cred, nil, fmt.Errorf("invalid credential type %q", cred.Type)
+	}
+
+	// Detect credential type from identifier.
+	if cred.Type == Auto {
+		if cred.Ident == "" {
+			if cred.Ident, err = p.AskIdent(Auto); err != nil {
+				return cred, nil, err
+			}
+			if cred.Ident == "" {
+				return cred, nil, errors.New("missing identifier")
+			}
+		}
+		detected, ambiguous := DetectCredType(cred.Ident)
+		if p, ok := p.(CredTypeConfirmer); ok {
+			if cred.Type, err = p.ConfirmCredType(cred.Ident, detected, ambiguous); err != nil {
+				return cred, nil, err
+			}
+		} else {
+			cred.Type = detected
+		}
+	}
+
+	// Prompt for credential type.
+	if cred.Type == "" {
+		if cred.Type, err = p.AskCredType(); err != nil {
+			return cred, nil, err
+		}
+	}
+
+	// Prompt for identifier.
+	if cred.Ident == "" {
+		if cred.Ident, err = p.AskIdent(cred.Type); err != nil {
+			return cred, nil, err
+		}
+		if cred.Ident == "" {
+			return cred, nil, errors.New("missing identifier")
+		}
+	}
+
+	attempts := 1
+	if p, ok := p.(PasswordRetrier); ok {
+		attempts = p.PasswordAttempts()
+	}
+	m := messagesOf(p)
+	var incorrect error
+	for attempt := 1; ; attempt++ {
+		// Prompt for password.
+		password, err := p.AskPassword(cred.Ident)
+		if err != nil {
+			if incorrect != nil && errors.Is(err, ErrPromptEOF) {
+				// The input ended instead of correcting the password.
+				return cred, nil, incorrect
+			}
+			return cred, nil, err
+		}
+
+		// Login.
+		result, err = c.LoginCredResult(ctx, cred, password, nil)
+		wipe(password)
+		if errors.Is(err, ErrBadCredentials) && attempt < attempts {
+			incorrect = err
+			p.Notify(fmt.Sprintf(m.IncorrectPassword, attempt+1, attempts))
+			continue
+		}
+		if cerr := (*ConsentError)(nil); errors.As(err, &cerr) {
+			if errors.Is(err, ErrParentalConsentRequired) {
+				p.Notify(m.ParentalConsent)
+			} else {
+				p.Notify(m.AgeRestricted)
+			}
+			if cerr.URL != "" {
+				p.Notify(fmt.Sprintf(m.ConsentURL, cerr.URL))
+			}
+		}
+		if err != nil {
+			return cred, nil, err
+		}
+		break
+	}
+
+	if challenge := result.Challenge; challenge != nil {
+		if result.Cookies, err = c.waitChallenge(ctx, p, challenge); err != nil {
+			return cred, nil, err
+		}
+	}
+
+	if questions := result.Questions; questions != nil {
+		qp, ok := p.(QuestionPrompter)
+		if !ok {
+			return cred, nil, ErrQuestionsRequired
+		}
+		if result.Cookies, err = c.promptQuestions(ctx, qp, questions); err != nil {
+			return cred, nil, err
+		}
+	}
+
+	if step := result.Step; step != nil {
+		if err = c.promptStep(ctx, p, step, result); err != nil {
+			if errors.Is(err, ErrPromptEOF) {
+				// The code cannot be entered, so the step is returned to
+				// be continued otherwise.
+				err = &TwoStepError{Step: step, err: err}
+			}
+			return cred, nil, err
+		}
+	}
+
+	if p, ok := p.(PINPrompter); ok {
+		if err := c.promptPIN(ctx, p, result.Cookies); err != nil {
+			return cred, nil, err
+		}
+	}
+
+	for _, action := range result.PendingActions {
+		if msg := m.pendingAction(action); msg != "" {
+			p.Notify(msg)
+		}
+	}
+
+	return cred, result, nil
+}
+
+// promptStep prompts for a verification code until step is verified. An
+// incorrect code is prompted again up to CodeRetries times. The cookies of
+// the verified step, whether the device was remembered, and the number of
+// resends are set on result.
+func (c Config) promptStep(ctx context.Context, p Prompter, step *Step, result *LoginResult) error {
+	retries := c.CodeRetries
+	if retries == 0 {
+		retries = DefaultCodeRetries
+	}
+	var remember bool
+	m := messagesOf(p)
+	p.Notify(m.codeSent(step.MediaType, step.Remaining()))
+	for attempt := 0; ; attempt++ {
+		code, err := c.promptCode(ctx, p, step, &result.Resends)
+		if err != nil {
+			return err
+		}
+
+		// Prompt for remember device.
+		if attempt == 0 {
+			if remember, err = p.AskRememberDevice(); err != nil {
+				return err
+			}
+		}
+
+		// Verify code.
+		cookies, err := step.VerifyContext(ctx, code, remember)
+		if errors.Is(err, ErrInvalidCode) && attempt < retries {
+			p.Notify(m.IncorrectCode)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		result.Cookies = cookies
+		result.RememberDevice = remember
+		return nil
+	}
+}
+
+// promptQuestions prompts for the answer to the security question of step
+// until it is answered correctly. An incorrect answer is prompted again up to
+// CodeRetries times.
+func (c Config) promptQuestions(ctx context.Context, p QuestionPrompter, step *QuestionStep) (cookies []*http.Cookie, err error) {
+	retries := c.CodeRetries
+	if retries == 0 {
+		retries = DefaultCodeRetries
+	}
+	for attempt := 0; ; attempt++ {
+		question, err := step.QuestionsContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		selected, err := p.AskQuestion(question)
+		if err != nil {
+			return nil, err
+		}
+		cookies, err = step.AnswerContext(ctx, selected)
+		if errors.Is(err, ErrWrongAnswer) && attempt < retries {
+			p.Notify(messagesOf(p).IncorrectAnswer)
+			continue
+		}
+		return cookies, err
+	}
+}
+
+// promptCode prompts for a verification code, resending the code as requested.
+// If CodeProvider is set, the code is received from it instead. resends is
+// incremented for each code that was resent.
+func (c Config) promptCode(ctx context.Context, p Prompter, step *Step, resends *int) (code string, err error) {
+	if c.CodeProvider != nil {
+		return c.CodeProvider(string(step.MediaType))
+	}
+	m := messagesOf(p)
+	for {
+		var action CodeAction
+		if code, action, err = p.AskCode(string(step.MediaType)); err != nil {
+			return "", err
+		}
+		if action == CodeSubmit {
+			return code, nil
+		}
+		if err := step.ResendContext(ctx); err != nil {
+			if errors.Is(err, ErrResendUnsupported) {
+				p.Notify(fmt.Sprintf(m.ResendUnsupported, step.MediaType))
+				continue
+			}
+			var cooldown *ResendCooldownError
+			if errors.As(err, &cooldown) {
+				if cooldown.RetryAfter > 0 {
+					p.Notify(fmt.Sprintf(m.ResendWait, cooldown.RetryAfter.Round(time.Second)))
+				} else {
+					p.Notify(m.ResendWaitUnknown)
+				}
+				continue
+			}
+			return "", err
+		}
+		*resends++
+		p.Notify(fmt.Sprintf(m.CodeResent, step.MediaType))
+	}
+}
+
+// waitChallenge waits for challenge to be approved according to PollInterval
+// and PollTimeout. The challenge is canceled if it is not approved in time.
+func (c Config) waitChallenge(ctx context.Context, p Prompter, challenge *PendingChallenge) ([]*http.Cookie, error) {
+	interval := c.PollInterval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	timeout := c.PollTimeout
+	if timeout <= 0 {
+		timeout = DefaultPollTimeout
+	}
+	p.Notify(messagesOf(p).ApproveLogin)
+	deadline := c.now().Add(timeout)
+	for {
+		cookies, err := challenge.PollContext(ctx)
+		if !errors.Is(err, ErrChallengePending) {
+			return cookies, err
+		}
+		d := deadline.Sub(c.now())
+		if d <= 0 {
+			challenge.Cancel()
+			return nil, errors.New("timed out waiting for approval")
+		}
+		if d > interval {
+			d = interval
+		}
+		if err := c.sleep(ctx, d); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// promptPIN prompts for the account PIN until the session represented by
+// cookies is unlocked, or the prompt is skipped. Failures to unlock are
+// reported without returning an error.
+func (c Config) promptPIN(ctx context.Context, p PINPrompter, cookies []*http.Cookie) error {
+	m := messagesOf(p)
+	for {
+		pin, err := p.AskPIN()
+		if err != nil {
+			return err
+		}
+		if len(pin) == 0 {
+			return nil
+		}
+		until, err := c.UnlockPINContext(ctx, cookies, string(pin))
+		wipe(pin)
+		switch {
+		case err == nil:
+			p.Notify(fmt.Sprintf(m.PINUnlocked, until.Format(time.Kitchen)))
+			return nil
+		case errors.Is(err, ErrIncorrectPIN):
+			p.Notify(m.IncorrectPIN)
+		case errors.Is(err, ErrPinNotSet):
+			p.Notify(m.PINNotSet)
+			return nil
+		default:
+			p.Notify(fmt.Sprintf(m.PINLocked, err))
+			return nil
+		}
+	}
+}
+
+// FuncPrompter implements PINPrompter, QuestionPrompter, and PasswordRetrier
+// by calling a function for each method.
+type FuncPrompter struct {
+	// CredType implements AskCredType. If nil, "Username" is used.
+	CredType func() (string, error)
+	// Ident implements AskIdent. Required unless the identifier is given.
+	Ident func(credType string) (string, error)
+	// Password implements AskPassword. Required.
+	Password func(ident string) ([]byte, error)
+	// Code implements AskCode. Required if two-step verification is
+	// enabled.
+	Code func(mediaType string) (string, CodeAction, error)
+	// RememberDevice implements AskRememberDevice. If nil, the device is not
+	// remembered.
+	RememberDevice func() (bool, error)
+	// Message implements Notify. If nil, messages are discarded.
+	Message func(msg string)
+	// PIN implements AskPIN. If nil, the PIN is not unlocked.
+	PIN func() ([]byte, error)
+	// Question implements AskQuestion. Required if the login requires
+	// security questions.
+	Question func(q *SecurityQuestion) ([]string, error)
+	// Attempts implements PasswordAttempts. If zero, Password is called
+	// once.
+	Attempts int
+}
+
+// errNoPrompt is returned by FuncPrompter when a required function is nil.
+func errNoPrompt(name string) error {
+	return errors.New("no prompt for " + name)
+}
+
+// AskCredType implements Prompter.
+func (f FuncPrompter) AskCredType() (string, error) {
+	if f.CredType == nil {
+		return "Username", nil
+	}
+	return f.CredType()
+}
+
+// AskIdent implements Prompter.
+func (f FuncPrompter) AskIdent(credType string) (string, error) {
+	if f.Ident == nil {
+		return "", errNoPrompt("identifier")
+	}
+	return f.Ident(credType)
+}
+
+// AskPassword implements Prompter.
+func (f FuncPrompter) AskPassword(ident string) ([]byte, error) {
+	if f.Password == nil {
+		return nil, errNoPrompt("password")
+	}
+	return f.Password(ident)
+}
+
+// AskCode implements Prompter.
+func (f FuncPrompter) AskCode(mediaType string) (string, CodeAction, error) {
+	if f.Code == nil {
+		return "", CodeSubmit, errNoPrompt("code")
+	}
+	return f.Code(mediaType)
+}
+
+// AskRememberDevice implements Prompter.
+func (f FuncPrompter) AskRememberDevice() (bool, error) {
+	if f.RememberDevice == nil {
+		return false, nil
+	}
+	return f.RememberDevice()
+}
+
+// Notify implements Prompter.
+func (f FuncPrompter) Notify(msg string) {
+	if f.Message != nil {
+		f.Message(msg)
+	}
+}
+
+// AskQuestion implements QuestionPrompter.
+func (f FuncPrompter) AskQuestion(q *SecurityQuestion) ([]string, error) {
+	if f.Question == nil {
+		return nil, errNoPrompt("security question")
+	}
+	return f.Question(q)
+}
+
+// AskPIN implements PINPrompter.
+func (f FuncPrompter) AskPIN() ([]byte, error) {
+	if f.PIN == nil {
+		return nil, nil
+	}
+	return f.PIN()
+}
+
+// PasswordAttempts implements PasswordRetrier.
+func (f FuncPrompter) PasswordAttempts() int {
+	return f.Attempts
+}
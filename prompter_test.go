@@ -0,0 +1,254 @@
+package rbxauth_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/anaminus/rbxauth"
+	"github.com/anaminus/rbxauth/rbxauthtest"
+)
+
+// errScriptEnd is returned by scriptPrompter when a script runs out.
+var errScriptEnd = errors.New("end of script")
+
+// scriptPrompter is a Prompter that answers from a script, recording each
+// call.
+type scriptPrompter struct {
+	credType  string
+	ident     string
+	passwords []string
+	// codes lists the codes to enter. An empty code requests a resend.
+	codes    []string
+	remember bool
+
+	calls    []string
+	messages []string
+}
+
+func (p *scriptPrompter) AskCredType() (string, error) {
+	p.calls = append(p.calls, "type")
+	return p.credType, nil
+}
+
+func (p *scriptPrompter) AskIdent(credType string) (string, error) {
+	p.calls = append(p.calls, "ident "+credType)
+	return p.ident, nil
+}
+
+func (p *scriptPrompter) AskPassword(ident string) ([]byte, error) {
+	p.calls = append(p.calls, "password "+ident)
+	if len(p.passwords) == 0 {
+		return nil, errScriptEnd
+	}
+	password := p.passwords[0]
+	p.passwords = p.passwords[1:]
+	return []byte(password), nil
+}
+
+func (p *scriptPrompter) AskCode(mediaType string) (string, rbxauth.CodeAction, error) {
+	p.calls = append(p.calls, "code "+mediaType)
+	if len(p.codes) == 0 {
+		return "", rbxauth.CodeSubmit, errScriptEnd
+	}
+	code := p.codes[0]
+	p.codes = p.codes[1:]
+	if code == "" {
+		return "", rbxauth.CodeResend, nil
+	}
+	return code, rbxauth.CodeSubmit, nil
+}
+
+func (p *scriptPrompter) AskRememberDevice() (bool, error) {
+	p.calls = append(p.calls, "remember")
+	return p.remember, nil
+}
+
+func (p *scriptPrompter) Notify(msg string) {
+	p.messages = append(p.messages, msg)
+}
+
+func TestLoginWithPrompter(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass"})
+	cfg := srv.Config()
+
+	p := &scriptPrompter{credType: "Username", ident: "alice", passwords: []string{"pass"}}
+	cred, cookies, err := cfg.LoginWithPrompter(p, rbxauth.Cred{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (rbxauth.Cred{Type: "Username", Ident: "alice"}); cred != want {
+		t.Errorf("expected cred %+v, got %+v", want, cred)
+	}
+	checkSession(t, cfg, cookies, rbxauth.UserInfo{ID: 1, Name: "alice"})
+	if want := []string{"type", "ident Username", "password alice"}; !reflect.DeepEqual(p.calls, want) {
+		t.Errorf("expected calls %q, got %q", want, p.calls)
+	}
+}
+
+func TestLoginWithPrompterResend(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{
+		ID: 1, Name: "alice", Password: "pass",
+		TwoStep: true, MediaType: "Email", Code: "123456",
+	})
+	cfg := srv.Config()
+	cfg.LegacyTwoStep = true
+	cfg.ResendCooldown = -1
+
+	p := &scriptPrompter{passwords: []string{"pass"}, codes: []string{"", "123456"}, remember: true}
+	_, cookies, err := cfg.LoginWithPrompter(p, rbxauth.Cred{Type: "Username", Ident: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSession(t, cfg, cookies, rbxauth.UserInfo{ID: 1, Name: "alice"})
+	if n := srv.Count(rbxauthtest.ResendPath); n != 1 {
+		t.Errorf("expected 1 resend, got %d", n)
+	}
+	if n := srv.Count(rbxauthtest.VerifyPath); n != 1 {
+		t.Errorf("expected 1 verify, got %d", n)
+	}
+	want := []string{"password alice", "code Email", "code Email", "remember"}
+	if !reflect.DeepEqual(p.calls, want) {
+		t.Errorf("expected calls %q, got %q", want, p.calls)
+	}
+	resent := fmt.Sprintf(rbxauth.DefaultMessages.CodeResent, rbxauth.MediaEmail)
+	var found bool
+	for _, msg := range p.messages {
+		found = found || msg == resent
+	}
+	if !found {
+		t.Errorf("expected message %q, got %q", resent, p.messages)
+	}
+
+	if cookieValue(cookies, rbxauthtest.DeviceCookieName) == "" {
+		t.Error("expected device to be remembered")
+	}
+}
+
+func TestLoginWithPrompterScriptEnd(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{
+		ID: 1, Name: "alice", Password: "pass",
+		TwoStep: true, MediaType: "Email", Code: "123456",
+	})
+	cfg := srv.Config()
+
+	p := &scriptPrompter{passwords: []string{"pass"}}
+	_, _, err := cfg.LoginWithPrompter(p, rbxauth.Cred{Type: "Username", Ident: "alice"})
+	if !errors.Is(err, errScriptEnd) {
+		t.Errorf("expected script end, got %v", err)
+	}
+	if n := srv.Count(rbxauthtest.VerifyPath); n != 0 {
+		t.Errorf("expected no verify, got %d", n)
+	}
+}
+
+// confirmPrompter is a scriptPrompter that confirms a detected credential
+// type.
+type confirmPrompter struct {
+	scriptPrompter
+	// confirm is the type returned by ConfirmCredType. If empty, the
+	// detected type is accepted.
+	confirm string
+}
+
+func (p *confirmPrompter) ConfirmCredType(ident, detected string, ambiguous bool) (string, error) {
+	p.calls = append(p.calls, fmt.Sprintf("confirm %s %t", detected, ambiguous))
+	if p.confirm == "" {
+		return detected, nil
+	}
+	return p.confirm, nil
+}
+
+func TestLoginWithPrompterConfirm(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "5551234567", Password: "pass"})
+	cfg := srv.Config()
+
+	// The detected type is rejected in favor of Username.
+	p := &confirmPrompter{scriptPrompter: scriptPrompter{ident: "5551234567", passwords: []string{"pass"}}, confirm: "Username"}
+	cred, cookies, err := cfg.LoginWithPrompter(p, rbxauth.Cred{Type: rbxauth.Auto})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Type != "Username" {
+		t.Errorf("expected Username, got %s", cred.Type)
+	}
+	checkSession(t, cfg, cookies, rbxauth.UserInfo{ID: 1})
+	want := []string{"ident Auto", "confirm PhoneNumber false", "password 5551234567"}
+	if !reflect.DeepEqual(p.calls, want) {
+		t.Errorf("expected calls %q, got %q", want, p.calls)
+	}
+
+	// Without confirmation, the detected type is used.
+	p = &confirmPrompter{scriptPrompter: scriptPrompter{ident: "5551234567", passwords: []string{"pass"}}}
+	if _, _, err := cfg.LoginWithPrompter(p, rbxauth.Cred{Type: rbxauth.Auto}); !errors.Is(err, rbxauth.ErrBadCredentials) {
+		t.Errorf("accepted: expected ErrBadCredentials, got %v", err)
+	}
+	sp := &scriptPrompter{ident: "5551234567", passwords: []string{"pass"}}
+	if _, _, err := cfg.LoginWithPrompter(sp, rbxauth.Cred{Type: rbxauth.Auto}); !errors.Is(err, rbxauth.ErrBadCredentials) {
+		t.Errorf("no confirmer: expected ErrBadCredentials, got %v", err)
+	}
+}
+
+// retryPrompter is a scriptPrompter that prompts for the password up to
+// attempts times.
+type retryPrompter struct {
+	scriptPrompter
+	attempts int
+}
+
+func (p *retryPrompter) PasswordAttempts() int {
+	return p.attempts
+}
+
+func TestLoginWithPrompterPasswordAttempts(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass"})
+	cred := rbxauth.Cred{Type: "Username", Ident: "alice"}
+	passwords := []string{"wrong", "wrong", "pass"}
+
+	for _, test := range []struct {
+		name string
+		p    rbxauth.Prompter
+		// logins is the expected number of login requests.
+		logins int
+		err    error
+	}{
+		{"retrier", &retryPrompter{scriptPrompter{passwords: passwords}, 3}, 3, nil},
+		{"too few", &retryPrompter{scriptPrompter{passwords: passwords}, 2}, 2, rbxauth.ErrBadCredentials},
+		{"not retrier", &scriptPrompter{passwords: passwords}, 1, rbxauth.ErrBadCredentials},
+		{"func", rbxauth.FuncPrompter{
+			Password: func(string) ([]byte, error) {
+				password := passwords[0]
+				passwords = passwords[1:]
+				return []byte(password), nil
+			},
+			Attempts: 3,
+		}, 3, nil},
+		{"stream", &rbxauth.Stream{Reader: strings.NewReader("wrong\nwrong\npass\n"), Quiet: true}, 3, nil},
+		{"stream source", &rbxauth.Stream{PasswordReader: strings.NewReader("wrong"), Quiet: true}, 1, rbxauth.ErrBadCredentials},
+	} {
+		cfg := srv.Config()
+		// The token is primed so that each login is a single request.
+		cfg.Token = srv.Token()
+		if s, ok := test.p.(*rbxauth.Stream); ok {
+			s.Config = cfg
+		}
+		before := srv.Count(rbxauthtest.LoginPath)
+		_, cookies, err := cfg.LoginWithPrompter(test.p, cred)
+		if n := srv.Count(rbxauthtest.LoginPath) - before; n != test.logins {
+			t.Errorf("%s: expected %d logins, got %d", test.name, test.logins, n)
+		}
+		if test.err != nil {
+			if !errors.Is(err, test.err) {
+				t.Errorf("%s: expected %v, got %v", test.name, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		checkSession(t, cfg, cookies, rbxauth.UserInfo{ID: 1})
+	}
+}
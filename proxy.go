@@ -0,0 +1,111 @@
+package rbxauth
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Timeouts of the transport used when Client is nil.
+const (
+	// DefaultDialTimeout is the duration after which establishing a
+	// connection fails.
+	DefaultDialTimeout = 10 * time.Second
+	// DefaultTLSHandshakeTimeout is the duration after which a TLS handshake
+	// fails.
+	DefaultTLSHandshakeTimeout = 10 * time.Second
+)
+
+// DefaultMaxIdleConnsPerHost is the number of idle connections to each host
+// kept by the transport used when Client is nil. A login, its verification,
+// and subsequent requests are made mostly to the same few hosts, so keeping
+// more connections than the default of net/http lets concurrent logins reuse
+// them.
+const DefaultMaxIdleConnsPerHost = 16
+
+// newTransport returns the transport used when Client is nil. Connections are
+// kept alive, and HTTP/2 is used when supported by the server.
+func newTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{
+		Timeout:   DefaultDialTimeout,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	transport.TLSHandshakeTimeout = DefaultTLSHandshakeTimeout
+	transport.MaxIdleConnsPerHost = DefaultMaxIdleConnsPerHost
+	transport.ForceAttemptHTTP2 = true
+	return transport
+}
+
+// defaultClient is used when neither Client nor ProxyURL is set. It is
+// created on first use, and shared by every Config.
+var defaultClient struct {
+	once   sync.Once
+	client *http.Client
+}
+
+// getDefaultClient returns defaultClient, creating it if necessary.
+func getDefaultClient() *http.Client {
+	defaultClient.once.Do(func() {
+		defaultClient.client = &http.Client{Transport: newTransport()}
+	})
+	return defaultClient.client
+}
+
+// ErrProxyConflict is returned when both Client and ProxyURL of a Config are
+// set.
+var ErrProxyConflict = errors.New("only one of Client and ProxyURL may be set")
+
+// proxyClients caches a client for each proxy URL, so that connections are
+// reused across requests.
+var proxyClients sync.Map
+
+// HTTPClient returns the client used to make requests, according to Client
+// and ProxyURL. If neither is set, the client uses a transport with
+// DefaultDialTimeout, DefaultTLSHandshakeTimeout, and
+// DefaultMaxIdleConnsPerHost. Returns an error if ProxyURL is invalid.
+//
+// The same client is returned for every copy of the Config, including those
+// held by a Step or Session, as well as for every Config with the same
+// ProxyURL, so that connections are pooled across a login and the requests
+// that follow it. Callers can make their own requests with the client to
+// share the pool.
+func (c Config) HTTPClient() (*http.Client, error) {
+	return c.httpClient()
+}
+
+// httpClient implements HTTPClient.
+func (c *Config) httpClient() (*http.Client, error) {
+	if c.ProxyURL == "" {
+		if c.Client == nil {
+			return getDefaultClient(), nil
+		}
+		return c.Client, nil
+	}
+	if c.Client != nil {
+		return nil, ErrProxyConflict
+	}
+	if client, ok := proxyClients.Load(c.ProxyURL); ok {
+		return client.(*http.Client), nil
+	}
+	u, err := url.Parse(c.ProxyURL)
+	if err != nil {
+		// The error contains the URL, which may contain credentials.
+		return nil, errors.New("invalid proxy URL")
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, errors.New("proxy scheme must be http, https, socks5, or socks5h")
+	}
+	if u.Host == "" {
+		return nil, errors.New("proxy URL is missing host")
+	}
+	transport := newTransport()
+	transport.Proxy = http.ProxyURL(u)
+	client, _ := proxyClients.LoadOrStore(c.ProxyURL, &http.Client{Transport: transport})
+	return client.(*http.Client), nil
+}
@@ -0,0 +1,140 @@
+package rbxauth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// These errors are returned for a QuestionStep.
+var (
+	// ErrQuestionsRequired is returned by login methods that cannot return a
+	// QuestionStep, when the login requires security questions to be
+	// answered. Use LoginCredResult to receive the step.
+	ErrQuestionsRequired = errors.New("login requires security questions")
+	// ErrWrongAnswer indicates that the selected answers were incorrect. The
+	// QuestionStep remains valid, so the question may be answered again.
+	ErrWrongAnswer = errors.New("incorrect answer")
+	// ErrQuestionsLocked indicates that too many incorrect answers were
+	// submitted.
+	ErrQuestionsLocked = errors.New("too many incorrect answers")
+)
+
+// questionErrorCodes maps error codes returned by the security question
+// endpoints to a kind.
+//
+//	1: The challenge is invalid or has expired.
+//	2: The answer is incorrect.
+//	3: Too many incorrect answers. (status 429)
+var questionErrorCodes = map[int]error{
+	1: ErrStepExpired,
+	2: ErrWrongAnswer,
+	3: ErrQuestionsLocked,
+}
+
+// SecurityQuestion is a question presented by a QuestionStep.
+type SecurityQuestion struct {
+	// Prompt describes what to select.
+	Prompt string
+	// Choices lists the items that may be selected.
+	Choices []string
+}
+
+// QuestionStep holds the state of a login that requires security questions to
+// be answered, such as selecting the items that belong to the account.
+type QuestionStep struct {
+	cfg Config
+
+	// ChallengeID identifies the challenge.
+	ChallengeID string
+
+	// User is the user being authenticated, as reported by the initial login
+	// response. May be nil.
+	User *UserInfo
+
+	// Expires is the time after which the step is no longer valid. The API
+	// does not report the expiration of a challenge, so it is estimated as
+	// DefaultStepTTL after the challenge was issued.
+	Expires time.Time
+}
+
+// Valid returns whether the step has not yet expired. A step with a zero
+// Expires is always valid.
+func (q *QuestionStep) Valid() bool {
+	return q.Expires.IsZero() || q.cfg.now().Before(q.Expires)
+}
+
+// endpoint returns the URL of a security question action.
+func (q *QuestionStep) endpoint(action string) string {
+	endpoint := q.cfg.SecurityQuestionsEndpoint
+	if endpoint == "" {
+		endpoint = DefaultSecurityQuestionsEndpoint
+	}
+	return endpoint + "/" + action
+}
+
+// Questions returns the question to be answered.
+func (q *QuestionStep) Questions() (*SecurityQuestion, error) {
+	return q.QuestionsContext(context.Background())
+}
+
+// QuestionsContext is like Questions, but with a context.
+func (q *QuestionStep) QuestionsContext(ctx context.Context) (question *SecurityQuestion, err error) {
+	defer wrapOp("get questions", &err)
+	if !q.Valid() {
+		return nil, ErrStepExpired
+	}
+
+	body, _ := json.Marshal(&securityQuestionRequest{ChallengeID: q.ChallengeID})
+	req, err := q.cfg.newRequest(ctx, "POST", q.endpoint("questions"), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	var apiResp securityQuestionResponse
+	if _, err := q.cfg.requestAPI("security-questions", req, &apiResp); err != nil {
+		return nil, classify(err, questionErrorCodes)
+	}
+	return &SecurityQuestion{Prompt: apiResp.Prompt, Choices: apiResp.Choices}, nil
+}
+
+// Answer submits the selected choices as the answer to the question. If the
+// answer is correct, then the login is completed, and HTTP cookies
+// representing the session are returned.
+//
+// Returns an error matching ErrWrongAnswer if the answer is incorrect, in which
+// case the question may be answered again, and ErrQuestionsLocked if too many
+// incorrect answers were submitted.
+func (q *QuestionStep) Answer(selected []string) ([]*http.Cookie, error) {
+	return q.AnswerContext(context.Background(), selected)
+}
+
+// AnswerContext is like Answer, but with a context.
+func (q *QuestionStep) AnswerContext(ctx context.Context, selected []string) (cookies []*http.Cookie, err error) {
+	defer wrapOp("answer", &err)
+	if !q.Valid() {
+		return nil, ErrStepExpired
+	}
+	if selected == nil {
+		selected = []string{}
+	}
+
+	body, _ := json.Marshal(&securityAnswerRequest{ChallengeID: q.ChallengeID, Answers: selected})
+	req, err := q.cfg.newRequest(ctx, "POST", q.endpoint("answer"), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := q.cfg.requestAPI("security-questions", req, &errorsResponse{})
+	if err != nil {
+		return nil, classify(err, questionErrorCodes)
+	}
+	return responseCookies(resp), nil
+}
@@ -0,0 +1,124 @@
+package rbxauth
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// ErrTwoStepRequired is matched by a TwoStepError.
+var ErrTwoStepRequired = errors.New("two-step verification required")
+
+// TwoStepError is returned by Login when the account requires two-step
+// verification. It is also returned by a prompted login when the input ends
+// before a verification code is entered, in which case it wraps ErrPromptEOF.
+type TwoStepError struct {
+	// Step continues the login. Passing the code received by the user to
+	// Step.VerifySession produces the session.
+	Step *Step
+
+	err error
+}
+
+// Error implements the error interface.
+func (err *TwoStepError) Error() string {
+	if err.err != nil {
+		return ErrTwoStepRequired.Error() + ": " + err.err.Error()
+	}
+	return "login: " + ErrTwoStepRequired.Error()
+}
+
+// Unwrap implements the Unwrap interface.
+func (err *TwoStepError) Unwrap() error {
+	return err.err
+}
+
+// Is returns whether target is ErrTwoStepRequired.
+func (err *TwoStepError) Is(target error) bool {
+	return target == ErrTwoStepRequired
+}
+
+// quickConfig returns the Config used by the package-level helpers, which
+// uses the default endpoints.
+func quickConfig() Config {
+	return Config{TokenCache: &TokenCache{}}
+}
+
+// Login logs in to the account of username with the default endpoints, as
+// with Config.LoginSessionContext. If the account requires two-step
+// verification, then a *TwoStepError is returned, from which the login can be
+// continued.
+func Login(ctx context.Context, username string, password []byte) (*Session, error) {
+	sess, step, err := quickConfig().LoginSessionContext(ctx, Cred{Type: Username, Ident: username}, password)
+	if err != nil {
+		return nil, err
+	}
+	if step != nil {
+		return nil, &TwoStepError{Step: step}
+	}
+	return sess, nil
+}
+
+// LoginInteractive prompts for credentials with StandardStream, and logs in
+// with the default endpoints, as with Stream.PromptSession. Each step of the
+// login is prompted as needed.
+func LoginInteractive(ctx context.Context) (*Session, error) {
+	s := StandardStream()
+	s.Config = quickConfig()
+	s.Context = ctx
+	_, sess, err := s.PromptSession(Cred{})
+	return sess, err
+}
+
+// LoadSession reads the cookies of a session from the file at path, which may
+// be in any format read by ReadCookiesAuto. The session makes requests with
+// the default endpoints.
+func LoadSession(path string) (*Session, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	cookies, err := ReadCookiesAuto(f)
+	if err != nil {
+		return nil, err
+	}
+	return NewSession(quickConfig(), cookies), nil
+}
+
+// Save writes the cookies of the session to the file at path, as with
+// WriteCookiesWithMeta, including the user of the session, if known. The file
+// is replaced atomically, and is created with permissions that restrict
+// access to the current user.
+func (s *Session) Save(path string) error {
+	var meta CookieMeta
+	if s.user != nil {
+		meta.Account, meta.UserID = s.user.Name, s.user.ID
+	}
+	endpoint := s.cfg.LoginEndpoint
+	if endpoint == "" {
+		endpoint = DefaultLoginEndpoint
+	}
+	if u, err := url.Parse(endpoint); err == nil {
+		meta.Host = u.Host
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := WriteCookiesWithMeta(tmp, s.Cookies(), meta); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	// TempFile creates the file with permissions restricted to the current
+	// user, which are retained by the rename.
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/anaminus/rbxauth"
+	"golang.org/x/term"
+)
+
+// abortAction is what is done with an established session when the program is
+// interrupted.
+type abortAction int
+
+const (
+	abortNothing abortAction = iota // Leave the session as is.
+	abortLogout                     // Log out of the session.
+	abortAsk                        // Ask whether to log out of the session.
+	abortWarn                       // Warn that the session remains active.
+)
+
+// abortDecision returns what is done with the session when the program is
+// interrupted. hasSession is whether a session was established, and written
+// is whether the session was written to the output. logout is whether the
+// session is logged out without asking, and interactive is whether the user
+// can be asked.
+func abortDecision(hasSession, written, logout, interactive bool) abortAction {
+	switch {
+	case !hasSession || written:
+		return abortNothing
+	case logout:
+		return abortLogout
+	case interactive:
+		return abortAsk
+	}
+	return abortWarn
+}
+
+// aborter tracks the state that must be cleaned up when the program is
+// interrupted.
+type aborter struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu sync.Mutex
+	// Terminal state of stdin, or of the terminal set by setTerminal,
+	// restored in case it was changed by a masked read.
+	termFd    int
+	termState *term.State
+	// Terminal through which the user is asked, which is stdin and stderr
+	// unless set by setTerminal.
+	termIn  io.Reader
+	termOut io.Writer
+	// Temporary files that are removed.
+	temps map[string]bool
+	// Session that may be logged out, and the path to which it is written.
+	cfg     rbxauth.Config
+	session []*http.Cookie
+	output  string
+	written bool
+	logout  bool
+}
+
+// abort is the aborter of the program.
+var abort = newAborter()
+
+// terminalOptions configures each masked read from a terminal. Interrupts are
+// handled by the aborter, so a masked read must not raise them again.
+var terminalOptions = rbxauth.TerminalOptions{NoRaiseInterrupt: true}
+
+func newAborter() *aborter {
+	a := &aborter{temps: map[string]bool{}, termIn: os.Stdin, termOut: os.Stderr}
+	a.ctx, a.cancel = context.WithCancel(context.Background())
+	return a
+}
+
+// listen begins handling interrupts. The first interrupt cancels the context
+// of the aborter, cleans up, and exits. A second interrupt exits immediately.
+func (a *aborter) listen() {
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		if state, err := term.GetState(fd); err == nil {
+			a.termFd, a.termState = fd, state
+		}
+	}
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		go func() {
+			<-signals
+			os.Exit(130)
+		}()
+		// The lock is held until exit, so that the state cannot change
+		// during clean up.
+		a.mu.Lock()
+		a.cancel()
+		a.cleanup()
+		os.Exit(130)
+	}()
+}
+
+// track registers a temporary file to be removed on interrupt.
+func (a *aborter) track(path string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.temps[path] = true
+}
+
+// untrack unregisters a temporary file.
+func (a *aborter) untrack(path string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.temps, path)
+}
+
+// commit renames the temporary file tmp to path, unless interrupted. The
+// session is marked as written if path is its output.
+func (a *aborter) commit(tmp, path string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	delete(a.temps, tmp)
+	if a.output != "" && path == a.output {
+		a.written = true
+	}
+	return nil
+}
+
+// setSession registers an established session, which is handled according to
+// abortDecision on interrupt. output is the path to which the session is
+// written, or "-" if it is written to stdout. If logout is true, the session
+// is logged out without asking.
+func (a *aborter) setSession(cfg rbxauth.Config, cookies []*http.Cookie, output string, logout bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.cfg, a.session, a.output, a.logout = cfg, cookies, output, logout
+}
+
+// setWritten marks the session as written to the output.
+func (a *aborter) setWritten() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.written = true
+}
+
+// setTerminal sets the terminal through which prompts are answered, when it is
+// not stdin.
+func (a *aborter) setTerminal(in, out *os.File) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.termIn, a.termOut = in, out
+	a.termFd, a.termState = 0, nil
+	if fd := int(in.Fd()); term.IsTerminal(fd) {
+		if state, err := term.GetState(fd); err == nil {
+			a.termFd, a.termState = fd, state
+		}
+	}
+}
+
+// halt blocks forever if the program was interrupted, letting the interrupt
+// finish cleaning up and exit. It is called before exiting on an error, which
+// may have been caused by the interrupt.
+func (a *aborter) halt() {
+	if a.ctx.Err() != nil {
+		a.mu.Lock()
+	}
+}
+
+// cleanup restores the terminal, removes temporary files, and handles the
+// established session. Expects the lock to be held.
+func (a *aborter) cleanup() {
+	if a.termState != nil {
+		term.Restore(a.termFd, a.termState)
+	}
+	fmt.Fprintln(os.Stderr, "\nInterrupted")
+	for path := range a.temps {
+		os.Remove(path)
+	}
+
+	switch abortDecision(len(a.session) > 0, a.written, a.logout, a.termState != nil) {
+	case abortAsk:
+		fmt.Fprint(a.termOut, "The session was not written. Log out of the session? [y/N]: ")
+		line, _ := bufio.NewReader(a.termIn).ReadString('\n')
+		if answer := strings.ToLower(strings.TrimSpace(line)); answer != "y" && answer != "yes" {
+			fmt.Fprintln(os.Stderr, "The session remains active")
+			return
+		}
+		fallthrough
+	case abortLogout:
+		if err := a.cfg.Logout(a.session); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to log out: %s\n", err)
+			return
+		}
+		fmt.Fprintln(os.Stderr, "Logged out")
+	case abortWarn:
+		fmt.Fprintln(os.Stderr, "The session was not written, and remains active")
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestAbortDecision(t *testing.T) {
+	for _, test := range []struct {
+		hasSession, written, logout, interactive bool
+		action                                   abortAction
+	}{
+		{false, false, false, false, abortNothing},
+		{false, false, true, true, abortNothing},
+		{true, true, false, false, abortNothing},
+		{true, true, true, true, abortNothing},
+		{true, false, true, false, abortLogout},
+		{true, false, true, true, abortLogout},
+		{true, false, false, true, abortAsk},
+		{true, false, false, false, abortWarn},
+	} {
+		action := abortDecision(test.hasSession, test.written, test.logout, test.interactive)
+		if action != test.action {
+			t.Errorf("abortDecision(%t, %t, %t, %t): expected %d, got %d",
+				test.hasSession, test.written, test.logout, test.interactive, test.action, action)
+		}
+	}
+}
+
+func TestAborterWritten(t *testing.T) {
+	a := newAborter()
+	a.setSession(a.cfg, nil, stdioPath, false)
+	if a.written {
+		t.Fatal("session is written before output")
+	}
+	a.setWritten()
+	if !a.written {
+		t.Error("session is not written after setWritten")
+	}
+
+	// Only committing the output marks the session as written.
+	dir := t.TempDir()
+	a = newAborter()
+	a.setSession(a.cfg, nil, filepath.Join(dir, "out"), false)
+	for _, name := range []string{"other", "out"} {
+		tmp := filepath.Join(dir, name+".tmp")
+		if err := ioutil.WriteFile(tmp, nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+		a.track(tmp)
+		if err := a.commit(tmp, filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+		if a.temps[tmp] {
+			t.Errorf("%s: temporary file is still tracked", name)
+		}
+		if written := name == "out"; a.written != written {
+			t.Errorf("%s: expected written to be %t", name, written)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/anaminus/rbxauth"
+)
+
+// accountsCommands maps the name of each action of the accounts subcommand to
+// its implementation.
+var accountsCommands = map[string]func(args []string){
+	"list": runAccountsList,
+	"use":  runAccountsUse,
+}
+
+// runAccounts implements the accounts subcommand.
+func runAccounts(args []string) {
+	if len(args) == 0 {
+		ifFatal(usageErrorf("expected action (list, use)"))
+	}
+	run, ok := accountsCommands[args[0]]
+	if !ok {
+		ifFatal(usageErrorf("unknown action %q", args[0]))
+	}
+	run(args[1:])
+}
+
+// accountsStore defines the -store flag of an accounts action, which defaults
+// to the file store.
+func accountsStore(fs *flag.FlagSet) *store {
+	var s store
+	fs.StringVar(&s.kind, "store", "file", "Store holding the sessions (file, keyring).")
+	return &s
+}
+
+// runAccountsList implements the accounts list action.
+func runAccountsList(args []string) {
+	var noCheck bool
+	fs := flag.NewFlagSet("accounts list", flag.ContinueOnError)
+	fs.BoolVar(&noCheck, "no-check", false, "Do not check whether each session is valid.")
+	st := accountsStore(fs)
+	config := configFlags(fs)
+	parseFlags(fs, args)
+
+	cfg := config()
+	cs, err := st.open()
+	ifFatal(err)
+	lister, ok := cs.(rbxauth.SessionLister)
+	if !ok {
+		ifFatal(fmt.Errorf("store %q cannot list sessions", st.kind))
+	}
+	infos, err := lister.ListSessions()
+	ifFatal(err)
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "ACCOUNT\tUSER\tEXPIRES\tSTATUS")
+	for _, info := range infos {
+		expires := "unknown"
+		if !info.Expires.IsZero() {
+			expires = info.Expires.Format(time.RFC3339)
+		}
+		user := "-"
+		status := "unchecked"
+		if !noCheck {
+			switch err := cfg.CheckSession(cs, &info); {
+			case err == nil:
+				user = fmt.Sprintf("%s (%d)", info.User.Name, info.User.ID)
+				status = "valid"
+			case errors.Is(err, rbxauth.ErrUnauthenticated):
+				status = "expired"
+			default:
+				status = "error: " + err.Error()
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", info.Account, user, expires, status)
+	}
+	ifFatal(tw.Flush())
+}
+
+// runAccountsUse implements the accounts use action.
+func runAccountsUse(args []string) {
+	var output string
+	var format string
+	fs := flag.NewFlagSet("accounts use", flag.ContinueOnError)
+	fs.StringVar(&output, "o", stdioPath, "Path to output file, or - for stdout.")
+	fs.StringVar(&format, "format", "headers", "Format of cookie output (headers, json).")
+	crypt := cryptFlags(fs, true)
+	st := accountsStore(fs)
+	rewrite := rewriteFlags(fs)
+	// The name may precede the flags.
+	var name string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	parseFlags(fs, args)
+	if name == "" {
+		name = fs.Arg(0)
+	}
+	if name == "" {
+		ifFatal(usageErrorf("expected account name"))
+	}
+	writeOutput, err := openOutput("-o", output)
+	ifFatal(err)
+
+	cs, err := st.open()
+	ifFatal(err)
+	cookies, err := loadSession(cs, name)
+	ifFatal(err)
+	warnExpiry(rbxauth.InspectCookies(cookies))
+	cookies = rewrite(cookies)
+
+	write := crypt.writer(format, crypt.encrypt)
+	ifFatal(writeOutput(func(w io.Writer) error {
+		return write(w, cookies)
+	}))
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/anaminus/rbxauth"
+	"github.com/anaminus/rbxauth/rbxauthtest"
+)
+
+func TestAccounts(t *testing.T) {
+	srv := rbxauthtest.NewServer()
+	defer srv.Close()
+	srv.AddAccount(rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass"})
+
+	// The file store is located in the user's configuration directory.
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	store := rbxauth.FileCredentialStore(filepath.Join(dir, "rbxauth", "sessions.json"))
+	_, cookies := writeSession(t, srv)
+	if err := store.SaveSession("alice", cookies); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveSession("dead", rbxauth.FromSecurityToken("dead")); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, stderr, code := runMain(t, srv, "", "accounts", "list")
+	if code != 0 {
+		t.Fatalf("list: exit %d: %s", code, stderr)
+	}
+	for _, pattern := range []string{
+		`(?m)^alice +alice \(1\) +\S+ +valid$`,
+		`(?m)^dead +- +\S+ +expired$`,
+	} {
+		if !regexp.MustCompile(pattern).MatchString(stdout) {
+			t.Errorf("list: expected line matching %s, got\n%s", pattern, stdout)
+		}
+	}
+
+	// Without checking, no requests are made.
+	before := srv.Count(rbxauthtest.AuthenticatedPath)
+	stdout, stderr, code = runMain(t, srv, "", "accounts", "list", "-no-check")
+	if code != 0 {
+		t.Fatalf("list unchecked: exit %d: %s", code, stderr)
+	}
+	if strings.Count(stdout, "unchecked") != 2 {
+		t.Errorf("list unchecked: expected two unchecked sessions, got\n%s", stdout)
+	}
+	if n := srv.Count(rbxauthtest.AuthenticatedPath) - before; n != 0 {
+		t.Errorf("list unchecked: expected no requests, got %d", n)
+	}
+
+	path := filepath.Join(t.TempDir(), "cookies")
+	if _, stderr, code := runMain(t, srv, "", "accounts", "use", "alice", "-o", path); code != 0 {
+		t.Fatalf("use: exit %d: %s", code, stderr)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkLogin(t, srv, "use", string(b), "", 0)
+
+	missing := filepath.Join(t.TempDir(), "cookies")
+	_, stderr, code = runMain(t, srv, "", "accounts", "use", "bob", "-o", missing)
+	if code != exitError {
+		t.Errorf("missing: expected exit %d, got %d", exitError, code)
+	}
+	if !strings.Contains(stderr, `"bob"`) {
+		t.Errorf("missing: expected account in message, got %q", stderr)
+	}
+	if _, err := ioutil.ReadFile(missing); err == nil {
+		t.Error("missing: output was written")
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"runtime"
+	"strings"
+)
+
+// commandArgs returns the program and arguments of the command line, which is
+// passed to the system shell if shell is true. Otherwise, the line is split
+// into arguments by spaces, which can be quoted with single or double quotes,
+// or escaped with a backslash outside of single quotes.
+func commandArgs(line string, shell bool) ([]string, error) {
+	if strings.TrimSpace(line) == "" {
+		return nil, usageErrorf("empty command")
+	}
+	if shell {
+		if runtime.GOOS == "windows" {
+			return []string{"cmd", "/C", line}, nil
+		}
+		return []string{"/bin/sh", "-c", line}, nil
+	}
+	var args []string
+	var arg strings.Builder
+	var quote rune
+	inArg := false
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			arg.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case r == '\\':
+			escaped, inArg = true, true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, usageErrorf("unterminated quote or escape in command")
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
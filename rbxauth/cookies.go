@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/anaminus/rbxauth"
+)
+
+// cookiesCommands maps the name of each action of the cookies subcommand to
+// its implementation.
+var cookiesCommands = map[string]func(args []string){
+	"convert": runCookiesConvert,
+	"show":    runCookiesShow,
+}
+
+// runCookies implements the cookies subcommand.
+func runCookies(args []string) {
+	if len(args) == 0 {
+		ifFatal(usageErrorf("expected action (convert, show)"))
+	}
+	run, ok := cookiesCommands[args[0]]
+	if !ok {
+		ifFatal(usageErrorf("unknown action %q", args[0]))
+	}
+	run(args[1:])
+}
+
+// readToken reads a raw session token from r.
+func readToken(r io.Reader) ([]*http.Cookie, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return nil, rbxauth.ErrNoCookies
+	}
+	return rbxauth.FromSecurityToken(token), nil
+}
+
+// writeToken writes the value of the session cookie as a raw token to w.
+func writeToken(w io.Writer, cookies []*http.Cookie) error {
+	return rbxauth.WriteSecurityToken(w, cookies, "raw")
+}
+
+// droppedAttrs returns a description of each attribute of cookies that cannot
+// be expressed by the given format.
+func droppedAttrs(cookies []*http.Cookie, format string) []string {
+	var dropped []string
+	for _, c := range cookies {
+		switch format {
+		case "json":
+			if c.MaxAge != 0 {
+				dropped = append(dropped, fmt.Sprintf("Max-Age of %s", c.Name))
+			}
+			if c.SameSite != 0 {
+				dropped = append(dropped, fmt.Sprintf("SameSite of %s", c.Name))
+			}
+		case "token":
+			if c.Name != rbxauth.SessionCookieName {
+				dropped = append(dropped, fmt.Sprintf("cookie %s", c.Name))
+			} else if c.Domain != "" || c.Path != "" || !c.Expires.IsZero() || c.MaxAge != 0 {
+				dropped = append(dropped, fmt.Sprintf("attributes of %s", c.Name))
+			}
+		}
+	}
+	return dropped
+}
+
+// runCookiesConvert implements the cookies convert action.
+func runCookiesConvert(args []string) {
+	var input string
+	var output string
+	var from string
+	var to string
+	fs := flag.NewFlagSet("cookies convert", flag.ContinueOnError)
+	fs.StringVar(&input, "i", stdioPath, "Path to cookie file, or - for stdin.")
+	fs.StringVar(&output, "o", stdioPath, "Path to output file, or - for stdout.")
+	fs.StringVar(&from, "from", "auto", "Format of cookie input (auto, headers, json, token).")
+	fs.StringVar(&to, "to", "headers", "Format of cookie output (headers, json, token). Ignored with -encrypt.")
+	crypt := cryptFlags(fs, true)
+	rewrite := rewriteFlags(fs)
+	parseFlags(fs, args)
+
+	writeOutput, err := openOutput("-o", output)
+	ifFatal(err)
+
+	read := readToken
+	if from != "token" {
+		read = crypt.reader(from)
+	}
+	var write func(io.Writer, []*http.Cookie) error
+	switch {
+	case crypt.encrypt:
+		// Encrypted cookies contain headers, which express every attribute.
+		write = crypt.writer("headers", true)
+		to = "headers"
+	case to == "token":
+		write = writeToken
+	default:
+		write = crypt.writer(to, false)
+	}
+
+	cookies, err := readCookieFile("-i", input, read)
+	ifFatal(err)
+	cookies = rewrite(cookies)
+	for _, d := range droppedAttrs(cookies, to) {
+		fmt.Fprintf(os.Stderr, "Warning: dropped %s, which cannot be expressed as %s\n", d, to)
+	}
+
+	ifFatal(writeOutput(func(w io.Writer) error {
+		return write(w, cookies)
+	}))
+}
+
+// runCookiesShow implements the cookies show action.
+func runCookiesShow(args []string) {
+	var input string
+	var format string
+	var showValues bool
+	fs := flag.NewFlagSet("cookies show", flag.ContinueOnError)
+	fs.StringVar(&input, "i", stdioPath, "Path to cookie file, or - for stdin.")
+	fs.StringVar(&format, "format", "auto", "Format of cookie input (auto, headers, json, token).")
+	fs.BoolVar(&showValues, "show-values", false, "Show the value of each cookie instead of redacting it.")
+	crypt := cryptFlags(fs, false)
+	parseFlags(fs, args)
+
+	read := readToken
+	if format != "token" {
+		read = crypt.reader(format)
+	}
+	var meta rbxauth.CookieMeta
+	cookies, err := readCookieFile("-i", input, func(r io.Reader) ([]*http.Cookie, error) {
+		if format == "token" {
+			return read(r)
+		}
+		// Buffer the input so that its metadata can be read separately.
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		meta = cookieMeta(b)
+		return read(bytes.NewReader(b))
+	})
+	ifFatal(err)
+
+	if meta != (rbxauth.CookieMeta{}) {
+		writeCookieMeta(os.Stdout, meta)
+		fmt.Println()
+	}
+	report := rbxauth.InspectCookies(cookies)
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tDOMAIN\tPATH\tEXPIRES\tVALUE")
+	for i, c := range cookies {
+		info := report.Cookies[i]
+		expires := "unknown"
+		if info.HasExpiry {
+			expires = info.Expires.Format(time.RFC3339)
+			if info.Remaining <= 0 {
+				expires += " (expired)"
+			}
+		}
+		value := "***"
+		if showValues {
+			value = c.Value
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", c.Name, c.Domain, c.Path, expires, value)
+	}
+	ifFatal(tw.Flush())
+	warnExpiry(report)
+}
+
+// cookieMeta returns the metadata of the cookie file content b. Returns zero
+// if b has no metadata, or is encrypted or JSON.
+func cookieMeta(b []byte) rbxauth.CookieMeta {
+	if rbxauth.IsCookiesEncrypted(b) {
+		return rbxauth.CookieMeta{}
+	}
+	_, meta, _ := rbxauth.ReadCookiesWithMeta(bytes.NewReader(b))
+	return meta
+}
+
+// writeCookieMeta writes each non-zero field of meta to w as a line.
+func writeCookieMeta(w io.Writer, meta rbxauth.CookieMeta) {
+	switch {
+	case meta.Account != "" && meta.UserID != 0:
+		fmt.Fprintf(w, "Account: %s (%d)\n", meta.Account, meta.UserID)
+	case meta.Account != "":
+		fmt.Fprintf(w, "Account: %s\n", meta.Account)
+	case meta.UserID != 0:
+		fmt.Fprintf(w, "Account: %d\n", meta.UserID)
+	}
+	if !meta.Created.IsZero() {
+		fmt.Fprintf(w, "Created: %s\n", meta.Created.Local().Format(time.RFC3339))
+	}
+	if meta.Host != "" {
+		fmt.Fprintf(w, "Host:    %s\n", meta.Host)
+	}
+}
+
+// endpointHost returns the host of endpoint, or of def if endpoint is empty.
+func endpointHost(endpoint, def string) string {
+	if endpoint == "" {
+		endpoint = def
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// expiryWarning is the duration before a session expires within which
+// warnExpiry writes a warning.
+const expiryWarning = 24 * time.Hour
+
+// warnExpiry writes a warning to stderr if the session in report is missing,
+// expires soon, or has an unknown expiry.
+func warnExpiry(report rbxauth.CookieReport) {
+	switch remaining := time.Until(report.SessionValidUntil); {
+	case !report.HasSession:
+		fmt.Fprintln(os.Stderr, "Warning: no session cookie")
+	case report.SessionValidUntil.IsZero():
+		fmt.Fprintln(os.Stderr, "Warning: session cookie has no expiry information")
+	case remaining <= 0:
+		fmt.Fprintln(os.Stderr, "Warning: session cookie has expired")
+	case remaining < expiryWarning:
+		fmt.Fprintf(os.Stderr, "Warning: session cookie expires in %s\n", remaining.Round(time.Minute))
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "Update golden files in testdata.")
+
+// convertFormats lists the formats converted between by cookies convert.
+var convertFormats = []string{"headers", "json", "token"}
+
+func TestCookiesConvertGolden(t *testing.T) {
+	for _, from := range convertFormats {
+		input := filepath.Join("testdata", "convert", "input."+from)
+		for _, to := range convertFormats {
+			stdout, stderr, code := runEnv(t, nil, "", "cookies", "convert", "-i", input, "-from", from, "-to", to)
+			if code != 0 {
+				t.Errorf("%s to %s: exit %d: %s", from, to, code, stderr)
+				continue
+			}
+			golden := filepath.Join("testdata", "convert", from+"-"+to+".golden")
+			if *update {
+				if err := ioutil.WriteFile(golden, []byte(stdout), 0666); err != nil {
+					t.Fatal(err)
+				}
+				continue
+			}
+			want, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stdout != string(want) {
+				t.Errorf("%s to %s: expected output\n%s\ngot\n%s", from, to, want, stdout)
+			}
+
+			// The format of the input is detected.
+			if from == "token" {
+				continue
+			}
+			auto, stderr, code := runEnv(t, nil, "", "cookies", "convert", "-i", input, "-to", to)
+			if code != 0 {
+				t.Errorf("auto to %s: exit %d: %s", to, code, stderr)
+			} else if auto != stdout {
+				t.Errorf("auto to %s: expected output\n%s\ngot\n%s", to, stdout, auto)
+			}
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// runEmail implements the email subcommand.
+func runEmail(args []string) {
+	var input string
+	var format string
+	var send bool
+	fs := flag.NewFlagSet("email", flag.ContinueOnError)
+	fs.StringVar(&input, "i", stdioPath, "Path to cookie file, or - for stdin.")
+	fs.StringVar(&format, "format", "auto", "Format of cookie input (auto, headers, json).")
+	fs.BoolVar(&send, "send-verification", false, "Send a verification email if the email is not verified.")
+	crypt := cryptFlags(fs, false)
+	config := configFlags(fs)
+	parseFlags(fs, args)
+
+	cfg := config()
+	cookies, err := readCookieFile("-i", input, crypt.reader(format))
+	ifFatal(err)
+	info, err := cfg.EmailStatus(cookies)
+	ifFatal(err)
+	switch {
+	case info.Address == "":
+		fmt.Println("No email")
+	case info.Verified:
+		fmt.Printf("%s (verified)\n", info.Address)
+	default:
+		fmt.Printf("%s (unverified)\n", info.Address)
+	}
+	if send && info.Address != "" && !info.Verified {
+		ifFatal(cfg.SendEmailVerification(cookies))
+		fmt.Fprintln(os.Stderr, "Sent verification email")
+	}
+}
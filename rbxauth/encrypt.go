@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+
+	"github.com/anaminus/rbxauth"
+)
+
+// crypt configures the encryption of cookie files.
+type crypt struct {
+	encrypt       bool
+	passphraseEnv string
+	passphrase    []byte
+}
+
+// cryptFlags defines flags on fs that configure the encryption of cookie
+// files. The -encrypt flag is defined only if output is true.
+func cryptFlags(fs *flag.FlagSet, output bool) *crypt {
+	var c crypt
+	if output {
+		fs.BoolVar(&c.encrypt, "encrypt", false, "Encrypt output cookies with a passphrase. The -format flag is ignored.")
+	}
+	fs.StringVar(&c.passphraseEnv, "passphrase-env", "", "Name of environment variable containing the passphrase of encrypted cookies. Prompt if empty.")
+	return &c
+}
+
+// getPassphrase returns a copy of the passphrase, which is retrieved once from
+// the environment or a prompt.
+func (c *crypt) getPassphrase() ([]byte, error) {
+	if c.passphrase == nil {
+		if c.passphraseEnv != "" {
+			p, ok := os.LookupEnv(c.passphraseEnv)
+			if !ok {
+				return nil, fmt.Errorf("variable %s is not set", c.passphraseEnv)
+			}
+			c.passphrase = []byte(p)
+		} else {
+			if err := useStdin("the passphrase prompt", true); err != nil {
+				return nil, fmt.Errorf("%w; set -passphrase-env instead", err)
+			}
+			p, err := rbxauth.TerminalPassphraseOpts(&terminalOptions)
+			if err != nil {
+				return nil, err
+			}
+			c.passphrase = p
+		}
+	}
+	return append([]byte(nil), c.passphrase...), nil
+}
+
+// reader returns a function that reads cookies in the given format. Encrypted
+// cookies are decrypted when the format is auto.
+func (c *crypt) reader(format string) func(io.Reader) ([]*http.Cookie, error) {
+	if format == "auto" {
+		return func(r io.Reader) ([]*http.Cookie, error) {
+			return rbxauth.ReadCookiesAutoPassphrase(r, c.getPassphrase)
+		}
+	}
+	return cookieReader(format)
+}
+
+// writer returns a function that writes cookies in the given format, or
+// encrypted if encrypt is true.
+func (c *crypt) writer(format string, encrypt bool) func(io.Writer, []*http.Cookie) error {
+	if !encrypt {
+		return cookieWriter(format)
+	}
+	return func(w io.Writer, cookies []*http.Cookie) error {
+		p, err := c.getPassphrase()
+		if err != nil {
+			return err
+		}
+		defer rbxauth.SecurePassword(p).Wipe()
+		return rbxauth.WriteCookiesEncrypted(w, cookies, p)
+	}
+}
+
+// sniffEncrypted returns whether the file at path appears to contain
+// encrypted cookies.
+func sniffEncrypted(path string) bool {
+	b, err := ioutil.ReadFile(path)
+	return err == nil && rbxauth.IsCookiesEncrypted(b)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/anaminus/rbxauth"
+)
+
+// endpoint describes a configurable endpoint of a rbxauth.Config.
+type endpoint struct {
+	// Name of the flag, and the basis of the environment variable.
+	name string
+	// Default URL of the endpoint.
+	def string
+	// Field of the Config that receives the URL.
+	field func(cfg *rbxauth.Config) *string
+}
+
+var endpoints = []endpoint{
+	{"login", rbxauth.DefaultLoginEndpoint, func(c *rbxauth.Config) *string { return &c.LoginEndpoint }},
+	{"logout", rbxauth.DefaultLogoutEndpoint, func(c *rbxauth.Config) *string { return &c.LogoutEndpoint }},
+	{"logout-all", rbxauth.DefaultLogoutAllEndpoint, func(c *rbxauth.Config) *string { return &c.LogoutAllEndpoint }},
+	{"validate-password", rbxauth.DefaultValidatePasswordEndpoint, func(c *rbxauth.Config) *string { return &c.ValidatePasswordEndpoint }},
+	{"change-password", rbxauth.DefaultChangePasswordEndpoint, func(c *rbxauth.Config) *string { return &c.ChangePasswordEndpoint }},
+	{"metadata", rbxauth.DefaultMetadataEndpoint, func(c *rbxauth.Config) *string { return &c.MetadataEndpoint }},
+	{"auth-ticket", rbxauth.DefaultAuthTicketEndpoint, func(c *rbxauth.Config) *string { return &c.AuthTicketEndpoint }},
+	{"redeem-auth-ticket", rbxauth.DefaultRedeemAuthTicketEndpoint, func(c *rbxauth.Config) *string { return &c.RedeemAuthTicketEndpoint }},
+	{"email", rbxauth.DefaultEmailEndpoint, func(c *rbxauth.Config) *string { return &c.EmailEndpoint }},
+	{"email-verify", rbxauth.DefaultEmailVerifyEndpoint, func(c *rbxauth.Config) *string { return &c.EmailVerifyEndpoint }},
+	{"social-login", rbxauth.DefaultSocialLoginEndpoint, func(c *rbxauth.Config) *string { return &c.SocialLoginEndpoint }},
+	{"signup", rbxauth.DefaultSignupEndpoint, func(c *rbxauth.Config) *string { return &c.SignupEndpoint }},
+	{"validate-username", rbxauth.DefaultValidateUsernameEndpoint, func(c *rbxauth.Config) *string { return &c.ValidateUsernameEndpoint }},
+	{"recommend-usernames", rbxauth.DefaultRecommendUsernamesEndpoint, func(c *rbxauth.Config) *string { return &c.RecommendUsernamesEndpoint }},
+	{"verify", rbxauth.DefaultVerifyEndpoint, func(c *rbxauth.Config) *string { return &c.VerifyEndpoint }},
+	{"resend", rbxauth.DefaultResendEndpoint, func(c *rbxauth.Config) *string { return &c.ResendEndpoint }},
+	{"userid", rbxauth.DefaultUserIDEndpoint, func(c *rbxauth.Config) *string { return &c.UserIDEndpoint }},
+	{"authenticated", rbxauth.DefaultAuthenticatedEndpoint, func(c *rbxauth.Config) *string { return &c.AuthenticatedEndpoint }},
+	{"refresh", rbxauth.DefaultRefreshEndpoint, func(c *rbxauth.Config) *string { return &c.RefreshEndpoint }},
+	{"username-lookup", rbxauth.DefaultUsernameLookupEndpoint, func(c *rbxauth.Config) *string { return &c.UsernameLookupEndpoint }},
+	{"twostep-challenge", rbxauth.DefaultTwoStepChallengeEndpoint, func(c *rbxauth.Config) *string { return &c.TwoStepChallengeEndpoint }},
+	{"twostep-login", rbxauth.DefaultTwoStepLoginEndpoint, func(c *rbxauth.Config) *string { return &c.TwoStepLoginEndpoint }},
+	{"security-questions", rbxauth.DefaultSecurityQuestionsEndpoint, func(c *rbxauth.Config) *string { return &c.SecurityQuestionsEndpoint }},
+	{"password-reset-send", rbxauth.DefaultPasswordResetSendEndpoint, func(c *rbxauth.Config) *string { return &c.PasswordResetSendEndpoint }},
+	{"password-reset-verify", rbxauth.DefaultPasswordResetVerifyEndpoint, func(c *rbxauth.Config) *string { return &c.PasswordResetVerifyEndpoint }},
+	{"password-reset", rbxauth.DefaultPasswordResetEndpoint, func(c *rbxauth.Config) *string { return &c.PasswordResetEndpoint }},
+}
+
+// envName returns the environment variable corresponding to the endpoint.
+func (e endpoint) envName() string {
+	return "RBXAUTH_" + strings.ToUpper(strings.ReplaceAll(e.name, "-", "_")) + "_ENDPOINT"
+}
+
+// endpointFlags defines a flag on fs for each endpoint, as well as -host,
+// -site, and -allow-insecure flags. The returned function applies the
+// endpoints to cfg after the flags have been parsed, then validates them. A
+// flag that is not set falls back to the corresponding environment variable,
+// then to the default rewritten with -host or derived from -site, if set.
+func endpointFlags(fs *flag.FlagSet) func(cfg *rbxauth.Config) error {
+	values := make([]string, len(endpoints))
+	for i, e := range endpoints {
+		fs.StringVar(&values[i], e.name+"-endpoint", "", fmt.Sprintf("URL of the %s endpoint. Falls back to $%s.", e.name, e.envName()))
+	}
+	var host string
+	fs.StringVar(&host, "host", "", "Replaces the host of each default endpoint. May include a scheme. Falls back to $RBXAUTH_HOST.")
+	var site string
+	fs.StringVar(&site, "site", "", "Replaces roblox.com in the host of each default endpoint, retaining the subdomain (e.g. sitetest1.robloxlabs.com). Falls back to $RBXAUTH_SITE.")
+	var insecure bool
+	fs.BoolVar(&insecure, "allow-insecure", false, "Permit endpoints to use http, such as for a local stub. Falls back to $RBXAUTH_ALLOW_INSECURE.")
+	return func(cfg *rbxauth.Config) error {
+		if !insecure {
+			insecure, _ = strconv.ParseBool(os.Getenv("RBXAUTH_ALLOW_INSECURE"))
+		}
+		cfg.AllowInsecure = insecure
+		if host == "" {
+			host = os.Getenv("RBXAUTH_HOST")
+		}
+		if site == "" {
+			site = os.Getenv("RBXAUTH_SITE")
+		}
+		if host != "" && site != "" {
+			return usageErrorf("only one of -host and -site may be set")
+		}
+		siteCfg, err := rbxauth.NewConfig(site)
+		if err != nil {
+			return err
+		}
+		for i, e := range endpoints {
+			value := values[i]
+			if value == "" {
+				value = os.Getenv(e.envName())
+			}
+			if value == "" {
+				switch {
+				case host != "":
+					value = rewriteHost(e.def, host)
+				case site != "":
+					value = *e.field(&siteCfg)
+				default:
+					continue
+				}
+			}
+			*e.field(cfg) = value
+		}
+		return cfg.Validate()
+	}
+}
+
+// rewriteHost replaces the host of endpoint with host. If host includes a
+// scheme, then the scheme is replaced as well.
+func rewriteHost(endpoint, host string) string {
+	scheme, rest := "https", endpoint
+	if i := strings.Index(endpoint, "://"); i >= 0 {
+		scheme, rest = endpoint[:i], endpoint[i+3:]
+	}
+	if i := strings.Index(host, "://"); i >= 0 {
+		scheme, host = host[:i], host[i+3:]
+	}
+	host = strings.TrimSuffix(host, "/")
+	if i := strings.IndexByte(rest, '/'); i >= 0 {
+		return scheme + "://" + host + rest[i:]
+	}
+	return scheme + "://" + host
+}
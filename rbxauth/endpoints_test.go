@@ -0,0 +1,99 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/anaminus/rbxauth"
+	"github.com/anaminus/rbxauth/rbxauthtest"
+)
+
+// loginArgs are the arguments that log into the account named alice, with the
+// password in $P.
+var loginArgs = []string{"login", "-t", "Username", "-u", "alice", "-password-env", "P"}
+
+// checkLogin checks that stdout contains the cookies of a session of alice.
+func checkLogin(t *testing.T, srv *rbxauthtest.Server, name, stdout, stderr string, code int) {
+	t.Helper()
+	if code != 0 {
+		t.Errorf("%s: exit %d: %s", name, code, stderr)
+		return
+	}
+	cookies, err := rbxauth.ReadCookies(strings.NewReader(stdout))
+	if err != nil {
+		t.Errorf("%s: read cookies: %v", name, err)
+		return
+	}
+	if user, err := srv.Config().Authenticated(cookies); err != nil || user.Name != "alice" {
+		t.Errorf("%s: expected session of alice, got %+v, %v", name, user, err)
+	}
+}
+
+// endpointArgs returns a flag setting each endpoint of srv.
+func endpointArgs(srv *rbxauthtest.Server) []string {
+	var args []string
+	cfg := srv.Config()
+	for _, e := range endpoints {
+		if value := *e.field(&cfg); value != "" {
+			args = append(args, "-"+e.name+"-endpoint", value)
+		}
+	}
+	return args
+}
+
+func TestEndpointOverrides(t *testing.T) {
+	srv := rbxauthtest.NewServer()
+	defer srv.Close()
+	srv.AddAccount(rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass"})
+	t.Setenv("P", "pass")
+	// An address at which nothing is listening.
+	const unreachable = "http://127.0.0.1:1"
+
+	stdout, stderr, code := runMain(t, srv, "", loginArgs...)
+	checkLogin(t, srv, "environment", stdout, stderr, code)
+
+	for _, test := range []struct {
+		name string
+		env  []string
+		args []string
+	}{
+		{"flags", nil, append([]string{"-allow-insecure"}, endpointArgs(srv)...)},
+		{"host flag", []string{"RBXAUTH_HOST=" + unreachable}, []string{"-allow-insecure", "-host", srv.URL}},
+		{"host environment", []string{"RBXAUTH_ALLOW_INSECURE=1", "RBXAUTH_HOST=" + srv.URL}, nil},
+		// Flags take precedence over the environment.
+		{"flags over environment", []string{"RBXAUTH_LOGIN_ENDPOINT=" + unreachable + rbxauthtest.LoginPath}, append([]string{"-allow-insecure"}, endpointArgs(srv)...)},
+	} {
+		stdout, stderr, code := runEnv(t, test.env, "", append(append([]string{}, loginArgs...), test.args...)...)
+		checkLogin(t, srv, test.name, stdout, stderr, code)
+	}
+}
+
+func TestEndpointInvalid(t *testing.T) {
+	srv := rbxauthtest.NewServer()
+	defer srv.Close()
+	srv.AddAccount(rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass"})
+	t.Setenv("P", "pass")
+
+	for _, test := range []struct {
+		name string
+		args []string
+		// msg is a substring of the expected error.
+		msg string
+	}{
+		{"not a URL", []string{"-login-endpoint", "::"}, "LoginEndpoint"},
+		{"relative", []string{"-login-endpoint", "/v2/login"}, "LoginEndpoint"},
+		{"insecure", []string{"-login-endpoint", srv.URL + rbxauthtest.LoginPath}, "LoginEndpoint"},
+		{"missing verb", []string{"-allow-insecure", "-userid-endpoint", srv.URL + rbxauthtest.UserIDPath}, "UserIDEndpoint"},
+		{"host and site", []string{"-host", "example.com", "-site", "robloxlabs.com"}, "-host and -site"},
+	} {
+		before := srv.Count(rbxauthtest.LoginPath)
+		_, stderr, code := runEnv(t, nil, "", append(append([]string{}, loginArgs...), test.args...)...)
+		if code == 0 || !strings.Contains(stderr, test.msg) {
+			t.Errorf("%s: expected failure mentioning %q, got exit %d: %s", test.name, test.msg, code, stderr)
+		}
+		// The endpoints are rejected before any request.
+		if srv.Count(rbxauthtest.LoginPath) != before {
+			t.Errorf("%s: login was attempted", test.name)
+		}
+	}
+}
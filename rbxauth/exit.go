@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/anaminus/but"
+	"github.com/anaminus/rbxauth"
+)
+
+// exitCategory is a category of failure, matched by any of errs.
+type exitCategory struct {
+	code int
+	name string
+	errs []error
+}
+
+// exitCategories lists each category of failure, in the order they are
+// matched, so that an error matching several categories has the first.
+var exitCategories = []exitCategory{
+	{exitUsage, "usage", []error{errUsage}},
+	{exitCaptcha, "captcha", []error{rbxauth.ErrCaptchaRequired}},
+	{exitTwoStep, "two-step", []error{rbxauth.ErrTwoStepRequired}},
+	{exitThrottled, "throttled", []error{
+		rbxauth.ErrAccountThrottled,
+		rbxauth.ErrTooManyAttempts,
+		rbxauth.ErrAccountLocked,
+		rbxauth.ErrPinLocked,
+		rbxauth.ErrTooManyCodeAttempts,
+		rbxauth.ErrQuestionsLocked,
+		rbxauth.ErrTooManyEmails,
+		rbxauth.ErrResendCooldown,
+	}},
+	{exitCredentials, "credentials", []error{
+		rbxauth.ErrBadCredentials,
+		rbxauth.ErrInvalidCode,
+		rbxauth.ErrIncorrectPIN,
+		rbxauth.ErrWrongAnswer,
+	}},
+	{exitNetwork, "network", []error{rbxauth.ErrTimeout, context.DeadlineExceeded}},
+}
+
+// exitCode returns the exit code for err, and the name of its category.
+// Returns exitError and an empty name if err has no category.
+func exitCode(err error) (code int, name string) {
+	for _, category := range exitCategories {
+		for _, e := range category.errs {
+			if errors.Is(err, e) {
+				return category.code, category.name
+			}
+		}
+	}
+	if netErr := net.Error(nil); errors.As(err, &netErr) {
+		return exitNetwork, "network"
+	}
+	return exitError, ""
+}
+
+// ifFatal prints err to stderr and exits, if the error is non-nil, as with
+// but.IfFatal. The category of the error, if any, is printed after the error,
+// and determines the exit code.
+func ifFatal(err error, args ...interface{}) {
+	if err == nil {
+		return
+	}
+	but.IfError(err, args...)
+	exit(err)
+}
+
+// exit exits with the exit code of err, printing the category of err to
+// stderr, if any.
+func exit(err error) {
+	code, name := exitCode(err)
+	if name != "" {
+		fmt.Fprintf(os.Stderr, "category: %s\n", name)
+	}
+	os.Exit(code)
+}
+
+// errUsage is matched by an error returned by usageErrorf.
+var errUsage = errors.New("usage")
+
+// usageError indicates that the program was used incorrectly.
+type usageError struct {
+	err error
+}
+
+func (e usageError) Error() string        { return e.err.Error() }
+func (e usageError) Unwrap() error        { return e.err }
+func (e usageError) Is(target error) bool { return target == errUsage }
+
+// usageErrorf returns an error indicating that the program was used
+// incorrectly, formatted as with fmt.Errorf.
+func usageErrorf(format string, args ...interface{}) error {
+	return usageError{err: fmt.Errorf(format, args...)}
+}
+
+// messageError replaces the message of err, while retaining err in the chain.
+type messageError struct {
+	msg string
+	err error
+}
+
+func (e messageError) Error() string { return e.msg }
+func (e messageError) Unwrap() error { return e.err }
+
+// parseFlags parses args with fs, which must use flag.ContinueOnError. Exits
+// with exitUsage if the flags are invalid, or successfully if help was
+// requested. The error is printed by fs.
+func parseFlags(fs *flag.FlagSet, args []string) {
+	switch err := fs.Parse(args); {
+	case err == flag.ErrHelp:
+		os.Exit(0)
+	case err != nil:
+		fmt.Fprintln(os.Stderr, "category: usage")
+		os.Exit(exitUsage)
+	}
+}
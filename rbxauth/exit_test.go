@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/anaminus/rbxauth"
+	"github.com/anaminus/rbxauth/rbxauthtest"
+)
+
+// exitTests maps each kind returned by rbxauth.Classify, and each other error
+// with a category, to its exit code and category name.
+var exitTests = []struct {
+	err  error
+	code int
+	name string
+}{
+	{rbxauth.ErrBadCredentials, exitCredentials, "credentials"},
+	{rbxauth.ErrCaptchaRequired, exitCaptcha, "captcha"},
+	{rbxauth.ErrAccountLocked, exitThrottled, "throttled"},
+	{rbxauth.ErrTooManyAttempts, exitThrottled, "throttled"},
+	{rbxauth.ErrPinLocked, exitThrottled, "throttled"},
+	{rbxauth.ErrPinNotSet, exitError, ""},
+	{rbxauth.ErrIncorrectPIN, exitCredentials, "credentials"},
+	{rbxauth.ErrInvalidCode, exitCredentials, "credentials"},
+	{rbxauth.ErrTooManyCodeAttempts, exitThrottled, "throttled"},
+	{rbxauth.ErrStepExpired, exitError, ""},
+	{rbxauth.ErrInvalidAuthTicket, exitError, ""},
+	{rbxauth.ErrAuthTicketExpired, exitError, ""},
+	{rbxauth.ErrNoEmail, exitError, ""},
+	{rbxauth.ErrTooManyEmails, exitThrottled, "throttled"},
+	{rbxauth.ErrInvalidSocialToken, exitError, ""},
+	{rbxauth.ErrUsernameTaken, exitError, ""},
+	{rbxauth.ErrInvalidBirthday, exitError, ""},
+	{rbxauth.ErrPasswordWeak, exitError, ""},
+	{rbxauth.ErrWrongAnswer, exitCredentials, "credentials"},
+	{rbxauth.ErrQuestionsLocked, exitThrottled, "throttled"},
+	{rbxauth.ErrAccountNotFound, exitError, ""},
+	{rbxauth.ErrAlreadyLoggedOut, exitError, ""},
+	{rbxauth.ErrAccountThrottled, exitThrottled, "throttled"},
+	{rbxauth.ErrParentalConsentRequired, exitError, ""},
+	{rbxauth.ErrAgeRestricted, exitError, ""},
+	{rbxauth.ErrTwoStepRequired, exitTwoStep, "two-step"},
+
+	// Errors that are not kinds.
+	{usageErrorf("bad flag"), exitUsage, "usage"},
+	{rbxauth.ErrResendCooldown, exitThrottled, "throttled"},
+	{rbxauth.ErrTimeout, exitNetwork, "network"},
+	{context.DeadlineExceeded, exitNetwork, "network"},
+	{&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, exitNetwork, "network"},
+	{errors.New("other"), exitError, ""},
+}
+
+func TestExitCode(t *testing.T) {
+	kinds := 0
+	for _, test := range exitTests {
+		if kind := rbxauth.Classify(test.err); kind != nil {
+			if kind != test.err {
+				t.Errorf("%v: classified as %v", test.err, kind)
+			}
+			kinds++
+		}
+		for _, err := range []error{test.err, fmt.Errorf("login: %w", test.err)} {
+			code, name := exitCode(err)
+			if code != test.code || name != test.name {
+				t.Errorf("%v: expected %d %q, got %d %q", err, test.code, test.name, code, name)
+			}
+		}
+	}
+	// The kinds of rbxauth cannot be enumerated, so check that the table
+	// lists as many as there are.
+	const numKinds = 26
+	if kinds != numKinds {
+		t.Errorf("expected %d kinds, got %d", numKinds, kinds)
+	}
+
+	// The first matching category wins.
+	err := usageErrorf("login: %w", rbxauth.ErrBadCredentials)
+	if code, name := exitCode(err); code != exitUsage || name != "usage" {
+		t.Errorf("%v: expected usage, got %d %q", err, code, name)
+	}
+}
+
+// exitTestEnv names the environment variable that makes TestIfFatal call
+// ifFatal with the exitTests entry at the given index.
+const exitTestEnv = "RBXAUTH_EXIT_TEST"
+
+func TestIfFatal(t *testing.T) {
+	if s := os.Getenv(exitTestEnv); s != "" {
+		i, _ := strconv.Atoi(s)
+		ifFatal(exitTests[i].err, "fatal")
+		os.Exit(100)
+	}
+
+	ifFatal(nil, "not fatal")
+
+	for i, test := range exitTests {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestIfFatal$")
+		cmd.Env = append(os.Environ(), exitTestEnv+"="+strconv.Itoa(i))
+		var stderr strings.Builder
+		cmd.Stderr = &stderr
+		err := cmd.Run()
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			t.Errorf("%v: expected exit error, got %v", test.err, err)
+			continue
+		}
+		if code := exitErr.ExitCode(); code != test.code {
+			t.Errorf("%v: expected exit code %d, got %d", test.err, test.code, code)
+		}
+		out := stderr.String()
+		if !strings.Contains(out, test.err.Error()) {
+			t.Errorf("%v: error not printed: %q", test.err, out)
+		}
+		category := "category: " + test.name + "\n"
+		if has := strings.Contains(out, "category:"); has != (test.name != "") ||
+			test.name != "" && !strings.HasSuffix(out, category) {
+			t.Errorf("%v: expected category %q, got %q", test.err, test.name, out)
+		}
+	}
+}
+
+func TestExitCategories(t *testing.T) {
+	srv := rbxauthtest.NewServer()
+	defer srv.Close()
+	srv.AddAccount(rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass"})
+	srv.AddAccount(rbxauthtest.Account{ID: 2, Name: "bob", Password: "pass", TwoStep: true, Code: "123456"})
+	srv.AddAccount(rbxauthtest.Account{ID: 3, Name: "carol", Password: "pass", Captcha: "token"})
+	srv.AddAccount(rbxauthtest.Account{ID: 4, Name: "dave", Password: "pass", Throttled: 1})
+
+	// A server that has been closed refuses connections.
+	closed := rbxauthtest.NewServer()
+	closed.Close()
+
+	for _, test := range []struct {
+		name     string
+		srv      *rbxauthtest.Server
+		user     string
+		password string
+		code     int
+		category string
+	}{
+		{"credentials", srv, "alice", "wrong", exitCredentials, "credentials"},
+		{"captcha", srv, "carol", "pass", exitCaptcha, "captcha"},
+		// The input ends before the code is entered.
+		{"two-step", srv, "bob", "pass", exitTwoStep, "two-step"},
+		{"throttled", srv, "dave", "pass", exitThrottled, "throttled"},
+		{"network", closed, "alice", "pass", exitNetwork, "network"},
+	} {
+		t.Setenv("P", test.password)
+		stdout, stderr, code := runMain(t, test.srv, "", "login", "-t", "Username", "-u", test.user, "-password-env", "P")
+		if code != test.code {
+			t.Errorf("%s: expected exit %d, got %d: %s", test.name, test.code, code, stderr)
+		}
+		if want := "category: " + test.category + "\n"; !strings.HasSuffix(stderr, want) {
+			t.Errorf("%s: expected category %q, got %q", test.name, test.category, stderr)
+		}
+		if stdout != "" {
+			t.Errorf("%s: expected no output, got %q", test.name, stdout)
+		}
+	}
+}
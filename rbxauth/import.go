@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/anaminus/rbxauth"
+)
+
+// runImport implements the import subcommand.
+func runImport(args []string) {
+	var browser string
+	var profile string
+	var tokenEnv string
+	var output string
+	var format string
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.StringVar(&browser, "browser", "", "Browser from which the session is imported ("+strings.Join(rbxauth.Browsers, ", ")+").")
+	fs.StringVar(&profile, "profile", "", "Name or path of the browser profile. Use the default profile if empty.")
+	fs.StringVar(&tokenEnv, "token-env", "", "Name of environment variable containing the value of the session cookie, instead of importing from a browser.")
+	fs.StringVar(&output, "o", stdioPath, "Path to output file, or - for stdout.")
+	fs.StringVar(&format, "format", "headers", "Format of cookie output (headers, json).")
+	crypt := cryptFlags(fs, true)
+	token := tokenFlags(fs)
+	parseFlags(fs, args)
+
+	writeOutput, err := openOutput("-o", output)
+	ifFatal(err)
+	ifFatal(token.open())
+
+	writeCookies := crypt.writer(format, crypt.encrypt)
+
+	var cookies []*http.Cookie
+	switch {
+	case browser != "" && tokenEnv != "":
+		ifFatal(usageErrorf("only one of -browser and -token-env may be set"))
+	case browser != "":
+		var err error
+		cookies, err = rbxauth.ImportBrowserCookies(browser, profile)
+		ifFatal(err)
+	case tokenEnv != "":
+		token, ok := os.LookupEnv(tokenEnv)
+		if !ok {
+			ifFatal(fmt.Errorf("variable %s is not set", tokenEnv))
+		}
+		cookies = rbxauth.FromSecurityToken(strings.TrimSpace(token))
+	default:
+		ifFatal(usageErrorf("-browser or -token-env must be set"))
+	}
+
+	ifFatal(writeOutput(func(w io.Writer) error {
+		return writeCookies(w, cookies)
+	}))
+	ifFatal(token.emit(cookies))
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/anaminus/rbxauth"
+)
+
+// runLogin implements the login subcommand, which is the default.
+func runLogin(args []string) {
+	var output string
+	var check string
+	var format string
+	var passwordEnv string
+	var passwordFile string
+	var passwordFD int
+	var passwordCmd string
+	var codeCmd string
+	var shell bool
+	var refresh string
+	var noConfirm bool
+	var checkMetadata bool
+	var remember string
+	var rememberDevice string
+	var timeout time.Duration
+	var totpEnv string
+	var code string
+	var noFallback bool
+	var jsonReport bool
+	var includeCookies bool
+	var minimal bool
+	var logoutOnAbort bool
+	// var passwd string
+	var cred rbxauth.Cred
+	fs := flag.NewFlagSet("login", flag.ContinueOnError)
+	fs.StringVar(&output, "o", stdioPath, "Path to output file, or - for stdout.")
+	fs.StringVar(&cred.Type, "t", "", "Credential type (Username, Email, PhoneNumber, or auto to detect from -u). Prompt if empty.")
+	fs.StringVar(&cred.Ident, "u", "", "Credential identifier. Prompt if empty.")
+	// fs.StringVar(&passwd, "p", "", "Password. Prompt if empty.")
+	fs.StringVar(&passwordEnv, "password-env", "", "Name of environment variable containing the password.")
+	fs.StringVar(&passwordFile, "password-file", "", "Path to file containing the password, or - for stdin.")
+	fs.IntVar(&passwordFD, "password-fd", -1, "File descriptor from which the password is read.")
+	fs.StringVar(&passwordCmd, "password-cmd", "", "Command whose output is the password, such as a password manager.")
+	fs.BoolVar(&shell, "shell", false, "Run the commands of -password-cmd and -code-cmd through the system shell.")
+	fs.StringVar(&format, "format", "headers", "Format of cookie output (headers, json).")
+	fs.StringVar(&check, "check", "", "Path to cookie file (- for stdin), or account name with -store. Print the user of the session instead of logging in.")
+	fs.StringVar(&refresh, "refresh", "", "Path to cookie file (- for stdin and stdout), or account name with -store. Refresh the session and rewrite it instead of logging in.")
+	fs.BoolVar(&noConfirm, "no-confirm", false, "Use a credential type detected with -t auto without confirmation.")
+	fs.BoolVar(&checkMetadata, "check-metadata", false, "Warn before logging in if a captcha is enforced.")
+	fs.StringVar(&remember, "remember", "", "Path to device cookie file. Read to skip two-step verification, and written when the device is remembered.")
+	fs.StringVar(&rememberDevice, "remember-device", "ask", "Whether to remember the device after two-step verification (yes, no, ask).")
+	fs.StringVar(&totpEnv, "totp-env", "", "Name of environment variable containing an authenticator secret, from which verification codes are generated instead of prompted.")
+	fs.StringVar(&code, "code", "", "Two-step verification code to submit instead of prompting. If incorrect, the code is prompted.")
+	fs.StringVar(&codeCmd, "code-cmd", "", "Command whose output is the two-step verification code, run again after each resend. Takes precedence over -code.")
+	fs.BoolVar(&noFallback, "no-fallback", false, "Fail instead of prompting if the code from -code or -code-cmd is incorrect.")
+	fs.DurationVar(&timeout, "timeout", 0, "Fail if a prompt is not answered within the given duration. No timeout if zero.")
+	fs.BoolVar(&minimal, "minimal", false, "Write only the cookies required for the session, excluding tracking cookies.")
+	fs.BoolVar(&logoutOnAbort, "logout-on-abort", false, "If interrupted after logging in but before the cookies are written, log out without asking.")
+	fs.BoolVar(&jsonReport, "json", false, "Write the result as a JSON object to stdout. Cookies are not written to stdout, so -o, -store, or -json-include-cookies must also be set.")
+	fs.BoolVar(&includeCookies, "json-include-cookies", false, "Include cookies in the JSON result.")
+	in := streamFlags(fs)
+	crypt := cryptFlags(fs, true)
+	st := storeFlags(fs)
+	rewrite := rewriteFlags(fs)
+	token := tokenFlags(fs)
+	config := configFlags(fs)
+	parseFlags(fs, args)
+
+	// report is written instead of failing with a message when -json is
+	// set.
+	var report loginReport
+	fatal := func(err error) {
+		if err == nil {
+			return
+		}
+		abort.halt()
+		if jsonReport {
+			report.fail(err)
+		}
+		ifFatal(err)
+	}
+
+	if strings.EqualFold(cred.Type, rbxauth.Auto) {
+		cred.Type = rbxauth.Auto
+	}
+
+	writeCookies := crypt.writer(format, crypt.encrypt)
+	// writeSession writes the session, preceded by meta when the output is
+	// plain headers.
+	var meta rbxauth.CookieMeta
+	writeSession := writeCookies
+	if format == "headers" && !crypt.encrypt {
+		writeSession = func(w io.Writer, cookies []*http.Cookie) error {
+			return rbxauth.WriteCookiesWithMeta(w, cookies, meta)
+		}
+	}
+	cfg := config()
+	cs, err := st.open()
+	fatal(err)
+	if cs != nil && isSet(fs, "o") {
+		fatal(usageErrorf("-o cannot be set with -store"))
+	}
+	if remember == stdioPath {
+		fatal(usageErrorf("-remember cannot be %s, because the file is both read and written", stdioPath))
+	}
+	// readSession reads the session from the cookie file at name, given to
+	// the flag, or the account of the given name if -store is set.
+	readSession := func(flag, name string) ([]*http.Cookie, error) {
+		if cs != nil {
+			return loadSession(cs, name)
+		}
+		return readCookieFile(flag, name, crypt.reader("auto"))
+	}
+
+	if check != "" {
+		cookies, err := readSession("-check", check)
+		fatal(err)
+		warnExpiry(rbxauth.InspectCookies(cookies))
+		user, err := cfg.Authenticated(cookies)
+		fatal(err)
+		if jsonReport {
+			report.Success = true
+			report.UserID, report.UserName = user.ID, user.Name
+			report.write()
+			return
+		}
+		fmt.Println(user.Name)
+		return
+	}
+
+	if refresh != "" {
+		// The input is buffered, so that its encryption and metadata are
+		// retained when rewritten, even when read from stdin.
+		var input []byte
+		var cookies []*http.Cookie
+		if cs != nil {
+			cookies, err = loadSession(cs, refresh)
+		} else {
+			read := crypt.reader("auto")
+			cookies, err = readCookieFile("-refresh", refresh, func(r io.Reader) ([]*http.Cookie, error) {
+				if input, err = ioutil.ReadAll(r); err != nil {
+					return nil, err
+				}
+				return read(bytes.NewReader(input))
+			})
+		}
+		fatal(err)
+		cookies, err = cfg.Refresh(cookies)
+		fatal(err)
+		if minimal {
+			cookies = rbxauth.FilterSessionCookies(cookies)
+		}
+		if cs != nil {
+			fatal(cs.SaveSession(refresh, cookies))
+			return
+		}
+		cookies = rewrite(cookies)
+		if rbxauth.IsCookiesEncrypted(input) {
+			writeSession = crypt.writer(format, true)
+		} else {
+			meta = cookieMeta(input)
+		}
+		writeRefresh, err := openOutput("-refresh", refresh)
+		fatal(err)
+		fatal(writeRefresh(func(w io.Writer) error {
+			return writeSession(w, cookies)
+		}))
+		return
+	}
+
+	// The outputs of the session are validated before logging in. With
+	// -json, stdout receives the report instead of the cookies.
+	if jsonReport {
+		fatal(useStdout("-json"))
+		if cs == nil && output == stdioPath && !includeCookies {
+			fatal(usageErrorf("-json requires -o, -store, or -json-include-cookies, or the cookies would be discarded"))
+		}
+	}
+	var writeOutput func(write func(w io.Writer) error) error
+	if cs == nil && !(jsonReport && output == stdioPath) {
+		writeOutput, err = openOutput("-o", output)
+		fatal(err)
+	}
+	fatal(token.open())
+
+	if totpEnv != "" {
+		secret, ok := os.LookupEnv(totpEnv)
+		if !ok {
+			fatal(fmt.Errorf("authenticator variable %s is not set", totpEnv))
+		}
+		cfg.CodeProvider = rbxauth.TOTPCodeProvider(secret, nil)
+	}
+
+	if remember != "" {
+		cookies, err := readCookieFile("-remember", remember, crypt.reader("auto"))
+		if err != nil && !os.IsNotExist(err) {
+			fatal(err)
+		}
+		cfg.PersistentCookies = cookies
+	}
+
+	stream, err := in.stream(fs, cfg)
+	fatal(err)
+	stream.Context = abort.ctx
+	stream.Code = code
+	stream.NoFallback = noFallback
+	stream.NoConfirm = noConfirm
+	stream.CheckMetadata = checkMetadata
+	stream.Timeout = timeout
+	switch strings.ToLower(rememberDevice) {
+	case "ask":
+		stream.RememberDevice = rbxauth.RememberAsk
+	case "yes":
+		stream.RememberDevice = rbxauth.RememberAlways
+	case "no":
+		stream.RememberDevice = rbxauth.RememberNever
+	default:
+		fatal(usageErrorf("unknown -remember-device value %q", rememberDevice))
+	}
+
+	var sources int
+	if passwordEnv != "" {
+		stream.PasswordEnv = passwordEnv
+		sources++
+	}
+	if passwordFile != "" {
+		fatal(setPasswordFile(stream, "-password-file", passwordFile))
+		sources++
+	}
+	if passwordFD >= 0 {
+		stream.PasswordReader = os.NewFile(uintptr(passwordFD), "password")
+		sources++
+	}
+	if passwordCmd != "" {
+		stream.PasswordCommand, err = commandArgs(passwordCmd, shell)
+		fatal(err)
+		sources++
+	}
+	if sources > 1 {
+		fatal(usageErrorf("only one of -password-env, -password-file, -password-fd, and -password-cmd may be set"))
+	}
+	if codeCmd != "" {
+		stream.CodeCommand, err = commandArgs(codeCmd, shell)
+		fatal(err)
+	}
+
+	report.CredType = cred.Type
+	report.Ident = cred.Ident
+	cred, result, err := stream.PromptResult(cred)
+	if errors.Is(err, rbxauth.ErrPromptEOF) {
+		msg := "input ended before login completed"
+		if in.input != "" {
+			msg = "input from -i ended before login completed"
+		}
+		if errors.Is(err, rbxauth.ErrTwoStepRequired) {
+			// Retain the cause, which determines the exit code.
+			fatal(fmt.Errorf("%s: %w", msg, rbxauth.ErrTwoStepRequired))
+		}
+		fatal(errors.New(msg))
+	}
+	report.CredType = cred.Type
+	report.Ident = cred.Ident
+	if errResp := (rbxauth.ErrorResponse{}); errors.As(err, &errResp) && !jsonReport {
+		// Print only the response, while retaining err for the exit code.
+		fatal(messageError{msg: errResp.Error(), err: err})
+	}
+	fatal(err)
+	if user := result.User; user != nil {
+		report.UserID, report.UserName = user.ID, user.Name
+	} else if cred.Type == rbxauth.Username {
+		if id, err := stream.GetUserID(cred.Ident); err == nil {
+			report.UserID, report.UserName = id, cred.Ident
+		}
+	}
+	if report.UserID != 0 {
+		fmt.Fprintf(os.Stderr, "Logged in as %s (%d)\n", report.UserName, report.UserID)
+	}
+	if result.Step != nil {
+		report.TwoStep = true
+		report.MediaType = string(result.Step.MediaType)
+	}
+	report.PendingActions = result.PendingActions
+	report.RememberDevice = result.RememberDevice
+	report.Resends = result.Resends
+
+	cookies := result.Cookies
+	meta = rbxauth.CookieMeta{
+		Account: report.UserName,
+		UserID:  report.UserID,
+		Created: time.Now(),
+		Host:    endpointHost(cfg.LoginEndpoint, rbxauth.DefaultLoginEndpoint),
+	}
+	if meta.Account == "" {
+		meta.Account = cred.Ident
+	}
+	abort.setSession(cfg, cookies, output, logoutOnAbort)
+	if remember != "" {
+		var device []*http.Cookie
+		if cookies, device = rbxauth.SplitDeviceCookies(cookies); len(device) > 0 {
+			fatal(writeFileAtomic(remember, func(w io.Writer) error {
+				return writeCookies(w, device)
+			}))
+		}
+	}
+
+	if minimal {
+		cookies = rbxauth.FilterSessionCookies(cookies)
+	}
+
+	if cs != nil {
+		account := st.account
+		if account == "" {
+			account = report.UserName
+		}
+		if account == "" {
+			account = cred.Ident
+		}
+		fatal(cs.SaveSession(account, cookies))
+		abort.setWritten()
+		fmt.Fprintf(os.Stderr, "Stored session as %q\n", account)
+	} else if output != stdioPath {
+		fatal(writeOutput(func(w io.Writer) error {
+			return writeSession(w, rewrite(cookies))
+		}))
+	} else if !jsonReport {
+		fatal(writeSession(os.Stdout, rewrite(cookies)))
+		abort.setWritten()
+	}
+	fatal(token.emit(cookies))
+
+	if jsonReport {
+		report.Success = true
+		if output != stdioPath {
+			report.Output = output
+		}
+		if includeCookies {
+			fatal(report.setCookies(cookies))
+		}
+		report.write()
+		if includeCookies {
+			abort.setWritten()
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anaminus/rbxauth"
+	"github.com/anaminus/rbxauth/rbxauthtest"
+)
+
+func TestLoginOutput(t *testing.T) {
+	srv := rbxauthtest.NewServer()
+	defer srv.Close()
+	srv.AddAccount(rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass"})
+	srv.AddAccount(rbxauthtest.Account{ID: 2, Name: "bob", Password: "pass", TwoStep: true, Code: "123456"})
+	t.Setenv("P", "pass")
+
+	stdout, stderr, code := runMain(t, srv, "", loginArgs...)
+	checkLogin(t, srv, "success", stdout, stderr, code)
+
+	// Prompts are written to stderr, leaving only the cookies on stdout.
+	stdout, stderr, code = runMain(t, srv, "123456\nn\n", "login", "-t", "Username", "-u", "bob", "-password-env", "P")
+	if code != 0 {
+		t.Fatalf("two-step: exit %d: %s", code, stderr)
+	}
+	cookies, err := rbxauth.ReadCookies(strings.NewReader(stdout))
+	if err != nil {
+		t.Fatalf("two-step: read cookies: %v", err)
+	}
+	if user, err := srv.Config().Authenticated(cookies); err != nil || user.ID != 2 {
+		t.Errorf("two-step: expected session of bob, got %+v, %v", user, err)
+	}
+	if !strings.Contains(stderr, rbxauth.DefaultMessages.AskRememberDevice) {
+		t.Errorf("two-step: expected prompts on stderr, got %q", stderr)
+	}
+
+	t.Setenv("P", "wrong")
+	stdout, stderr, code = runMain(t, srv, "", loginArgs...)
+	if code != exitCredentials {
+		t.Errorf("wrong password: expected exit %d, got %d: %s", exitCredentials, code, stderr)
+	}
+	if stdout != "" {
+		t.Errorf("wrong password: expected no output, got %q", stdout)
+	}
+	if stderr == "" {
+		t.Error("wrong password: expected message")
+	}
+}
+
+func TestLoginJSON(t *testing.T) {
+	srv := rbxauthtest.NewServer()
+	defer srv.Close()
+	srv.AddAccount(rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass"})
+	srv.AddAccount(rbxauthtest.Account{ID: 2, Name: "bob", Password: "pass", TwoStep: true, Code: "123456"})
+	t.Setenv("P", "pass")
+
+	// run runs the login command, decoding the report from stdout.
+	run := func(name, stdin string, args ...string) (report loginReport, code int) {
+		t.Helper()
+		stdout, stderr, code := runMain(t, srv, stdin, append(append([]string{}, loginArgs...), args...)...)
+		if err := json.Unmarshal([]byte(stdout), &report); err != nil {
+			t.Errorf("%s: decode report: %v: %q: %s", name, err, stdout, stderr)
+		}
+		return report, code
+	}
+
+	// Without a destination, the cookies would be discarded.
+	report, code := run("discard", "", "-json")
+	if code != exitUsage || report.Success || report.Error == nil {
+		t.Errorf("discard: expected usage failure, got exit %d, %+v", code, report)
+	}
+	if n := srv.Count(rbxauthtest.LoginPath); n != 0 {
+		t.Errorf("discard: expected no login, got %d requests", n)
+	}
+
+	report, code = run("include", "", "-json", "-json-include-cookies")
+	if code != 0 || !report.Success || report.UserID != 1 || report.TwoStep {
+		t.Errorf("include: unexpected report: exit %d, %+v", code, report)
+	}
+	cookies, err := rbxauth.ReadCookiesJSON(strings.NewReader(string(report.Cookies)))
+	if err != nil {
+		t.Errorf("include: read cookies: %v", err)
+	} else if _, err := srv.Config().Authenticated(cookies); err != nil {
+		t.Errorf("include: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "cookies")
+	report, code = run("file", "", "-json", "-o", path)
+	if code != 0 || !report.Success || report.Output != path || report.Cookies != nil {
+		t.Errorf("file: unexpected report: exit %d, %+v", code, report)
+	}
+	if b, err := ioutil.ReadFile(path); err != nil {
+		t.Errorf("file: %v", err)
+	} else {
+		checkLogin(t, srv, "file", string(b), "", 0)
+	}
+
+	stdout, stderr, code := runMain(t, srv, "123456\ny\n", "login", "-t", "Username", "-u", "bob", "-password-env", "P", "-json", "-json-include-cookies")
+	if err := json.Unmarshal([]byte(stdout), &report); err != nil {
+		t.Fatalf("two-step: decode report: %v: %q: %s", err, stdout, stderr)
+	}
+	if code != 0 || !report.Success || !report.TwoStep || !report.RememberDevice || report.UserID != 2 {
+		t.Errorf("two-step: unexpected report: exit %d, %+v", code, report)
+	}
+
+	t.Setenv("P", "wrong")
+	report, code = run("wrong password", "", "-json", "-o", path)
+	if code != exitCredentials || report.Success || report.Error == nil {
+		t.Fatalf("wrong password: unexpected report: exit %d, %+v", code, report)
+	}
+	if report.Error.Kind != rbxauth.ErrBadCredentials.Error() {
+		t.Errorf("wrong password: expected kind %q, got %q", rbxauth.ErrBadCredentials, report.Error.Kind)
+	}
+}
+
+func TestLoginProxy(t *testing.T) {
+	srv := rbxauthtest.NewServer()
+	defer srv.Close()
+	srv.AddAccount(rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass"})
+	t.Setenv("P", "pass")
+
+	for _, test := range []struct {
+		name     string
+		newProxy func(user, password string) *rbxauthtest.Proxy
+	}{
+		{"http", rbxauthtest.NewHTTPProxy},
+		{"socks5", rbxauthtest.NewSOCKS5Proxy},
+	} {
+		proxy := test.newProxy("user", "secret")
+		defer proxy.Close()
+		stdout, stderr, code := runMain(t, srv, "", append(append([]string{}, loginArgs...), "-proxy", proxy.URL)...)
+		checkLogin(t, srv, test.name, stdout, stderr, code)
+		if len(proxy.Targets()) == 0 {
+			t.Errorf("%s: expected login through proxy", test.name)
+		}
+	}
+}
+
+func TestLoginTimeout(t *testing.T) {
+	srv := rbxauthtest.NewServer()
+	defer srv.Close()
+	srv.AddAccount(rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass"})
+	t.Setenv("P", "pass")
+
+	srv.Stall(rbxauthtest.LoginPath, 10*time.Second)
+	start := time.Now()
+	stdout, stderr, code := runMain(t, srv, "", append(append([]string{}, loginArgs...), "-request-timeout", "100ms")...)
+	if code != exitNetwork {
+		t.Errorf("expected exit %d, got %d: %s", exitNetwork, code, stderr)
+	}
+	if stdout != "" {
+		t.Errorf("expected no output, got %q", stdout)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("expected timeout after 100ms, got %v", d)
+	}
+}
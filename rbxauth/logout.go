@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/anaminus/rbxauth"
+)
+
+// runLogout implements the logout subcommand.
+func runLogout(args []string) {
+	var input string
+	var format string
+	var force bool
+	var all bool
+	fs := flag.NewFlagSet("logout", flag.ContinueOnError)
+	fs.StringVar(&input, "i", stdioPath, "Path to cookie file, or - for stdin.")
+	fs.StringVar(&format, "format", "auto", "Format of cookie input (auto, headers, json).")
+	fs.BoolVar(&force, "force", false, "Succeed if the session is already logged out.")
+	fs.BoolVar(&all, "all", false, "Log out of all other sessions, and rewrite the cookie file with the reissued session. Written to stdout if -i is -.")
+	crypt := cryptFlags(fs, true)
+	st := storeFlags(fs)
+	config := configFlags(fs)
+	parseFlags(fs, args)
+
+	cfg := config()
+	cs, err := st.open()
+	ifFatal(err)
+	var cookies []*http.Cookie
+	if cs != nil {
+		cookies, err = loadSession(cs, st.account)
+	} else {
+		cookies, err = readCookieFile("-i", input, crypt.reader(format))
+	}
+	ifFatal(err)
+
+	if all {
+		cookies, err = cfg.LogoutAll(cookies)
+		ifFatal(err)
+		if cs != nil {
+			ifFatal(cs.SaveSession(st.account, cookies))
+			fmt.Fprintln(os.Stderr, "Logged out of all other sessions")
+			return
+		}
+		if format == "auto" {
+			format = "headers"
+			if input != stdioPath && sniffJSON(input) {
+				format = "json"
+			}
+		}
+		writeCookies := crypt.writer(format, crypt.encrypt || input != stdioPath && sniffEncrypted(input))
+		writeInput, err := openOutput("-i", input)
+		ifFatal(err)
+		ifFatal(writeInput(func(w io.Writer) error {
+			return writeCookies(w, cookies)
+		}))
+		fmt.Fprintln(os.Stderr, "Logged out of all other sessions")
+		return
+	}
+
+	if force {
+		err = cfg.EnsureLoggedOut(cookies)
+	} else {
+		err = cfg.Logout(cookies)
+	}
+	ifFatal(err)
+	if cs != nil {
+		if err := cs.DeleteSession(st.account); err != nil && !errors.Is(err, rbxauth.ErrSessionNotFound) {
+			ifFatal(err)
+		}
+	}
+	fmt.Fprintln(os.Stderr, "Logged out")
+}
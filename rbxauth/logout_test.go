@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/anaminus/rbxauth"
+	"github.com/anaminus/rbxauth/rbxauthtest"
+)
+
+// sessionValue returns the value of the session cookie within cookies.
+func sessionValue(cookies []*http.Cookie) string {
+	for _, c := range cookies {
+		if c.Name == rbxauthtest.SessionCookieName {
+			return c.Value
+		}
+	}
+	return ""
+}
+
+func TestLogoutCommand(t *testing.T) {
+	srv := rbxauthtest.NewServer()
+	defer srv.Close()
+	srv.AddAccount(rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass"})
+	cfg := srv.Config()
+
+	path, cookies := writeSession(t, srv)
+	if _, stderr, code := runMain(t, srv, "", "logout", "-i", path); code != 0 || !strings.Contains(stderr, "Logged out") {
+		t.Fatalf("logout: exit %d: %s", code, stderr)
+	}
+	if _, err := cfg.Authenticated(cookies); !errors.Is(err, rbxauth.ErrUnauthenticated) {
+		t.Errorf("logout: expected ErrUnauthenticated, got %v", err)
+	}
+
+	// The session has already been logged out.
+	if _, stderr, code := runMain(t, srv, "", "logout", "-i", path); code == 0 {
+		t.Errorf("logged out: expected failure: %s", stderr)
+	}
+	if _, stderr, code := runMain(t, srv, "", "logout", "-force", "-i", path); code != 0 {
+		t.Errorf("logged out with -force: exit %d: %s", code, stderr)
+	}
+
+	// Cookies are read from stdin.
+	_, cookies = writeSession(t, srv)
+	var buf strings.Builder
+	if err := rbxauth.WriteCookies(&buf, cookies); err != nil {
+		t.Fatal(err)
+	}
+	if _, stderr, code := runMain(t, srv, buf.String(), "logout"); code != 0 {
+		t.Fatalf("stdin: exit %d: %s", code, stderr)
+	}
+	if _, err := cfg.Authenticated(cookies); !errors.Is(err, rbxauth.ErrUnauthenticated) {
+		t.Errorf("stdin: expected ErrUnauthenticated, got %v", err)
+	}
+}
+
+func TestLogoutAllCommand(t *testing.T) {
+	srv := rbxauthtest.NewServer()
+	defer srv.Close()
+	srv.AddAccount(rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass"})
+	cfg := srv.Config()
+
+	stale, other := writeSession(t, srv)
+	path, cookies := writeSession(t, srv)
+	if _, stderr, code := runMain(t, srv, "", "logout", "-all", "-i", path); code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr)
+	}
+	if _, err := cfg.Authenticated(other); !errors.Is(err, rbxauth.ErrUnauthenticated) {
+		t.Errorf("other session: expected ErrUnauthenticated, got %v", err)
+	}
+
+	// The file is rewritten with the reissued session.
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	reissued, err := rbxauth.ReadCookies(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cfg.Authenticated(reissued); err != nil {
+		t.Errorf("reissued session: %v", err)
+	}
+	if a, b := sessionValue(cookies), sessionValue(reissued); b == "" || a == b {
+		t.Errorf("session was not reissued: %q", b)
+	}
+
+	// A stale session fails, leaving its file intact.
+	before, err := ioutil.ReadFile(stale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, stderr, code := runMain(t, srv, "", "logout", "-all", "-i", stale)
+	if code == 0 {
+		t.Error("stale: expected failure")
+	}
+	if !strings.Contains(stderr, rbxauth.ErrUnauthenticated.Error()) {
+		t.Errorf("stale: expected unauthenticated error, got %q", stderr)
+	}
+	if after, _ := ioutil.ReadFile(stale); string(after) != string(before) {
+		t.Error("stale: file was modified")
+	}
+}
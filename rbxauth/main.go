@@ -1,53 +1,324 @@
 package main
 
 import (
-	"errors"
+	"bytes"
 	"flag"
+	"fmt"
 	"io"
+	"io/ioutil"
+	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
-	"github.com/anaminus/but"
 	"github.com/anaminus/rbxauth"
 )
 
+// commands maps the name of each subcommand to its implementation. Each
+// receives the arguments following the name of the subcommand.
+var commands = map[string]func(args []string){
+	"login":          runLogin,
+	"logout":         runLogout,
+	"import":         runImport,
+	"ticket":         runTicket,
+	"email":          runEmail,
+	"cookies":        runCookies,
+	"username-check": runUsernameCheck,
+	"accounts":       runAccounts,
+	"reset":          runReset,
+}
+
+// Exit codes of the program, which indicate why the program failed, so that
+// scripts can respond accordingly. The name of the category of a failure other
+// than exitError is also printed to stderr, as "category: name", after the
+// error.
+const (
+	exitError       = 1 // Any failure not covered below.
+	exitCredentials = 2 // The password, code, PIN, or answer was incorrect.
+	exitCaptcha     = 3 // The login requires a captcha to be solved.
+	exitTwoStep     = 4 // The login requires a verification code, but input ended.
+	exitThrottled   = 5 // Too many attempts were made, or the account is locked.
+	exitNetwork     = 6 // The API could not be reached, or did not respond in time.
+	exitUsage       = 7 // The program was used incorrectly.
+)
+
 func main() {
-	var input string
-	var output string
-	// var passwd string
-	var cred rbxauth.Cred
-	flag.StringVar(&input, "i", "", "Input stream as string. '\\n' becomes newline. Use stdin if empty.")
-	flag.StringVar(&output, "o", "", "Path to output file. Write to stdout if empty.")
-	flag.StringVar(&cred.Type, "t", "", "Credential type. Prompt if empty.")
-	flag.StringVar(&cred.Ident, "u", "", "Credential identifier. Prompt if empty.")
-	// flag.StringVar(&passwd, "p", "", "Password. Prompt if empty.")
-	flag.Parse()
-
-	var stream *rbxauth.Stream
-	if input == "" {
-		stream = rbxauth.StandardStream()
-	} else {
-		input = strings.ReplaceAll(input, "\\n", "\n")
-		stream = &rbxauth.Stream{
-			Reader: strings.NewReader(input),
-			Writer: os.Stderr,
-		}
-	}
-
-	cred, cookies, err := stream.PromptCred(cred)
-	if errResp := (rbxauth.ErrorResponse{}); errors.As(err, &errResp) {
-		but.IfFatal(errResp)
-	}
-	but.IfFatal(err)
-
-	var w io.Writer
-	if output == "" {
-		w = os.Stdout
-	} else {
-		f, err := os.Create(output)
-		but.IfFatal(err)
-		defer f.Close()
-		w = f
-	}
-	but.IfFatal(rbxauth.WriteCookies(w, cookies))
+	abort.listen()
+	args := os.Args[1:]
+	name := "login"
+	if len(args) > 0 {
+		if _, ok := commands[args[0]]; ok {
+			name = args[0]
+			args = args[1:]
+		}
+	}
+	commands[name](args)
+}
+
+// configFlags defines flags on fs that configure a rbxauth.Config. The
+// returned function returns the Config after the flags have been parsed.
+func configFlags(fs *flag.FlagSet) func() rbxauth.Config {
+	var tokenCache string
+	var verbose bool
+	var dump bool
+	var userAgent string
+	var proxy string
+	var prime bool
+	var requestTimeout time.Duration
+	var waitOnThrottle bool
+	fs.StringVar(&tokenCache, "token-cache", "", "Path to file used to persist the CSRF token between runs.")
+	fs.DurationVar(&requestTimeout, "request-timeout", 0, "Fail a request to the API that takes longer than the given duration. Defaults to 30s; negative disables.")
+	fs.BoolVar(&prime, "prime", false, "Obtain a CSRF token before logging in, if none is cached.")
+	fs.BoolVar(&waitOnThrottle, "wait-on-throttle", false, "If the account is locked for too many attempts, wait until the lock lifts, then log in again once.")
+	fs.BoolVar(&verbose, "v", false, "Log each request made to the API to stderr.")
+	fs.StringVar(&userAgent, "user-agent", "", "User-Agent header sent with each request.")
+	fs.StringVar(&proxy, "proxy", "", "URL of a proxy (http, https, socks5) through which requests are made. If empty, $HTTPS_PROXY is honored.")
+	fs.BoolVar(&dump, "dump", false, "Write the body of each request made to the API to stderr, with secrets redacted.")
+	applyEndpoints := endpointFlags(fs)
+	return func() (cfg rbxauth.Config) {
+		cfg.TokenCache = &rbxauth.TokenCache{}
+		if tokenCache != "" {
+			cfg.TokenStore = rbxauth.FileTokenStore(tokenCache)
+		}
+		cfg.AutoPrime = prime
+		cfg.Timeout = requestTimeout
+		cfg.WaitOnThrottle = waitOnThrottle
+		if verbose {
+			cfg.Log = func(event rbxauth.LogEvent) {
+				fmt.Fprintln(os.Stderr, event)
+			}
+		}
+		cfg.UserAgent = userAgent
+		cfg.ProxyURL = proxy
+		if dump {
+			cfg.DumpRequests = os.Stderr
+		}
+		ifFatal(applyEndpoints(&cfg))
+		return cfg
+	}
+}
+
+// cookieWriter returns a function that writes cookies in the given format.
+func cookieWriter(format string) func(io.Writer, []*http.Cookie) error {
+	switch format {
+	case "headers":
+		return rbxauth.WriteCookies
+	case "json":
+		return rbxauth.WriteCookiesJSON
+	}
+	ifFatal(usageErrorf("unknown format %q", format))
+	return nil
+}
+
+// cookieReader returns a function that reads cookies in the given format.
+func cookieReader(format string) func(io.Reader) ([]*http.Cookie, error) {
+	switch format {
+	case "auto":
+		return rbxauth.ReadCookiesAuto
+	case "headers":
+		return rbxauth.ReadCookies
+	case "json":
+		return rbxauth.ReadCookiesJSON
+	}
+	ifFatal(usageErrorf("unknown format %q", format))
+	return nil
+}
+
+// tokenOutput writes the session token alongside the cookie output, as
+// requested by the flags defined by tokenFlags.
+type tokenOutput struct {
+	format string
+	output string
+	write  func(write func(w io.Writer) error) error
+}
+
+// tokenFlags defines flags on fs that emit the session token alongside the
+// cookie output.
+func tokenFlags(fs *flag.FlagSet) *tokenOutput {
+	var t tokenOutput
+	fs.StringVar(&t.format, "emit-token", "", "Also write the session token in the given format (raw, env, dotenv).")
+	fs.StringVar(&t.output, "token-o", stdioPath, "Path to token output file, or - for stdout.")
+	return &t
+}
+
+// open validates the output of the token, if requested, as with openOutput.
+func (t *tokenOutput) open() (err error) {
+	if t.format == "" {
+		return nil
+	}
+	t.write, err = openOutput("-token-o", t.output)
+	return err
+}
+
+// emit writes the token within cookies, if requested. The output must have
+// been opened with open.
+func (t *tokenOutput) emit(cookies []*http.Cookie) error {
+	if t.write == nil {
+		return nil
+	}
+	return t.write(func(w io.Writer) error {
+		return rbxauth.WriteSecurityToken(w, cookies, t.format)
+	})
+}
+
+// rewriteFlags defines the -rewrite-domain flag on fs. The returned function
+// rewrites the domains of cookies as requested, after the flags have been
+// parsed.
+func rewriteFlags(fs *flag.FlagSet) func(cookies []*http.Cookie) []*http.Cookie {
+	var rewrite string
+	fs.StringVar(&rewrite, "rewrite-domain", "", "Rewrite the domain of exported cookies, given as old=new (e.g. roblox.com=sitetest1.robloxlabs.com). Subdomains of old are also rewritten.")
+	return func(cookies []*http.Cookie) []*http.Cookie {
+		if rewrite == "" {
+			return cookies
+		}
+		i := strings.Index(rewrite, "=")
+		if i < 0 {
+			ifFatal(usageErrorf("-rewrite-domain %q must be of the form old=new", rewrite))
+		}
+		return rbxauth.RewriteCookieDomain(cookies, rewrite[:i], rewrite[i+1:])
+	}
+}
+
+// stdioPath is the path that names stdin or stdout in a flag that takes the
+// path of a file.
+const stdioPath = "-"
+
+// stdinUse records how stdin is used, so that conflicting uses are rejected.
+var stdinUse struct {
+	// data is the flag whose file is read from stdin.
+	data string
+	// prompt is what is answered through stdin.
+	prompt string
+}
+
+// useStdin registers that stdin is used by user, to answer prompts if prompt
+// is true, and to read data otherwise. Returns an error if the use conflicts
+// with an earlier use: data can be read from stdin only once, and not while
+// stdin also answers prompts, which are free to share stdin with each other.
+func useStdin(user string, prompt bool) error {
+	switch {
+	case stdinUse.data != "":
+		return usageErrorf("%s cannot use stdin, which is read by %s", user, stdinUse.data)
+	case !prompt && stdinUse.prompt != "":
+		return usageErrorf("%s cannot read from stdin, which answers %s; use -i to answer prompts otherwise", user, stdinUse.prompt)
+	case prompt:
+		if stdinUse.prompt == "" {
+			stdinUse.prompt = user
+		}
+	default:
+		stdinUse.data = user
+	}
+	return nil
+}
+
+// stdoutUse is the user that writes to stdout, so that other outputs are not
+// interleaved with it.
+var stdoutUse string
+
+// useStdout registers that stdout is written by user. Returns an error if
+// stdout is already written by another user.
+func useStdout(user string) error {
+	if stdoutUse != "" {
+		return usageErrorf("%s cannot write to stdout, which is written by %s; give one of them a path", user, stdoutUse)
+	}
+	stdoutUse = user
+	return nil
+}
+
+// checkPath returns an error if path, given to the flag name, is empty.
+func checkPath(name, path string) error {
+	if path == "" {
+		return usageErrorf("%s requires a path, or %s for the standard stream", name, stdioPath)
+	}
+	return nil
+}
+
+// openInput opens the file at path, given to the flag name, for reading. If
+// path is "-", then stdin is returned, which is registered as read by the
+// flag.
+func openInput(name, path string) (io.ReadCloser, error) {
+	if err := checkPath(name, path); err != nil {
+		return nil, err
+	}
+	if path == stdioPath {
+		if err := useStdin(name, false); err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(os.Stdin), nil
+	}
+	return os.Open(path)
+}
+
+// openOutput validates path, given to the flag name, and returns a function
+// that writes to it with write. If path is "-", then write receives stdout,
+// which is registered as written by the flag. Otherwise, the file at path is
+// replaced atomically, as with writeFileAtomic. The path is validated before
+// anything is written, so that a mistake is reported before a login is made.
+func openOutput(name, path string) (func(write func(w io.Writer) error) error, error) {
+	if err := checkPath(name, path); err != nil {
+		return nil, err
+	}
+	if path == stdioPath {
+		if err := useStdout(name); err != nil {
+			return nil, err
+		}
+		return func(write func(w io.Writer) error) error {
+			return write(os.Stdout)
+		}, nil
+	}
+	return func(write func(w io.Writer) error) error {
+		return writeFileAtomic(path, write)
+	}, nil
+}
+
+// readCookieFile reads cookies with read from the file at path, given to the
+// flag name, as opened by openInput.
+func readCookieFile(name, path string, read func(io.Reader) ([]*http.Cookie, error)) ([]*http.Cookie, error) {
+	f, err := openInput(name, path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return read(f)
+}
+
+// isSet returns whether the flag name was set by the arguments parsed by fs.
+func isSet(fs *flag.FlagSet, name string) (set bool) {
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// sniffJSON returns whether the file at path appears to contain JSON.
+func sniffJSON(path string) bool {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	b = bytes.TrimSpace(b)
+	return len(b) > 0 && b[0] == '['
+}
+
+// writeFileAtomic writes to a temporary file with write, then renames the file
+// to path. The temporary file is removed if the program is interrupted.
+func writeFileAtomic(path string, write func(w io.Writer) error) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	abort.track(f.Name())
+	defer abort.untrack(f.Name())
+	defer os.Remove(f.Name())
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return abort.commit(f.Name(), path)
 }
@@ -0,0 +1,433 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/anaminus/rbxauth"
+	"github.com/anaminus/rbxauth/rbxauthtest"
+)
+
+// mainTestEnv names the environment variable that makes the test binary run
+// main with its arguments instead of the tests.
+const mainTestEnv = "RBXAUTH_MAIN_TEST"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(mainTestEnv) != "" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runMain runs the program in a separate process with args, writing stdin to
+// its standard input. Each endpoint points to srv, through both the
+// environment variable of the endpoint and RBXAUTH_HOST. Returns the output
+// of the program, and its exit code.
+func runMain(t *testing.T, srv *rbxauthtest.Server, stdin string, args ...string) (stdout, stderr string, code int) {
+	t.Helper()
+	env := []string{"RBXAUTH_ALLOW_INSECURE=1", "RBXAUTH_HOST=" + srv.URL}
+	cfg := srv.Config()
+	for _, e := range endpoints {
+		if value := *e.field(&cfg); value != "" {
+			env = append(env, e.envName()+"="+value)
+		}
+	}
+	return runEnv(t, env, stdin, args...)
+}
+
+// runEnv is like runMain, but with the given environment variables instead
+// of those pointing to a server.
+func runEnv(t *testing.T, env []string, stdin string, args ...string) (stdout, stderr string, code int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(append(os.Environ(), mainTestEnv+"=1"), env...)
+	cmd.Stdin = strings.NewReader(stdin)
+	var out, errOut strings.Builder
+	cmd.Stdout, cmd.Stderr = &out, &errOut
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		code = exitErr.ExitCode()
+	case err != nil:
+		t.Fatalf("run %q: %v", args, err)
+	}
+	return out.String(), errOut.String(), code
+}
+
+// writeSession logs into the account named alice with the password "pass",
+// writing the session cookies to a file, whose path is returned with the
+// cookies.
+func writeSession(t *testing.T, srv *rbxauthtest.Server) (string, []*http.Cookie) {
+	t.Helper()
+	cookies, _, err := srv.Config().Login("alice", []byte("pass"))
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "cookies")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := rbxauth.WriteCookies(f, cookies); err != nil {
+		t.Fatal(err)
+	}
+	return path, cookies
+}
+
+// resetStdio forgets the registered uses of stdin and stdout.
+func resetStdio() {
+	stdinUse.data, stdinUse.prompt = "", ""
+	stdoutUse = ""
+}
+
+func TestUseStdin(t *testing.T) {
+	type use struct {
+		user   string
+		prompt bool
+		// err is a substring of the expected error, or empty for no error.
+		err string
+	}
+	for _, test := range []struct {
+		name string
+		uses []use
+	}{
+		{"data", []use{{"-i", false, ""}}},
+		{"prompts share", []use{{"prompts", true, ""}, {"the passphrase prompt", true, ""}, {"prompts", true, ""}}},
+		{"data twice", []use{{"-i", false, ""}, {"-password-file", false, "read by -i"}}},
+		{"same data twice", []use{{"-i", false, ""}, {"-i", false, "read by -i"}}},
+		{"data then prompt", []use{{"-i", false, ""}, {"prompts", true, "read by -i"}}},
+		{"prompt then data", []use{{"prompts", true, ""}, {"-password-file", false, "answers prompts"}}},
+		{"first prompt named", []use{{"the passphrase prompt", true, ""}, {"prompts", true, ""}, {"-i", false, "answers the passphrase prompt"}}},
+	} {
+		resetStdio()
+		for i, u := range test.uses {
+			err := useStdin(u.user, u.prompt)
+			switch {
+			case u.err == "" && err != nil:
+				t.Errorf("%s: use %d: unexpected error: %v", test.name, i, err)
+			case u.err != "" && err == nil:
+				t.Errorf("%s: use %d: expected error", test.name, i)
+			case u.err != "" && (!errors.Is(err, errUsage) || !strings.Contains(err.Error(), u.err)):
+				t.Errorf("%s: use %d: expected usage error containing %q, got %v", test.name, i, u.err, err)
+			}
+		}
+	}
+	resetStdio()
+}
+
+func TestOpenInput(t *testing.T) {
+	defer resetStdio()
+	resetStdio()
+	if _, err := openInput("-i", ""); !errors.Is(err, errUsage) {
+		t.Errorf("empty path: expected usage error, got %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "cookies")
+	if err := ioutil.WriteFile(path, []byte("content"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := openInput("-i", path)
+	if err != nil {
+		t.Fatalf("file: %v", err)
+	}
+	b, err := ioutil.ReadAll(f)
+	f.Close()
+	if err != nil || string(b) != "content" {
+		t.Errorf("file: expected content, got %q, %v", b, err)
+	}
+	if _, err := openInput("-i", path+".missing"); !os.IsNotExist(err) {
+		t.Errorf("missing file: expected not exist, got %v", err)
+	}
+	if stdinUse.data != "" {
+		t.Errorf("files registered stdin as read by %s", stdinUse.data)
+	}
+
+	// stdin is read once.
+	if _, err := openInput("-i", stdioPath); err != nil {
+		t.Fatalf("stdin: %v", err)
+	}
+	if _, err := openInput("-remember", stdioPath); !errors.Is(err, errUsage) {
+		t.Errorf("stdin twice: expected usage error, got %v", err)
+	}
+	// Files are unaffected.
+	if f, err := openInput("-remember", path); err != nil {
+		t.Errorf("file after stdin: %v", err)
+	} else {
+		f.Close()
+	}
+
+	// stdin is not read while it answers prompts.
+	resetStdio()
+	if err := useStdin("prompts", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openInput("-i", stdioPath); !errors.Is(err, errUsage) {
+		t.Errorf("stdin with prompts: expected usage error, got %v", err)
+	}
+}
+
+func TestOpenOutput(t *testing.T) {
+	defer resetStdio()
+	resetStdio()
+	if _, err := openOutput("-o", ""); !errors.Is(err, errUsage) {
+		t.Errorf("empty path: expected usage error, got %v", err)
+	}
+
+	// The file is written only when write is called.
+	path := filepath.Join(t.TempDir(), "out")
+	write, err := openOutput("-o", path)
+	if err != nil {
+		t.Fatalf("file: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("file exists before write: %v", err)
+	}
+	if err := write(func(w io.Writer) error {
+		_, err := io.WriteString(w, "content")
+		return err
+	}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if b, err := ioutil.ReadFile(path); err != nil || string(b) != "content" {
+		t.Errorf("file: expected content, got %q, %v", b, err)
+	}
+	if stdoutUse != "" {
+		t.Errorf("files registered stdout as written by %s", stdoutUse)
+	}
+
+	// stdout is written once.
+	write, err = openOutput("-o", stdioPath)
+	if err != nil {
+		t.Fatalf("stdout: %v", err)
+	}
+	if err := write(func(w io.Writer) error {
+		if w != os.Stdout {
+			t.Errorf("stdout: writer is %T", w)
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("stdout: %v", err)
+	}
+	_, err = openOutput("-token-o", stdioPath)
+	if !errors.Is(err, errUsage) || !strings.Contains(err.Error(), "written by -o") {
+		t.Errorf("stdout twice: expected usage error naming -o, got %v", err)
+	}
+	if _, err := openOutput("-token-o", path); err != nil {
+		t.Errorf("file after stdout: %v", err)
+	}
+}
+
+// checkOnlyFile fails if dir contains files other than name, such as a
+// temporary file left behind by writeFileAtomic.
+func checkOnlyFile(t *testing.T, dir, name string) {
+	t.Helper()
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		if f.Name() != name {
+			t.Errorf("unexpected file %s", f.Name())
+		}
+	}
+	abort.mu.Lock()
+	defer abort.mu.Unlock()
+	if len(abort.temps) != 0 {
+		t.Errorf("temporary files are still tracked: %v", abort.temps)
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cookies")
+	if err := ioutil.WriteFile(path, []byte("original"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	checkOriginal := func(name string) {
+		t.Helper()
+		if b, err := ioutil.ReadFile(path); err != nil || string(b) != "original" {
+			t.Errorf("%s: expected original file, got %q, %v", name, b, err)
+		}
+		checkOnlyFile(t, dir, "cookies")
+	}
+
+	// A failed write leaves the original file intact.
+	errWrite := errors.New("write failed")
+	err := writeFileAtomic(path, func(w io.Writer) error {
+		io.WriteString(w, "partial")
+		return errWrite
+	})
+	if !errors.Is(err, errWrite) {
+		t.Errorf("failed write: expected write error, got %v", err)
+	}
+	checkOriginal("failed write")
+
+	// A failed rename leaves the target intact.
+	target := filepath.Join(dir, "cookies.d")
+	if err := os.Mkdir(target, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(target, "file"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	err = writeFileAtomic(target, func(w io.Writer) error {
+		_, err := io.WriteString(w, "content")
+		return err
+	})
+	if err == nil {
+		t.Error("failed rename: expected error")
+	}
+	if fi, err := os.Stat(target); err != nil || !fi.IsDir() {
+		t.Errorf("failed rename: expected directory, got %v", err)
+	}
+	os.RemoveAll(target)
+	checkOriginal("failed rename")
+
+	// The temporary file cannot be created.
+	if err := writeFileAtomic(filepath.Join(dir, "missing", "cookies"), func(w io.Writer) error {
+		t.Error("missing directory: write was called")
+		return nil
+	}); !os.IsNotExist(err) {
+		t.Errorf("missing directory: expected not exist, got %v", err)
+	}
+	checkOriginal("missing directory")
+
+	if err := writeFileAtomic(path, func(w io.Writer) error {
+		_, err := io.WriteString(w, "content")
+		return err
+	}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if b, err := ioutil.ReadFile(path); err != nil || string(b) != "content" {
+		t.Errorf("write: expected content, got %q, %v", b, err)
+	}
+	checkOnlyFile(t, dir, "cookies")
+}
+
+func TestWriteFileAtomicParallel(t *testing.T) {
+	const writers = 8
+	const size = 64 << 10
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cookies")
+	if err := ioutil.WriteFile(path, bytes.Repeat([]byte{'-'}, size), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each writer writes its own byte in small chunks, so that writes
+	// interleave.
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(c byte) {
+			defer wg.Done()
+			chunk := bytes.Repeat([]byte{c}, 1<<10)
+			err := writeFileAtomic(path, func(w io.Writer) error {
+				for n := 0; n < size; n += len(chunk) {
+					if _, err := w.Write(chunk); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				t.Errorf("writer %c: %v", c, err)
+			}
+		}('a' + byte(i))
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	// checkWhole fails if the file is not entirely the content of a single
+	// writer.
+	checkWhole := func() bool {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Errorf("read: %v", err)
+			return false
+		}
+		if len(b) != size || len(bytes.Trim(b, string(b[:1]))) != 0 {
+			t.Errorf("read: file is not whole: %d bytes", len(b))
+			return false
+		}
+		return true
+	}
+	for reading := true; reading; {
+		select {
+		case <-done:
+			reading = false
+		default:
+			if !checkWhole() {
+				<-done
+				reading = false
+			}
+		}
+	}
+	if checkWhole() {
+		if b, _ := ioutil.ReadFile(path); b[0] == '-' {
+			t.Error("no write replaced the file")
+		}
+	}
+	checkOnlyFile(t, dir, "cookies")
+}
+
+func TestTokenOutput(t *testing.T) {
+	defer resetStdio()
+	path := filepath.Join(t.TempDir(), "token")
+	for _, test := range []struct {
+		name string
+		args []string
+		err  bool
+		// file is the expected content of path, if written.
+		file string
+	}{
+		{"not emitted", nil, false, ""},
+		{"not emitted to stdout", []string{"-token-o", stdioPath}, false, ""},
+		{"stdout", []string{"-emit-token", "raw"}, true, ""},
+		{"file", []string{"-emit-token", "raw", "-token-o", path}, false, "token\n"},
+	} {
+		resetStdio()
+		os.Remove(path)
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		token := tokenFlags(fs)
+		if err := fs.Parse(test.args); err != nil {
+			t.Fatal(err)
+		}
+		// The cookies are written to stdout.
+		if _, err := openOutput("-o", stdioPath); err != nil {
+			t.Fatal(err)
+		}
+		err := token.open()
+		if test.err {
+			if !errors.Is(err, errUsage) {
+				t.Errorf("%s: expected usage error, got %v", test.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if err := token.emit(rbxauth.FromSecurityToken("token")); err != nil {
+			t.Errorf("%s: emit: %v", test.name, err)
+		}
+		if b, _ := ioutil.ReadFile(path); string(b) != test.file {
+			t.Errorf("%s: expected file %q, got %q", test.name, test.file, b)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+
+	"github.com/anaminus/rbxauth"
+)
+
+// loginReport is the result of the login subcommand, written to stdout as JSON
+// when the -json flag is set.
+type loginReport struct {
+	Success   bool            `json:"success"`
+	CredType  string          `json:"credType,omitempty"`
+	Ident     string          `json:"ident,omitempty"`
+	UserID    int64           `json:"userId,omitempty"`
+	UserName  string          `json:"userName,omitempty"`
+	TwoStep   bool            `json:"twoStep"`
+	MediaType string          `json:"mediaType,omitempty"`
+	Output    string          `json:"output,omitempty"`
+	Cookies   json.RawMessage `json:"cookies,omitempty"`
+	Error     *reportError    `json:"error,omitempty"`
+
+	// PendingActions lists the actions that the user must take before the
+	// session can be used fully.
+	PendingActions []rbxauth.PendingAction `json:"pendingActions,omitempty"`
+	// RememberDevice is whether the device was remembered after two-step
+	// verification, and Resends is the number of times the code was resent.
+	RememberDevice bool `json:"rememberDevice"`
+	Resends        int  `json:"resends"`
+}
+
+// reportError describes the failure of a loginReport.
+type reportError struct {
+	Message string `json:"message"`
+	// Status is the HTTP status of the failed request, if any.
+	Status int `json:"status,omitempty"`
+	// Codes are the error codes reported by the API, if any.
+	Codes []int `json:"codes,omitempty"`
+	// Kind is the classification of the error, as returned by
+	// rbxauth.Classify.
+	Kind string `json:"kind,omitempty"`
+}
+
+// setCookies includes cookies in the report.
+func (r *loginReport) setCookies(cookies []*http.Cookie) error {
+	var buf bytes.Buffer
+	if err := rbxauth.WriteCookiesJSON(&buf, cookies); err != nil {
+		return err
+	}
+	r.Cookies = buf.Bytes()
+	return nil
+}
+
+// write writes the report to stdout.
+func (r *loginReport) write() {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "\t")
+	enc.Encode(r)
+}
+
+// fail writes the report with err to stdout, then exits with the exit code of
+// err.
+func (r *loginReport) fail(err error) {
+	r.Success = false
+	r.Error = &reportError{
+		Message: err.Error(),
+		Codes:   rbxauth.ErrorCodes(err),
+	}
+	r.Error.Status, _ = rbxauth.HTTPStatus(err)
+	if kind := rbxauth.Classify(err); kind != nil {
+		r.Error.Kind = kind.Error()
+	}
+	r.write()
+	exit(err)
+}
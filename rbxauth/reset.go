@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/anaminus/rbxauth"
+)
+
+// runReset implements the reset subcommand, which resets the password of an
+// account and writes the cookies of the resulting session.
+func runReset(args []string) {
+	var output string
+	var format string
+	var passwordEnv string
+	var passwordFile string
+	var code string
+	var noFallback bool
+	var timeout time.Duration
+	var cred rbxauth.Cred
+	fs := flag.NewFlagSet("reset", flag.ContinueOnError)
+	fs.StringVar(&output, "o", stdioPath, "Path to output file, or - for stdout.")
+	fs.StringVar(&cred.Type, "t", "", "Credential type (Email, PhoneNumber, or auto to detect from -u). Prompt if empty.")
+	fs.StringVar(&cred.Ident, "u", "", "Email address or phone number of the account. Prompt if empty.")
+	fs.StringVar(&passwordEnv, "password-env", "", "Name of environment variable containing the new password.")
+	fs.StringVar(&passwordFile, "password-file", "", "Path to file containing the new password, or - for stdin.")
+	fs.StringVar(&format, "format", "headers", "Format of cookie output (headers, json).")
+	fs.StringVar(&code, "code", "", "Reset code to submit instead of prompting. If incorrect, the code is prompted.")
+	fs.BoolVar(&noFallback, "no-fallback", false, "Fail instead of prompting if the code from -code is incorrect.")
+	fs.DurationVar(&timeout, "timeout", 0, "Fail if a prompt is not answered within the given duration. No timeout if zero.")
+	in := streamFlags(fs)
+	crypt := cryptFlags(fs, true)
+	st := storeFlags(fs)
+	rewrite := rewriteFlags(fs)
+	config := configFlags(fs)
+	parseFlags(fs, args)
+
+	if strings.EqualFold(cred.Type, rbxauth.Auto) {
+		cred.Type = rbxauth.Auto
+	}
+	if passwordEnv != "" && passwordFile != "" {
+		ifFatal(usageErrorf("only one of -password-env and -password-file may be set"))
+	}
+
+	cfg := config()
+	cs, err := st.open()
+	ifFatal(err)
+	if cs != nil && isSet(fs, "o") {
+		ifFatal(usageErrorf("-o cannot be set with -store"))
+	}
+	writeOutput, err := openOutput("-o", output)
+	ifFatal(err)
+
+	stream, err := in.stream(fs, cfg)
+	ifFatal(err)
+	stream.Context = abort.ctx
+	stream.Code = code
+	stream.NoFallback = noFallback
+	stream.Timeout = timeout
+	stream.PasswordEnv = passwordEnv
+	if passwordFile != "" {
+		ifFatal(setPasswordFile(stream, "-password-file", passwordFile))
+	}
+
+	cred, cookies, err := stream.PromptPasswordReset(cred)
+	if errors.Is(err, rbxauth.ErrPromptEOF) {
+		ifFatal(errors.New("input ended before the password was reset"))
+	}
+	ifFatal(err)
+	fmt.Fprintln(os.Stderr, "Password reset")
+
+	if cs != nil {
+		account := st.account
+		if account == "" {
+			account = cred.Ident
+		}
+		ifFatal(cs.SaveSession(account, cookies))
+		fmt.Fprintf(os.Stderr, "Stored session as %q\n", account)
+		return
+	}
+	write := crypt.writer(format, crypt.encrypt)
+	cookies = rewrite(cookies)
+	ifFatal(writeOutput(func(w io.Writer) error {
+		return write(w, cookies)
+	}))
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/anaminus/rbxauth"
+	"github.com/anaminus/rbxauth/rbxauthtest"
+)
+
+func TestReset(t *testing.T) {
+	srv := rbxauthtest.NewServer()
+	defer srv.Close()
+	srv.AddAccount(rbxauthtest.Account{
+		ID: 1, Name: "alice", Password: "old",
+		Email: "alice@example.com", ResetCode: "654321",
+	})
+	t.Setenv("P", "pass-new")
+	args := []string{"reset", "-t", "Email", "-u", "alice@example.com", "-password-env", "P"}
+
+	// An incorrect code fails without fallback.
+	stdout, stderr, code := runMain(t, srv, "", append(append([]string{}, args...), "-code", "000000", "-no-fallback")...)
+	if code != exitCredentials || stdout != "" {
+		t.Errorf("invalid code: expected exit %d and no output, got %d, %q: %s", exitCredentials, code, stdout, stderr)
+	}
+
+	stdout, stderr, code = runMain(t, srv, "", append(append([]string{}, args...), "-code", "654321")...)
+	if code != 0 {
+		t.Fatalf("reset: exit %d: %s", code, stderr)
+	}
+	cookies, err := rbxauth.ReadCookies(strings.NewReader(stdout))
+	if err != nil {
+		t.Fatalf("reset: read cookies: %v", err)
+	}
+	if user, err := srv.Config().Authenticated(cookies); err != nil || user.ID != 1 {
+		t.Errorf("reset: expected session of alice, got %+v, %v", user, err)
+	}
+	if _, _, err := srv.Config().Login("alice", []byte("pass-new")); err != nil {
+		t.Errorf("reset: new password: %v", err)
+	}
+
+	_, stderr, code = runMain(t, srv, "", "reset", "-t", "Email", "-u", "bob@example.com", "-password-env", "P")
+	if code != exitError || stderr == "" {
+		t.Errorf("unknown account: expected exit %d with message, got %d: %q", exitError, code, stderr)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/anaminus/rbxauth"
+	"github.com/anaminus/rbxauth/rbxauthkeyring"
+)
+
+// store configures the storage of sessions in a rbxauth.CredentialStore
+// instead of cookie files.
+type store struct {
+	kind    string
+	account string
+}
+
+// storeFlags defines flags on fs that configure the storage of sessions.
+func storeFlags(fs *flag.FlagSet) *store {
+	var s store
+	fs.StringVar(&s.kind, "store", "", "Store sessions in the given store (file, keyring) instead of cookie files.")
+	fs.StringVar(&s.account, "account", "", "Name of the account under which the session is stored with -store.")
+	return &s
+}
+
+// open returns the selected store, or nil if -store is not set.
+func (s *store) open() (rbxauth.CredentialStore, error) {
+	switch s.kind {
+	case "":
+		return nil, nil
+	case "file":
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return nil, err
+		}
+		return rbxauth.FileCredentialStore(filepath.Join(dir, "rbxauth", "sessions.json")), nil
+	case "keyring":
+		return rbxauthkeyring.Store{}, nil
+	}
+	return nil, usageErrorf("unknown -store value %q", s.kind)
+}
+
+// loadSession returns the session stored in cs for account.
+func loadSession(cs rbxauth.CredentialStore, account string) ([]*http.Cookie, error) {
+	if account == "" {
+		return nil, usageErrorf("-account must be set with -store")
+	}
+	return cs.LoadSession(account)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/anaminus/rbxauth"
+	"golang.org/x/term"
+)
+
+// terminal opens the terminal through which prompts are answered in -tty
+// mode.
+type terminal interface {
+	open() (in io.Reader, out io.Writer, err error)
+}
+
+// controllingTerminal implements terminal with the controlling terminal of
+// the process.
+type controllingTerminal struct{}
+
+func (controllingTerminal) open() (io.Reader, io.Writer, error) {
+	in, out, err := openTTY()
+	if err != nil {
+		return nil, nil, err
+	}
+	abort.setTerminal(in, out)
+	return in, out, nil
+}
+
+// streamInput configures the input of a rbxauth.Stream.
+type streamInput struct {
+	// input is the value of the -i flag.
+	input string
+	// tty is whether prompts are answered through the terminal.
+	tty bool
+	// explicit is whether -tty was set explicitly.
+	explicit bool
+	// term opens the terminal.
+	term terminal
+	// messages is the path to the catalog of prompts, from the -messages
+	// flag.
+	messages string
+}
+
+// streamFlags defines flags on fs that configure the input of a Stream.
+// Without -i, prompts are answered through stdin and written to stderr. With
+// -tty, which is the default when both stdin and stdout are pipes, prompts are
+// answered through the terminal instead, while lines piped to stdin answer the
+// first prompts. -messages replaces the prompts with a translated catalog.
+func streamFlags(fs *flag.FlagSet) *streamInput {
+	s := streamInput{term: controllingTerminal{}}
+	fs.StringVar(&s.input, "i", "", "Input stream as string. '\\n' becomes newline. Use stdin if empty or -.")
+	fs.StringVar(&s.messages, "messages", "", "Path to a JSON file containing a catalog of translated prompts.")
+	fs.BoolVar(&s.tty, "tty", !isTerminal(os.Stdin) && !isTerminal(os.Stdout), "Answer prompts through the terminal, leaving stdin for scripted answers and stdout for output. Default when stdin and stdout are pipes.")
+	return &s
+}
+
+// isTerminal returns whether f is a terminal.
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// stream returns a Stream using cfg, configured by the flags, which must have
+// been parsed by fs.
+func (s *streamInput) stream(fs *flag.FlagSet, cfg rbxauth.Config) (*rbxauth.Stream, error) {
+	stream, err := s.open(fs, cfg)
+	if err != nil {
+		return nil, err
+	}
+	stream.Terminal = terminalOptions
+	if s.messages == "" {
+		return stream, nil
+	}
+	f, err := openInput("-messages", s.messages)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if stream.Messages, err = rbxauth.LoadMessages(f); err != nil {
+		return nil, fmt.Errorf("%s: %w", s.messages, err)
+	}
+	return stream, nil
+}
+
+// open returns a Stream using cfg, with input configured by the flags.
+func (s *streamInput) open(fs *flag.FlagSet, cfg rbxauth.Config) (*rbxauth.Stream, error) {
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "tty" {
+			s.explicit = true
+		}
+	})
+	if s.input == stdioPath {
+		s.input = ""
+	}
+	if s.input != "" {
+		if s.tty && s.explicit {
+			return nil, usageErrorf("-i cannot be set with -tty")
+		}
+		return &rbxauth.Stream{
+			Config: cfg,
+			Reader: strings.NewReader(strings.ReplaceAll(s.input, "\\n", "\n")),
+			Writer: os.Stderr,
+		}, nil
+	}
+	if err := useStdin("prompts", true); err != nil {
+		return nil, err
+	}
+	stream := rbxauth.StandardStream()
+	stream.Config = cfg
+	if !s.tty {
+		return stream, nil
+	}
+	in, out, err := s.term.open()
+	if err != nil {
+		if !s.explicit {
+			// Not requested, so behave as though the mode were not
+			// available.
+			s.tty = false
+			return stream, nil
+		}
+		return nil, fmt.Errorf("-tty: no controlling terminal: %w", err)
+	}
+	stream.Input = os.Stdin
+	stream.Reader = in
+	stream.Writer = out
+	return stream, nil
+}
+
+// setPasswordFile sets the password of stream to be read from the file at
+// path, given to the flag name. If path is "-", then the password is read from
+// stdin.
+func setPasswordFile(stream *rbxauth.Stream, name, path string) error {
+	if path != stdioPath {
+		stream.PasswordFile = path
+		return nil
+	}
+	if err := useStdin(name, false); err != nil {
+		return err
+	}
+	stream.PasswordReader = os.Stdin
+	return nil
+}
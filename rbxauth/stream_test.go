@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/anaminus/rbxauth"
+	"github.com/anaminus/rbxauth/rbxauthtest"
+)
+
+func TestSetPasswordFile(t *testing.T) {
+	defer resetStdio()
+	resetStdio()
+
+	var stream rbxauth.Stream
+	if err := setPasswordFile(&stream, "-password-file", "password.txt"); err != nil {
+		t.Fatalf("file: %v", err)
+	}
+	if stream.PasswordFile != "password.txt" || stream.PasswordReader != nil {
+		t.Errorf("file: unexpected sources %q, %v", stream.PasswordFile, stream.PasswordReader)
+	}
+	if stdinUse.data != "" {
+		t.Errorf("file registered stdin as read by %s", stdinUse.data)
+	}
+
+	stream = rbxauth.Stream{}
+	if err := setPasswordFile(&stream, "-password-file", stdioPath); err != nil {
+		t.Fatalf("stdin: %v", err)
+	}
+	if stream.PasswordFile != "" || stream.PasswordReader != os.Stdin {
+		t.Errorf("stdin: unexpected sources %q, %v", stream.PasswordFile, stream.PasswordReader)
+	}
+	if stdinUse.data != "-password-file" {
+		t.Errorf("stdin: registered as read by %q", stdinUse.data)
+	}
+
+	// stdin cannot also answer prompts.
+	resetStdio()
+	if err := useStdin("prompts", true); err != nil {
+		t.Fatal(err)
+	}
+	stream = rbxauth.Stream{}
+	if err := setPasswordFile(&stream, "-password-file", stdioPath); !errors.Is(err, errUsage) {
+		t.Errorf("stdin with prompts: expected usage error, got %v", err)
+	}
+	if stream.PasswordReader != nil {
+		t.Error("stdin with prompts: password reader was set")
+	}
+}
+
+func TestLoginInputEnded(t *testing.T) {
+	srv := rbxauthtest.NewServer()
+	defer srv.Close()
+	srv.AddAccount(rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass", TwoStep: true, Code: "123456"})
+
+	for _, test := range []struct {
+		name  string
+		stdin string
+		args  []string
+		code  int
+		msg   string
+	}{
+		{"stdin", "", nil, exitError, "input ended before login completed"},
+		{"input", "", []string{"-i", "alice"}, exitError, "input from -i ended before login completed"},
+		{"code", "", []string{"-u", "alice", "-i", "pass"}, exitTwoStep, "input from -i ended before login completed"},
+	} {
+		args := append([]string{"login", "-t", "Username"}, test.args...)
+		stdout, stderr, code := runMain(t, srv, test.stdin, args...)
+		if code != test.code {
+			t.Errorf("%s: expected exit %d, got %d: %s", test.name, test.code, code, stderr)
+		}
+		if !strings.Contains(stderr, test.msg) {
+			t.Errorf("%s: expected message %q, got %q", test.name, test.msg, stderr)
+		}
+		if stdout != "" {
+			t.Errorf("%s: expected no output, got %q", test.name, stdout)
+		}
+	}
+}
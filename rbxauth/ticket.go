@@ -0,0 +1,27 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+// runTicket implements the ticket subcommand.
+func runTicket(args []string) {
+	var input string
+	var format string
+	var referer string
+	fs := flag.NewFlagSet("ticket", flag.ContinueOnError)
+	fs.StringVar(&input, "i", stdioPath, "Path to cookie file, or - for stdin.")
+	fs.StringVar(&format, "format", "auto", "Format of cookie input (auto, headers, json).")
+	fs.StringVar(&referer, "referer", "https://www.roblox.com/", "Referer sent when creating the ticket.")
+	crypt := cryptFlags(fs, false)
+	config := configFlags(fs)
+	parseFlags(fs, args)
+
+	cfg := config()
+	cookies, err := readCookieFile("-i", input, crypt.reader(format))
+	ifFatal(err)
+	ticket, err := cfg.CreateAuthTicket(cookies, referer)
+	ifFatal(err)
+	fmt.Println(ticket)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/anaminus/rbxauth"
+)
+
+// runUsernameCheck implements the username-check subcommand.
+func runUsernameCheck(args []string) {
+	var birthday string
+	var suggest bool
+	fs := flag.NewFlagSet("username-check", flag.ContinueOnError)
+	fs.StringVar(&birthday, "birthday", "", "Birthday of the user, as YYYY-MM-DD.")
+	fs.BoolVar(&suggest, "suggest", true, "Print suggested usernames if the username is not valid.")
+	config := configFlags(fs)
+	parseFlags(fs, args)
+	if fs.NArg() != 1 {
+		ifFatal(usageErrorf("expected one username"))
+	}
+	name := fs.Arg(0)
+
+	var bday time.Time
+	if birthday != "" {
+		var err error
+		bday, err = time.Parse("2006-01-02", birthday)
+		ifFatal(err, "parse birthday")
+	}
+
+	cfg := config()
+	v, err := cfg.ValidateUsername(name, bday)
+	ifFatal(err)
+	if v.Message != "" {
+		fmt.Printf("%s: %s (%s)\n", name, v.Status, v.Message)
+	} else {
+		fmt.Printf("%s: %s\n", name, v.Status)
+	}
+	if v.Status == rbxauth.UsernameValid || !suggest {
+		return
+	}
+	names, err := cfg.RecommendUsernames(name)
+	ifFatal(err)
+	for _, n := range names {
+		fmt.Println(n)
+	}
+}
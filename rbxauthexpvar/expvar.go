@@ -0,0 +1,73 @@
+// The rbxauthexpvar package provides an implementation of rbxauth.Metrics that
+// publishes measurements as expvar variables.
+package rbxauthexpvar
+
+import (
+	"errors"
+	"expvar"
+	"strconv"
+	"time"
+
+	"github.com/anaminus/rbxauth"
+)
+
+// Metrics implements rbxauth.Metrics by publishing measurements to an
+// expvar.Map. The map contains the following variables, each an expvar.Map
+// keyed by the operation or event:
+//
+//	requests:    The number of exchanges made for each operation.
+//	errors:      The number of exchanges that resulted in an error.
+//	retries:     The number of exchanges retried due to failed token validation.
+//	statuses:    The number of responses received for each operation and
+//	             status, keyed as "op:status".
+//	duration_ns: The total duration of exchanges for each operation, in
+//	             nanoseconds.
+//	events:      The number of times each event occurred.
+type Metrics struct {
+	requests *expvar.Map
+	errors   *expvar.Map
+	retries  *expvar.Map
+	statuses *expvar.Map
+	duration *expvar.Map
+	events   *expvar.Map
+}
+
+// New returns a Metrics that publishes its variables under name. Like
+// expvar.Publish, New panics if name is already in use.
+func New(name string) *Metrics {
+	m := &Metrics{
+		requests: new(expvar.Map).Init(),
+		errors:   new(expvar.Map).Init(),
+		retries:  new(expvar.Map).Init(),
+		statuses: new(expvar.Map).Init(),
+		duration: new(expvar.Map).Init(),
+		events:   new(expvar.Map).Init(),
+	}
+	root := expvar.NewMap(name)
+	root.Set("requests", m.requests)
+	root.Set("errors", m.errors)
+	root.Set("retries", m.retries)
+	root.Set("statuses", m.statuses)
+	root.Set("duration_ns", m.duration)
+	root.Set("events", m.events)
+	return m
+}
+
+// ObserveRequest implements rbxauth.Metrics.
+func (m *Metrics) ObserveRequest(op string, status int, dur time.Duration, err error) {
+	m.requests.Add(op, 1)
+	m.duration.Add(op, int64(dur))
+	if status != 0 {
+		m.statuses.Add(op+":"+strconv.Itoa(status), 1)
+	}
+	if errors.Is(err, rbxauth.ErrTokenRetry) {
+		m.retries.Add(op, 1)
+	} else if err != nil {
+		m.errors.Add(op, 1)
+	}
+}
+
+// ObserveEvent implements rbxauth.Metrics.
+func (m *Metrics) ObserveEvent(event string) {
+	m.events.Add(event, 1)
+}
@@ -0,0 +1,87 @@
+// The rbxauthkeyring package provides an implementation of
+// rbxauth.CredentialStore that stores sessions in the keyring of the operating
+// system: the Keychain on macOS, the Credential Manager on Windows, and the
+// Secret Service on Linux, by way of the secret-tool command.
+package rbxauthkeyring
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/anaminus/rbxauth"
+)
+
+// DefaultService is the service under which sessions are stored when
+// Store.Service is empty.
+const DefaultService = "rbxauth"
+
+// ErrUnsupported is returned when the keyring is not available on the current
+// system.
+var ErrUnsupported = errors.New("keyring not supported")
+
+// Store implements rbxauth.CredentialStore using the keyring of the operating
+// system. Each session is stored as an item identified by the service and the
+// account name.
+type Store struct {
+	// Service names the service under which sessions are stored. If empty,
+	// DefaultService is used.
+	Service string
+}
+
+// service returns the service of the store.
+func (s Store) service() string {
+	if s.Service == "" {
+		return DefaultService
+	}
+	return s.Service
+}
+
+// SaveSession implements rbxauth.CredentialStore.
+func (s Store) SaveSession(account string, cookies []*http.Cookie) error {
+	if account == "" {
+		return rbxauth.ErrInvalidAccount
+	}
+	var buf bytes.Buffer
+	if err := rbxauth.WriteCookiesJSON(&buf, cookies); err != nil {
+		return err
+	}
+	// Encoded so that the item contains only printable characters, which
+	// some keyrings require.
+	return set(s.service(), account, base64.StdEncoding.EncodeToString(buf.Bytes()))
+}
+
+// LoadSession implements rbxauth.CredentialStore.
+func (s Store) LoadSession(account string) ([]*http.Cookie, error) {
+	if account == "" {
+		return nil, rbxauth.ErrInvalidAccount
+	}
+	data, err := get(s.service(), account)
+	if err != nil {
+		return nil, notFound(account, err)
+	}
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(data))
+	if err != nil {
+		return nil, err
+	}
+	return rbxauth.ReadCookiesJSON(bytes.NewReader(b))
+}
+
+// DeleteSession implements rbxauth.CredentialStore.
+func (s Store) DeleteSession(account string) error {
+	if account == "" {
+		return rbxauth.ErrInvalidAccount
+	}
+	return notFound(account, del(s.service(), account))
+}
+
+// notFound converts err to a *rbxauth.SessionNotFoundError if it matches
+// rbxauth.ErrSessionNotFound.
+func notFound(account string, err error) error {
+	if errors.Is(err, rbxauth.ErrSessionNotFound) {
+		return &rbxauth.SessionNotFoundError{Account: account}
+	}
+	return err
+}
@@ -0,0 +1,71 @@
+package rbxauthkeyring
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/anaminus/rbxauth"
+)
+
+// errItemNotFound is the exit status of the security command when an item
+// does not exist.
+const errItemNotFound = 44
+
+// security runs the security command with the given input and arguments,
+// returning its output.
+func security(input string, args ...string) (string, error) {
+	cmd := exec.Command("security", args...)
+	cmd.Stdin = strings.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return "", fmt.Errorf("%w: security not found", ErrUnsupported)
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == errItemNotFound {
+			return "", rbxauth.ErrSessionNotFound
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("security: %s", msg)
+		}
+		return "", fmt.Errorf("security: %w", err)
+	}
+	return stdout.String(), nil
+}
+
+// quote quotes s as an argument of an interactive security command.
+func quote(s string) (string, error) {
+	if strings.ContainsAny(s, "'\n\r") {
+		return "", rbxauth.ErrInvalidAccount
+	}
+	return "'" + s + "'", nil
+}
+
+func set(service, account, data string) error {
+	s, err := quote(service)
+	if err != nil {
+		return err
+	}
+	a, err := quote(account)
+	if err != nil {
+		return err
+	}
+	// The command is passed through the input of an interactive session so
+	// that the data does not appear in the arguments of the process.
+	_, err = security(fmt.Sprintf("add-generic-password -U -s %s -a %s -w '%s'\n", s, a, data), "-i")
+	return err
+}
+
+func get(service, account string) (string, error) {
+	return security("", "find-generic-password", "-s", service, "-a", account, "-w")
+}
+
+func del(service, account string) error {
+	_, err := security("", "delete-generic-password", "-s", service, "-a", account)
+	return err
+}
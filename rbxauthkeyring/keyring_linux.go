@@ -0,0 +1,61 @@
+package rbxauthkeyring
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/anaminus/rbxauth"
+)
+
+// secretTool runs the secret-tool command with the given input and arguments,
+// returning its output.
+func secretTool(input string, args ...string) (string, error) {
+	cmd := exec.Command("secret-tool", args...)
+	cmd.Stdin = strings.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return "", fmt.Errorf("%w: secret-tool not found", ErrUnsupported)
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && stderr.Len() == 0 && stdout.Len() == 0 {
+			// Lookup of a missing item fails without output.
+			return "", rbxauth.ErrSessionNotFound
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("secret-tool: %s", msg)
+		}
+		return "", fmt.Errorf("secret-tool: %w", err)
+	}
+	return stdout.String(), nil
+}
+
+func set(service, account, data string) error {
+	_, err := secretTool(data, "store", "--label="+service+": "+account, "service", service, "account", account)
+	return err
+}
+
+func get(service, account string) (string, error) {
+	data, err := secretTool("", "lookup", "service", service, "account", account)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(data) == "" {
+		return "", rbxauth.ErrSessionNotFound
+	}
+	return data, nil
+}
+
+func del(service, account string) error {
+	// Clearing a missing item succeeds, so the item is looked up first.
+	if _, err := get(service, account); err != nil {
+		return err
+	}
+	_, err := secretTool("", "clear", "service", service, "account", account)
+	return err
+}
@@ -0,0 +1,16 @@
+//go:build !darwin && !linux && !windows
+// +build !darwin,!linux,!windows
+
+package rbxauthkeyring
+
+func set(service, account, data string) error {
+	return ErrUnsupported
+}
+
+func get(service, account string) (string, error) {
+	return "", ErrUnsupported
+}
+
+func del(service, account string) error {
+	return ErrUnsupported
+}
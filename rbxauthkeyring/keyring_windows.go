@@ -0,0 +1,117 @@
+package rbxauthkeyring
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"unsafe"
+
+	"github.com/anaminus/rbxauth"
+)
+
+var (
+	advapi32        = syscall.NewLazyDLL("advapi32.dll")
+	procCredWriteW  = advapi32.NewProc("CredWriteW")
+	procCredReadW   = advapi32.NewProc("CredReadW")
+	procCredDeleteW = advapi32.NewProc("CredDeleteW")
+	procCredFree    = advapi32.NewProc("CredFree")
+)
+
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+	// credMaxBlobSize is the maximum size of the data of a credential.
+	credMaxBlobSize = 5 * 512
+	// errorNotFound is returned when a credential does not exist.
+	errorNotFound syscall.Errno = 1168
+)
+
+// credential corresponds to the CREDENTIALW structure.
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        syscall.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// target returns the target name of the credential of an account.
+func target(service, account string) (*uint16, error) {
+	p, err := syscall.UTF16PtrFromString(service + ":" + account)
+	if err != nil {
+		return nil, rbxauth.ErrInvalidAccount
+	}
+	return p, nil
+}
+
+// credError converts an error returned by a credential function.
+func credError(err error) error {
+	if errors.Is(err, errorNotFound) {
+		return rbxauth.ErrSessionNotFound
+	}
+	return err
+}
+
+func set(service, account, data string) error {
+	if len(data) > credMaxBlobSize {
+		return fmt.Errorf("session of %d bytes exceeds credential limit of %d bytes", len(data), credMaxBlobSize)
+	}
+	name, err := target(service, account)
+	if err != nil {
+		return err
+	}
+	user, err := syscall.UTF16PtrFromString(account)
+	if err != nil {
+		return rbxauth.ErrInvalidAccount
+	}
+	blob := []byte(data)
+	cred := credential{
+		Type:               credTypeGeneric,
+		TargetName:         name,
+		CredentialBlobSize: uint32(len(blob)),
+		Persist:            credPersistLocalMachine,
+		UserName:           user,
+	}
+	if len(blob) > 0 {
+		cred.CredentialBlob = &blob[0]
+	}
+	if r, _, err := procCredWriteW.Call(uintptr(unsafe.Pointer(&cred)), 0); r == 0 {
+		return credError(err)
+	}
+	return nil
+}
+
+func get(service, account string) (string, error) {
+	name, err := target(service, account)
+	if err != nil {
+		return "", err
+	}
+	var cred *credential
+	if r, _, err := procCredReadW.Call(uintptr(unsafe.Pointer(name)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred))); r == 0 {
+		return "", credError(err)
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+	blob := make([]byte, cred.CredentialBlobSize)
+	if len(blob) > 0 {
+		copy(blob, (*[credMaxBlobSize]byte)(unsafe.Pointer(cred.CredentialBlob))[:len(blob):len(blob)])
+	}
+	return string(blob), nil
+}
+
+func del(service, account string) error {
+	name, err := target(service, account)
+	if err != nil {
+		return err
+	}
+	if r, _, err := procCredDeleteW.Call(uintptr(unsafe.Pointer(name)), credTypeGeneric, 0); r == 0 {
+		return credError(err)
+	}
+	return nil
+}
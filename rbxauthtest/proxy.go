@@ -0,0 +1,317 @@
+package rbxauthtest
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+)
+
+// Proxy is a proxy that records the target of each request made through it.
+// A Proxy requires the credentials it was created with, if any.
+type Proxy struct {
+	// URL is the URL of the proxy, including its credentials.
+	URL string
+
+	user, password string
+	close          func()
+
+	mu      sync.Mutex
+	targets []string
+	denied  int
+}
+
+// Targets returns the target of each request that was made through the
+// proxy. For an HTTP proxy, a target is the host and path of the request. For
+// a SOCKS5 proxy, a target is the address of the connection.
+func (p *Proxy) Targets() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.targets...)
+}
+
+// Denied returns the number of requests rejected for having incorrect
+// credentials.
+func (p *Proxy) Denied() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.denied
+}
+
+// Close shuts down the proxy.
+func (p *Proxy) Close() {
+	p.close()
+}
+
+func (p *Proxy) record(target string) {
+	p.mu.Lock()
+	p.targets = append(p.targets, target)
+	p.mu.Unlock()
+}
+
+func (p *Proxy) deny() {
+	p.mu.Lock()
+	p.denied++
+	p.mu.Unlock()
+}
+
+// proxyURL returns the URL of a proxy with scheme at addr.
+func (p *Proxy) proxyURL(scheme, addr string) string {
+	u := url.URL{Scheme: scheme, Host: addr}
+	if p.user != "" {
+		u.User = url.UserPassword(p.user, p.password)
+	}
+	return u.String()
+}
+
+// NewHTTPProxy returns a running HTTP proxy that forwards plain HTTP
+// requests. If user is not empty, each request must be authorized with user
+// and password.
+func NewHTTPProxy(user, password string) *Proxy {
+	p := &Proxy{user: user, password: password}
+	transport := &http.Transport{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p.user != "" {
+			want := "Basic " + base64.StdEncoding.EncodeToString([]byte(p.user+":"+p.password))
+			if r.Header.Get("Proxy-Authorization") != want {
+				p.deny()
+				w.WriteHeader(http.StatusProxyAuthRequired)
+				return
+			}
+		}
+		if !r.URL.IsAbs() {
+			http.Error(w, "not a proxy request", http.StatusBadRequest)
+			return
+		}
+		p.record(r.URL.Host + r.URL.Path)
+		out := r.Clone(r.Context())
+		out.RequestURI = ""
+		out.Header.Del("Proxy-Authorization")
+		resp, err := transport.RoundTrip(out)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		for k, v := range resp.Header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+	}))
+	p.URL = p.proxyURL("http", srv.Listener.Addr().String())
+	p.close = func() {
+		srv.Close()
+		transport.CloseIdleConnections()
+	}
+	return p
+}
+
+// NewSOCKS5Proxy returns a running SOCKS5 proxy that supports the CONNECT
+// command. If user is not empty, each connection must be authenticated with
+// user and password.
+func NewSOCKS5Proxy(user, password string) *Proxy {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic("rbxauthtest: failed to listen: " + err.Error())
+	}
+	p := &Proxy{user: user, password: password}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	conns := map[net.Conn]bool{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			conns[conn] = true
+			mu.Unlock()
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				p.serveSOCKS5(conn)
+				mu.Lock()
+				delete(conns, conn)
+				mu.Unlock()
+			}()
+		}
+	}()
+	p.URL = p.proxyURL("socks5", l.Addr().String())
+	p.close = func() {
+		l.Close()
+		// Idle connections kept alive by clients would otherwise block.
+		mu.Lock()
+		for conn := range conns {
+			conn.Close()
+		}
+		mu.Unlock()
+		wg.Wait()
+	}
+	return p
+}
+
+// SOCKS5 protocol values. See RFC 1928 and RFC 1929.
+const (
+	socksVersion      = 5
+	socksNoAuth       = 0
+	socksUserPass     = 2
+	socksNoAcceptable = 0xFF
+	socksConnect      = 1
+	socksIPv4         = 1
+	socksDomain       = 3
+	socksIPv6         = 4
+	socksSucceeded    = 0
+	socksFailure      = 1
+	socksUnsupported  = 7
+)
+
+var errSOCKS = errors.New("socks5 protocol error")
+
+// serveSOCKS5 handles a single SOCKS5 connection, relaying it to the
+// requested target.
+func (p *Proxy) serveSOCKS5(conn net.Conn) {
+	defer conn.Close()
+	target, err := p.socksHandshake(conn)
+	if err != nil {
+		return
+	}
+	upstream, err := net.Dial("tcp", target)
+	if err != nil {
+		conn.Write([]byte{socksVersion, socksFailure, 0, socksIPv4, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer upstream.Close()
+	p.record(target)
+	if _, err := conn.Write([]byte{socksVersion, socksSucceeded, 0, socksIPv4, 0, 0, 0, 0, 0, 0}); err != nil {
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		io.Copy(upstream, conn)
+		upstream.(*net.TCPConn).CloseWrite()
+		close(done)
+	}()
+	io.Copy(conn, upstream)
+	conn.Close()
+	<-done
+}
+
+// socksHandshake negotiates authentication and reads the request of a
+// connection, returning the requested target.
+func (p *Proxy) socksHandshake(conn net.Conn) (target string, err error) {
+	var head [2]byte
+	if _, err := io.ReadFull(conn, head[:]); err != nil {
+		return "", err
+	}
+	if head[0] != socksVersion {
+		return "", errSOCKS
+	}
+	methods := make([]byte, head[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return "", err
+	}
+	method := byte(socksNoAuth)
+	if p.user != "" {
+		method = socksUserPass
+	}
+	var offered bool
+	for _, m := range methods {
+		offered = offered || m == method
+	}
+	if !offered {
+		conn.Write([]byte{socksVersion, socksNoAcceptable})
+		p.deny()
+		return "", errSOCKS
+	}
+	if _, err := conn.Write([]byte{socksVersion, method}); err != nil {
+		return "", err
+	}
+	if method == socksUserPass {
+		user, password, err := readSOCKSUserPass(conn)
+		if err != nil {
+			return "", err
+		}
+		if user != p.user || password != p.password {
+			conn.Write([]byte{1, 1})
+			p.deny()
+			return "", errSOCKS
+		}
+		if _, err := conn.Write([]byte{1, 0}); err != nil {
+			return "", err
+		}
+	}
+
+	var req [4]byte
+	if _, err := io.ReadFull(conn, req[:]); err != nil {
+		return "", err
+	}
+	if req[0] != socksVersion {
+		return "", errSOCKS
+	}
+	var host string
+	switch req[3] {
+	case socksIPv4, socksIPv6:
+		ip := make(net.IP, 4)
+		if req[3] == socksIPv6 {
+			ip = make(net.IP, 16)
+		}
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return "", err
+		}
+		host = ip.String()
+	case socksDomain:
+		var n [1]byte
+		if _, err := io.ReadFull(conn, n[:]); err != nil {
+			return "", err
+		}
+		domain := make([]byte, n[0])
+		if _, err := io.ReadFull(conn, domain); err != nil {
+			return "", err
+		}
+		host = string(domain)
+	default:
+		return "", errSOCKS
+	}
+	var port [2]byte
+	if _, err := io.ReadFull(conn, port[:]); err != nil {
+		return "", err
+	}
+	if req[1] != socksConnect {
+		conn.Write([]byte{socksVersion, socksUnsupported, 0, socksIPv4, 0, 0, 0, 0, 0, 0})
+		return "", errSOCKS
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port[:])))), nil
+}
+
+// readSOCKSUserPass reads a username/password authentication request.
+func readSOCKSUserPass(conn net.Conn) (user, password string, err error) {
+	var b [2]byte
+	if _, err := io.ReadFull(conn, b[:]); err != nil {
+		return "", "", err
+	}
+	if b[0] != 1 {
+		return "", "", errSOCKS
+	}
+	u := make([]byte, b[1])
+	if _, err := io.ReadFull(conn, u); err != nil {
+		return "", "", err
+	}
+	if _, err := io.ReadFull(conn, b[:1]); err != nil {
+		return "", "", err
+	}
+	pw := make([]byte, b[0])
+	if _, err := io.ReadFull(conn, pw); err != nil {
+		return "", "", err
+	}
+	return string(u), string(pw), nil
+}
@@ -0,0 +1,1667 @@
+// The rbxauthtest package provides a fake implementation of the Roblox
+// authentication API, for testing code that depends on rbxauth.
+package rbxauthtest
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anaminus/rbxauth"
+)
+
+// Paths of each endpoint implemented by the server.
+const (
+	LoginPath         = "/v2/login"
+	LogoutPath        = "/v2/logout"
+	LogoutAllPath     = "/v2/logoutfromallsessionsandreauthenticate"
+	VerifyPath        = "/v2/twostepverification/verify"
+	ResendPath        = "/v2/twostepverification/resend"
+	UserIDPath        = "/v1/users/" // Followed by a user ID.
+	AuthenticatedPath = "/v1/users/authenticated"
+	UsernamesPath     = "/v1/usernames/users"
+	ChallengePath     = "/2sv/v1/users/" // Followed by {id}/challenges/{media}/{action}.
+	TwoStepLoginPath  = "/v3/users/"     // Followed by {id}/two-step-verification/login.
+
+	IdentityVerificationPath = "/v1/identity-verification/login" // Followed by /{action}.
+	SecurityQuestionsPath    = "/v1/security-questions/login"    // Followed by /{action}.
+
+	UnlockPINPath = "/v1/account/pin/unlock"
+	LockPINPath   = "/v1/account/pin/lock"
+
+	ValidatePasswordPath = "/v2/passwords/validate"
+	ChangePasswordPath   = "/v2/user/passwords/change"
+
+	MetadataPath = "/v2/metadata"
+
+	AuthTicketPath       = "/v1/authentication-ticket"
+	RedeemAuthTicketPath = "/v1/authentication-ticket/redeem"
+
+	EmailPath       = "/v1/email"
+	EmailVerifyPath = "/v1/email/verify"
+
+	SocialLoginPath = "/v1/social/" // Followed by {provider}/login.
+
+	SignupPath = "/v2/signup"
+
+	ValidateUsernamePath   = "/v2/usernames/validate"
+	RecommendUsernamesPath = "/v2/usernames/recommendations"
+
+	PasswordResetSendPath   = "/v2/passwords/reset/send"
+	PasswordResetVerifyPath = "/v2/passwords/reset/verify"
+	PasswordResetPath       = "/v2/passwords/reset"
+)
+
+// SessionCookieName is the name of the cookie holding a session.
+const SessionCookieName = ".ROBLOSECURITY"
+
+// DeviceCookieName is the name of the cookie identifying a remembered device.
+const DeviceCookieName = "RBXEventTrackerV2"
+
+const tokenHeader = "X-CSRF-TOKEN"
+
+// RequestIDHeader is the header identifying each response of the server.
+const RequestIDHeader = "X-Roblox-Request-Id"
+
+// Error codes returned by the server.
+const (
+	errorTokenValidation   = 0
+	errorBadCredentials    = 1
+	errorInvalidTicket     = 5
+	errorInvalidCode       = 6
+	errorInvalidChallenge  = 1
+	errorChallengeCode     = 10
+	errorPinNotSet         = 1
+	errorIncorrectPIN      = 2
+	errorPinAttempts       = 3
+	errorInvalidPassword   = 2
+	errorWrongPassword     = 8
+	errorCodeAttempts      = 7
+	errorChallengeLimit    = 5
+	errorMissingReferer    = 1
+	errorInvalidAuthTicket = 1
+	errorExpiredAuthTicket = 2
+	errorNegotiation       = 3
+	errorNoEmail           = 1
+	errorEmailAttempts     = 6
+	errorInvalidSocial     = 1
+	errorCaptchaRequired   = 2
+	errorInvalidBirthday   = 3
+	errorUsernameTaken     = 6
+	errorSignupPassword    = 7
+	errorInvalidIntent     = 0
+	errorInvalidQuestions  = 1
+	errorWrongAnswer       = 2
+	errorQuestionsLocked   = 3
+	errorResetUnknown      = 1
+	errorResetLimit        = 2
+	errorResetCode         = 3
+	errorResetTicket       = 4
+	errorResetPassword     = 5
+	errorLoginAttempts     = 7
+	errorParentalConsent   = 16
+	errorAgeRestricted     = 17
+)
+
+// MaxVerificationEmails is the number of verification emails sent for an
+// account before further requests are rejected.
+const MaxVerificationEmails = 3
+
+// MaxCodeAttempts is the number of incorrect verification codes accepted for a
+// ticket before further attempts are rejected.
+const MaxCodeAttempts = 3
+
+// MaxQuestionAttempts is the number of incorrect answers accepted for a
+// security question challenge before further attempts are rejected.
+const MaxQuestionAttempts = 3
+
+// MaxResetRequests is the number of password reset codes sent for an account
+// before further requests are rejected.
+const MaxResetRequests = 3
+
+// MinPasswordLength is the minimum length of a password accepted by the
+// server.
+const MinPasswordLength = 8
+
+// LoginRetryAfter is the value of the Retry-After header of a throttled
+// login, in seconds.
+const LoginRetryAfter = 60
+
+// MaxPINAttempts is the number of incorrect PINs accepted before further
+// attempts are rejected.
+const MaxPINAttempts = 3
+
+// PINUnlockDuration is the duration for which an account is unlocked by a PIN,
+// in seconds.
+const PINUnlockDuration = 300
+
+// Account describes an account known to the server.
+type Account struct {
+	ID          int64
+	Name        string
+	Email       string
+	PhoneNumber string
+	Password    string
+	Banned      bool
+
+	// TwoStep indicates whether two-step verification is required to log in.
+	TwoStep bool
+	// MediaType is the media type reported for two-step verification.
+	// Defaults to "Email".
+	MediaType string
+	// Code is the two-step verification code accepted by the server.
+	Code string
+
+	// Approval indicates whether a login must be approved out-of-band. Takes
+	// precedence over TwoStep.
+	Approval bool
+	// ApproveAfter is the number of times the status of a login is polled
+	// before the login is approved.
+	ApproveAfter int
+	// Deny causes a login to be denied instead of approved.
+	Deny bool
+
+	// Captcha, if not empty, is the captcha token that must be passed to log
+	// in. A login without the token is rejected with a captcha challenge,
+	// requested through challenge headers. The solution must be passed both
+	// in the body and in the challenge headers.
+	Captcha string
+
+	// Question, if non-nil, must be answered to log in. Takes precedence
+	// over Approval and TwoStep.
+	Question *SecurityQuestion
+
+	// SocialTokens maps the name of a social provider to the token that logs
+	// in to the account through the provider.
+	SocialTokens map[string]string
+
+	// ResetCode is the code sent to reset the password of the account. If
+	// empty, every code is rejected.
+	ResetCode string
+
+	// Throttled is the number of logins to the account that are rejected
+	// for too many attempts, with a Retry-After of LoginRetryAfter, before
+	// logins are accepted again.
+	Throttled int
+
+	// ConsentURL, if not empty, causes logins to the account to be rejected
+	// because parental consent is required, reporting ConsentURL as the page
+	// where consent is given.
+	ConsentURL string
+	// AgeRestricted causes logins to the account to be rejected due to age
+	// restrictions.
+	AgeRestricted bool
+
+	// PendingActions lists the actions reported by a successful login to
+	// the account.
+	PendingActions []rbxauth.PendingAction
+
+	// EmailVerified is whether Email has been verified.
+	EmailVerified bool
+
+	// PIN is the account PIN. If empty, the account has no PIN.
+	PIN string
+	// Unlocked is whether the PIN is currently unlocked.
+	Unlocked bool
+
+	pinAttempts int
+	emailsSent  int
+	resetsSent  int
+}
+
+// Server is a fake authentication server.
+type Server struct {
+	*httptest.Server
+
+	// RotateTokens causes the CSRF token to change after each accepted
+	// request.
+	RotateTokens bool
+
+	// RotateSessions causes each request to the authenticated endpoint to
+	// replace the session cookie, ending the previous session.
+	RotateSessions bool
+
+	// Metadata is the response to a metadata request. If nil, a response
+	// with default settings is returned.
+	Metadata map[string]interface{}
+
+	// SignupCaptcha, if not empty, is the captcha token that must be passed
+	// with each sign-up.
+	SignupCaptcha string
+
+	mu           sync.Mutex
+	lastLogin    LoginRecord
+	lastVerify   VerifyRecord
+	token        string
+	accounts     []*Account
+	sessions     map[string]*Account
+	devices      map[string]*Account
+	tickets      map[string]*Account
+	attempts     map[string]int
+	authTickets  map[string]*authTicket
+	pending      map[string]*approval
+	questions    map[string]*questionChallenge
+	captchas     map[string]string
+	resets       map[string]*passwordReset
+	resetTickets map[string]*Account
+	failures     map[string][]failure
+	stalls       map[string][]time.Duration
+	counts       map[string]int
+	conns        int
+}
+
+// SecurityQuestion is a security question that must be answered to log in to
+// an account.
+type SecurityQuestion struct {
+	Prompt  string
+	Choices []string
+	// Answers is the set of choices that must be selected.
+	Answers []string
+}
+
+// questionChallenge is a login awaiting the answer to a security question.
+type questionChallenge struct {
+	account  *Account
+	attempts int
+}
+
+// LoginRecord describes the device information included in a login request.
+type LoginRecord struct {
+	// DeviceMeta is whether device metadata was included.
+	DeviceMeta bool
+	DeviceType string
+	OS         string
+	DeviceID   string
+	// Intent is whether a secure authentication intent was included. An
+	// intent with an invalid signature is rejected.
+	Intent bool
+	// Captcha is whether the solution to a captcha challenge was included.
+	Captcha bool
+}
+
+// VerifyRecord describes a request that completed two-step verification.
+type VerifyRecord struct {
+	// Code is the verification code that was accepted.
+	Code string
+	// RememberDevice is whether the request asked for the device to be
+	// remembered.
+	RememberDevice bool
+}
+
+// approval is a login awaiting out-of-band approval.
+type approval struct {
+	account *Account
+	polls   int
+}
+
+// authTicket is an authentication ticket that has not been redeemed.
+type authTicket struct {
+	account *Account
+	expired bool
+}
+
+// passwordReset is a password reset awaiting verification of its code.
+type passwordReset struct {
+	targetType string
+	accounts   []*Account
+	attempts   int
+}
+
+// failure is a canned error response.
+type failure struct {
+	status int
+	code   int
+	msg    string
+}
+
+// NewServer starts and returns a new Server. The server should be closed when
+// finished.
+func NewServer() *Server {
+	s := newServer()
+	s.Start()
+	return s
+}
+
+// NewTLSServer is like NewServer, but the server uses https. The client of a
+// Config returned by Server.Config trusts the certificate of the server.
+func NewTLSServer() *Server {
+	s := newServer()
+	s.StartTLS()
+	return s
+}
+
+// newServer returns a Server that has not been started.
+func newServer() *Server {
+	s := &Server{
+		token:        randomString(),
+		sessions:     map[string]*Account{},
+		devices:      map[string]*Account{},
+		tickets:      map[string]*Account{},
+		attempts:     map[string]int{},
+		authTickets:  map[string]*authTicket{},
+		pending:      map[string]*approval{},
+		questions:    map[string]*questionChallenge{},
+		captchas:     map[string]string{},
+		resets:       map[string]*passwordReset{},
+		resetTickets: map[string]*Account{},
+		failures:     map[string][]failure{},
+		stalls:       map[string][]time.Duration{},
+		counts:       map[string]int{},
+	}
+	s.Server = httptest.NewUnstartedServer(http.HandlerFunc(s.serveHTTP))
+	s.Server.Config.ConnState = s.connState
+	return s
+}
+
+// connState counts each new connection.
+func (s *Server) connState(conn net.Conn, state http.ConnState) {
+	if state != http.StateNew {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conns++
+}
+
+// Config returns a Config with each endpoint pointing to the server. Like a
+// Config returned by rbxauth.NewConfig, it has a TokenCache. Because the
+// server may use http, AllowInsecure is set.
+func (s *Server) Config() rbxauth.Config {
+	return rbxauth.Config{
+		Client:                   s.Client(),
+		TokenCache:               &rbxauth.TokenCache{},
+		AllowInsecure:            true,
+		LoginEndpoint:            s.URL + LoginPath,
+		LogoutEndpoint:           s.URL + LogoutPath,
+		LogoutAllEndpoint:        s.URL + LogoutAllPath,
+		VerifyEndpoint:           s.URL + VerifyPath,
+		ResendEndpoint:           s.URL + ResendPath,
+		UserIDEndpoint:           s.URL + UserIDPath + "%d",
+		AuthenticatedEndpoint:    s.URL + AuthenticatedPath,
+		RefreshEndpoint:          s.URL + AuthenticatedPath,
+		UsernameLookupEndpoint:   s.URL + UsernamesPath,
+		TwoStepChallengeEndpoint: s.URL + ChallengePath + "%d/challenges/%s",
+		TwoStepLoginEndpoint:     s.URL + TwoStepLoginPath + "%d/two-step-verification/login",
+
+		IdentityVerificationEndpoint: s.URL + IdentityVerificationPath,
+		SecurityQuestionsEndpoint:    s.URL + SecurityQuestionsPath,
+		UnlockPINEndpoint:            s.URL + UnlockPINPath,
+		LockPINEndpoint:              s.URL + LockPINPath,
+		ValidatePasswordEndpoint:     s.URL + ValidatePasswordPath,
+		ChangePasswordEndpoint:       s.URL + ChangePasswordPath,
+		MetadataEndpoint:             s.URL + MetadataPath,
+		AuthTicketEndpoint:           s.URL + AuthTicketPath,
+		RedeemAuthTicketEndpoint:     s.URL + RedeemAuthTicketPath,
+		EmailEndpoint:                s.URL + EmailPath,
+		EmailVerifyEndpoint:          s.URL + EmailVerifyPath,
+		SocialLoginEndpoint:          s.URL + SocialLoginPath + "%s/login",
+		SignupEndpoint:               s.URL + SignupPath,
+		ValidateUsernameEndpoint:     s.URL + ValidateUsernamePath,
+		RecommendUsernamesEndpoint:   s.URL + RecommendUsernamesPath,
+		PasswordResetSendEndpoint:    s.URL + PasswordResetSendPath,
+		PasswordResetVerifyEndpoint:  s.URL + PasswordResetVerifyPath,
+		PasswordResetEndpoint:        s.URL + PasswordResetPath,
+	}
+}
+
+// AddAccount adds an account to the server.
+func (s *Server) AddAccount(account Account) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if account.MediaType == "" {
+		account.MediaType = "Email"
+	}
+	s.accounts = append(s.accounts, &account)
+}
+
+// Fail causes the next request to the endpoint at path to fail with the given
+// status and API error.
+func (s *Server) Fail(path string, status, code int, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[path] = append(s.failures[path], failure{status: status, code: code, msg: message})
+}
+
+// Stall causes the next request to the endpoint at path to wait for d before
+// it is handled. A request abandoned by the client while waiting is not
+// handled.
+func (s *Server) Stall(path string, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stalls[path] = append(s.stalls[path], d)
+}
+
+// stall waits for the stall of the endpoint of r, if any. Returns false if
+// the request was abandoned while waiting.
+func (s *Server) stall(r *http.Request) bool {
+	path := endpointPath(r.URL.Path)
+	s.mu.Lock()
+	d := s.stalls[path]
+	if len(d) > 0 {
+		s.stalls[path] = d[1:]
+	}
+	s.mu.Unlock()
+	if len(d) == 0 {
+		return true
+	}
+	// The server notices that the client has gone only after the body is
+	// read.
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return false
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	t := time.NewTimer(d[0])
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+// Count returns the number of requests received by the endpoint at path,
+// including rejected requests.
+func (s *Server) Count(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.counts[path]
+}
+
+// Conns returns the number of connections accepted by the server.
+func (s *Server) Conns() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conns
+}
+
+// ExpireAuthTickets causes each unredeemed authentication ticket to be
+// reported as expired.
+func (s *Server) ExpireAuthTickets() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.authTickets {
+		t.expired = true
+	}
+}
+
+// LastLogin returns a record of the last login request with valid
+// credentials.
+func (s *Server) LastLogin() LoginRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastLogin
+}
+
+// LastVerify returns a record of the last request that completed two-step
+// verification.
+func (s *Server) LastVerify() VerifyRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastVerify
+}
+
+// Token returns the current CSRF token.
+func (s *Server) Token() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.token
+}
+
+func randomString() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status, code int, msg string) {
+	writeJSON(w, status, map[string]interface{}{
+		"errors": []rbxauth.ErrorResponse{{Code: code, Message: msg}},
+	})
+}
+
+// endpointPath returns the path used to identify the endpoint handling p.
+func endpointPath(p string) string {
+	switch {
+	case strings.HasPrefix(p, TwoStepLoginPath) && strings.HasSuffix(p, "/two-step-verification/login"):
+		return TwoStepLoginPath
+	case strings.HasPrefix(p, ChallengePath) && strings.Contains(p, "/challenges/"):
+		return ChallengePath
+	case strings.HasPrefix(p, IdentityVerificationPath+"/"):
+		return IdentityVerificationPath
+	case strings.HasPrefix(p, SecurityQuestionsPath+"/"):
+		return SecurityQuestionsPath
+	case strings.HasPrefix(p, SocialLoginPath) && strings.HasSuffix(p, "/login"):
+		return SocialLoginPath
+	case p == AuthenticatedPath:
+		return AuthenticatedPath
+	case strings.HasPrefix(p, UserIDPath):
+		return UserIDPath
+	}
+	return p
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.stall(r) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := endpointPath(r.URL.Path)
+	s.counts[path]++
+	w.Header().Set(RequestIDHeader, randomString())
+
+	if r.Method == "POST" {
+		if r.Header.Get(tokenHeader) != s.token {
+			w.Header().Set(tokenHeader, s.token)
+			writeError(w, 403, errorTokenValidation, "Token Validation Failed")
+			return
+		}
+		if s.RotateTokens {
+			s.token = randomString()
+		}
+		w.Header().Set(tokenHeader, s.token)
+	}
+
+	if f := s.failures[path]; len(f) > 0 {
+		s.failures[path] = f[1:]
+		writeError(w, f[0].status, f[0].code, f[0].msg)
+		return
+	}
+
+	switch path {
+	case LoginPath:
+		s.login(w, r)
+	case LogoutPath:
+		s.logout(w, r)
+	case LogoutAllPath:
+		s.logoutAll(w, r)
+	case VerifyPath:
+		s.verify(w, r)
+	case ResendPath:
+		s.resend(w, r)
+	case UserIDPath:
+		s.userID(w, r)
+	case AuthenticatedPath:
+		s.authenticated(w, r)
+	case UsernamesPath:
+		s.usernames(w, r)
+	case ChallengePath:
+		s.challenge(w, r)
+	case TwoStepLoginPath:
+		s.twoStepLogin(w, r)
+	case IdentityVerificationPath:
+		s.identityVerification(w, r)
+	case SecurityQuestionsPath:
+		s.securityQuestions(w, r)
+	case UnlockPINPath:
+		s.unlockPIN(w, r)
+	case LockPINPath:
+		s.lockPIN(w, r)
+	case ValidatePasswordPath:
+		s.validatePassword(w, r)
+	case ChangePasswordPath:
+		s.changePassword(w, r)
+	case MetadataPath:
+		s.metadata(w, r)
+	case AuthTicketPath:
+		s.createAuthTicket(w, r)
+	case RedeemAuthTicketPath:
+		s.redeemAuthTicket(w, r)
+	case EmailPath:
+		s.email(w, r)
+	case EmailVerifyPath:
+		s.emailVerify(w, r)
+	case SocialLoginPath:
+		s.socialLogin(w, r)
+	case SignupPath:
+		s.signup(w, r)
+	case ValidateUsernamePath:
+		s.validateUsername(w, r)
+	case RecommendUsernamesPath:
+		s.recommendUsernames(w, r)
+	case PasswordResetSendPath:
+		s.passwordResetSend(w, r)
+	case PasswordResetVerifyPath:
+		s.passwordResetVerify(w, r)
+	case PasswordResetPath:
+		s.passwordReset(w, r)
+	default:
+		writeError(w, 404, 0, "NotFound")
+	}
+}
+
+// rememberDevice sets a device cookie that allows account to skip two-step
+// verification.
+func (s *Server) rememberDevice(w http.ResponseWriter, account *Account) {
+	value := randomString()
+	s.devices[value] = account
+	http.SetCookie(w, &http.Cookie{
+		Name:     DeviceCookieName,
+		Value:    value,
+		Path:     "/",
+		HttpOnly: true,
+	})
+}
+
+// remembered returns whether r presents a device cookie remembered for
+// account.
+func (s *Server) remembered(r *http.Request, account *Account) bool {
+	cookie, err := r.Cookie(DeviceCookieName)
+	return err == nil && s.devices[cookie.Value] == account
+}
+
+// startSession creates a session for account, setting the session cookie.
+func (s *Server) startSession(w http.ResponseWriter, account *Account) {
+	value := randomString()
+	s.sessions[value] = account
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    value,
+		Path:     "/",
+		HttpOnly: true,
+	})
+}
+
+// session returns the account associated with the request's session cookie.
+func (s *Server) session(r *http.Request) (string, *Account) {
+	cookie, err := r.Cookie(SessionCookieName)
+	if err != nil {
+		return "", nil
+	}
+	return cookie.Value, s.sessions[cookie.Value]
+}
+
+// startTwoStep creates a ticket for account.
+func (s *Server) startTwoStep(account *Account) string {
+	ticket := randomString()
+	s.tickets[ticket] = account
+	return ticket
+}
+
+type userModel struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		CredType   string `json:"ctype"`
+		CredValue  string `json:"cvalue"`
+		Password   string `json:"password"`
+		DeviceMeta *struct {
+			DeviceType string `json:"deviceType"`
+			OS         string `json:"os"`
+			DeviceID   string `json:"deviceId"`
+		} `json:"deviceMeta"`
+		Intent       *secureAuthIntent `json:"secureAuthenticationIntent"`
+		CaptchaToken string            `json:"captchaToken"`
+		CaptchaID    string            `json:"captchaId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, 400, 0, "BadRequest")
+		return
+	}
+	var account *Account
+	for _, a := range s.accounts {
+		var ident string
+		switch req.CredType {
+		case "Username":
+			ident = a.Name
+		case "Email":
+			ident = a.Email
+		case "PhoneNumber":
+			ident = a.PhoneNumber
+		}
+		if ident != "" && strings.EqualFold(ident, req.CredValue) {
+			account = a
+			break
+		}
+	}
+	if account != nil && account.Throttled > 0 {
+		account.Throttled--
+		w.Header().Set("Retry-After", strconv.Itoa(LoginRetryAfter))
+		writeError(w, 429, errorLoginAttempts, "Too many attempts. Please wait a bit.")
+		return
+	}
+	if account == nil || account.Password != req.Password {
+		writeError(w, 403, errorBadCredentials, "Incorrect username or password. Please try again.")
+		return
+	}
+	if account.ConsentURL != "" {
+		data, _ := json.Marshal(map[string]string{"consentUrl": account.ConsentURL})
+		writeJSON(w, 403, map[string]interface{}{
+			"errors": []rbxauth.ErrorResponse{{
+				Code:      errorParentalConsent,
+				Message:   "Parental consent is required to log in.",
+				FieldData: string(data),
+			}},
+		})
+		return
+	}
+	if account.AgeRestricted {
+		writeError(w, 403, errorAgeRestricted, "This account cannot log in due to age restrictions.")
+		return
+	}
+	if req.Intent != nil && !req.Intent.valid() {
+		writeError(w, 400, errorInvalidIntent, "Invalid secure authentication intent.")
+		return
+	}
+	var solved bool
+	if account.Captcha != "" {
+		if solved = s.solvedCaptcha(r, account, req.CaptchaToken, req.CaptchaID); !solved {
+			s.challengeCaptcha(w)
+			return
+		}
+	}
+	s.lastLogin = LoginRecord{Intent: req.Intent != nil, Captcha: solved}
+	if m := req.DeviceMeta; m != nil {
+		s.lastLogin.DeviceMeta = true
+		s.lastLogin.DeviceType, s.lastLogin.OS, s.lastLogin.DeviceID = m.DeviceType, m.OS, m.DeviceID
+	}
+	resp := map[string]interface{}{
+		"user": userModel{ID: account.ID, Name: account.Name},
+	}
+	for _, action := range account.PendingActions {
+		switch action {
+		case rbxauth.PendingPasswordReset:
+			resp["shouldResetPassword"] = true
+		case rbxauth.PendingTermsAcceptance:
+			resp["shouldAcceptTerms"] = true
+		case rbxauth.PendingEmailVerification:
+			resp["shouldUpdateEmail"] = true
+		}
+	}
+	if account.Question != nil {
+		id := randomString()
+		s.questions[id] = &questionChallenge{account: account}
+		resp["securityQuestionChallengeId"] = id
+	} else if account.Approval {
+		ticket := randomString()
+		s.pending[ticket] = &approval{account: account}
+		resp["identityVerificationLoginTicket"] = ticket
+	} else if account.TwoStep && !s.remembered(r, account) {
+		resp["twoStepVerificationData"] = map[string]string{
+			"mediaType": account.MediaType,
+			"ticket":    s.startTwoStep(account),
+		}
+	} else {
+		s.startSession(w, account)
+	}
+	writeJSON(w, 200, resp)
+}
+
+func (s *Server) logout(w http.ResponseWriter, r *http.Request) {
+	value, account := s.session(r)
+	if account == nil {
+		writeError(w, 401, 0, "Authorization has been denied for this request.")
+		return
+	}
+	delete(s.sessions, value)
+	writeJSON(w, 200, struct{}{})
+}
+
+func (s *Server) logoutAll(w http.ResponseWriter, r *http.Request) {
+	_, account := s.session(r)
+	if account == nil {
+		writeError(w, 401, 0, "Authorization has been denied for this request.")
+		return
+	}
+	for value, a := range s.sessions {
+		if a == account {
+			delete(s.sessions, value)
+		}
+	}
+	s.startSession(w, account)
+	writeJSON(w, 200, struct{}{})
+}
+
+func (s *Server) verify(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Username       string `json:"username"`
+		Ticket         string `json:"ticket"`
+		Code           string `json:"code"`
+		RememberDevice bool   `json:"rememberDevice"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, 400, 0, "BadRequest")
+		return
+	}
+	account := s.tickets[req.Ticket]
+	if account == nil || account.Name != req.Username {
+		writeError(w, 400, errorInvalidTicket, "Invalid two step verification ticket.")
+		return
+	}
+	if s.attempts[req.Ticket] >= MaxCodeAttempts {
+		writeError(w, 429, errorCodeAttempts, "Too many attempts. Please wait a bit.")
+		return
+	}
+	if account.Code != req.Code {
+		s.attempts[req.Ticket]++
+		writeError(w, 400, errorInvalidCode, "Invalid two step verification code.")
+		return
+	}
+	delete(s.tickets, req.Ticket)
+	delete(s.attempts, req.Ticket)
+	s.lastVerify = VerifyRecord{Code: req.Code, RememberDevice: req.RememberDevice}
+	if req.RememberDevice {
+		s.rememberDevice(w, account)
+	}
+	s.startSession(w, account)
+	writeJSON(w, 200, struct{}{})
+}
+
+func (s *Server) resend(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Username string `json:"username"`
+		Ticket   string `json:"ticket"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, 400, 0, "BadRequest")
+		return
+	}
+	account := s.tickets[req.Ticket]
+	if account == nil || account.Name != req.Username {
+		writeError(w, 400, errorInvalidTicket, "Invalid two step verification ticket.")
+		return
+	}
+	delete(s.tickets, req.Ticket)
+	writeJSON(w, 200, map[string]string{
+		"mediaType": account.MediaType,
+		"ticket":    s.startTwoStep(account),
+	})
+}
+
+// accountByID returns the account with the given ID.
+func (s *Server) accountByID(id int64) *Account {
+	for _, a := range s.accounts {
+		if a.ID == id {
+			return a
+		}
+	}
+	return nil
+}
+
+func (s *Server) userID(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, UserIDPath), 10, 64)
+	if err != nil {
+		writeError(w, 400, 0, "BadRequest")
+		return
+	}
+	account := s.accountByID(id)
+	if account == nil {
+		writeError(w, 404, 0, "NotFound")
+		return
+	}
+	writeJSON(w, 200, map[string]interface{}{
+		"id":          account.ID,
+		"name":        account.Name,
+		"displayName": account.Name,
+		"isBanned":    account.Banned,
+	})
+}
+
+func (s *Server) authenticated(w http.ResponseWriter, r *http.Request) {
+	value, account := s.session(r)
+	if account == nil {
+		writeError(w, 401, 0, "Authorization has been denied for this request.")
+		return
+	}
+	if s.RotateSessions {
+		delete(s.sessions, value)
+		s.startSession(w, account)
+	}
+	writeJSON(w, 200, map[string]interface{}{
+		"id":          account.ID,
+		"name":        account.Name,
+		"displayName": account.Name,
+	})
+}
+
+func (s *Server) usernames(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Usernames []string `json:"usernames"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, 400, 0, "BadRequest")
+		return
+	}
+	data := []map[string]interface{}{}
+	for _, name := range req.Usernames {
+		for _, a := range s.accounts {
+			if strings.EqualFold(a.Name, name) {
+				data = append(data, map[string]interface{}{
+					"requestedUsername": name,
+					"id":                a.ID,
+					"name":              a.Name,
+					"displayName":       a.Name,
+				})
+				break
+			}
+		}
+	}
+	writeJSON(w, 200, map[string]interface{}{"data": data})
+}
+
+// challenge handles {ChallengePath}{id}/challenges/{media}/{action}.
+func (s *Server) challenge(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, ChallengePath), "/")
+	if len(parts) != 4 {
+		writeError(w, 404, 0, "NotFound")
+		return
+	}
+	id, _ := strconv.ParseInt(parts[0], 10, 64)
+	var req struct {
+		ChallengeID string `json:"challengeId"`
+		Code        string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, 400, 0, "BadRequest")
+		return
+	}
+	account := s.tickets[req.ChallengeID]
+	if account == nil || account.ID != id {
+		writeError(w, 400, errorInvalidChallenge, "Invalid challenge ID.")
+		return
+	}
+	switch parts[3] {
+	case "verify":
+		if s.attempts[req.ChallengeID] >= MaxCodeAttempts {
+			writeError(w, 429, errorChallengeLimit, "Too many requests.")
+			return
+		}
+		if account.Code != req.Code {
+			s.attempts[req.ChallengeID]++
+			writeError(w, 400, errorChallengeCode, "The code is invalid.")
+			return
+		}
+		token := randomString()
+		s.tickets[token] = account
+		writeJSON(w, 200, map[string]string{"verificationToken": token})
+	case "send-code":
+		writeJSON(w, 200, struct{}{})
+	default:
+		writeError(w, 404, 0, "NotFound")
+	}
+}
+
+func (s *Server) twoStepLogin(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ChallengeID       string `json:"challengeId"`
+		VerificationToken string `json:"verificationToken"`
+		RememberDevice    bool   `json:"rememberDevice"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, 400, 0, "BadRequest")
+		return
+	}
+	account := s.tickets[req.VerificationToken]
+	if account == nil || s.tickets[req.ChallengeID] != account {
+		writeError(w, 400, errorInvalidChallenge, "Invalid challenge ID.")
+		return
+	}
+	delete(s.tickets, req.ChallengeID)
+	delete(s.tickets, req.VerificationToken)
+	s.lastVerify = VerifyRecord{Code: account.Code, RememberDevice: req.RememberDevice}
+	if req.RememberDevice {
+		s.rememberDevice(w, account)
+	}
+	s.startSession(w, account)
+	writeJSON(w, 200, struct{}{})
+}
+
+// identityVerification handles {IdentityVerificationPath}/{action}.
+func (s *Server) identityVerification(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Ticket string `json:"ticket"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, 400, 0, "BadRequest")
+		return
+	}
+	pending := s.pending[req.Ticket]
+	if pending == nil {
+		writeError(w, 400, errorInvalidTicket, "Invalid ticket.")
+		return
+	}
+	switch strings.TrimPrefix(r.URL.Path, IdentityVerificationPath+"/") {
+	case "status":
+		pending.polls++
+		switch {
+		case pending.polls <= pending.account.ApproveAfter:
+			writeJSON(w, 200, map[string]string{"status": "Pending"})
+		case pending.account.Deny:
+			delete(s.pending, req.Ticket)
+			writeJSON(w, 200, map[string]string{"status": "Denied"})
+		default:
+			delete(s.pending, req.Ticket)
+			s.startSession(w, pending.account)
+			writeJSON(w, 200, map[string]string{"status": "Approved"})
+		}
+	case "cancel":
+		delete(s.pending, req.Ticket)
+		writeJSON(w, 200, struct{}{})
+	default:
+		writeError(w, 404, 0, "NotFound")
+	}
+}
+
+func (s *Server) unlockPIN(w http.ResponseWriter, r *http.Request) {
+	_, account := s.session(r)
+	if account == nil {
+		writeError(w, 401, 0, "Authorization has been denied for this request.")
+		return
+	}
+	var req struct {
+		PIN string `json:"pin"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, 400, 0, "BadRequest")
+		return
+	}
+	switch {
+	case account.PIN == "":
+		writeError(w, 400, errorPinNotSet, "Account PIN is not set.")
+	case account.pinAttempts >= MaxPINAttempts:
+		writeError(w, 429, errorPinAttempts, "Too many attempts. Please wait a bit.")
+	case account.PIN != req.PIN:
+		account.pinAttempts++
+		writeError(w, 403, errorIncorrectPIN, "Incorrect PIN.")
+	default:
+		account.pinAttempts = 0
+		account.Unlocked = true
+		writeJSON(w, 200, map[string]interface{}{
+			"isEnabled":     true,
+			"unlockedUntil": PINUnlockDuration,
+		})
+	}
+}
+
+func (s *Server) lockPIN(w http.ResponseWriter, r *http.Request) {
+	_, account := s.session(r)
+	if account == nil {
+		writeError(w, 401, 0, "Authorization has been denied for this request.")
+		return
+	}
+	account.Unlocked = false
+	writeJSON(w, 200, struct{}{})
+}
+
+// passwordReason returns the reason password is invalid for an account with
+// the given username, or an empty string if the password is valid.
+func passwordReason(username, password string) (reason, msg string) {
+	switch {
+	case len(password) < MinPasswordLength:
+		return "ShortPassword", "Your password is too short."
+	case strings.EqualFold(password, username):
+		return "PasswordSameAsUsername", "Your password cannot be the same as your username."
+	case strings.EqualFold(password, "password"), strings.EqualFold(password, "12345678"):
+		return "ForbiddenPassword", "Your password is too easy to guess."
+	}
+	return "", ""
+}
+
+func (s *Server) validatePassword(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, 400, 0, "BadRequest")
+		return
+	}
+	reason, msg := passwordReason(req.Username, req.Password)
+	if reason == "" {
+		reason, msg = "ValidPassword", "Password is valid"
+	}
+	writeJSON(w, 200, map[string]string{"code": reason, "message": msg})
+}
+
+func (s *Server) changePassword(w http.ResponseWriter, r *http.Request) {
+	_, account := s.session(r)
+	if account == nil {
+		writeError(w, 401, 0, "Authorization has been denied for this request.")
+		return
+	}
+	var req struct {
+		CurrentPassword string `json:"currentPassword"`
+		NewPassword     string `json:"newPassword"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, 400, 0, "BadRequest")
+		return
+	}
+	if req.CurrentPassword != account.Password {
+		writeError(w, 403, errorWrongPassword, "Your current password is incorrect.")
+		return
+	}
+	if reason, _ := passwordReason(account.Name, req.NewPassword); reason != "" {
+		writeError(w, 400, errorInvalidPassword, "New password is invalid.")
+		return
+	}
+	account.Password = req.NewPassword
+	// Changing the password ends every other session.
+	for value, a := range s.sessions {
+		if a == account {
+			delete(s.sessions, value)
+		}
+	}
+	s.startSession(w, account)
+	writeJSON(w, 200, struct{}{})
+}
+
+func (s *Server) metadata(w http.ResponseWriter, r *http.Request) {
+	meta := s.Metadata
+	if meta == nil {
+		meta = map[string]interface{}{
+			"isUpdateUsernameEnabled": true,
+			"ftuxAvatarAssetMap":      "",
+		}
+	}
+	writeJSON(w, 200, meta)
+}
+
+func (s *Server) createAuthTicket(w http.ResponseWriter, r *http.Request) {
+	_, account := s.session(r)
+	if account == nil {
+		writeError(w, 401, 0, "Authorization has been denied for this request.")
+		return
+	}
+	if r.Header.Get("Referer") == "" {
+		writeError(w, 400, errorMissingReferer, "The request is missing a Referer header.")
+		return
+	}
+	ticket := randomString()
+	s.authTickets[ticket] = &authTicket{account: account}
+	w.Header().Set("Rbx-Authentication-Ticket", ticket)
+	writeJSON(w, 200, struct{}{})
+}
+
+func (s *Server) redeemAuthTicket(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("RBXAuthenticationNegotiation") == "" {
+		writeError(w, 400, errorNegotiation, "The request is missing the RBXAuthenticationNegotiation header.")
+		return
+	}
+	var req struct {
+		AuthenticationTicket string `json:"authenticationTicket"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, 400, 0, "BadRequest")
+		return
+	}
+	t := s.authTickets[req.AuthenticationTicket]
+	switch {
+	case t == nil:
+		writeError(w, 400, errorInvalidAuthTicket, "Authentication ticket is invalid.")
+	case t.expired:
+		writeError(w, 400, errorExpiredAuthTicket, "Authentication ticket has expired.")
+	default:
+		delete(s.authTickets, req.AuthenticationTicket)
+		s.startSession(w, t.account)
+		writeJSON(w, 200, struct{}{})
+	}
+}
+
+// maskEmail masks the local part of an email address, as the API does.
+func maskEmail(email string) string {
+	i := strings.IndexByte(email, '@')
+	if i < 1 {
+		return email
+	}
+	return email[:1] + strings.Repeat("*", i-1) + email[i:]
+}
+
+func (s *Server) email(w http.ResponseWriter, r *http.Request) {
+	_, account := s.session(r)
+	if account == nil {
+		writeError(w, 401, 0, "Authorization has been denied for this request.")
+		return
+	}
+	writeJSON(w, 200, map[string]interface{}{
+		"emailAddress": maskEmail(account.Email),
+		"verified":     account.Email != "" && account.EmailVerified,
+	})
+}
+
+func (s *Server) emailVerify(w http.ResponseWriter, r *http.Request) {
+	_, account := s.session(r)
+	switch {
+	case account == nil:
+		writeError(w, 401, 0, "Authorization has been denied for this request.")
+	case account.Email == "":
+		writeError(w, 400, errorNoEmail, "No email is associated with the account.")
+	case account.emailsSent >= MaxVerificationEmails:
+		writeError(w, 429, errorEmailAttempts, "Too many attempts. Please wait a bit.")
+	default:
+		account.emailsSent++
+		writeJSON(w, 200, struct{}{})
+	}
+}
+
+func (s *Server) socialLogin(w http.ResponseWriter, r *http.Request) {
+	provider := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, SocialLoginPath), "/login")
+	var req struct {
+		AuthorizationCode string `json:"authorizationCode"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, 400, 0, "BadRequest")
+		return
+	}
+	var account *Account
+	for _, a := range s.accounts {
+		if token, ok := a.SocialTokens[provider]; ok && req.AuthorizationCode != "" && token == req.AuthorizationCode {
+			account = a
+			break
+		}
+	}
+	if account == nil {
+		writeError(w, 400, errorInvalidSocial, "The authorization code is invalid.")
+		return
+	}
+	resp := map[string]interface{}{
+		"user": userModel{ID: account.ID, Name: account.Name},
+	}
+	if account.TwoStep && !s.remembered(r, account) {
+		resp["twoStepVerificationData"] = map[string]string{
+			"mediaType": account.MediaType,
+			"ticket":    s.startTwoStep(account),
+		}
+	} else {
+		s.startSession(w, account)
+	}
+	writeJSON(w, 200, resp)
+}
+
+func (s *Server) signup(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Username     string `json:"username"`
+		Password     string `json:"password"`
+		Birthday     string `json:"birthday"`
+		Gender       string `json:"gender"`
+		CaptchaToken string `json:"captchaToken"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, 400, 0, "BadRequest")
+		return
+	}
+	if s.SignupCaptcha != "" && req.CaptchaToken != s.SignupCaptcha {
+		writeError(w, 403, errorCaptchaRequired, "You must pass the robot test before signing up.")
+		return
+	}
+	birthday, err := time.Parse(time.RFC3339, req.Birthday)
+	if err != nil || birthday.After(time.Now()) {
+		writeError(w, 400, errorInvalidBirthday, "Birthday invalid.")
+		return
+	}
+	var id int64
+	for _, a := range s.accounts {
+		if strings.EqualFold(a.Name, req.Username) {
+			writeError(w, 400, errorUsernameTaken, "Username is already in use.")
+			return
+		}
+		if a.ID > id {
+			id = a.ID
+		}
+	}
+	if len(req.Password) < MinPasswordLength {
+		writeError(w, 400, errorSignupPassword, "Password is invalid.")
+		return
+	}
+	account := &Account{
+		ID:        id + 1,
+		Name:      req.Username,
+		Password:  req.Password,
+		MediaType: "Email",
+	}
+	s.accounts = append(s.accounts, account)
+	s.startSession(w, account)
+	writeJSON(w, 200, map[string]interface{}{
+		"userId":         account.ID,
+		"starterPlaceId": 0,
+	})
+}
+
+// usernameCode returns the validation code and message for name.
+func (s *Server) usernameCode(name string) (int, string) {
+	if len(name) < 3 || len(name) > 20 {
+		return 3, "Usernames can be 3 to 20 characters long."
+	}
+	if strings.HasPrefix(name, "_") || strings.HasSuffix(name, "_") {
+		return 4, "Usernames can't start or end with _."
+	}
+	if strings.Count(name, "_") > 1 {
+		return 5, "Usernames can have at most one _."
+	}
+	for _, c := range name {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_') {
+			return 6, "Only a-z, A-Z, 0-9, and _ are allowed."
+		}
+	}
+	for _, a := range s.accounts {
+		if strings.EqualFold(a.Name, name) {
+			return 1, "Username is already in use."
+		}
+	}
+	return 0, "Username is valid."
+}
+
+func (s *Server) validateUsername(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Username string `json:"username"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, 400, 0, "BadRequest")
+		return
+	}
+	code, msg := s.usernameCode(req.Username)
+	writeJSON(w, 200, map[string]interface{}{"code": code, "message": msg})
+}
+
+func (s *Server) recommendUsernames(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Username string `json:"username"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, 400, 0, "BadRequest")
+		return
+	}
+	base := strings.Trim(req.Username, "_")
+	if len(base) > 16 {
+		base = base[:16]
+	}
+	suggestions := []string{}
+	for i := 1; len(suggestions) < 3 && i < 1000; i++ {
+		name := fmt.Sprintf("%s%d", base, i)
+		if code, _ := s.usernameCode(name); code == 0 {
+			suggestions = append(suggestions, name)
+		}
+	}
+	writeJSON(w, 200, map[string]interface{}{
+		"didGenerateNewUsername": true,
+		"suggestedUsernames":     suggestions,
+	})
+}
+
+// secureAuthIntent is the secure authentication intent of a login request.
+type secureAuthIntent struct {
+	ClientPublicKey      string `json:"clientPublicKey"`
+	ClientEpochTimestamp int64  `json:"clientEpochTimestamp"`
+	SAISignature         string `json:"saiSignature"`
+	ServerNonce          string `json:"serverNonce"`
+}
+
+// valid returns whether the signature of the intent is valid.
+func (i *secureAuthIntent) valid() bool {
+	der, err := base64.StdEncoding.DecodeString(i.ClientPublicKey)
+	if err != nil {
+		return false
+	}
+	pub, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return false
+	}
+	key, ok := pub.(*ecdsa.PublicKey)
+	if !ok {
+		return false
+	}
+	sig, err := base64.StdEncoding.DecodeString(i.SAISignature)
+	if err != nil {
+		return false
+	}
+	var rs struct{ R, S *big.Int }
+	if _, err := asn1.Unmarshal(sig, &rs); err != nil {
+		return false
+	}
+	hash := sha256.Sum256([]byte(i.ClientPublicKey + "|" + strconv.FormatInt(i.ClientEpochTimestamp, 10) + "|" + i.ServerNonce))
+	return ecdsa.Verify(key, hash[:], rs.R, rs.S)
+}
+
+func (s *Server) securityQuestions(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ChallengeID string   `json:"challengeId"`
+		Answers     []string `json:"answers"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, 400, 0, "BadRequest")
+		return
+	}
+	challenge := s.questions[req.ChallengeID]
+	if challenge == nil {
+		writeError(w, 400, errorInvalidQuestions, "The challenge is invalid.")
+		return
+	}
+	question := challenge.account.Question
+	switch strings.TrimPrefix(r.URL.Path, SecurityQuestionsPath+"/") {
+	case "questions":
+		writeJSON(w, 200, map[string]interface{}{
+			"prompt":  question.Prompt,
+			"choices": question.Choices,
+		})
+	case "answer":
+		if challenge.attempts >= MaxQuestionAttempts {
+			writeError(w, 429, errorQuestionsLocked, "Too many incorrect answers.")
+			return
+		}
+		if !sameSet(req.Answers, question.Answers) {
+			challenge.attempts++
+			writeError(w, 400, errorWrongAnswer, "The answer is incorrect.")
+			return
+		}
+		delete(s.questions, req.ChallengeID)
+		s.startSession(w, challenge.account)
+		writeJSON(w, 200, struct{}{})
+	default:
+		writeError(w, 404, 0, "NotFound")
+	}
+}
+
+// sameSet returns whether a and b contain the same strings, ignoring order and
+// duplicates.
+func sameSet(a, b []string) bool {
+	set := func(s []string) map[string]bool {
+		m := make(map[string]bool, len(s))
+		for _, v := range s {
+			m[v] = true
+		}
+		return m
+	}
+	sa, sb := set(a), set(b)
+	if len(sa) != len(sb) {
+		return false
+	}
+	for v := range sa {
+		if !sb[v] {
+			return false
+		}
+	}
+	return true
+}
+
+// Headers of the captcha challenge protocol.
+const (
+	challengeIDHeader       = "rblx-challenge-id"
+	challengeTypeHeader     = "rblx-challenge-type"
+	challengeMetadataHeader = "rblx-challenge-metadata"
+)
+
+// challengeCaptcha responds with a new captcha challenge.
+func (s *Server) challengeCaptcha(w http.ResponseWriter) {
+	id, captchaID := randomString(), randomString()
+	s.captchas[id] = captchaID
+	meta, _ := json.Marshal(map[string]string{
+		"unifiedCaptchaId": captchaID,
+		"dataExchangeBlob": "blob-" + captchaID,
+		"actionType":       "Login",
+	})
+	w.Header().Set(challengeIDHeader, id)
+	w.Header().Set(challengeTypeHeader, "captcha")
+	w.Header().Set(challengeMetadataHeader, base64.StdEncoding.EncodeToString(meta))
+	writeError(w, 403, 0, "Challenge is required to authorize the request")
+}
+
+// solvedCaptcha returns whether r contains the solution to a captcha
+// challenge previously issued for account. The challenge is consumed.
+func (s *Server) solvedCaptcha(r *http.Request, account *Account, token, captchaID string) bool {
+	id := r.Header.Get(challengeIDHeader)
+	expected, ok := s.captchas[id]
+	if !ok {
+		return false
+	}
+	delete(s.captchas, id)
+	if r.Header.Get(challengeTypeHeader) != "captcha" {
+		return false
+	}
+	b, err := base64.StdEncoding.DecodeString(r.Header.Get(challengeMetadataHeader))
+	if err != nil {
+		return false
+	}
+	var meta struct {
+		UnifiedCaptchaID string `json:"unifiedCaptchaId"`
+		CaptchaToken     string `json:"captchaToken"`
+		ActionType       string `json:"actionType"`
+	}
+	if json.Unmarshal(b, &meta) != nil {
+		return false
+	}
+	return meta.UnifiedCaptchaID == expected && captchaID == expected &&
+		meta.CaptchaToken == account.Captcha && token == account.Captcha &&
+		meta.ActionType == "Login"
+}
+
+func (s *Server) passwordResetSend(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		TargetType string `json:"targetType"`
+		Target     string `json:"target"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, 400, 0, "BadRequest")
+		return
+	}
+	var accounts []*Account
+	for _, a := range s.accounts {
+		var target string
+		switch req.TargetType {
+		case "Email":
+			target = a.Email
+		case "PhoneNumber":
+			target = a.PhoneNumber
+		}
+		if target != "" && strings.EqualFold(target, req.Target) {
+			accounts = append(accounts, a)
+		}
+	}
+	if len(accounts) == 0 {
+		writeError(w, 400, errorResetUnknown, "No account is associated with the target.")
+		return
+	}
+	for _, a := range accounts {
+		if a.resetsSent >= MaxResetRequests {
+			writeError(w, 429, errorResetLimit, "Too many attempts. Please wait a bit.")
+			return
+		}
+	}
+	for _, a := range accounts {
+		a.resetsSent++
+	}
+	nonce := randomString()
+	s.resets[nonce] = &passwordReset{targetType: req.TargetType, accounts: accounts}
+	writeJSON(w, 200, map[string]string{"nonce": nonce})
+}
+
+func (s *Server) passwordResetVerify(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		TargetType string `json:"targetType"`
+		Nonce      string `json:"nonce"`
+		Code       string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, 400, 0, "BadRequest")
+		return
+	}
+	reset := s.resets[req.Nonce]
+	if reset == nil || reset.targetType != req.TargetType {
+		writeError(w, 400, errorResetTicket, "The ticket is invalid or has expired.")
+		return
+	}
+	if reset.attempts >= MaxCodeAttempts {
+		writeError(w, 429, errorResetLimit, "Too many attempts. Please wait a bit.")
+		return
+	}
+	// Every account associated with the target receives the same code.
+	if code := reset.accounts[0].ResetCode; code == "" || code != req.Code {
+		reset.attempts++
+		writeError(w, 400, errorResetCode, "The code is invalid.")
+		return
+	}
+	delete(s.resets, req.Nonce)
+	type userTicket struct {
+		UserID int64  `json:"userId"`
+		Ticket string `json:"ticket"`
+	}
+	tickets := make([]userTicket, len(reset.accounts))
+	for i, a := range reset.accounts {
+		ticket := randomString()
+		s.resetTickets[ticket] = a
+		tickets[i] = userTicket{UserID: a.ID, Ticket: ticket}
+	}
+	writeJSON(w, 200, map[string]interface{}{"userTickets": tickets})
+}
+
+func (s *Server) passwordReset(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		TargetType       string `json:"targetType"`
+		Ticket           string `json:"ticket"`
+		UserID           int64  `json:"userId"`
+		Password         string `json:"password"`
+		PasswordRepeated string `json:"passwordRepeated"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, 400, 0, "BadRequest")
+		return
+	}
+	account := s.resetTickets[req.Ticket]
+	if account == nil || account.ID != req.UserID {
+		writeError(w, 400, errorResetTicket, "The ticket is invalid or has expired.")
+		return
+	}
+	if reason, _ := passwordReason(account.Name, req.Password); reason != "" || req.Password != req.PasswordRepeated {
+		writeError(w, 400, errorResetPassword, "The new password is invalid.")
+		return
+	}
+	delete(s.resetTickets, req.Ticket)
+	account.Password = req.Password
+	// Resetting the password ends every session.
+	for value, a := range s.sessions {
+		if a == account {
+			delete(s.sessions, value)
+		}
+	}
+	s.startSession(w, account)
+	writeJSON(w, 200, map[string]interface{}{
+		"user": userModel{ID: account.ID, Name: account.Name},
+	})
+}
@@ -0,0 +1,139 @@
+package rbxauthtest
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/anaminus/rbxauth"
+)
+
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	s := NewServer()
+	t.Cleanup(s.Close)
+	s.AddAccount(Account{ID: 1, Name: "alice", Password: "pass"})
+	s.AddAccount(Account{ID: 2, Name: "bob", Password: "pass", TwoStep: true, Code: "123456"})
+	return s
+}
+
+func TestLoginTwoStep(t *testing.T) {
+	s := newTestServer(t)
+	for _, legacy := range []bool{false, true} {
+		cfg := s.Config()
+		cfg.LegacyTwoStep = legacy
+		cookies, step, err := cfg.Login("bob", []byte("pass"))
+		if err != nil {
+			t.Fatalf("legacy=%t: login: %v", legacy, err)
+		}
+		if len(cookies) != 0 || step == nil {
+			t.Fatalf("legacy=%t: expected two-step verification", legacy)
+		}
+		if step.MediaType != rbxauth.MediaEmail {
+			t.Errorf("legacy=%t: expected default media type, got %s", legacy, step.MediaType)
+		}
+		if cookies, err = step.Verify("123456", false); err != nil {
+			t.Fatalf("legacy=%t: verify: %v", legacy, err)
+		}
+		user, err := cfg.Authenticated(cookies)
+		if err != nil {
+			t.Fatalf("legacy=%t: authenticated: %v", legacy, err)
+		}
+		if user.ID != 2 || user.Name != "bob" {
+			t.Errorf("legacy=%t: unexpected user %+v", legacy, *user)
+		}
+		if err := cfg.Logout(cookies); err != nil {
+			t.Errorf("legacy=%t: logout: %v", legacy, err)
+		}
+		if _, err := cfg.Authenticated(cookies); !errors.Is(err, rbxauth.ErrUnauthenticated) {
+			t.Errorf("legacy=%t: session remains after logout: %v", legacy, err)
+		}
+	}
+}
+
+func TestRememberDevice(t *testing.T) {
+	s := newTestServer(t)
+	cfg := s.Config()
+	_, step, err := cfg.Login("bob", []byte("pass"))
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	cookies, err := step.Verify("123456", true)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	var device []*http.Cookie
+	for _, cookie := range cookies {
+		if cookie.Name == DeviceCookieName {
+			device = append(device, cookie)
+		}
+	}
+	if len(device) == 0 {
+		t.Fatal("expected device cookie")
+	}
+	cfg.PersistentCookies = device
+	if _, step, err = cfg.Login("bob", []byte("pass")); err != nil {
+		t.Fatalf("second login: %v", err)
+	}
+	if step != nil {
+		t.Error("remembered device still requires two-step verification")
+	}
+}
+
+func TestWrongPassword(t *testing.T) {
+	s := newTestServer(t)
+	for _, name := range []string{"alice", "nobody"} {
+		_, _, err := s.Config().Login(name, []byte("wrong"))
+		if !errors.Is(err, rbxauth.ErrBadCredentials) {
+			t.Errorf("%s: expected ErrBadCredentials, got %v", name, err)
+		}
+		if !rbxauth.HasCode(err, errorBadCredentials) {
+			t.Errorf("%s: expected code %d, got %v", name, errorBadCredentials, rbxauth.ErrorCodes(err))
+		}
+		if status, _ := rbxauth.HTTPStatus(err); status != 403 {
+			t.Errorf("%s: expected status 403, got %d", name, status)
+		}
+	}
+}
+
+func TestTokenValidation(t *testing.T) {
+	s := newTestServer(t)
+	s.RotateTokens = true
+	cfg := s.Config()
+	for i := 0; i < 2; i++ {
+		if _, _, err := cfg.Login("alice", []byte("pass")); err != nil {
+			t.Fatalf("login %d: %v", i+1, err)
+		}
+	}
+	// One rejected request to obtain the first token, then one request per
+	// login.
+	if n := s.Count(LoginPath); n != 3 {
+		t.Errorf("expected 3 login requests, got %d", n)
+	}
+	if cfg.TokenCache.Get() != s.Token() {
+		t.Error("token of config does not match server")
+	}
+}
+
+func TestFail(t *testing.T) {
+	s := newTestServer(t)
+	s.Fail(LoginPath, 403, 4, "Account has been locked.")
+	cfg := s.Config()
+	if _, _, err := cfg.Login("alice", []byte("pass")); !errors.Is(err, rbxauth.ErrAccountLocked) {
+		t.Errorf("expected ErrAccountLocked, got %v", err)
+	}
+	if _, _, err := cfg.Login("alice", []byte("pass")); err != nil {
+		t.Errorf("failure was not consumed: %v", err)
+	}
+}
+
+func TestUserID(t *testing.T) {
+	s := newTestServer(t)
+	cfg := s.Config()
+	if _, _, err := cfg.LoginID(2, []byte("pass")); err != nil {
+		t.Errorf("login by ID: %v", err)
+	}
+	if _, _, err := cfg.LoginID(3, []byte("pass")); err == nil {
+		t.Error("expected error for unknown ID")
+	}
+}
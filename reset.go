@@ -0,0 +1,247 @@
+package rbxauth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ErrAccountNotFound indicates that no account is associated with the
+// credentials of a password reset.
+var ErrAccountNotFound = errors.New("account not found")
+
+// resetErrorCodes maps error codes returned by the password reset endpoints to
+// a kind.
+//
+//	1: No account is associated with the target.
+//	2: Too many attempts. Please wait a bit. (status 429)
+//	3: The code is invalid.
+//	4: The ticket is invalid or has expired.
+//	5: The new password is invalid.
+var resetErrorCodes = map[int]error{
+	1: ErrAccountNotFound,
+	2: ErrTooManyAttempts,
+	3: ErrInvalidCode,
+	4: ErrStepExpired,
+	5: ErrPasswordWeak,
+}
+
+// ResetStep holds the state of a password reset. A code is sent to the email
+// address or phone number of the account, which is verified with Verify,
+// after which the password is set with SetNewPassword.
+type ResetStep struct {
+	cfg        Config
+	targetType string
+	target     string
+	nonce      string
+	tickets    map[int64]string
+
+	// MediaType indicates the means by which the code was sent.
+	MediaType MediaType
+
+	// UserIDs lists each account associated with the email address or phone
+	// number. Nil until the code is verified.
+	UserIDs []int64
+
+	// UserID is the account whose password is set by SetNewPassword. Verify
+	// sets it to the first of UserIDs, but it may be changed to any other.
+	UserID int64
+
+	// Expires is the time after which the step is no longer valid. The API
+	// does not report the expiration of a reset, so it is estimated as
+	// DefaultStepTTL after the code was sent.
+	Expires time.Time
+}
+
+// Valid returns whether the step has not yet expired. A step with a zero
+// Expires is always valid.
+func (r *ResetStep) Valid() bool {
+	return r.Expires.IsZero() || r.cfg.now().Before(r.Expires)
+}
+
+// Verified returns whether the code has been verified, such that the password
+// can be set.
+func (r *ResetStep) Verified() bool {
+	return r.tickets != nil
+}
+
+// StartPasswordReset begins resetting the password of the account identified
+// by cred, which must be an Email or PhoneNumber. A code is sent to the email
+// address or phone number, which is then passed to ResetStep.Verify.
+//
+// Returns an error matching ErrAccountNotFound if no account is associated
+// with cred, and ErrTooManyAttempts if too many resets were requested.
+func (c Config) StartPasswordReset(cred Cred) (*ResetStep, error) {
+	return c.StartPasswordResetContext(context.Background(), cred)
+}
+
+// StartPasswordResetContext is like StartPasswordReset, but with a context.
+func (c Config) StartPasswordResetContext(ctx context.Context, cred Cred) (step *ResetStep, err error) {
+	defer wrapOp("start password reset", &err)
+	if cred.Type == Auto {
+		cred.Type, _ = DetectCredType(cred.Ident)
+	}
+	r := &ResetStep{cfg: c, targetType: cred.Type, target: cred.Ident}
+	switch cred.Type {
+	case Email:
+		r.MediaType = MediaEmail
+	case PhoneNumber:
+		r.MediaType = MediaSMS
+	default:
+		return nil, fmt.Errorf("credential type %q cannot reset a password", cred.Type)
+	}
+	if err := r.send(ctx); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// send requests that a code be sent to the target.
+func (r *ResetStep) send(ctx context.Context) error {
+	body, _ := json.Marshal(&passwordResetSendRequest{
+		TargetType: r.targetType,
+		Target:     r.target,
+	})
+	endpoint := r.cfg.PasswordResetSendEndpoint
+	if endpoint == "" {
+		endpoint = DefaultPasswordResetSendEndpoint
+	}
+	req, err := r.cfg.newRequest(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	var apiResp passwordResetSendResponse
+	if _, err := r.cfg.requestAPI("reset-send", req, &apiResp); err != nil {
+		return classify(err, resetErrorCodes)
+	}
+	r.nonce = apiResp.Nonce
+	r.tickets = nil
+	r.UserIDs = nil
+	r.UserID = 0
+	r.Expires = r.cfg.now().Add(DefaultStepTTL)
+	return nil
+}
+
+// Resend sends another code, invalidating the previous code. The code must be
+// verified again, even if it was already verified.
+func (r *ResetStep) Resend() error {
+	return r.ResendContext(context.Background())
+}
+
+// ResendContext is like Resend, but with a context.
+func (r *ResetStep) ResendContext(ctx context.Context) (err error) {
+	defer wrapOp("resend", &err)
+	return r.send(ctx)
+}
+
+// Verify receives the code that was sent to complete verification, after
+// which UserIDs is set.
+//
+// If the code is incorrect, the returned error matches ErrInvalidCode, and the
+// step remains usable for another attempt. Otherwise, the error may match
+// ErrTooManyAttempts or ErrStepExpired.
+func (r *ResetStep) Verify(code string) error {
+	return r.VerifyContext(context.Background(), code)
+}
+
+// VerifyContext is like Verify, but with a context.
+func (r *ResetStep) VerifyContext(ctx context.Context, code string) (err error) {
+	defer wrapOp("verify", &err)
+	if !r.Valid() {
+		return ErrStepExpired
+	}
+
+	body, _ := json.Marshal(&passwordResetVerifyRequest{
+		TargetType: r.targetType,
+		Nonce:      r.nonce,
+		Code:       code,
+	})
+	endpoint := r.cfg.PasswordResetVerifyEndpoint
+	if endpoint == "" {
+		endpoint = DefaultPasswordResetVerifyEndpoint
+	}
+	req, err := r.cfg.newRequest(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	var apiResp passwordResetVerifyResponse
+	if _, err := r.cfg.requestAPI("reset-verify", req, &apiResp); err != nil {
+		return classify(err, resetErrorCodes)
+	}
+	if len(apiResp.UserTickets) == 0 {
+		return ErrAccountNotFound
+	}
+	r.tickets = make(map[int64]string, len(apiResp.UserTickets))
+	r.UserIDs = make([]int64, len(apiResp.UserTickets))
+	for i, t := range apiResp.UserTickets {
+		r.tickets[t.UserID] = t.Ticket
+		r.UserIDs[i] = t.UserID
+	}
+	r.UserID = r.UserIDs[0]
+	return nil
+}
+
+// SetNewPassword sets the password of the account selected by UserID, after
+// the code has been verified. If successful, returns HTTP cookies
+// representing a session of the account. If Config.WipePassword is set, then
+// password is wiped.
+//
+// Returns an error matching ErrPasswordWeak if the password is not acceptable,
+// in which case another password may be set.
+func (r *ResetStep) SetNewPassword(password []byte) ([]*http.Cookie, error) {
+	return r.SetNewPasswordContext(context.Background(), password)
+}
+
+// SetNewPasswordContext is like SetNewPassword, but with a context.
+func (r *ResetStep) SetNewPasswordContext(ctx context.Context, password []byte) (cookies []*http.Cookie, err error) {
+	defer wrapOp("reset password", &err)
+	if r.cfg.WipePassword {
+		defer wipe(password)
+	}
+	if !r.Valid() {
+		return nil, ErrStepExpired
+	}
+	if !r.Verified() {
+		return nil, errors.New("code has not been verified")
+	}
+	ticket, ok := r.tickets[r.UserID]
+	if !ok {
+		return nil, fmt.Errorf("user %d is not associated with the reset", r.UserID)
+	}
+
+	apiReq := passwordResetRequest{
+		TargetType: r.targetType,
+		Ticket:     ticket,
+		UserID:     r.UserID,
+		Password:   password,
+	}
+	body, _ := apiReq.MarshalJSON()
+	defer wipe(body)
+
+	endpoint := r.cfg.PasswordResetEndpoint
+	if endpoint == "" {
+		endpoint = DefaultPasswordResetEndpoint
+	}
+	req, err := r.cfg.newRequest(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := r.cfg.requestAPI("reset-password", req, &errorsResponse{})
+	if err != nil {
+		return nil, classify(err, resetErrorCodes)
+	}
+	return responseCookies(resp), nil
+}
@@ -0,0 +1,250 @@
+package rbxauth_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anaminus/rbxauth"
+	"github.com/anaminus/rbxauth/rbxauthtest"
+)
+
+// resetAccount is an account whose password can be reset.
+var resetAccount = rbxauthtest.Account{
+	ID: 1, Name: "alice", Password: "pass",
+	Email: "alice@example.com", PhoneNumber: "5551234567",
+	ResetCode: "654321",
+}
+
+func TestPasswordReset(t *testing.T) {
+	srv := newServer(t, resetAccount)
+	cfg := srv.Config()
+	old, _, err := cfg.Login("alice", []byte("pass"))
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+
+	step, err := cfg.StartPasswordReset(rbxauth.Cred{Type: rbxauth.Email, Ident: "alice@example.com"})
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if step.MediaType != rbxauth.MediaEmail {
+		t.Errorf("expected media type %s, got %s", rbxauth.MediaEmail, step.MediaType)
+	}
+	if step.Verified() || step.UserIDs != nil || !step.Valid() {
+		t.Errorf("unexpected step before verify: %+v", step)
+	}
+	if err := step.Verify("654321"); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if !step.Verified() || len(step.UserIDs) != 1 || step.UserIDs[0] != 1 || step.UserID != 1 {
+		t.Errorf("unexpected step after verify: %+v", step)
+	}
+	cookies, err := step.SetNewPassword([]byte("correct horse"))
+	if err != nil {
+		t.Fatalf("set password: %v", err)
+	}
+	checkSession(t, cfg, cookies, rbxauth.UserInfo{ID: 1, Name: "alice"})
+
+	// The reset ends previous sessions, and replaces the password.
+	if _, err := cfg.Authenticated(old); !errors.Is(err, rbxauth.ErrUnauthenticated) {
+		t.Errorf("old session: expected ErrUnauthenticated, got %v", err)
+	}
+	if _, _, err := cfg.Login("alice", []byte("pass")); !errors.Is(err, rbxauth.ErrBadCredentials) {
+		t.Errorf("old password: expected ErrBadCredentials, got %v", err)
+	}
+	if _, _, err := cfg.Login("alice", []byte("correct horse")); err != nil {
+		t.Errorf("new password: %v", err)
+	}
+
+	// A phone number is detected, and the code is sent by SMS.
+	step, err = cfg.StartPasswordReset(rbxauth.Cred{Type: rbxauth.Auto, Ident: "5551234567"})
+	if err != nil {
+		t.Fatalf("phone: start: %v", err)
+	}
+	if step.MediaType != rbxauth.MediaSMS {
+		t.Errorf("phone: expected media type %s, got %s", rbxauth.MediaSMS, step.MediaType)
+	}
+	if err := step.Verify("654321"); err != nil {
+		t.Fatalf("phone: verify: %v", err)
+	}
+	if _, err := step.SetNewPassword([]byte("battery staple")); err != nil {
+		t.Fatalf("phone: set password: %v", err)
+	}
+}
+
+func TestPasswordResetMultipleAccounts(t *testing.T) {
+	srv := newServer(t, resetAccount)
+	srv.AddAccount(rbxauthtest.Account{
+		ID: 2, Name: "alice2", Password: "pass",
+		Email: "alice@example.com", ResetCode: "654321",
+	})
+	cfg := srv.Config()
+
+	step, err := cfg.StartPasswordReset(rbxauth.Cred{Type: rbxauth.Email, Ident: "alice@example.com"})
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if err := step.Verify("654321"); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if len(step.UserIDs) != 2 {
+		t.Fatalf("expected two accounts, got %v", step.UserIDs)
+	}
+
+	// Only an associated account can be selected.
+	step.UserID = 3
+	if _, err := step.SetNewPassword([]byte("correct horse")); err == nil {
+		t.Error("unassociated: expected error")
+	}
+	step.UserID = 2
+	cookies, err := step.SetNewPassword([]byte("correct horse"))
+	if err != nil {
+		t.Fatalf("set password: %v", err)
+	}
+	checkSession(t, cfg, cookies, rbxauth.UserInfo{ID: 2, Name: "alice2"})
+	if _, _, err := cfg.Login("alice", []byte("pass")); err != nil {
+		t.Errorf("other account: %v", err)
+	}
+}
+
+func TestPasswordResetErrors(t *testing.T) {
+	srv := newServer(t, resetAccount)
+	cfg := srv.Config()
+	email := rbxauth.Cred{Type: rbxauth.Email, Ident: "alice@example.com"}
+
+	_, err := cfg.StartPasswordReset(rbxauth.Cred{Type: rbxauth.Email, Ident: "bob@example.com"})
+	if !errors.Is(err, rbxauth.ErrAccountNotFound) {
+		t.Errorf("unknown account: expected ErrAccountNotFound, got %v", err)
+	}
+	before := srv.Count(rbxauthtest.PasswordResetSendPath)
+	if _, err := cfg.StartPasswordReset(rbxauth.Cred{Type: "Username", Ident: "alice"}); err == nil {
+		t.Error("username: expected error")
+	}
+	if n := srv.Count(rbxauthtest.PasswordResetSendPath) - before; n != 0 {
+		t.Errorf("username: expected no request, got %d", n)
+	}
+
+	// An incorrect code leaves the step usable.
+	step, err := cfg.StartPasswordReset(email)
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if _, err := step.SetNewPassword([]byte("correct horse")); err == nil {
+		t.Error("unverified: expected error")
+	}
+	if err := step.Verify("000000"); !errors.Is(err, rbxauth.ErrInvalidCode) {
+		t.Errorf("invalid code: expected ErrInvalidCode, got %v", err)
+	}
+	if err := step.Verify("654321"); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	// A weak password can be replaced.
+	if _, err := step.SetNewPassword([]byte("short")); !errors.Is(err, rbxauth.ErrPasswordWeak) {
+		t.Errorf("weak: expected ErrPasswordWeak, got %v", err)
+	}
+	if _, err := step.SetNewPassword([]byte("correct horse")); err != nil {
+		t.Fatalf("set password: %v", err)
+	}
+	// The ticket is used only once.
+	if _, err := step.SetNewPassword([]byte("battery staple")); !errors.Is(err, rbxauth.ErrStepExpired) {
+		t.Errorf("reused: expected ErrStepExpired, got %v", err)
+	}
+
+	// Repeated incorrect codes lock the step.
+	step, err = cfg.StartPasswordReset(email)
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	for i := 0; i < rbxauthtest.MaxCodeAttempts; i++ {
+		step.Verify("000000")
+	}
+	if err := step.Verify("654321"); !errors.Is(err, rbxauth.ErrTooManyAttempts) {
+		t.Errorf("code attempts: expected ErrTooManyAttempts, got %v", err)
+	}
+
+	// An expired step is rejected without a request.
+	var clock fakeClock
+	clock.install(&cfg)
+	step, err = cfg.StartPasswordReset(email)
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	cfg.Sleep(context.Background(), rbxauth.DefaultStepTTL+time.Second)
+	if step.Valid() {
+		t.Error("expired: expected invalid step")
+	}
+	before = srv.Count(rbxauthtest.PasswordResetVerifyPath)
+	if err := step.Verify("654321"); !errors.Is(err, rbxauth.ErrStepExpired) {
+		t.Errorf("expired: expected ErrStepExpired, got %v", err)
+	}
+	if n := srv.Count(rbxauthtest.PasswordResetVerifyPath) - before; n != 0 {
+		t.Errorf("expired: expected no request, got %d", n)
+	}
+}
+
+func TestPasswordResetRateLimit(t *testing.T) {
+	srv := newServer(t, resetAccount)
+	cfg := srv.Config()
+	email := rbxauth.Cred{Type: rbxauth.Email, Ident: "alice@example.com"}
+	for i := 0; i < rbxauthtest.MaxResetRequests; i++ {
+		if _, err := cfg.StartPasswordReset(email); err != nil {
+			t.Fatalf("start %d: %v", i, err)
+		}
+	}
+	_, err := cfg.StartPasswordReset(email)
+	if !errors.Is(err, rbxauth.ErrTooManyAttempts) {
+		t.Errorf("expected ErrTooManyAttempts, got %v", err)
+	}
+	if code, ok := rbxauth.HTTPStatus(err); !ok || code != 429 {
+		t.Errorf("expected status 429, got %v", err)
+	}
+}
+
+func TestStreamPasswordReset(t *testing.T) {
+	srv := newServer(t, resetAccount)
+	cfg := srv.Config()
+
+	// The type and identifier are prompted, an incorrect code and a weak
+	// password are retried, and the new password is confirmed.
+	input := strings.Join([]string{
+		"email",
+		"alice@example.com",
+		"000000",
+		"654321",
+		"short", "short",
+		"correct horse", "correct horse",
+	}, "\n") + "\n"
+	var out strings.Builder
+	s := &rbxauth.Stream{Config: cfg, Reader: strings.NewReader(input), Writer: &out, Quiet: true}
+	cred, cookies, err := s.PromptPasswordReset(rbxauth.Cred{})
+	if err != nil {
+		t.Fatalf("%v\n%s", err, out.String())
+	}
+	if want := (rbxauth.Cred{Type: rbxauth.Email, Ident: "alice@example.com"}); cred != want {
+		t.Errorf("expected cred %+v, got %+v", want, cred)
+	}
+	checkSession(t, cfg, cookies, rbxauth.UserInfo{ID: 1})
+	for _, msg := range []string{
+		rbxauth.DefaultMessages.ResetCodeSentEmail,
+		rbxauth.DefaultMessages.IncorrectCode,
+		rbxauth.DefaultMessages.PasswordNotAcceptable,
+	} {
+		if !strings.Contains(out.String(), msg) {
+			t.Errorf("expected message %q, got\n%s", msg, out.String())
+		}
+	}
+	if _, _, err := cfg.Login("alice", []byte("correct horse")); err != nil {
+		t.Errorf("new password: %v", err)
+	}
+
+	// Failures end the prompt.
+	s = &rbxauth.Stream{Config: cfg, Reader: strings.NewReader("bob@example.com\n"), Writer: ioutil.Discard, Quiet: true}
+	if _, _, err := s.PromptPasswordReset(rbxauth.Cred{Type: rbxauth.Email}); !errors.Is(err, rbxauth.ErrAccountNotFound) {
+		t.Errorf("unknown account: expected ErrAccountNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+package rbxauth
+
+import (
+	"context"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sync"
+)
+
+// Session represents an authenticated session. It holds the cookies of the
+// session within a cookie jar, so that the session can be used to make
+// further requests.
+type Session struct {
+	cfg  Config
+	jar  *sessionJar
+	user *UserInfo
+}
+
+// NewSession returns a Session from a list of cookies, such as those returned
+// by LoginCred or ReadCookies. The Config is used to make subsequent requests
+// with the session.
+func NewSession(cfg Config, cookies []*http.Cookie) *Session {
+	jar, _ := cookiejar.New(nil)
+	s := &Session{
+		cfg: cfg,
+		jar: &sessionJar{jar: jar},
+	}
+	endpoint := cfg.LoginEndpoint
+	if endpoint == "" {
+		endpoint = DefaultLoginEndpoint
+	}
+	if u, err := url.Parse(endpoint); err == nil {
+		s.jar.SetCookies(u, cookies)
+	} else {
+		s.jar.merge(cookies)
+	}
+	return s
+}
+
+// Cookies returns the cookies of the session. Cookies set by responses to
+// requests made through the session's client are included.
+func (s *Session) Cookies() []*http.Cookie {
+	return s.jar.list()
+}
+
+// User returns the user authenticated by the session, if known. Returns nil if
+// the user is not known, in which case Config.Authenticated may be used to
+// retrieve it.
+func (s *Session) User() *UserInfo {
+	return s.user
+}
+
+// Jar returns the cookie jar containing the session's cookies.
+func (s *Session) Jar() http.CookieJar {
+	return s.jar
+}
+
+// Client returns an HTTP client that attaches the session's cookies to each
+// request. The client is derived from the session's Config, as returned by
+// Config.HTTPClient, so that it shares the connections of the Config.
+func (s *Session) Client() *http.Client {
+	var client http.Client
+	if base, err := s.cfg.httpClient(); err == nil {
+		client = *base
+	}
+	client.Jar = s.jar
+	return &client
+}
+
+// Logout logs out of the session. Like Config.Logout, returns an error
+// matching ErrAlreadyLoggedOut if the session is not valid.
+func (s *Session) Logout() error {
+	return s.LogoutContext(context.Background())
+}
+
+// LogoutContext is like Logout, but with a context.
+func (s *Session) LogoutContext(ctx context.Context) error {
+	return s.cfg.LogoutContext(ctx, s.Cookies())
+}
+
+// LogoutAll logs out of every other session of the account, and
+// reauthenticates the session with the reissued cookies.
+func (s *Session) LogoutAll() error {
+	return s.LogoutAllContext(context.Background())
+}
+
+// LogoutAllContext is like LogoutAll, but with a context.
+func (s *Session) LogoutAllContext(ctx context.Context) error {
+	cookies, err := s.cfg.LogoutAllContext(ctx, s.Cookies())
+	if err != nil {
+		return err
+	}
+	s.jar.merge(cookies)
+	return nil
+}
+
+// sessionJar wraps a cookie jar, retaining the full attributes of each cookie
+// set, which are otherwise lost when retrieved from the jar.
+type sessionJar struct {
+	jar     http.CookieJar
+	mu      sync.Mutex
+	cookies []*http.Cookie
+}
+
+// SetCookies implements the http.CookieJar interface.
+func (j *sessionJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.jar.SetCookies(u, AsJarCookies(u, cookies))
+	j.merge(cookies)
+}
+
+// Cookies implements the http.CookieJar interface.
+func (j *sessionJar) Cookies(u *url.URL) []*http.Cookie {
+	return j.jar.Cookies(u)
+}
+
+// merge adds cookies to the list, replacing existing cookies with the same
+// name, domain, and path.
+func (j *sessionJar) merge(cookies []*http.Cookie) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+loop:
+	for _, cookie := range cookies {
+		for i, c := range j.cookies {
+			if c.Name == cookie.Name && c.Domain == cookie.Domain && c.Path == cookie.Path {
+				j.cookies[i] = cookie
+				continue loop
+			}
+		}
+		j.cookies = append(j.cookies, cookie)
+	}
+}
+
+// list returns a copy of the list of cookies.
+func (j *sessionJar) list() []*http.Cookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	cookies := make([]*http.Cookie, len(j.cookies))
+	copy(cookies, j.cookies)
+	return cookies
+}
@@ -0,0 +1,84 @@
+package rbxauth
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// cookieTimeLayouts are the layouts of an Expires attribute that are parsed in
+// addition to those parsed by the http package. These include each format of
+// a date permitted by RFC 7231, and their variants with two-digit years.
+var cookieTimeLayouts = []string{
+	time.RFC1123,
+	"Mon, 02 Jan 06 15:04:05 MST",
+	time.RFC850,
+	"Monday, 02-Jan-2006 15:04:05 MST",
+	"Mon, 02-Jan-06 15:04:05 MST",
+	time.ANSIC,
+}
+
+// responseCookies returns the cookies set by resp. Unlike resp.Cookies, the
+// Expires attribute is parsed from any date format permitted by HTTP, so that
+// each attribute of a cookie is retained as sent by the server. Attributes not
+// represented by http.Cookie remain in the Unparsed field, and are written by
+// WriteCookies.
+func responseCookies(resp *http.Response) []*http.Cookie {
+	cookies := resp.Cookies()
+	for _, cookie := range cookies {
+		parseExpires(cookie)
+	}
+	return cookies
+}
+
+// parseExpires sets the Expires field of cookie from its Raw field, if the
+// http package did not recognize the format of the attribute. Once parsed, the
+// attribute is removed from the Unparsed field.
+func parseExpires(cookie *http.Cookie) {
+	if !cookie.Expires.IsZero() {
+		return
+	}
+	attrs := strings.Split(cookie.Raw, ";")
+	for _, attr := range attrs[1:] {
+		attr = strings.TrimSpace(attr)
+		eq := strings.IndexByte(attr, '=')
+		if eq < 0 || !strings.EqualFold(strings.TrimSpace(attr[:eq]), "expires") {
+			continue
+		}
+		value := strings.TrimSpace(attr[eq+1:])
+		for _, layout := range cookieTimeLayouts {
+			t, err := time.Parse(layout, value)
+			if err != nil {
+				continue
+			}
+			cookie.Expires = t.UTC()
+			unparsed := cookie.Unparsed[:0]
+			for _, u := range cookie.Unparsed {
+				if strings.TrimSpace(u) != attr {
+					unparsed = append(unparsed, u)
+				}
+			}
+			cookie.Unparsed = unparsed
+			return
+		}
+	}
+}
+
+// formatCookie returns cookie formatted as the value of a Set-Cookie header.
+// Unlike cookie.String, attributes in the Unparsed field are included, as is a
+// SameSite attribute without a value, which is read as SameSiteDefaultMode.
+func formatCookie(cookie *http.Cookie) string {
+	s := cookie.String()
+	if s == "" {
+		return s
+	}
+	if cookie.SameSite == http.SameSiteDefaultMode {
+		s += "; SameSite"
+	}
+	for _, attr := range cookie.Unparsed {
+		if attr = strings.TrimSpace(attr); attr != "" && !strings.ContainsAny(attr, ";\r\n") {
+			s += "; " + attr
+		}
+	}
+	return s
+}
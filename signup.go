@@ -0,0 +1,143 @@
+package rbxauth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Genders accepted by SignupRequest.
+const (
+	GenderUnknown = "Unknown"
+	GenderMale    = "Male"
+	GenderFemale  = "Female"
+)
+
+// These errors classify the reasons a sign-up is rejected.
+var (
+	ErrUsernameTaken   = errors.New("username already taken")
+	ErrInvalidBirthday = errors.New("invalid birthday")
+)
+
+// signupErrorCodes maps error codes returned by the sign-up endpoint to a
+// kind. Code 2 (captcha required) is handled by CaptchaError.
+//
+//	3: Invalid birthday.
+//	6: Username is already in use.
+//	7: Password does not meet requirements.
+var signupErrorCodes = map[int]error{
+	3: ErrInvalidBirthday,
+	6: ErrUsernameTaken,
+	7: ErrPasswordWeak,
+}
+
+// SignupRequest specifies the account created by Signup.
+type SignupRequest struct {
+	// Username is the name of the new account.
+	Username string
+	// Password is the password of the new account. It is wiped after the
+	// request if Config.WipePassword is set.
+	Password []byte
+	// Birthday is the date of birth of the user. Only the date is used.
+	Birthday time.Time
+	// Gender is one of the Gender constants. If empty, the gender is not
+	// specified.
+	Gender string
+	// AgreementIDs lists the IDs of the agreements (such as the terms of
+	// service and privacy policy) accepted by the user. The API requires the
+	// IDs of the current agreements.
+	AgreementIDs []string
+	// CaptchaToken is the token obtained from solving a captcha challenge.
+	CaptchaToken string
+	// CaptchaProvider is the provider of the captcha. If empty while
+	// CaptchaToken is set, CaptchaProviderArkoseLabs is used.
+	CaptchaProvider string
+}
+
+// SignupResult contains the result of a successful sign-up.
+type SignupResult struct {
+	// UserID is the ID of the new account.
+	UserID int64
+	// StarterPlaceID is the ID of the place created for the new account, or
+	// 0 if the API did not report one.
+	StarterPlaceID int64
+}
+
+// Signup creates a new account, returning the result along with the HTTP
+// cookies representing a session of the new account.
+//
+// The production site requires a captcha to be solved for every sign-up, so
+// req.CaptchaToken must be set in practice. Without it, the returned error
+// will contain a *CaptchaError. Sign-ups without a captcha are generally only
+// possible against test sites.
+func (c Config) Signup(req SignupRequest) (result *SignupResult, cookies []*http.Cookie, err error) {
+	return c.SignupContext(context.Background(), req)
+}
+
+// SignupContext is like Signup, but with a context.
+func (c Config) SignupContext(ctx context.Context, req SignupRequest) (result *SignupResult, cookies []*http.Cookie, err error) {
+	defer wrapOp("signup", &err)
+	if c.WipePassword {
+		defer wipe(req.Password)
+	}
+	if req.Username == "" {
+		return nil, nil, errors.New("missing username")
+	}
+	if req.Birthday.IsZero() {
+		return nil, nil, errors.New("missing birthday")
+	}
+
+	apiReq := signupRequest{
+		Username:     req.Username,
+		Password:     req.Password,
+		Birthday:     formatBirthday(req.Birthday),
+		Gender:       req.Gender,
+		IsTosAgreed:  true,
+		AgreementIDs: req.AgreementIDs,
+	}
+	if apiReq.AgreementIDs == nil {
+		apiReq.AgreementIDs = []string{}
+	}
+	if req.CaptchaToken != "" {
+		apiReq.CaptchaToken = req.CaptchaToken
+		apiReq.CaptchaProvider = req.CaptchaProvider
+		if apiReq.CaptchaProvider == "" {
+			apiReq.CaptchaProvider = CaptchaProviderArkoseLabs
+		}
+	}
+	// Marshal directly to avoid copying the password.
+	body, err := apiReq.MarshalJSON()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer wipe(body)
+
+	endpoint := c.SignupEndpoint
+	if endpoint == "" {
+		endpoint = DefaultSignupEndpoint
+	}
+	httpReq, err := c.newRequest(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "application/json")
+
+	var apiResp signupResponse
+	resp, err := c.requestAPI("signup", httpReq, &apiResp)
+	if err != nil {
+		return nil, nil, classify(ifCaptcha(err), signupErrorCodes)
+	}
+	result = &SignupResult{
+		UserID:         apiResp.UserID,
+		StarterPlaceID: apiResp.StarterPlaceID,
+	}
+	return result, responseCookies(resp), nil
+}
+
+// formatBirthday formats the date of t as expected by the API.
+func formatBirthday(t time.Time) string {
+	return t.Format("2006-01-02") + "T00:00:00.000Z"
+}
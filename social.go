@@ -0,0 +1,89 @@
+package rbxauth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Social providers accepted by LoginSocial. Other providers are passed to the
+// API as given.
+const (
+	SocialXbox = "Xbox"
+)
+
+// ErrInvalidSocialToken indicates that the token given to LoginSocial was
+// rejected by the provider.
+var ErrInvalidSocialToken = errors.New("invalid social token")
+
+// socialLoginErrorCodes maps error codes returned by the social login endpoint
+// to a kind.
+//
+//	1: The authorization code is invalid.
+//	4: Account has been locked. Please request a password reset.
+//	7: Too many attempts. Please wait a bit. (status 429)
+var socialLoginErrorCodes = map[int]error{
+	1: ErrInvalidSocialToken,
+	4: ErrAccountLocked,
+	7: ErrTooManyAttempts,
+}
+
+// LoginSocial attempts to authenticate a user through a social provider, such
+// as SocialXbox, instead of a password. token is the authorization code
+// received from the provider.
+//
+// Like LoginCred, a list of HTTP cookies representing the session are
+// returned, or a Step if multi-step authentication is required.
+func (c Config) LoginSocial(provider, token string) (cookies []*http.Cookie, step *Step, err error) {
+	return c.LoginSocialContext(context.Background(), provider, token)
+}
+
+// LoginSocialContext is like LoginSocial, but with a context.
+func (c Config) LoginSocialContext(ctx context.Context, provider, token string) (cookies []*http.Cookie, step *Step, err error) {
+	defer wrapOp("social login", &err)
+	if provider == "" {
+		return nil, nil, errors.New("missing provider")
+	}
+
+	body, err := json.Marshal(socialLoginRequest{AuthorizationCode: token})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	endpoint := c.SocialLoginEndpoint
+	if endpoint == "" {
+		endpoint = DefaultSocialLoginEndpoint
+	}
+	req, err := c.newRequest(ctx, "POST", fmt.Sprintf(endpoint, url.PathEscape(provider)), bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	for _, cookie := range c.PersistentCookies {
+		req.AddCookie(cookie)
+	}
+
+	var apiResp loginResponse
+	resp, err := c.requestAPI("social-login", req, &apiResp)
+	if err != nil {
+		return nil, nil, classify(err, socialLoginErrorCodes)
+	}
+
+	var username string
+	if apiResp.User != nil {
+		username = apiResp.User.Name
+	}
+	result := c.newLoginResult(resp, &apiResp, username)
+	if result.Challenge != nil {
+		return nil, nil, ErrChallengeRequired
+	}
+	if result.Questions != nil {
+		return nil, nil, ErrQuestionsRequired
+	}
+	return result.Cookies, result.Step, nil
+}
@@ -2,18 +2,202 @@ package rbxauth
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
 )
 
+// MediaType is the means by which a two-step verification code is delivered.
+// Values other than the MediaType constants may be reported by the API.
+type MediaType string
+
+// Media types reported by the API.
+const (
+	MediaEmail         MediaType = "Email"
+	MediaSMS           MediaType = "SMS"
+	MediaAuthenticator MediaType = "Authenticator"
+	MediaRecoveryCode  MediaType = "RecoveryCode"
+)
+
+// ParseMediaType returns the MediaType named by s. Case is ignored, and
+// common aliases are accepted. Returns false if s does not name a known media
+// type, in which case s is returned unchanged as a MediaType.
+func ParseMediaType(s string) (MediaType, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "email", "e-mail":
+		return MediaEmail, true
+	case "sms", "text", "phone":
+		return MediaSMS, true
+	case "authenticator", "totp", "app":
+		return MediaAuthenticator, true
+	case "recoverycode", "recovery code", "recovery-code", "recovery":
+		return MediaRecoveryCode, true
+	}
+	return MediaType(s), false
+}
+
 // Step holds the state of a multi-step verification action.
 type Step struct {
 	cfg Config
 	req twoStepVerificationVerifyRequest
 
+	// If non-zero, the step uses the challenge API, where the ticket is the
+	// challenge ID, and challenges are keyed by user ID.
+	userID int64
+
+	// The time at which a code was last sent.
+	sent time.Time
+
 	// MediaType indicates the means by which the verification code was sent.
-	MediaType string
+	MediaType MediaType
+
+	// User is the user being authenticated, as reported by the initial login
+	// response. May be nil.
+	User *UserInfo
+
+	// Expires is the time after which the verification ticket is no longer
+	// valid. The API does not report the expiration of a ticket, so it is
+	// estimated as DefaultStepTTL after the ticket was issued.
+	Expires time.Time
+}
+
+// Action types known to require two-step verification. The API may require
+// verification for other actions.
+const (
+	// ActionLogin is the action of logging in.
+	ActionLogin = "Login"
+	// ActionRobuxSpend is the action of spending Robux.
+	ActionRobuxSpend = "RobuxSpend"
+	// ActionGeneric is used for sensitive actions that do not have a
+	// specific type.
+	ActionGeneric = "Generic"
+)
+
+// NewStep returns a Step that completes verification of a ticket received
+// from an API other than login, such as a purchase that requires two-step
+// verification. username is the name of the user performing the action,
+// actionType is the type of action for which the ticket was issued, such as
+// ActionGeneric, and mediaType is the media type reported with the ticket.
+//
+// The ticket is assumed to have been issued just now. Note that the result of
+// Verify only includes cookies if the action produces them, as a login does.
+func NewStep(cfg Config, username, ticket, actionType, mediaType string) (*Step, error) {
+	if actionType == "" {
+		return nil, errors.New("new step: action type is required")
+	}
+	if ticket == "" {
+		return nil, errors.New("new step: ticket is required")
+	}
+	m, _ := ParseMediaType(mediaType)
+	now := cfg.now()
+	return &Step{
+		cfg:       cfg,
+		MediaType: m,
+		Expires:   now.Add(DefaultStepTTL),
+		sent:      now,
+		req: twoStepVerificationVerifyRequest{
+			twoStepVerificationTicketRequest: twoStepVerificationTicketRequest{
+				Username:   username,
+				Ticket:     ticket,
+				ActionType: actionType,
+			},
+		},
+	}, nil
+}
+
+// ActionType returns the type of action verified by the step.
+func (s *Step) ActionType() string {
+	return s.req.ActionType
+}
+
+// DefaultStepTTL is the estimated duration for which a verification ticket is
+// valid after being issued.
+const DefaultStepTTL = 10 * time.Minute
+
+// ErrStepExpired is returned when the verification ticket of a Step has
+// expired.
+var ErrStepExpired = errors.New("verification ticket expired")
+
+// Valid returns whether the step has not yet expired. A step with a zero
+// Expires is always valid.
+func (s *Step) Valid() bool {
+	return s.Expires.IsZero() || s.cfg.now().Before(s.Expires)
+}
+
+// Remaining returns the duration until the step expires, or zero if the step
+// has expired or has no expiration.
+func (s *Step) Remaining() time.Duration {
+	if s.Expires.IsZero() {
+		return 0
+	}
+	if d := s.Expires.Sub(s.cfg.now()); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// ErrResendCooldown is matched by a ResendCooldownError.
+var ErrResendCooldown = errors.New("resend cooldown")
+
+// ResendCooldownError is returned by Step.Resend when a code was sent too
+// recently.
+type ResendCooldownError struct {
+	// RetryAfter is the duration to wait before resending. May be zero if the
+	// API did not report a duration.
+	RetryAfter time.Duration
+
+	err error
+}
+
+// Error implements the error interface.
+func (err *ResendCooldownError) Error() string {
+	msg := "resend cooldown"
+	if err.RetryAfter > 0 {
+		msg = "wait " + err.RetryAfter.Round(time.Second).String() + " before resending"
+	}
+	if err.err != nil {
+		msg += ": " + err.err.Error()
+	}
+	return msg
+}
+
+// Unwrap implements the Unwrap interface.
+func (err *ResendCooldownError) Unwrap() error {
+	return err.err
+}
+
+// Is returns whether target is ErrResendCooldown.
+func (err *ResendCooldownError) Is(target error) bool {
+	return target == ErrResendCooldown
+}
+
+// CanResendAt returns the time after which a code can be resent, according to
+// Config.ResendCooldown.
+func (s *Step) CanResendAt() time.Time {
+	if s.sent.IsZero() {
+		return time.Time{}
+	}
+	cooldown := s.cfg.ResendCooldown
+	if cooldown < 0 {
+		return time.Time{}
+	} else if cooldown == 0 {
+		cooldown = DefaultResendCooldown
+	}
+	return s.sent.Add(cooldown)
+}
+
+// ifCooldown returns a ResendCooldownError if resp, received at now,
+// indicates that too many requests were made. Returns err otherwise.
+func ifCooldown(resp *http.Response, err error, now time.Time) error {
+	if err == nil || resp == nil || resp.StatusCode != http.StatusTooManyRequests {
+		return err
+	}
+	d, _ := retryAfter(resp, now)
+	return &ResendCooldownError{RetryAfter: d, err: err}
 }
 
 // Verify receives a verification code to complete authentication. If
@@ -21,12 +205,31 @@ type Step struct {
 //
 // The remember argument specifies whether the current device should be
 // remembered for future authentication.
+//
+// If the code is incorrect, the returned error matches ErrInvalidCode, and the
+// step remains usable for another attempt. Otherwise, the error may match
+// ErrTooManyCodeAttempts or ErrStepExpired.
 func (s *Step) Verify(code string, remember bool) (cookies []*http.Cookie, err error) {
+	return s.VerifyContext(context.Background(), code, remember)
+}
+
+// VerifyContext is like Verify, but with a context.
+func (s *Step) VerifyContext(ctx context.Context, code string, remember bool) (cookies []*http.Cookie, err error) {
+	defer wrapOp("verify", &err)
 	defer func() {
 		if err != nil {
-			err = fmt.Errorf("verify: %w", err)
+			s.cfg.observeEvent(EventVerifyFailure)
+		} else {
+			s.cfg.observeEvent(EventVerifySuccess)
 		}
 	}()
+	if !s.Valid() {
+		return nil, ErrStepExpired
+	}
+	if s.userID != 0 {
+		return s.verifyChallenge(ctx, code, remember)
+	}
+
 	apiReq := s.req
 	apiReq.Code = code
 	apiReq.RememberDevice = remember
@@ -36,27 +239,83 @@ func (s *Step) Verify(code string, remember bool) (cookies []*http.Cookie, err e
 	if endpoint == "" {
 		endpoint = DefaultVerifyEndpoint
 	}
-	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+	req, err := s.cfg.newRequest(ctx, "POST", endpoint, bytes.NewReader(body))
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
 
-	resp, err := s.cfg.requestAPI(req, &errorsResponse{})
+	resp, err := s.cfg.requestAPI("verify", req, &errorsResponse{})
+	if err != nil {
+		return nil, classify(err, verifyErrorCodes)
+	}
+	return responseCookies(resp), nil
+}
+
+// VerifyResult contains the cookies returned by a successful verification.
+type VerifyResult struct {
+	// SessionCookies contains the HTTP cookies representing the session.
+	SessionCookies []*http.Cookie
+	// DeviceCookies contains the cookies that identify the device, if it was
+	// remembered. These can be set to Config.PersistentCookies so that
+	// subsequent logins from the device skip verification.
+	DeviceCookies []*http.Cookie
+}
+
+// VerifyResult is like VerifyContext, but separates the returned cookies as a
+// VerifyResult.
+func (s *Step) VerifyResult(ctx context.Context, code string, remember bool) (*VerifyResult, error) {
+	cookies, err := s.VerifyContext(ctx, code, remember)
+	if err != nil {
+		return nil, err
+	}
+	session, device := SplitDeviceCookies(cookies)
+	return &VerifyResult{SessionCookies: session, DeviceCookies: device}, nil
+}
+
+// VerifySession wraps Verify, returning the cookies as a Session.
+func (s *Step) VerifySession(code string, remember bool) (*Session, error) {
+	return s.VerifySessionContext(context.Background(), code, remember)
+}
+
+// VerifySessionContext is like VerifySession, but with a context.
+func (s *Step) VerifySessionContext(ctx context.Context, code string, remember bool) (*Session, error) {
+	cookies, err := s.VerifyContext(ctx, code, remember)
 	if err != nil {
 		return nil, err
 	}
-	return resp.Cookies(), nil
+	sess := NewSession(s.cfg, cookies)
+	sess.user = s.User
+	return sess, nil
 }
 
 // Resend retransmits a two-step verification message.
-func (s *Step) Resend() (err error) {
-	func() {
-		if err != nil {
-			err = fmt.Errorf("resend: %w", err)
+//
+// Returns a *ResendCooldownError if Config.ResendCooldown has not elapsed
+// since the code was last sent, or if the API reports that too many requests
+// were made.
+func (s *Step) Resend() error {
+	return s.ResendContext(context.Background())
+}
+
+// ResendContext is like Resend, but with a context.
+func (s *Step) ResendContext(ctx context.Context) (err error) {
+	defer wrapOp("resend", &err)
+	defer func() {
+		if err == nil {
+			s.cfg.observeEvent(EventResend)
 		}
 	}()
+	if s.MediaType == MediaAuthenticator {
+		return ErrResendUnsupported
+	}
+	if d := s.CanResendAt().Sub(s.cfg.now()); d > 0 {
+		return &ResendCooldownError{RetryAfter: d}
+	}
+	if s.userID != 0 {
+		return s.resendChallenge(ctx)
+	}
 
 	body, _ := json.Marshal(&s.req.twoStepVerificationTicketRequest)
 
@@ -64,20 +323,182 @@ func (s *Step) Resend() (err error) {
 	if endpoint == "" {
 		endpoint = DefaultResendEndpoint
 	}
-	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+	req, err := s.cfg.newRequest(ctx, "POST", endpoint, bytes.NewReader(body))
 	if err != nil {
 		return err
 	}
+	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
 
 	var apiResp struct {
 		twoStepVerificationSentResponse
 		errorsResponse
 	}
-	if _, err = s.cfg.requestAPI(req, &apiResp); err != nil {
-		return err
+	if resp, err := s.cfg.requestAPI("resend", req, &apiResp); err != nil {
+		return ifCooldown(resp, err, s.cfg.now())
 	}
-	s.MediaType = apiResp.MediaType
+	s.MediaType, _ = ParseMediaType(apiResp.MediaType)
 	s.req.Ticket = apiResp.Ticket
+	s.sent = s.cfg.now()
+	s.Expires = s.sent.Add(DefaultStepTTL)
+	return nil
+}
+
+// ErrResendUnsupported is returned by Step.Resend when the verification code
+// cannot be resent, such as when codes are generated by an authenticator app.
+var ErrResendUnsupported = errors.New("code cannot be resent")
+
+// challengeMedia returns the media component of a challenge endpoint for the
+// step's media type.
+func (s *Step) challengeMedia() string {
+	switch s.MediaType {
+	case MediaSMS:
+		return "sms"
+	case MediaEmail:
+		return "email"
+	case MediaAuthenticator:
+		return "authenticator"
+	}
+	return strings.ToLower(string(s.MediaType))
+}
+
+// challengeEndpoint returns the URL of a challenge action for the step's user
+// and media type.
+func (s *Step) challengeEndpoint(action string) string {
+	endpoint := s.cfg.TwoStepChallengeEndpoint
+	if endpoint == "" {
+		endpoint = DefaultTwoStepChallengeEndpoint
+	}
+	return fmt.Sprintf(endpoint, s.userID, s.challengeMedia()) + "/" + action
+}
+
+// verifyChallenge implements Verify for the challenge API. The code is
+// exchanged for a verification token, which is then used to complete the
+// login.
+func (s *Step) verifyChallenge(ctx context.Context, code string, remember bool) (cookies []*http.Cookie, err error) {
+	body, _ := json.Marshal(&twoStepChallengeVerifyRequest{
+		ChallengeID: s.req.Ticket,
+		ActionType:  s.req.ActionType,
+		Code:        code,
+	})
+	req, err := s.cfg.newRequest(ctx, "POST", s.challengeEndpoint("verify"), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	var verifyResp twoStepChallengeVerifyResponse
+	if _, err = s.cfg.requestAPI("twostep-challenge", req, &verifyResp); err != nil {
+		return nil, classify(err, challengeErrorCodes)
+	}
+
+	body, _ = json.Marshal(&twoStepLoginRequest{
+		ChallengeID:       s.req.Ticket,
+		VerificationToken: verifyResp.VerificationToken,
+		RememberDevice:    remember,
+	})
+	endpoint := s.cfg.TwoStepLoginEndpoint
+	if endpoint == "" {
+		endpoint = DefaultTwoStepLoginEndpoint
+	}
+	req, err = s.cfg.newRequest(ctx, "POST", fmt.Sprintf(endpoint, s.userID), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.cfg.requestAPI("twostep-login", req, &errorsResponse{})
+	if err != nil {
+		return nil, classify(err, challengeErrorCodes)
+	}
+	return responseCookies(resp), nil
+}
+
+// resendChallenge implements Resend for the challenge API.
+func (s *Step) resendChallenge(ctx context.Context) (err error) {
+	body, _ := json.Marshal(&twoStepChallengeSendRequest{
+		ChallengeID: s.req.Ticket,
+		ActionType:  s.req.ActionType,
+	})
+	req, err := s.cfg.newRequest(ctx, "POST", s.challengeEndpoint("send-code"), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if resp, err := s.cfg.requestAPI("twostep-challenge", req, &errorsResponse{}); err != nil {
+		return ifCooldown(resp, err, s.cfg.now())
+	}
+	s.sent = s.cfg.now()
+	s.Expires = s.sent.Add(DefaultStepTTL)
+	return nil
+}
+
+// RestoreStep returns a Step decoded from data, which was produced by
+// Step.MarshalJSON. The Step uses c, including its endpoints, for subsequent
+// requests.
+func (c Config) RestoreStep(data []byte) (*Step, error) {
+	s := &Step{cfg: c}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("restore step: %w", err)
+	}
+	return s, nil
+}
+
+// stepJSON is the JSON representation of a Step.
+type stepJSON struct {
+	Username   string    `json:"username"`
+	Ticket     string    `json:"ticket"`
+	ActionType string    `json:"actionType"`
+	MediaType  MediaType `json:"mediaType"`
+	User       *UserInfo `json:"user,omitempty"`
+	Expires    time.Time `json:"expires,omitempty"`
+	Sent       time.Time `json:"sent,omitempty"`
+
+	// ChallengeUserID is non-zero if the challenge API is used.
+	ChallengeUserID int64 `json:"challengeUserId,omitempty"`
+}
+
+// MarshalJSON implements the json.Marshaler interface, allowing a Step to be
+// persisted so that verification can be completed by a different process.
+// The Step's Config, including its endpoints, is not included, so that the
+// ticket is only ever sent to the endpoints of the Config that restores it.
+//
+// Note that the result contains the verification ticket, which must be
+// treated as a secret.
+func (s *Step) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&stepJSON{
+		Username:        s.req.Username,
+		Ticket:          s.req.Ticket,
+		ActionType:      s.req.ActionType,
+		MediaType:       s.MediaType,
+		User:            s.User,
+		Expires:         s.Expires,
+		Sent:            s.sent,
+		ChallengeUserID: s.userID,
+	})
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface, restoring a Step
+// encoded by MarshalJSON. The Step's Config is retained.
+func (s *Step) UnmarshalJSON(b []byte) error {
+	var v stepJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	s.req = twoStepVerificationVerifyRequest{
+		twoStepVerificationTicketRequest: twoStepVerificationTicketRequest{
+			Username:   v.Username,
+			Ticket:     v.Ticket,
+			ActionType: v.ActionType,
+		},
+	}
+	s.MediaType = v.MediaType
+	s.User = v.User
+	s.Expires = v.Expires
+	s.sent = v.Sent
+	s.userID = v.ChallengeUserID
 	return nil
 }
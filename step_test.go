@@ -0,0 +1,481 @@
+package rbxauth_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anaminus/rbxauth"
+	"github.com/anaminus/rbxauth/rbxauthtest"
+)
+
+// loginStep logs into the account named alice with cfg, which must require
+// two-step verification, returning the resulting step.
+func loginStep(t *testing.T, cfg rbxauth.Config) *rbxauth.Step {
+	t.Helper()
+	cookies, step, err := cfg.Login("alice", []byte("pass"))
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if step == nil {
+		t.Fatalf("expected step, got %d cookies", len(cookies))
+	}
+	return step
+}
+
+func TestTwoStepChallenge(t *testing.T) {
+	for _, media := range []rbxauth.MediaType{
+		rbxauth.MediaEmail,
+		rbxauth.MediaSMS,
+		rbxauth.MediaAuthenticator,
+	} {
+		t.Run(string(media), func(t *testing.T) {
+			srv := newServer(t, rbxauthtest.Account{
+				ID: 1, Name: "alice", Password: "pass",
+				TwoStep: true, MediaType: string(media), Code: "123456",
+			})
+			cfg := srv.Config()
+			cfg.ResendCooldown = -1
+			step := loginStep(t, cfg)
+			if step.MediaType != media {
+				t.Errorf("expected media type %s, got %s", media, step.MediaType)
+			}
+
+			err := step.Resend()
+			if media == rbxauth.MediaAuthenticator {
+				if !errors.Is(err, rbxauth.ErrResendUnsupported) {
+					t.Errorf("expected ErrResendUnsupported, got %v", err)
+				}
+			} else if err != nil {
+				t.Errorf("resend: %v", err)
+			}
+
+			if _, err := step.Verify("000000", false); !errors.Is(err, rbxauth.ErrInvalidCode) {
+				t.Fatalf("expected ErrInvalidCode, got %v", err)
+			}
+			cookies, err := step.Verify("123456", false)
+			if err != nil {
+				t.Fatalf("verify: %v", err)
+			}
+			checkSession(t, cfg, cookies, rbxauth.UserInfo{ID: 1, Name: "alice"})
+			if n := srv.Count(rbxauthtest.VerifyPath); n != 0 {
+				t.Errorf("legacy verify endpoint used %d times", n)
+			}
+			if n := srv.Count(rbxauthtest.TwoStepLoginPath); n != 1 {
+				t.Errorf("expected 1 two-step login request, got %d", n)
+			}
+		})
+	}
+}
+
+func TestTwoStepLegacy(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{
+		ID: 1, Name: "alice", Password: "pass",
+		TwoStep: true, Code: "123456",
+	})
+	cfg := srv.Config()
+	cfg.LegacyTwoStep = true
+	cfg.ResendCooldown = -1
+	step := loginStep(t, cfg)
+	if err := step.Resend(); err != nil {
+		t.Fatalf("resend: %v", err)
+	}
+	cookies, err := step.Verify("123456", false)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if len(cookies) == 0 {
+		t.Fatal("expected session cookies")
+	}
+	if n := srv.Count(rbxauthtest.ChallengePath); n != 0 {
+		t.Errorf("challenge endpoint used %d times", n)
+	}
+}
+
+func TestTwoStepTooManyAttempts(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{
+		ID: 1, Name: "alice", Password: "pass",
+		TwoStep: true, Code: "123456",
+	})
+	step := loginStep(t, srv.Config())
+	for i := 0; i < rbxauthtest.MaxCodeAttempts; i++ {
+		if _, err := step.Verify("000000", false); !errors.Is(err, rbxauth.ErrInvalidCode) {
+			t.Fatalf("attempt %d: expected ErrInvalidCode, got %v", i+1, err)
+		}
+	}
+	if _, err := step.Verify("123456", false); !errors.Is(err, rbxauth.ErrTooManyCodeAttempts) {
+		t.Errorf("expected ErrTooManyCodeAttempts, got %v", err)
+	}
+}
+
+func TestVerifyErrors(t *testing.T) {
+	for _, legacy := range []bool{false, true} {
+		srv := newServer(t, rbxauthtest.Account{
+			ID: 1, Name: "alice", Password: "pass",
+			TwoStep: true, Code: "123456",
+		})
+		cfg := srv.Config()
+		cfg.LegacyTwoStep = legacy
+
+		// An incorrect code leaves the step usable.
+		step := loginStep(t, cfg)
+		_, err := step.Verify("000000", false)
+		if !errors.Is(err, rbxauth.ErrInvalidCode) || errors.Is(err, rbxauth.ErrStepExpired) {
+			t.Errorf("legacy=%t: invalid: expected ErrInvalidCode, got %v", legacy, err)
+		}
+		if _, err := step.Verify("123456", false); err != nil {
+			t.Errorf("legacy=%t: retry: %v", legacy, err)
+		}
+
+		// A ticket unknown to the server has expired.
+		data, _ := json.Marshal(loginStep(t, cfg))
+		var blob map[string]interface{}
+		json.Unmarshal(data, &blob)
+		blob["ticket"] = "stale"
+		data, _ = json.Marshal(blob)
+		step, err = cfg.RestoreStep(data)
+		if err != nil {
+			t.Fatalf("legacy=%t: restore: %v", legacy, err)
+		}
+		if _, err := step.Verify("123456", false); !errors.Is(err, rbxauth.ErrStepExpired) {
+			t.Errorf("legacy=%t: stale: expected ErrStepExpired, got %v", legacy, err)
+		}
+
+		// Too many incorrect codes lock the step.
+		step = loginStep(t, cfg)
+		for i := 0; i < rbxauthtest.MaxCodeAttempts; i++ {
+			step.Verify("000000", false)
+		}
+		_, err = step.Verify("123456", false)
+		if !errors.Is(err, rbxauth.ErrTooManyCodeAttempts) || errors.Is(err, rbxauth.ErrInvalidCode) {
+			t.Errorf("legacy=%t: attempts: expected ErrTooManyCodeAttempts, got %v", legacy, err)
+		}
+	}
+}
+
+func TestStreamCodeRetry(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		retries int
+		input   string
+		// verifies is the expected number of verification requests.
+		verifies int
+		err      error
+	}{
+		{"second code", 0, "pass\n000000\n123456\n", 2, nil},
+		{"exhausted", 1, "pass\n000000\n111111\n123456\n", 2, rbxauth.ErrInvalidCode},
+		{"no retries", -1, "pass\n000000\n123456\n", 1, rbxauth.ErrInvalidCode},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			srv := newServer(t, rbxauthtest.Account{
+				ID: 1, Name: "alice", Password: "pass",
+				TwoStep: true, Code: "123456",
+			})
+			cfg := srv.Config()
+			cfg.LegacyTwoStep = true
+			cfg.CodeRetries = test.retries
+			var out strings.Builder
+			s := &rbxauth.Stream{
+				Config:         cfg,
+				Reader:         strings.NewReader(test.input),
+				Writer:         &out,
+				Quiet:          true,
+				RememberDevice: rbxauth.RememberNever,
+			}
+			_, cookies, err := s.PromptCred(rbxauth.Cred{Type: "Username", Ident: "alice"})
+			if test.err != nil {
+				if !errors.Is(err, test.err) {
+					t.Errorf("expected %v, got %v", test.err, err)
+				}
+			} else if err != nil {
+				t.Fatalf("prompt: %v", err)
+			} else {
+				checkSession(t, cfg, cookies, rbxauth.UserInfo{ID: 1})
+			}
+			if n := srv.Count(rbxauthtest.VerifyPath); n != test.verifies {
+				t.Errorf("expected %d verifications, got %d", test.verifies, n)
+			}
+			// The password is not prompted again.
+			if n := strings.Count(out.String(), fmt.Sprintf(rbxauth.DefaultMessages.AskPasswordFor, "alice")); n != 1 {
+				t.Errorf("expected 1 password prompt, got %d", n)
+			}
+			incorrect := strings.Count(out.String(), rbxauth.DefaultMessages.IncorrectCode)
+			if incorrect != test.verifies-1 {
+				t.Errorf("expected %d incorrect code messages, got %d", test.verifies-1, incorrect)
+			}
+		})
+	}
+}
+
+func TestRestoreStep(t *testing.T) {
+	for _, legacy := range []bool{false, true} {
+		srv := newServer(t, rbxauthtest.Account{
+			ID: 1, Name: "alice", Password: "pass",
+			TwoStep: true, Code: "123456",
+		})
+		cfg := srv.Config()
+		cfg.LegacyTwoStep = legacy
+		data, err := json.Marshal(loginStep(t, cfg))
+		if err != nil {
+			t.Fatalf("legacy=%t: marshal: %v", legacy, err)
+		}
+
+		// A blob naming other endpoints must not redirect the ticket.
+		var forged int
+		evil := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			forged++
+		}))
+		var blob map[string]interface{}
+		json.Unmarshal(data, &blob)
+		for name, path := range map[string]string{
+			"verifyEndpoint":           rbxauthtest.VerifyPath,
+			"resendEndpoint":           rbxauthtest.ResendPath,
+			"twoStepChallengeEndpoint": rbxauthtest.ChallengePath + "%d/challenges/%s",
+			"twoStepLoginEndpoint":     rbxauthtest.TwoStepLoginPath + "%d/two-step-verification/login",
+		} {
+			if _, ok := blob[name]; ok {
+				t.Errorf("legacy=%t: step encodes %s", legacy, name)
+			}
+			blob[name] = evil.URL + path
+		}
+		data, _ = json.Marshal(blob)
+
+		step, err := cfg.RestoreStep(data)
+		if err != nil {
+			t.Fatalf("legacy=%t: restore: %v", legacy, err)
+		}
+		if step.User == nil || step.User.ID != 1 {
+			t.Errorf("legacy=%t: user not restored", legacy)
+		}
+		cookies, err := step.Verify("123456", false)
+		evil.Close()
+		if err != nil {
+			t.Fatalf("legacy=%t: verify: %v", legacy, err)
+		}
+		if len(cookies) == 0 {
+			t.Errorf("legacy=%t: expected session cookies", legacy)
+		}
+		if forged != 0 {
+			t.Errorf("legacy=%t: %d requests sent to endpoints of blob", legacy, forged)
+		}
+	}
+}
+
+func TestStepExpires(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass", TwoStep: true, Code: "123456"})
+	cfg := srv.Config()
+	cfg.LegacyTwoStep = true
+	cfg.ResendCooldown = -1
+	var clock fakeClock
+	clock.install(&cfg)
+	start := clock.now
+
+	step := loginStep(t, cfg)
+	if !step.Expires.Equal(start.Add(rbxauth.DefaultStepTTL)) {
+		t.Errorf("expected expiry %v, got %v", start.Add(rbxauth.DefaultStepTTL), step.Expires)
+	}
+	if !step.Valid() || step.Remaining() != rbxauth.DefaultStepTTL {
+		t.Errorf("expected valid step with %v remaining, got %t %v", rbxauth.DefaultStepTTL, step.Valid(), step.Remaining())
+	}
+
+	cfg.Sleep(context.Background(), rbxauth.DefaultStepTTL-time.Minute)
+	if !step.Valid() || step.Remaining() != time.Minute {
+		t.Errorf("expected valid step with 1m remaining, got %t %v", step.Valid(), step.Remaining())
+	}
+
+	// Resending resets the deadline.
+	if err := step.Resend(); err != nil {
+		t.Fatalf("resend: %v", err)
+	}
+	if want := clock.now.Add(rbxauth.DefaultStepTTL); !step.Expires.Equal(want) {
+		t.Errorf("resend: expected expiry %v, got %v", want, step.Expires)
+	}
+
+	// An expired step is rejected without a request.
+	cfg.Sleep(context.Background(), rbxauth.DefaultStepTTL)
+	if step.Valid() || step.Remaining() != 0 {
+		t.Errorf("expected expired step, got %t %v", step.Valid(), step.Remaining())
+	}
+	n := srv.Count(rbxauthtest.VerifyPath)
+	if _, err := step.Verify("123456", false); !errors.Is(err, rbxauth.ErrStepExpired) {
+		t.Errorf("expected ErrStepExpired, got %v", err)
+	}
+	if srv.Count(rbxauthtest.VerifyPath) != n {
+		t.Error("expired step sent a request")
+	}
+
+	// A step without an expiry is always valid.
+	step.Expires = time.Time{}
+	if !step.Valid() || step.Remaining() != 0 {
+		t.Errorf("no expiry: expected valid step, got %t %v", step.Valid(), step.Remaining())
+	}
+}
+
+func TestStepExpiresMessage(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass", TwoStep: true, Code: "123456"})
+	cfg := srv.Config()
+	var clock fakeClock
+	clock.install(&cfg)
+	var out strings.Builder
+	s := &rbxauth.Stream{
+		Config:         cfg,
+		Reader:         strings.NewReader("pass\n123456\n"),
+		Writer:         &out,
+		Quiet:          true,
+		RememberDevice: rbxauth.RememberNever,
+	}
+	if _, _, err := s.PromptCred(rbxauth.Cred{Type: "Username", Ident: "alice"}); err != nil {
+		t.Fatalf("prompt: %v", err)
+	}
+	if want := "expires in " + rbxauth.DefaultStepTTL.String(); !strings.Contains(out.String(), want) {
+		t.Errorf("expected output containing %q, got %q", want, out.String())
+	}
+}
+
+func TestResendCooldownLocal(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass", TwoStep: true, Code: "123456"})
+	cfg := srv.Config()
+	cfg.LegacyTwoStep = true
+	var clock fakeClock
+	clock.install(&cfg)
+
+	step := loginStep(t, cfg)
+	if want := clock.now.Add(rbxauth.DefaultResendCooldown); !step.CanResendAt().Equal(want) {
+		t.Errorf("expected resend at %v, got %v", want, step.CanResendAt())
+	}
+	cfg.Sleep(context.Background(), 10*time.Second)
+	err := step.Resend()
+	var cerr *rbxauth.ResendCooldownError
+	if !errors.As(err, &cerr) || !errors.Is(err, rbxauth.ErrResendCooldown) {
+		t.Fatalf("expected ResendCooldownError, got %v", err)
+	}
+	if want := rbxauth.DefaultResendCooldown - 10*time.Second; cerr.RetryAfter != want {
+		t.Errorf("expected retry after %v, got %v", want, cerr.RetryAfter)
+	}
+	if n := srv.Count(rbxauthtest.ResendPath); n != 0 {
+		t.Errorf("cooldown sent %d requests", n)
+	}
+
+	// The cooldown restarts after a resend.
+	cfg.Sleep(context.Background(), cerr.RetryAfter)
+	if err := step.Resend(); err != nil {
+		t.Fatalf("resend: %v", err)
+	}
+	if want := clock.now.Add(rbxauth.DefaultResendCooldown); !step.CanResendAt().Equal(want) {
+		t.Errorf("resent: expected resend at %v, got %v", want, step.CanResendAt())
+	}
+	if err := step.Resend(); !errors.Is(err, rbxauth.ErrResendCooldown) {
+		t.Errorf("resent: expected ErrResendCooldown, got %v", err)
+	}
+
+	// The cooldown is configurable, and can be disabled.
+	cfg.ResendCooldown = time.Second
+	step = loginStep(t, cfg)
+	if err := step.Resend(); !errors.Is(err, rbxauth.ErrResendCooldown) {
+		t.Errorf("configured: expected ErrResendCooldown, got %v", err)
+	}
+	cfg.Sleep(context.Background(), time.Second)
+	if err := step.Resend(); err != nil {
+		t.Errorf("configured: %v", err)
+	}
+	cfg.ResendCooldown = -1
+	step = loginStep(t, cfg)
+	if !step.CanResendAt().IsZero() {
+		t.Errorf("disabled: expected zero resend time, got %v", step.CanResendAt())
+	}
+	if err := step.Resend(); err != nil {
+		t.Errorf("disabled: %v", err)
+	}
+}
+
+// cooldownTransport responds to each request to resend a code with a 429
+// status and the given Retry-After header.
+type cooldownTransport struct {
+	transport  http.RoundTripper
+	retryAfter string
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t cooldownTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Path != rbxauthtest.ResendPath && !strings.HasSuffix(req.URL.Path, "/send-code") {
+		return t.transport.RoundTrip(req)
+	}
+	rec := httptest.NewRecorder()
+	rec.Header().Set("Content-Type", "application/json")
+	if t.retryAfter != "" {
+		rec.Header().Set("Retry-After", t.retryAfter)
+	}
+	rec.WriteHeader(http.StatusTooManyRequests)
+	rec.WriteString(`{"errors":[{"code":0,"message":"TooManyRequests"}]}`)
+	return rec.Result(), nil
+}
+
+func TestResendCooldownServer(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass", TwoStep: true, Code: "123456"})
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, test := range []struct {
+		name       string
+		retryAfter string
+		want       time.Duration
+	}{
+		{"seconds", "45", 45 * time.Second},
+		{"date", start.Add(2 * time.Minute).Format(http.TimeFormat), 2 * time.Minute},
+		{"past date", start.Add(-time.Minute).Format(http.TimeFormat), 0},
+		{"missing", "", 0},
+		{"invalid", "soon", 0},
+	} {
+		for _, legacy := range []bool{false, true} {
+			cfg := srv.Config()
+			cfg.Client = &http.Client{Transport: cooldownTransport{cfg.Client.Transport, test.retryAfter}}
+			cfg.LegacyTwoStep = legacy
+			cfg.ResendCooldown = -1
+			var clock fakeClock
+			clock.install(&cfg)
+
+			err := loginStep(t, cfg).Resend()
+			var cerr *rbxauth.ResendCooldownError
+			if !errors.As(err, &cerr) {
+				t.Errorf("%s (legacy %t): expected ResendCooldownError, got %v", test.name, legacy, err)
+				continue
+			}
+			if cerr.RetryAfter != test.want {
+				t.Errorf("%s (legacy %t): expected retry after %v, got %v", test.name, legacy, test.want, cerr.RetryAfter)
+			}
+			if status, _ := rbxauth.HTTPStatus(err); status != http.StatusTooManyRequests {
+				t.Errorf("%s (legacy %t): expected status 429, got %v", test.name, legacy, err)
+			}
+		}
+	}
+}
+
+func TestResendCooldownPrompt(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass", TwoStep: true, Code: "123456"})
+	cfg := srv.Config()
+	var clock fakeClock
+	clock.install(&cfg)
+	var out strings.Builder
+	s := &rbxauth.Stream{
+		Config: cfg,
+		// The empty lines request resends.
+		Reader:         strings.NewReader("pass\n\n\n123456\n"),
+		Writer:         &out,
+		Quiet:          true,
+		RememberDevice: rbxauth.RememberNever,
+	}
+	_, result, err := s.PromptResult(rbxauth.Cred{Type: "Username", Ident: "alice"})
+	if err != nil {
+		t.Fatalf("prompt: %v", err)
+	}
+	if n := strings.Count(out.String(), "Wait "+rbxauth.DefaultResendCooldown.String()+" before resending"); n != 2 {
+		t.Errorf("expected 2 cooldown messages, got %d:\n%s", n, out.String())
+	}
+	if result.Resends != 0 {
+		t.Errorf("expected no resends, got %d", result.Resends)
+	}
+}
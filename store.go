@@ -0,0 +1,268 @@
+package rbxauth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// CredentialStore persists the sessions of accounts, identified by a name
+// chosen by the caller, such as the username.
+type CredentialStore interface {
+	// SaveSession stores the cookies of a session, replacing any session
+	// already stored for account.
+	SaveSession(account string, cookies []*http.Cookie) error
+	// LoadSession returns the cookies of the session stored for account.
+	// Returns an error matching ErrSessionNotFound if no session is stored.
+	LoadSession(account string) ([]*http.Cookie, error)
+	// DeleteSession removes the session stored for account. Returns an
+	// error matching ErrSessionNotFound if no session is stored.
+	DeleteSession(account string) error
+}
+
+// SessionLister is implemented by a CredentialStore that can enumerate the
+// sessions it holds.
+type SessionLister interface {
+	// ListSessions returns a description of each stored session, sorted by
+	// account.
+	ListSessions() ([]SessionInfo, error)
+}
+
+// SessionInfo describes a session held by a CredentialStore.
+type SessionInfo struct {
+	// Account is the name under which the session is stored.
+	Account string
+	// Expires is the time at which the session cookie expires. Zero if the
+	// expiry is unknown.
+	Expires time.Time
+	// User is the user of the session. Nil until the session is checked with
+	// Config.CheckSession.
+	User *UserInfo
+}
+
+// ErrSessionNotFound is matched by errors returned by a CredentialStore when
+// no session is stored for an account.
+var ErrSessionNotFound = errors.New("session not found")
+
+// SessionNotFoundError is returned by a CredentialStore when no session is
+// stored for an account. It matches ErrSessionNotFound.
+type SessionNotFoundError struct {
+	Account string
+}
+
+// Error implements the error interface.
+func (err *SessionNotFoundError) Error() string {
+	return fmt.Sprintf("no session stored for account %q", err.Account)
+}
+
+// Is implements errors.Is, matching ErrSessionNotFound.
+func (err *SessionNotFoundError) Is(target error) bool {
+	return target == ErrSessionNotFound
+}
+
+// ErrInvalidAccount is returned by a CredentialStore when an account name
+// cannot be stored.
+var ErrInvalidAccount = errors.New("invalid account name")
+
+// CheckSession verifies the session stored in store for info.Account with the
+// Authenticated endpoint, setting info.User if the session is valid. Returns
+// an error matching ErrUnauthenticated if the session is not valid.
+func (c Config) CheckSession(store CredentialStore, info *SessionInfo) error {
+	return c.CheckSessionContext(context.Background(), store, info)
+}
+
+// CheckSessionContext is like CheckSession, but with a context.
+func (c Config) CheckSessionContext(ctx context.Context, store CredentialStore, info *SessionInfo) error {
+	cookies, err := store.LoadSession(info.Account)
+	if err != nil {
+		return err
+	}
+	user, err := c.AuthenticatedContext(ctx, cookies)
+	if err != nil {
+		return err
+	}
+	info.User = user
+	return nil
+}
+
+// sessionFileVersion is the version of the document written by
+// FileCredentialStore.
+const sessionFileVersion = 1
+
+// sessionFile is the document written by FileCredentialStore.
+type sessionFile struct {
+	// Version is the format of the document. A document with a greater
+	// version than sessionFileVersion is not modified.
+	Version int `json:"version"`
+	// Sessions maps each account to its cookies, in the JSON cookie format.
+	Sessions map[string]json.RawMessage `json:"sessions"`
+}
+
+// FileCredentialStore implements CredentialStore and SessionLister by storing
+// every session within a single JSON document at the given path. The file is
+// created with permissions that restrict access to the current user.
+//
+// Modifications lock the document with a separate lock file, so that
+// concurrent writes, from the same or other processes, are not lost.
+type FileCredentialStore string
+
+// lockTimeout is the duration after which a lock that cannot be acquired is
+// abandoned. A lock older than this is assumed to have been left by a crashed
+// process, and is removed.
+const lockTimeout = 10 * time.Second
+
+// lock acquires the lock of the document, returning a function that releases
+// it.
+func (f FileCredentialStore) lock() (unlock func(), err error) {
+	path := string(f) + ".lock"
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err == nil {
+			file.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if stat, err := os.Stat(path); err == nil && time.Since(stat.ModTime()) > lockTimeout {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("session file is locked by %s", path)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// read reads the document. An empty document is returned if the file does
+// not exist.
+func (f FileCredentialStore) read() (*sessionFile, error) {
+	doc := &sessionFile{Version: sessionFileVersion, Sessions: map[string]json.RawMessage{}}
+	b, err := ioutil.ReadFile(string(f))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return doc, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(b, doc); err != nil {
+		return nil, fmt.Errorf("read session file: %w", err)
+	}
+	if doc.Sessions == nil {
+		doc.Sessions = map[string]json.RawMessage{}
+	}
+	return doc, nil
+}
+
+// update applies fn to the document while locked, then writes the document
+// atomically.
+func (f FileCredentialStore) update(fn func(doc *sessionFile) error) error {
+	if err := os.MkdirAll(filepath.Dir(string(f)), 0700); err != nil {
+		return err
+	}
+	unlock, err := f.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	doc, err := f.read()
+	if err != nil {
+		return err
+	}
+	if doc.Version > sessionFileVersion {
+		return fmt.Errorf("session file version %d is not supported", doc.Version)
+	}
+	if err := fn(doc); err != nil {
+		return err
+	}
+	doc.Version = sessionFileVersion
+
+	tmp, err := ioutil.TempFile(filepath.Dir(string(f)), filepath.Base(string(f))+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	je := json.NewEncoder(tmp)
+	je.SetIndent("", "\t")
+	if err := je.Encode(doc); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	// TempFile creates the file with permissions restricted to the current
+	// user, which are retained by the rename.
+	return os.Rename(tmp.Name(), string(f))
+}
+
+// SaveSession implements CredentialStore.
+func (f FileCredentialStore) SaveSession(account string, cookies []*http.Cookie) error {
+	if account == "" {
+		return ErrInvalidAccount
+	}
+	var buf bytes.Buffer
+	if err := WriteCookiesJSON(&buf, cookies); err != nil {
+		return err
+	}
+	return f.update(func(doc *sessionFile) error {
+		doc.Sessions[account] = json.RawMessage(bytes.TrimSpace(buf.Bytes()))
+		return nil
+	})
+}
+
+// LoadSession implements CredentialStore.
+func (f FileCredentialStore) LoadSession(account string) ([]*http.Cookie, error) {
+	doc, err := f.read()
+	if err != nil {
+		return nil, err
+	}
+	raw, ok := doc.Sessions[account]
+	if !ok {
+		return nil, &SessionNotFoundError{Account: account}
+	}
+	return ReadCookiesJSON(bytes.NewReader(raw))
+}
+
+// DeleteSession implements CredentialStore.
+func (f FileCredentialStore) DeleteSession(account string) error {
+	return f.update(func(doc *sessionFile) error {
+		if _, ok := doc.Sessions[account]; !ok {
+			return &SessionNotFoundError{Account: account}
+		}
+		delete(doc.Sessions, account)
+		return nil
+	})
+}
+
+// ListSessions implements SessionLister. The user of each session is not
+// checked.
+func (f FileCredentialStore) ListSessions() ([]SessionInfo, error) {
+	doc, err := f.read()
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]SessionInfo, 0, len(doc.Sessions))
+	for account, raw := range doc.Sessions {
+		info := SessionInfo{Account: account}
+		if cookies, err := ReadCookiesJSON(bytes.NewReader(raw)); err == nil {
+			info.Expires = InspectCookies(cookies).SessionValidUntil
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Account < infos[j].Account
+	})
+	return infos, nil
+}
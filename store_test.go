@@ -0,0 +1,134 @@
+package rbxauth_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/anaminus/rbxauth"
+	"github.com/anaminus/rbxauth/rbxauthtest"
+)
+
+func TestFileCredentialStoreConcurrent(t *testing.T) {
+	const sessions = 20
+	path := filepath.Join(t.TempDir(), "sessions.json")
+	store := rbxauth.FileCredentialStore(path)
+
+	// Each goroutine saves its own accounts. A lost write would drop the
+	// accounts of the other.
+	var wg sync.WaitGroup
+	for _, prefix := range []string{"a", "b"} {
+		wg.Add(1)
+		go func(prefix string) {
+			defer wg.Done()
+			for i := 0; i < sessions; i++ {
+				account := fmt.Sprintf("%s%d", prefix, i)
+				if err := store.SaveSession(account, rbxauth.FromSecurityToken(account)); err != nil {
+					t.Errorf("save %s: %v", account, err)
+				}
+			}
+		}(prefix)
+	}
+	wg.Wait()
+
+	infos, err := store.ListSessions()
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(infos) != 2*sessions {
+		t.Errorf("expected %d sessions, got %d", 2*sessions, len(infos))
+	}
+	for _, info := range infos {
+		cookies, err := store.LoadSession(info.Account)
+		if err != nil {
+			t.Errorf("load %s: %v", info.Account, err)
+		} else if v := cookieValue(cookies, rbxauthtest.SessionCookieName); v != info.Account {
+			t.Errorf("load %s: expected own session, got %q", info.Account, v)
+		}
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Version  int                        `json:"version"`
+		Sessions map[string]json.RawMessage `json:"sessions"`
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatalf("decode document: %v", err)
+	}
+	if doc.Version != 1 || len(doc.Sessions) != 2*sessions {
+		t.Errorf("unexpected document: version %d, %d sessions", doc.Version, len(doc.Sessions))
+	}
+}
+
+func TestFileCredentialStoreDeadSession(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass"})
+	cfg := srv.Config()
+	store := rbxauth.FileCredentialStore(filepath.Join(t.TempDir(), "sessions.json"))
+
+	cookies, _, err := cfg.Login("alice", []byte("pass"))
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if err := store.SaveSession("alice", cookies); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveSession("dead", rbxauth.FromSecurityToken("dead")); err != nil {
+		t.Fatal(err)
+	}
+
+	infos, err := store.ListSessions()
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(infos) != 2 || infos[0].Account != "alice" || infos[1].Account != "dead" {
+		t.Fatalf("expected sorted accounts, got %+v", infos)
+	}
+	// Users are not checked when listing.
+	for _, info := range infos {
+		if info.User != nil {
+			t.Errorf("%s: expected unchecked user, got %+v", info.Account, info.User)
+		}
+	}
+
+	if err := cfg.CheckSession(store, &infos[0]); err != nil {
+		t.Errorf("alice: %v", err)
+	} else if infos[0].User == nil || infos[0].User.ID != 1 || infos[0].User.Name != "alice" {
+		t.Errorf("alice: unexpected user %+v", infos[0].User)
+	}
+	if err := cfg.CheckSession(store, &infos[1]); !errors.Is(err, rbxauth.ErrUnauthenticated) {
+		t.Errorf("dead: expected ErrUnauthenticated, got %v", err)
+	}
+	if infos[1].User != nil {
+		t.Errorf("dead: expected no user, got %+v", infos[1].User)
+	}
+}
+
+func TestFileCredentialStoreMissing(t *testing.T) {
+	store := rbxauth.FileCredentialStore(filepath.Join(t.TempDir(), "sessions.json"))
+	if err := store.SaveSession("alice", rbxauth.FromSecurityToken("token")); err != nil {
+		t.Fatal(err)
+	}
+
+	var notFound *rbxauth.SessionNotFoundError
+	_, err := store.LoadSession("bob")
+	if !errors.As(err, &notFound) || notFound.Account != "bob" {
+		t.Errorf("load: expected SessionNotFoundError for bob, got %v", err)
+	}
+	if !errors.Is(err, rbxauth.ErrSessionNotFound) {
+		t.Errorf("load: expected ErrSessionNotFound, got %v", err)
+	}
+	err = store.DeleteSession("bob")
+	if !errors.As(err, &notFound) || notFound.Account != "bob" {
+		t.Errorf("delete: expected SessionNotFoundError for bob, got %v", err)
+	}
+	if err := store.SaveSession("", rbxauth.FromSecurityToken("token")); !errors.Is(err, rbxauth.ErrInvalidAccount) {
+		t.Errorf("empty account: expected ErrInvalidAccount, got %v", err)
+	}
+}
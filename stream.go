@@ -2,15 +2,44 @@ package rbxauth
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
-	"syscall"
+	"time"
+	"unicode"
 
-	"golang.org/x/crypto/ssh/terminal"
+	"golang.org/x/term"
+)
+
+// ErrPromptEOF is returned by a prompt when the Reader of a Stream ends before
+// the prompt is answered.
+var ErrPromptEOF = errors.New("unexpected end of input")
+
+// ErrPromptTimeout is returned by a prompt that is not answered within the
+// Timeout of a Stream.
+var ErrPromptTimeout = errors.New("timed out waiting for input")
+
+// DefaultPasswordAttempts is the default value of Stream.MaxPasswordAttempts.
+const DefaultPasswordAttempts = 3
+
+// RememberMode determines whether a Stream remembers the device after two-step
+// verification.
+type RememberMode int
+
+const (
+	// RememberAsk causes the user to be prompted.
+	RememberAsk RememberMode = iota
+	// RememberAlways causes the device to be remembered without prompting.
+	RememberAlways
+	// RememberNever causes the device to not be remembered without prompting.
+	RememberNever
 )
 
 // Stream uses a io.Reader and an optional io.Writer to perform an interactive
@@ -19,6 +48,326 @@ type Stream struct {
 	Config
 	io.Reader
 	io.Writer
+
+	// Input, if non-nil, supplies scripted answers to prompts ahead of
+	// Reader. Each line of Input answers one prompt, including a password
+	// prompt. Once Input ends, prompts are answered from Reader instead, so
+	// that, for example, answers piped through Input can be followed by
+	// answers typed into a terminal.
+	Input io.Reader
+
+	// PasswordEnv names an environment variable from which the password is
+	// read, instead of being prompted.
+	PasswordEnv string
+	// PasswordFile is the path to a file from which the password is read,
+	// instead of being prompted.
+	PasswordFile string
+	// PasswordReader is read to get the password, instead of being prompted.
+	// The entire content of the reader is read.
+	PasswordReader io.Reader
+	// PasswordCommand, if not empty, is a program and its arguments, run to
+	// get the password instead of it being prompted, such as a password
+	// manager. The command is not run through a shell. Its output to stdout,
+	// with surrounding space trimmed, is the password. The login fails with
+	// a *CommandError if the command exits unsuccessfully, writes nothing,
+	// or does not finish within Timeout or before Context is canceled.
+	PasswordCommand []string
+
+	// NoConfirm causes a credential type detected from the identifier to be
+	// used without being confirmed. An identifier that may be a Username or a
+	// UserID is assumed to be a Username.
+	NoConfirm bool
+
+	// PromptPIN causes the account PIN to be prompted after a successful
+	// login, unlocking the session for operations that require it.
+	PromptPIN bool
+
+	// CheckMetadata causes the authentication metadata to be checked before
+	// logging in, warning when a captcha is enforced, in which case the login
+	// is likely to fail.
+	CheckMetadata bool
+
+	// Quiet suppresses the warning written when a password is read from a
+	// Reader that is not a terminal, and so cannot be masked.
+	Quiet bool
+
+	// RedactIdent causes the identifier to be omitted from the password
+	// prompt, such as when prompts are written to a log.
+	RedactIdent bool
+
+	// MaxPasswordAttempts is the number of times the password is prompted
+	// when the login fails due to incorrect credentials. If zero or less,
+	// DefaultPasswordAttempts is used. The password is not prompted again if
+	// it is received from a source.
+	MaxPasswordAttempts int
+
+	// RememberDevice determines whether the device is remembered after
+	// two-step verification. The user is prompted by default.
+	RememberDevice RememberMode
+
+	// Code, if not empty, is submitted as the first two-step verification
+	// code instead of being prompted. If the code is incorrect, then the code
+	// is prompted, unless NoFallback is set.
+	Code string
+
+	// CodeFunc, if non-nil, is called to get the first two-step verification
+	// code instead of it being prompted, and takes precedence over Code. Like
+	// an empty line, an empty code requests that the code be resent, after
+	// which CodeFunc is called again. This happens at most once; a second
+	// empty code is treated like an incorrect one.
+	CodeFunc func(mediaType string) (string, error)
+
+	// CodeCommand, if not empty, is run to get the first two-step
+	// verification code instead of it being prompted, as with
+	// PasswordCommand, and takes precedence over Code and CodeFunc. The
+	// command is run again for the code that follows each resend.
+	CodeCommand []string
+
+	// NoFallback causes the login to fail when the code from Code,
+	// CodeFunc, or CodeCommand is incorrect, rather than prompting for the
+	// code.
+	NoFallback bool
+
+	// Timeout is the duration after which an unanswered prompt fails with
+	// ErrPromptTimeout. No timeout is applied if less than or equal to zero.
+	// Does not apply to a password read from a terminal.
+	Timeout time.Duration
+
+	// Context bounds each request made by the stream, and interrupts a prompt
+	// when canceled. If nil, context.Background is used. Does not interrupt a
+	// password read from a terminal.
+	Context context.Context
+
+	// Messages is the catalog of the messages written by the stream. If nil,
+	// DefaultMessages is used.
+	Messages *Messages
+
+	// Terminal configures the masked read of a password from Reader, when
+	// Reader is a terminal.
+	Terminal TerminalOptions
+
+	// warned is whether the unmasked password warning has been written.
+	warned bool
+
+	// inputEnded is whether Input has ended.
+	inputEnded bool
+
+	// codeTried is whether the code from Code, CodeFunc, or CodeCommand has
+	// been submitted, and codeResent is whether CodeFunc requested a resend.
+	codeTried  bool
+	codeResent bool
+
+	// scanner reads lines from scanReader, which is the Input or Reader at
+	// the time the scanner was created. Because the scanner buffers input, it
+	// is retained between prompts.
+	scanner    *bufio.Scanner
+	scanReader io.Reader
+	// pending receives the result of a scan that is still in progress, such
+	// as after a prompt has timed out.
+	pending chan scanResult
+}
+
+// scanResult is the result of scanning a line.
+type scanResult struct {
+	line []byte
+	err  error
+}
+
+// lines returns a scanner that reads lines from r.
+func (s *Stream) lines(r io.Reader) *bufio.Scanner {
+	if s.scanner == nil || s.scanReader != r {
+		s.scanner = bufio.NewScanner(r)
+		s.scanner.Split(bufio.ScanLines)
+		s.scanReader = r
+	}
+	return s.scanner
+}
+
+// scanLine reads a line from Input until it ends, then from Reader.
+func (s *Stream) scanLine() ([]byte, error) {
+	if line, ok, err := s.scanInput(); ok {
+		return line, err
+	}
+	return s.scan(s.Reader)
+}
+
+// scanInput reads a line from Input. Returns false if Input is nil or has
+// ended, in which case the line must be read from Reader instead.
+func (s *Stream) scanInput() (line []byte, ok bool, err error) {
+	if s.Input == nil || s.inputEnded {
+		return nil, false, nil
+	}
+	if line, err = s.scan(s.Input); err == ErrPromptEOF {
+		s.inputEnded = true
+		return nil, false, nil
+	}
+	return line, true, err
+}
+
+// scan reads a line from r. Returns ErrPromptEOF if r is nil or ends, or
+// ErrPromptTimeout if no line is read within Timeout. A scan interrupted by a
+// timeout continues in the background, and its result is received by the next
+// call.
+func (s *Stream) scan(r io.Reader) ([]byte, error) {
+	if r == nil {
+		return nil, ErrPromptEOF
+	}
+	if s.pending == nil || s.scanReader != r {
+		scanner := s.lines(r)
+		pending := make(chan scanResult, 1)
+		go func() {
+			if !scanner.Scan() {
+				err := scanner.Err()
+				switch err {
+				case nil:
+					err = ErrPromptEOF
+				case bufio.ErrTooLong:
+					err = fmt.Errorf("input line exceeds %d bytes", bufio.MaxScanTokenSize)
+				}
+				pending <- scanResult{err: err}
+				return
+			}
+			// Copy the line so that the buffer of the scanner, which may
+			// contain a secret, can be wiped.
+			b := scanner.Bytes()
+			line := append([]byte(nil), b...)
+			wipe(b)
+			pending <- scanResult{line: line}
+		}()
+		s.pending = pending
+	}
+	var timeout <-chan time.Time
+	if s.Timeout > 0 {
+		timer := time.NewTimer(s.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case r := <-s.pending:
+		s.pending = nil
+		return r.line, r.err
+	case <-timeout:
+		return nil, ErrPromptTimeout
+	case <-s.context().Done():
+		return nil, s.context().Err()
+	}
+}
+
+// context returns Context, or context.Background if Context is nil.
+func (s *Stream) context() context.Context {
+	if s.Context == nil {
+		return context.Background()
+	}
+	return s.Context
+}
+
+// scanText is like scanLine, but returns the line as a string.
+func (s *Stream) scanText() (string, error) {
+	b, err := s.scanLine()
+	return string(b), err
+}
+
+// trimNewline removes one trailing line ending from b.
+func trimNewline(b []byte) []byte {
+	if n := len(b); n > 0 && b[n-1] == '\n' {
+		b = b[:n-1]
+		if n := len(b); n > 0 && b[n-1] == '\r' {
+			b = b[:n-1]
+		}
+	}
+	return b
+}
+
+// sourcePassword returns the password from the configured password source.
+// Returns false if no source is configured. A trailing line ending is removed
+// from the password.
+func (s *Stream) sourcePassword() (password []byte, ok bool, err error) {
+	var n int
+	for _, set := range []bool{s.PasswordEnv != "", s.PasswordFile != "", s.PasswordReader != nil, len(s.PasswordCommand) > 0} {
+		if set {
+			n++
+		}
+	}
+	switch {
+	case n == 0:
+		return nil, false, nil
+	case n > 1:
+		return nil, true, errors.New("multiple password sources")
+	case s.PasswordEnv != "":
+		v, ok := os.LookupEnv(s.PasswordEnv)
+		if !ok {
+			return nil, true, fmt.Errorf("password variable %s is not set", s.PasswordEnv)
+		}
+		return trimNewline([]byte(v)), true, nil
+	case s.PasswordFile != "":
+		password, err = ioutil.ReadFile(s.PasswordFile)
+	case len(s.PasswordCommand) > 0:
+		password, err = s.commandSecret(s.PasswordCommand)
+		return password, true, err
+	default:
+		password, err = ioutil.ReadAll(s.PasswordReader)
+	}
+	if err != nil {
+		wipe(password)
+		return nil, true, fmt.Errorf("read password: %w", err)
+	}
+	return trimNewline(password), true, nil
+}
+
+// PasswordAttempts implements PasswordRetrier, returning MaxPasswordAttempts,
+// or 1 if the password is received from a source.
+func (s *Stream) PasswordAttempts() int {
+	if s.PasswordEnv != "" || s.PasswordFile != "" || s.PasswordReader != nil || len(s.PasswordCommand) > 0 {
+		return 1
+	}
+	if s.MaxPasswordAttempts <= 0 {
+		return DefaultPasswordAttempts
+	}
+	return s.MaxPasswordAttempts
+}
+
+// fder is implemented by a file, such as an *os.File.
+type fder interface {
+	Fd() uintptr
+}
+
+// terminalFd returns the file descriptor of v, and whether it is a terminal.
+func terminalFd(v interface{}) (int, bool) {
+	f, ok := v.(fder)
+	if !ok {
+		return 0, false
+	}
+	fd := int(f.Fd())
+	return fd, term.IsTerminal(fd)
+}
+
+// readSecret reads a line without echoing it, if possible. A line from Input
+// is never echoed. A terminal is restored as with readPassword, including when
+// the read is interrupted.
+func (s *Stream) readSecret() ([]byte, error) {
+	if line, ok, err := s.scanInput(); ok {
+		return line, err
+	}
+	if fd, ok := terminalFd(s.Reader); ok {
+		// Safely read from the terminal.
+		b, err := readPassword(fd, s.Terminal)
+		s.write("\n")
+		if err == io.EOF {
+			err = ErrPromptEOF
+		}
+		return b, err
+	}
+	// Fallback to scan.
+	return s.scan(s.Reader)
+}
+
+// PromptMessages implements MessagePrompter by returning Messages, or
+// DefaultMessages if Messages is nil.
+func (s *Stream) PromptMessages() *Messages {
+	if s.Messages == nil {
+		return &DefaultMessages
+	}
+	return s.Messages
 }
 
 // write prints to Writer if it exists.
@@ -42,134 +391,271 @@ func (s *Stream) writef(format string, a ...interface{}) (n int, err error) {
 // are empty, then they will be prompted as well.
 //
 // Returns the updated cred and cookies, or any error that may have occurred.
-func (s *Stream) PromptCred(cred Cred) (credout Cred, cookies []*http.Cookie, err error) {
-	defer func() {
-		if err != nil {
-			err = fmt.Errorf("prompt: %w", err)
-		}
-	}()
-	if s.Reader == nil {
-		return cred, nil, errors.New("stream is missing reader")
+// If the input ends before a verification code is entered, then the error is
+// a *TwoStepError, from which the login can be continued. PromptResult
+// returns further details about the login.
+func (s *Stream) PromptCred(cred Cred) (Cred, []*http.Cookie, error) {
+	cred, result, err := s.PromptResult(cred)
+	if err != nil {
+		return cred, nil, err
 	}
+	return cred, result.Cookies, nil
+}
 
-	switch cred.Type {
-	case "Username", "Email", "PhoneNumber", "":
-	default:
-		return cred, nil, fmt.Errorf("invalid credential type %q", cred.Type)
+// PromptResult is like PromptCred, but returns the result of the login as a
+// LoginResult. The Cookies of the result are those of the completed login,
+// while Step and Challenge are those that were completed, if any. If two-step
+// verification was completed, then RememberDevice and Resends describe how.
+func (s *Stream) PromptResult(cred Cred) (Cred, *LoginResult, error) {
+	if s.Reader == nil && s.Input == nil {
+		return cred, nil, fmt.Errorf("prompt: %w", errors.New("stream is missing reader"))
 	}
+	s.codeTried, s.codeResent = false, false
+	if s.CheckMetadata {
+		// Metadata is advisory, so failing to get it is not an error.
+		if meta, err := s.Config.MetadataContext(s.context()); err == nil && meta.CaptchaEnforced {
+			s.Notify(s.PromptMessages().CaptchaWarning)
+		}
+	}
+	return s.Config.loginWithPrompter(s.context(), s, cred)
+}
 
-	scanner := bufio.NewScanner(s.Reader)
-	scanner.Split(bufio.ScanLines)
-
-	// Prompt for credential type.
-	for cred.Type == "" {
-		s.write("Enter credential type ((Username), Email, PhoneNumber): ")
-		if scanner.Scan(); scanner.Err() != nil {
-			return cred, nil, scanner.Err()
+// AskCredType implements Prompter by prompting until a known credential type
+// is entered.
+func (s *Stream) AskCredType() (credType string, err error) {
+	m := s.PromptMessages()
+	for credType == "" {
+		s.write(m.AskCredType)
+		text, err := s.scanText()
+		if err != nil {
+			return "", err
 		}
-		cred.Type = strings.ToLower(scanner.Text())
-		switch cred.Type {
+		credType = strings.ToLower(text)
+		switch credType {
 		case "username", "user", "u", "":
-			cred.Type = "Username"
+			credType = "Username"
 		case "email", "e":
-			cred.Type = "Email"
+			credType = "Email"
 		case "phonenumber", "phone number", "pn":
-			cred.Type = "PhoneNumber"
+			credType = "PhoneNumber"
 		default:
 			// TODO: maybe support whatever was entered, for forward
 			// compatibility with the API.
-			s.writef("Unknown credential type %q\n", cred.Type)
-			cred.Type = ""
+			s.writef(m.UnknownCredType+"\n", credType)
+			credType = ""
 		}
 	}
+	return credType, nil
+}
 
-	// Prompt for identifier.
-	for cred.Ident == "" {
-		var msg string
-		switch cred.Type {
+// AskIdent implements Prompter by prompting until an identifier is entered.
+func (s *Stream) AskIdent(credType string) (ident string, err error) {
+	m := s.PromptMessages()
+	for ident == "" {
+		switch credType {
 		case "Username":
-			msg = "Enter username: "
+			s.write(m.AskUsername)
 		case "Email":
-			msg = "Enter email: "
+			s.write(m.AskEmail)
 		case "PhoneNumber":
-			msg = "Enter phone number: "
+			s.write(m.AskPhoneNumber)
+		case Auto:
+			s.write(m.AskAnyIdent)
 		default:
-			msg = "Enter " + cred.Type + ": "
+			s.writef(m.AskIdent, credType)
 		}
-		s.write(msg)
-		if scanner.Scan(); scanner.Err() != nil {
-			return cred, nil, scanner.Err()
+		if ident, err = s.scanText(); err != nil {
+			return "", err
 		}
-		cred.Ident = scanner.Text()
 	}
+	return ident, nil
+}
 
-	// Prompt for password.
-	s.writef("Enter password for %s: ", cred.Ident)
-	var password []byte
-	if s.Reader == os.Stdin {
-		// Safely read from stdin.
-		password, err = terminal.ReadPassword(int(syscall.Stdin))
-		os.Stdout.Write([]byte{'\n'})
+// ConfirmCredType implements CredTypeConfirmer. If the detected type is
+// rejected, then the type is prompted.
+func (s *Stream) ConfirmCredType(ident, detected string, ambiguous bool) (string, error) {
+	if s.NoConfirm {
+		return detected, nil
+	}
+	m := s.PromptMessages()
+	if ambiguous {
+		for {
+			s.writef(m.AskUsernameOrID, ident)
+			text, err := s.scanText()
+			if err != nil {
+				return "", err
+			}
+			switch strings.ToLower(text) {
+			case "username", "user", "u", "":
+				return Username, nil
+			case "id", "userid", "user id", "i":
+				return UserID, nil
+			}
+		}
+	}
+	for {
+		s.writef(m.ConfirmCredType, detected)
+		text, err := s.scanText()
 		if err != nil {
-			return cred, nil, err
+			return "", err
 		}
-	} else {
-		// Fallback to scan.
-		if scanner.Scan(); scanner.Err() != nil {
-			return cred, nil, scanner.Err()
+		if yes, ok := m.answer(text, true); ok {
+			if yes {
+				return detected, nil
+			}
+			return s.AskCredType()
 		}
-		password = scanner.Bytes()
 	}
+}
 
-	// Login.
-	cookies, step, err := s.Config.LoginCred(cred, password)
-	if err != nil {
-		return cred, nil, err
+// AskPassword implements Prompter. The password is prompted, unless it is
+// received from a source.
+func (s *Stream) AskPassword(ident string) ([]byte, error) {
+	password, ok, err := s.sourcePassword()
+	if err != nil || ok {
+		return password, err
 	}
+	m := s.PromptMessages()
+	if _, ok := terminalFd(s.Reader); !ok && !s.Quiet && !s.warned {
+		s.write(m.NotTerminal, "\n")
+		s.warned = true
+	}
+	if s.RedactIdent {
+		s.write(m.AskPassword)
+	} else {
+		s.writef(m.AskPasswordFor, ident)
+	}
+	return s.readSecret()
+}
 
-	if step != nil {
-		var code string
-		var remember bool
-
-		// Prompt for verification code.
-		s.writef("Two-step verification code sent via %s\n", step.MediaType)
-		for {
-			s.write("Enter code (leave empty to resend): ")
-			if scanner.Scan(); scanner.Err() != nil {
-				return cred, nil, scanner.Err()
-			}
-			if code = scanner.Text(); code != "" {
-				break
+// AskCode implements Prompter. An empty line requests that the code be
+// resent. The first code is received from CodeCommand, CodeFunc, or Code, if
+// set.
+func (s *Stream) AskCode(mediaType string) (string, CodeAction, error) {
+	if !s.codeTried && len(s.CodeCommand) > 0 {
+		code, err := s.commandSecret(s.CodeCommand)
+		if err != nil {
+			return "", CodeSubmit, err
+		}
+		s.codeTried = true
+		return string(code), CodeSubmit, nil
+	}
+	if !s.codeTried && (s.CodeFunc != nil || s.Code != "") {
+		code := s.Code
+		if s.CodeFunc != nil {
+			var err error
+			if code, err = s.CodeFunc(mediaType); err != nil {
+				return "", CodeSubmit, err
 			}
-			if err := step.Resend(); err != nil {
-				return cred, nil, err
+			if code == "" && !s.codeResent {
+				s.codeResent = true
+				return "", CodeResend, nil
 			}
-			s.writef("Resent verification code via %s\n", step.MediaType)
 		}
+		s.codeTried = true
+		if code != "" {
+			return code, CodeSubmit, nil
+		}
+	}
+	if s.codeTried && s.NoFallback {
+		return "", CodeSubmit, fmt.Errorf("supplied code: %w", ErrInvalidCode)
+	}
+	s.write(s.PromptMessages().AskCode)
+	code, err := s.scanText()
+	if err != nil {
+		return "", CodeSubmit, err
+	}
+	if code != "" {
+		return code, CodeSubmit, nil
+	}
+	if len(s.CodeCommand) > 0 {
+		// Get the resent code from the command.
+		s.codeTried = false
+	}
+	return "", CodeResend, nil
+}
 
-		// Prompt for remember device.
-	loop:
-		for {
-			s.write("Remember device? ((no), yes): ")
-			if scanner.Scan(); scanner.Err() != nil {
-				return cred, nil, scanner.Err()
-			}
-			switch text := strings.ToLower(scanner.Text()); text {
-			case "y", "yes":
-				remember = true
-				break loop
-			case "n", "no", "":
-				break loop
-			}
+// AskRememberDevice implements Prompter by prompting until yes or no is
+// entered. The prompt is skipped if RememberDevice is RememberAlways or
+// RememberNever.
+func (s *Stream) AskRememberDevice() (bool, error) {
+	switch s.RememberDevice {
+	case RememberAlways:
+		return true, nil
+	case RememberNever:
+		return false, nil
+	}
+	m := s.PromptMessages()
+	for {
+		s.write(m.AskRememberDevice)
+		text, err := s.scanText()
+		if err != nil {
+			return false, err
+		}
+		if yes, ok := m.answer(text, false); ok {
+			return yes, nil
 		}
+	}
+}
 
-		// Verify code.
-		if cookies, err = step.Verify(code, remember); err != nil {
-			return cred, nil, err
+// Notify implements Prompter by writing msg as a line.
+func (s *Stream) Notify(msg string) {
+	s.write(msg, "\n")
+}
+
+// AskPIN implements PINPrompter. The PIN is prompted only if PromptPIN is set.
+func (s *Stream) AskPIN() ([]byte, error) {
+	if !s.PromptPIN {
+		return nil, nil
+	}
+	s.write(s.PromptMessages().AskPIN)
+	return s.readSecret()
+}
+
+// AskQuestion implements QuestionPrompter. The choices are written as a
+// numbered list, and the numbers of the selected choices are read, separated
+// by spaces or commas.
+func (s *Stream) AskQuestion(q *SecurityQuestion) ([]string, error) {
+	m := s.PromptMessages()
+	s.write(q.Prompt, "\n")
+	for i, choice := range q.Choices {
+		s.writef("%d. %s\n", i+1, choice)
+	}
+loop:
+	for {
+		s.write(m.AskItems)
+		text, err := s.scanText()
+		if err != nil {
+			return nil, err
+		}
+		fields := strings.FieldsFunc(text, func(r rune) bool {
+			return r == ',' || unicode.IsSpace(r)
+		})
+		if len(fields) == 0 {
+			continue
+		}
+		selected := make([]string, 0, len(fields))
+		for _, field := range fields {
+			n, err := strconv.Atoi(field)
+			if err != nil || n < 1 || n > len(q.Choices) {
+				s.writef(m.InvalidItem+"\n", field)
+				continue loop
+			}
+			selected = append(selected, q.Choices[n-1])
 		}
+		return selected, nil
 	}
+}
 
-	return cred, cookies, nil
+// PromptSession wraps PromptCred, returning the cookies as a Session.
+func (s *Stream) PromptSession(cred Cred) (Cred, *Session, error) {
+	cred, result, err := s.PromptResult(cred)
+	if err != nil {
+		return cred, nil, err
+	}
+	sess := NewSession(s.Config, result.Cookies)
+	sess.user = result.User
+	return cred, sess, nil
 }
 
 // Prompt wraps PromptCred, using a username for the credentials. If the
@@ -188,23 +674,208 @@ func (s *Stream) Prompt(username string) (cred Cred, cookies []*http.Cookie, err
 // Note that an initial request must be made in order to associate the ID with
 // its corresponding credentials.
 func (s *Stream) PromptID(userID int64) (cred Cred, cookies []*http.Cookie, err error) {
+	username, err := s.promptUsername(userID)
+	if err != nil {
+		return Cred{}, nil, err
+	}
+	return s.PromptCred(Cred{Type: "Username", Ident: username})
+}
+
+// promptUsername returns the username of the given user ID. If the ID is less
+// than 1, then it is prompted.
+func (s *Stream) promptUsername(userID int64) (username string, err error) {
+	defer wrapOp("prompt", &err)
 	if userID < 1 {
-		if s.Reader != nil {
-			return cred, nil, fmt.Errorf("prompt: %w", errors.New("stream is missing reader"))
+		if s.Reader == nil && s.Input == nil {
+			return "", errors.New("stream is missing reader")
+		}
+		m := s.PromptMessages()
+		for userID < 1 {
+			s.write(m.AskUserID)
+			text, err := s.scanText()
+			if err != nil {
+				return "", err
+			}
+			text = strings.TrimSpace(text)
+			id, err := strconv.ParseInt(text, 10, 64)
+			if err != nil || id < 1 {
+				s.writef(m.InvalidUserID+"\n", text)
+				continue
+			}
+			userID = id
 		}
-		scanner := bufio.NewScanner(s.Reader)
-		scanner.Split(bufio.ScanLines)
 	}
+	return s.getUsername(s.context(), userID)
+}
 
-	url := s.Config.UserIDEndpoint
-	if url == "" {
-		url = DefaultUserIDEndpoint
+// PromptPasswordReset prompts a user to reset the password of an account
+// through the specified input stream. If cred.Type and/or cred.Ident are
+// empty, then they will be prompted as well. The type must be Email,
+// PhoneNumber, or Auto.
+//
+// The code sent to the account is received like a two-step verification code.
+// If multiple accounts are associated with the credentials, then the account
+// is prompted. The new password is received from a password source if one is
+// configured, and is otherwise prompted twice to confirm it.
+//
+// Returns the updated cred and the cookies of a session of the account.
+func (s *Stream) PromptPasswordReset(cred Cred) (_ Cred, cookies []*http.Cookie, err error) {
+	defer wrapOp("prompt", &err)
+	if s.Reader == nil && s.Input == nil {
+		return cred, nil, errors.New("stream is missing reader")
 	}
-	username, err := s.getUsername(userID)
+	s.codeTried, s.codeResent = false, false
+	ctx := s.context()
+	m := s.PromptMessages()
+
+	for cred.Type == "" {
+		s.write(m.AskResetCredType)
+		text, err := s.scanText()
+		if err != nil {
+			return cred, nil, err
+		}
+		switch strings.ToLower(text) {
+		case "email", "e", "":
+			cred.Type = Email
+		case "phonenumber", "phone number", "phone", "pn":
+			cred.Type = PhoneNumber
+		default:
+			s.writef(m.UnknownCredType+"\n", text)
+		}
+	}
+	if cred.Ident == "" {
+		if cred.Ident, err = s.AskIdent(cred.Type); err != nil {
+			return cred, nil, err
+		}
+	}
+	if cred.Type == Auto {
+		cred.Type, _ = DetectCredType(cred.Ident)
+	}
+
+	step, err := s.Config.StartPasswordResetContext(ctx, cred)
 	if err != nil {
-		return Cred{}, nil, fmt.Errorf("prompt: %w", err)
+		return cred, nil, err
+	}
+	switch step.MediaType {
+	case MediaEmail:
+		s.Notify(m.ResetCodeSentEmail)
+	default:
+		s.Notify(fmt.Sprintf(m.ResetCodeSent, step.MediaType))
+	}
+	if err := s.promptResetCode(ctx, step); err != nil {
+		return cred, nil, err
+	}
+	if len(step.UserIDs) > 1 {
+		if step.UserID, err = s.askResetUser(step.UserIDs); err != nil {
+			return cred, nil, err
+		}
+	}
+
+	for attempt, attempts := 1, s.PasswordAttempts(); ; attempt++ {
+		password, err := s.askNewPassword()
+		if err != nil {
+			return cred, nil, err
+		}
+		cookies, err = step.SetNewPasswordContext(ctx, password)
+		wipe(password)
+		if errors.Is(err, ErrPasswordWeak) && attempt < attempts {
+			s.Notify(m.PasswordNotAcceptable)
+			continue
+		}
+		return cred, cookies, err
+	}
+}
+
+// promptResetCode prompts for the code of a password reset until it is
+// verified. An incorrect code is prompted again up to CodeRetries times.
+func (s *Stream) promptResetCode(ctx context.Context, step *ResetStep) error {
+	retries := s.CodeRetries
+	if retries == 0 {
+		retries = DefaultCodeRetries
+	}
+	m := s.PromptMessages()
+	for attempt := 0; ; attempt++ {
+		code, action, err := s.AskCode(string(step.MediaType))
+		for err == nil && action == CodeResend {
+			if err = step.ResendContext(ctx); err == nil {
+				s.Notify(fmt.Sprintf(m.ResetCodeResent, step.MediaType))
+				code, action, err = s.AskCode(string(step.MediaType))
+			}
+		}
+		if err != nil {
+			return err
+		}
+		err = step.VerifyContext(ctx, code)
+		if errors.Is(err, ErrInvalidCode) && attempt < retries {
+			s.Notify(m.IncorrectCode)
+			continue
+		}
+		return err
+	}
+}
+
+// askResetUser prompts until one of the given user IDs is selected. An empty
+// line selects the first.
+func (s *Stream) askResetUser(userIDs []int64) (int64, error) {
+	m := s.PromptMessages()
+	s.write(m.MultipleAccounts, "\n")
+	for _, id := range userIDs {
+		s.writef("\t%d\n", id)
+	}
+	for {
+		s.writef(m.AskResetUser, userIDs[0])
+		text, err := s.scanText()
+		if err != nil {
+			return 0, err
+		}
+		if text = strings.TrimSpace(text); text == "" {
+			return userIDs[0], nil
+		}
+		id, _ := strconv.ParseInt(text, 10, 64)
+		for _, u := range userIDs {
+			if u == id {
+				return id, nil
+			}
+		}
+		s.writef(m.InvalidUserID+"\n", text)
+	}
+}
+
+// askNewPassword returns a new password from a password source, or prompts
+// for the password until it is entered identically twice.
+func (s *Stream) askNewPassword() ([]byte, error) {
+	password, ok, err := s.sourcePassword()
+	if err != nil || ok {
+		return password, err
+	}
+	m := s.PromptMessages()
+	if _, ok := terminalFd(s.Reader); !ok && !s.Quiet && !s.warned {
+		s.write(m.NotTerminal, "\n")
+		s.warned = true
+	}
+	for {
+		s.write(m.AskNewPassword)
+		password, err := s.readSecret()
+		if err != nil {
+			return nil, err
+		}
+		if len(password) == 0 {
+			continue
+		}
+		s.write(m.ConfirmNewPassword)
+		confirm, err := s.readSecret()
+		if err != nil {
+			wipe(password)
+			return nil, err
+		}
+		same := bytes.Equal(password, confirm)
+		wipe(confirm)
+		if same {
+			return password, nil
+		}
+		wipe(password)
+		s.Notify(m.PasswordMismatch)
 	}
-	return s.PromptCred(Cred{Type: "Username", Ident: username})
 }
 
 // StandardStream returns a Stream connected to stdin and stderr.
@@ -0,0 +1,67 @@
+package rbxauth_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/anaminus/rbxauth"
+	"github.com/anaminus/rbxauth/rbxauthtest"
+)
+
+// The seed corpus of each target is in testdata/fuzz.
+
+func FuzzPromptCredInput(f *testing.F) {
+	f.Fuzz(func(t *testing.T, input string) {
+		srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass"})
+		srv.AddAccount(rbxauthtest.Account{
+			ID: 2, Name: "bob", Password: "pass",
+			Email: "bob@example.com", PhoneNumber: "5551234567",
+			TwoStep: true, Code: "123456",
+		})
+		cfg := srv.Config()
+		cfg.ResendCooldown = -1
+		s := &rbxauth.Stream{
+			Config: cfg,
+			Reader: strings.NewReader(input),
+			Writer: ioutil.Discard,
+			Quiet:  true,
+		}
+		cred, cookies, err := s.PromptCred(rbxauth.Cred{})
+		if err != nil {
+			if cookies != nil {
+				t.Fatalf("expected no cookies with error %v", err)
+			}
+			return
+		}
+		// A login succeeds only with the correct answers.
+		user, err := cfg.Authenticated(cookies)
+		if err != nil {
+			t.Fatalf("session of %+v is not valid: %v", cred, err)
+		}
+		if user.ID != 1 && user.ID != 2 {
+			t.Fatalf("unexpected user %+v", user)
+		}
+	})
+}
+
+func TestStreamLongLine(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass"})
+	s := &rbxauth.Stream{
+		Config: srv.Config(),
+		Reader: strings.NewReader(strings.Repeat("x", 1<<17) + "\npass\n"),
+		Writer: ioutil.Discard,
+		Quiet:  true,
+	}
+	_, _, err := s.PromptCred(rbxauth.Cred{Type: "Username"})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if errors.Is(err, rbxauth.ErrPromptEOF) || !strings.Contains(err.Error(), "input line exceeds") {
+		t.Errorf("expected oversized line error, got %v", err)
+	}
+	if n := srv.Count(rbxauthtest.LoginPath); n != 0 {
+		t.Errorf("expected no login, got %d requests", n)
+	}
+}
@@ -0,0 +1,468 @@
+package rbxauth_test
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anaminus/rbxauth"
+	"github.com/anaminus/rbxauth/rbxauthtest"
+)
+
+func TestStreamPasswordSources(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass"})
+	dir := t.TempDir()
+	const env = "RBXAUTH_TEST_STREAM_PASSWORD"
+	cred := rbxauth.Cred{Type: "Username", Ident: "alice"}
+
+	for _, test := range []struct {
+		name     string
+		password string
+		// err is the expected error, or nil if the login succeeds.
+		err error
+	}{
+		{"plain", "pass", nil},
+		{"LF", "pass\n", nil},
+		{"CRLF", "pass\r\n", nil},
+		// Only one line ending is removed.
+		{"two LF", "pass\n\n", rbxauth.ErrBadCredentials},
+		{"CR", "pass\r", rbxauth.ErrBadCredentials},
+		{"space", "pass ", rbxauth.ErrBadCredentials},
+	} {
+		file := filepath.Join(dir, test.name)
+		if err := ioutil.WriteFile(file, []byte(test.password), 0600); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv(env, test.password)
+		for _, source := range []struct {
+			name string
+			set  func(*rbxauth.Stream)
+		}{
+			{"env", func(s *rbxauth.Stream) { s.PasswordEnv = env }},
+			{"file", func(s *rbxauth.Stream) { s.PasswordFile = file }},
+			{"reader", func(s *rbxauth.Stream) { s.PasswordReader = strings.NewReader(test.password) }},
+		} {
+			s := &rbxauth.Stream{
+				Config: srv.Config(),
+				Reader: strings.NewReader(""),
+				Writer: ioutil.Discard,
+			}
+			source.set(s)
+			_, cookies, err := s.PromptCred(cred)
+			switch {
+			case test.err == nil && err != nil:
+				t.Errorf("%s %s: unexpected error: %v", source.name, test.name, err)
+			case test.err == nil && len(cookies) == 0:
+				t.Errorf("%s %s: expected cookies", source.name, test.name)
+			case test.err != nil && !errors.Is(err, test.err):
+				t.Errorf("%s %s: expected %v, got %v", source.name, test.name, test.err, err)
+			}
+		}
+	}
+}
+
+func TestStreamPasswordSourceErrors(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass"})
+	cred := rbxauth.Cred{Type: "Username", Ident: "alice"}
+	for _, test := range []struct {
+		name string
+		set  func(*rbxauth.Stream)
+		err  string
+	}{
+		{"unset env", func(s *rbxauth.Stream) { s.PasswordEnv = "RBXAUTH_TEST_UNSET" }, "is not set"},
+		{"missing file", func(s *rbxauth.Stream) { s.PasswordFile = filepath.Join(t.TempDir(), "missing") }, "read password"},
+		{"multiple", func(s *rbxauth.Stream) {
+			s.PasswordEnv = "RBXAUTH_TEST_UNSET"
+			s.PasswordReader = strings.NewReader("pass")
+		}, "multiple password sources"},
+	} {
+		s := &rbxauth.Stream{
+			Config: srv.Config(),
+			Reader: strings.NewReader("pass\n"),
+			Writer: ioutil.Discard,
+		}
+		test.set(s)
+		_, _, err := s.PromptCred(cred)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: expected error containing %q, got %v", test.name, test.err, err)
+		}
+	}
+	if n := srv.Count(rbxauthtest.LoginPath); n != 0 {
+		t.Errorf("expected no login requests, got %d", n)
+	}
+}
+
+func TestStreamPromptID(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{ID: 42, Name: "alice", Password: "pass"})
+	for _, test := range []struct {
+		name  string
+		id    int64
+		input string
+		// invalid is the number of rejected IDs written to the output.
+		invalid int
+		// status is the expected HTTP status of the error, or zero if the
+		// login succeeds.
+		status int
+	}{
+		{"argument", 42, "pass\n", 0, 0},
+		{"prompted", 0, "42\npass\n", 0, 0},
+		{"space", 0, "  42 \npass\n", 0, 0},
+		{"garbage", 0, "abc\n-1\n0\n\n42\npass\n", 4, 0},
+		{"not found", 0, "7\npass\n", 0, 404},
+		{"argument not found", 7, "pass\n", 0, 404},
+	} {
+		var out strings.Builder
+		s := &rbxauth.Stream{
+			Config: srv.Config(),
+			Reader: strings.NewReader(test.input),
+			Writer: &out,
+			Quiet:  true,
+		}
+		cred, cookies, err := s.PromptID(test.id)
+		if n := strings.Count(out.String(), "Invalid user ID"); n != test.invalid {
+			t.Errorf("%s: expected %d invalid IDs, got %d", test.name, test.invalid, n)
+		}
+		if test.status != 0 {
+			if code, ok := rbxauth.HTTPStatus(err); !ok || code != test.status {
+				t.Errorf("%s: expected status %d, got %v", test.name, test.status, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if cred != (rbxauth.Cred{Type: "Username", Ident: "alice"}) {
+			t.Errorf("%s: unexpected cred %+v", test.name, cred)
+		}
+		if len(cookies) == 0 {
+			t.Errorf("%s: expected cookies", test.name)
+		}
+	}
+
+	// The input ends before an ID is entered.
+	s := &rbxauth.Stream{Config: srv.Config(), Reader: strings.NewReader("abc\n"), Writer: ioutil.Discard}
+	if _, _, err := s.PromptID(0); !errors.Is(err, rbxauth.ErrPromptEOF) {
+		t.Errorf("EOF: expected ErrPromptEOF, got %v", err)
+	}
+}
+
+func TestStreamAutoDetect(t *testing.T) {
+	srv := rbxauthtest.NewServer()
+	t.Cleanup(srv.Close)
+	srv.AddAccount(rbxauthtest.Account{
+		ID: 12345, Name: "alice", Password: "pass",
+		Email: "alice@example.com", PhoneNumber: "+1 555-123-4567",
+	})
+	srv.AddAccount(rbxauthtest.Account{ID: 2, Name: "5551234567", Password: "pass"})
+
+	for _, test := range []struct {
+		name      string
+		ident     string
+		input     string
+		noConfirm bool
+		// credType is the type of the resulting cred.
+		credType string
+		id       int64
+	}{
+		{"email default", "alice@example.com", "\npass\n", false, "Email", 12345},
+		{"email yes", "alice@example.com", "y\npass\n", false, "Email", 12345},
+		{"phone", "+1 555-123-4567", "yes\npass\n", false, "PhoneNumber", 12345},
+		{"invalid answer", "alice@example.com", "maybe\ny\npass\n", false, "Email", 12345},
+		{"username", "alice", "y\npass\n", false, "Username", 12345},
+		{"rejected", "5551234567", "n\nu\npass\n", false, "Username", 2},
+		{"ambiguous username", "12345", "\npass\n", false, "Username", 0},
+		{"ambiguous id", "12345", "id\npass\n", false, "UserID", 12345},
+		{"no confirm", "alice@example.com", "pass\n", true, "Email", 12345},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var out strings.Builder
+			s := &rbxauth.Stream{
+				Config:    srv.Config(),
+				Reader:    strings.NewReader(test.input),
+				Writer:    &out,
+				Quiet:     true,
+				NoConfirm: test.noConfirm,
+			}
+			cred, cookies, err := s.PromptCred(rbxauth.Cred{Type: rbxauth.Auto, Ident: test.ident})
+			if test.id == 0 {
+				// No account has the username "12345".
+				if !errors.Is(err, rbxauth.ErrBadCredentials) {
+					t.Fatalf("expected ErrBadCredentials, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("prompt: %v\n%s", err, out.String())
+			}
+			if cred.Type != test.credType {
+				t.Errorf("expected type %s, got %s", test.credType, cred.Type)
+			}
+			checkSession(t, srv.Config(), cookies, rbxauth.UserInfo{ID: test.id})
+			if test.noConfirm && strings.Contains(out.String(), "correct?") {
+				t.Errorf("expected no confirmation, got %q", out.String())
+			}
+		})
+	}
+}
+
+func TestStreamTruncatedInput(t *testing.T) {
+	for _, test := range []struct {
+		stage string
+		cred  rbxauth.Cred
+		input string
+		// login is whether the password was submitted.
+		login bool
+	}{
+		{"type", rbxauth.Cred{}, "", false},
+		{"ident", rbxauth.Cred{Type: "Username"}, "", false},
+		{"ident after type", rbxauth.Cred{}, "username\n", false},
+		{"password", rbxauth.Cred{Type: "Username", Ident: "alice"}, "", false},
+		{"code", rbxauth.Cred{Type: "Username", Ident: "alice"}, "pass\n", true},
+		{"remember", rbxauth.Cred{Type: "Username", Ident: "alice"}, "pass\n123456\n", true},
+		{"remember invalid", rbxauth.Cred{Type: "Username", Ident: "alice"}, "pass\n123456\nmaybe\n", true},
+	} {
+		t.Run(test.stage, func(t *testing.T) {
+			srv := newServer(t, rbxauthtest.Account{
+				ID: 1, Name: "alice", Password: "pass",
+				TwoStep: true, Code: "123456",
+			})
+			cfg := srv.Config()
+			cfg.LegacyTwoStep = true
+			var out strings.Builder
+			s := &rbxauth.Stream{
+				Config: cfg,
+				Reader: strings.NewReader(test.input),
+				Writer: &out,
+				Quiet:  true,
+			}
+			done := make(chan error, 1)
+			go func() {
+				_, _, err := s.PromptCred(test.cred)
+				done <- err
+			}()
+			var err error
+			select {
+			case err = <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("prompt did not terminate")
+			}
+			if !errors.Is(err, rbxauth.ErrPromptEOF) {
+				t.Fatalf("expected ErrPromptEOF, got %v", err)
+			}
+			if login := srv.Count(rbxauthtest.LoginPath) > 0; login != test.login {
+				t.Errorf("expected login %t, got %t", test.login, login)
+			}
+			if n := srv.Count(rbxauthtest.VerifyPath); n != 0 {
+				t.Errorf("expected no verification, got %d", n)
+			}
+			if test.login {
+				// The step can be continued by other means.
+				var serr *rbxauth.TwoStepError
+				if !errors.As(err, &serr) || serr.Step == nil {
+					t.Errorf("expected TwoStepError, got %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestStreamTimeout(t *testing.T) {
+	r, w := io.Pipe()
+	defer w.Close()
+	s := &rbxauth.Stream{
+		Reader:  r,
+		Writer:  ioutil.Discard,
+		Quiet:   true,
+		Timeout: 50 * time.Millisecond,
+	}
+	start := time.Now()
+	_, err := s.AskCredType()
+	if !errors.Is(err, rbxauth.ErrPromptTimeout) {
+		t.Fatalf("expected ErrPromptTimeout, got %v", err)
+	}
+	if d := time.Since(start); d < s.Timeout || d > 5*time.Second {
+		t.Errorf("expected timeout after %v, got %v", s.Timeout, d)
+	}
+}
+
+func TestStreamRememberDevice(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		mode  rbxauth.RememberMode
+		input string
+		// remember is the value expected to be sent with the code.
+		remember bool
+	}{
+		{"ask yes", rbxauth.RememberAsk, "pass\n123456\ny\n", true},
+		{"ask YES", rbxauth.RememberAsk, "pass\n123456\nYES\n", true},
+		{"ask no", rbxauth.RememberAsk, "pass\n123456\nn\n", false},
+		{"ask default", rbxauth.RememberAsk, "pass\n123456\n\n", false},
+		{"ask invalid", rbxauth.RememberAsk, "pass\n123456\nmaybe\ny\n", true},
+		{"always", rbxauth.RememberAlways, "pass\n123456\n", true},
+		{"never", rbxauth.RememberNever, "pass\n123456\n", false},
+	} {
+		for _, legacy := range []bool{false, true} {
+			srv := newServer(t, rbxauthtest.Account{
+				ID: 1, Name: "alice", Password: "pass",
+				TwoStep: true, Code: "123456",
+			})
+			cfg := srv.Config()
+			cfg.LegacyTwoStep = legacy
+			var out strings.Builder
+			s := &rbxauth.Stream{
+				Config:         cfg,
+				Reader:         strings.NewReader(test.input),
+				Writer:         &out,
+				Quiet:          true,
+				RememberDevice: test.mode,
+			}
+			_, result, err := s.PromptResult(rbxauth.Cred{Type: "Username", Ident: "alice"})
+			if err != nil {
+				t.Errorf("%s, legacy=%t: %v", test.name, legacy, err)
+				continue
+			}
+			if got := srv.LastVerify(); got.Code != "123456" || got.RememberDevice != test.remember {
+				t.Errorf("%s, legacy=%t: expected remember %t, got %+v", test.name, legacy, test.remember, got)
+			}
+			if result.RememberDevice != test.remember {
+				t.Errorf("%s, legacy=%t: expected result remember %t, got %t", test.name, legacy, test.remember, result.RememberDevice)
+			}
+			if has := cookieValue(result.Cookies, rbxauthtest.DeviceCookieName) != ""; has != test.remember {
+				t.Errorf("%s, legacy=%t: expected device cookie %t, got %t", test.name, legacy, test.remember, has)
+			}
+			prompted := strings.Contains(out.String(), rbxauth.DefaultMessages.AskRememberDevice)
+			if want := test.mode == rbxauth.RememberAsk; prompted != want {
+				t.Errorf("%s, legacy=%t: expected prompt %t, got %t", test.name, legacy, want, prompted)
+			}
+		}
+	}
+}
+
+func TestStreamCodeFunc(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		script     []string
+		input      string
+		noFallback bool
+		err        error
+		// verifies, resends, and prompts are the expected number of verify
+		// requests, resend requests, and code prompts.
+		verifies, resends, prompts int
+	}{
+		{"success", []string{"123456"}, "pass\n", false, nil, 1, 0, 0},
+		{"invalid then prompt", []string{"000000"}, "pass\n123456\n", false, nil, 2, 0, 1},
+		{"invalid without fallback", []string{"000000"}, "pass\n123456\n", true, rbxauth.ErrInvalidCode, 1, 0, 0},
+		{"resend", []string{"", "123456"}, "pass\n", false, nil, 1, 1, 0},
+		{"resend once", []string{"", ""}, "pass\n123456\n", false, nil, 1, 1, 1},
+	} {
+		srv := newServer(t, rbxauthtest.Account{
+			ID: 1, Name: "alice", Password: "pass",
+			TwoStep: true, MediaType: "Email", Code: "123456",
+		})
+		cfg := srv.Config()
+		cfg.LegacyTwoStep = true
+		cfg.ResendCooldown = -1
+
+		script := test.script
+		var calls []string
+		var out strings.Builder
+		s := &rbxauth.Stream{
+			Config:         cfg,
+			Reader:         strings.NewReader(test.input),
+			Writer:         &out,
+			Quiet:          true,
+			RememberDevice: rbxauth.RememberNever,
+			NoFallback:     test.noFallback,
+			CodeFunc: func(mediaType string) (string, error) {
+				calls = append(calls, mediaType)
+				if len(script) == 0 {
+					return "", errScriptEnd
+				}
+				code := script[0]
+				script = script[1:]
+				return code, nil
+			},
+		}
+		_, cookies, err := s.PromptCred(rbxauth.Cred{Type: "Username", Ident: "alice"})
+		switch {
+		case test.err != nil:
+			if !errors.Is(err, test.err) {
+				t.Errorf("%s: expected %v, got %v", test.name, test.err, err)
+			}
+		case err != nil:
+			t.Errorf("%s: %v", test.name, err)
+		default:
+			checkSession(t, cfg, cookies, rbxauth.UserInfo{ID: 1})
+		}
+		if len(script) != 0 {
+			t.Errorf("%s: expected script to be used, %q remains", test.name, script)
+		}
+		for _, mediaType := range calls {
+			if mediaType != string(rbxauth.MediaEmail) {
+				t.Errorf("%s: expected media type %s, got %s", test.name, rbxauth.MediaEmail, mediaType)
+			}
+		}
+		if n := srv.Count(rbxauthtest.VerifyPath); n != test.verifies {
+			t.Errorf("%s: expected %d verifies, got %d", test.name, test.verifies, n)
+		}
+		if n := srv.Count(rbxauthtest.ResendPath); n != test.resends {
+			t.Errorf("%s: expected %d resends, got %d", test.name, test.resends, n)
+		}
+		if n := strings.Count(out.String(), rbxauth.DefaultMessages.AskCode); n != test.prompts {
+			t.Errorf("%s: expected %d code prompts, got %d", test.name, test.prompts, n)
+		}
+	}
+}
+
+func TestStreamPromptResult(t *testing.T) {
+	// Without two-step verification, the fields describing it are zero.
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass"})
+	s := &rbxauth.Stream{Config: srv.Config(), Reader: strings.NewReader("pass\n"), Writer: ioutil.Discard, Quiet: true}
+	cred, result, err := s.PromptResult(rbxauth.Cred{Type: "Username", Ident: "alice"})
+	if err != nil {
+		t.Fatalf("no two-step: %v", err)
+	}
+	if want := (rbxauth.Cred{Type: "Username", Ident: "alice"}); cred != want {
+		t.Errorf("no two-step: expected cred %+v, got %+v", want, cred)
+	}
+	checkSession(t, s.Config, result.Cookies, rbxauth.UserInfo{ID: 1, Name: "alice"})
+	if result.Step != nil || result.Challenge != nil || result.Questions != nil || result.RememberDevice || result.Resends != 0 {
+		t.Errorf("no two-step: expected zero two-step fields, got %+v", result)
+	}
+	if result.User == nil || result.User.ID != 1 || result.User.Name != "alice" {
+		t.Errorf("no two-step: unexpected user %+v", result.User)
+	}
+
+	// With two-step verification, the step, the choice to remember the
+	// device, and the resends are reported. The empty line requests a resend.
+	srv = newServer(t, rbxauthtest.Account{
+		ID: 1, Name: "alice", Password: "pass",
+		TwoStep: true, MediaType: string(rbxauth.MediaEmail), Code: "123456",
+	})
+	cfg := srv.Config()
+	cfg.ResendCooldown = -1
+	s = &rbxauth.Stream{Config: cfg, Reader: strings.NewReader("pass\n\n123456\ny\n"), Writer: ioutil.Discard, Quiet: true}
+	_, result, err = s.PromptResult(rbxauth.Cred{Type: "Username", Ident: "alice"})
+	if err != nil {
+		t.Fatalf("two-step: %v", err)
+	}
+	checkSession(t, cfg, result.Cookies, rbxauth.UserInfo{ID: 1, Name: "alice"})
+	if result.Step == nil || result.Step.MediaType != rbxauth.MediaEmail {
+		t.Errorf("two-step: expected step with media type %s, got %+v", rbxauth.MediaEmail, result.Step)
+	}
+	if !result.RememberDevice || result.Resends != 1 {
+		t.Errorf("two-step: expected remembered device and 1 resend, got %t and %d", result.RememberDevice, result.Resends)
+	}
+	// The challenge endpoint receives the resend and the verification.
+	if n := srv.Count(rbxauthtest.ChallengePath); n != 2 {
+		t.Errorf("two-step: expected 2 challenge requests, got %d", n)
+	}
+	if result.User == nil || result.User.ID != 1 {
+		t.Errorf("two-step: unexpected user %+v", result.User)
+	}
+}
@@ -0,0 +1,71 @@
+package rbxauth
+
+import (
+	"os"
+	"os/signal"
+
+	"golang.org/x/term"
+)
+
+// TerminalOptions configures a masked read from a terminal.
+type TerminalOptions struct {
+	// NoRaiseInterrupt causes a read that is interrupted by a signal to only
+	// restore the terminal. Otherwise, the signal is raised again once the
+	// terminal has been restored, so that the signal has its usual effect,
+	// such as terminating the process. A program that handles the signal
+	// itself should set this, or else the program receives the signal twice.
+	NoRaiseInterrupt bool
+}
+
+// terminal changes the state of a terminal. It is implemented by sysTerminal,
+// and replaced to observe the calls made by readPassword.
+type terminal interface {
+	GetState(fd int) (*term.State, error)
+	Restore(fd int, state *term.State) error
+	ReadPassword(fd int) ([]byte, error)
+}
+
+// sysTerminal implements terminal with the term package.
+type sysTerminal struct{}
+
+func (sysTerminal) GetState(fd int) (*term.State, error)    { return term.GetState(fd) }
+func (sysTerminal) Restore(fd int, state *term.State) error { return term.Restore(fd, state) }
+func (sysTerminal) ReadPassword(fd int) ([]byte, error)     { return term.ReadPassword(fd) }
+
+// termIO is the terminal used by readPassword.
+var termIO terminal = sysTerminal{}
+
+// readPassword reads a line from the terminal fd without echoing it. The state
+// of the terminal is captured before echo is disabled, and is restored once
+// the read returns. If the process receives an interrupt signal during the
+// read, then the state is restored before the signal takes effect, so that the
+// terminal is not left without echo.
+func readPassword(fd int, opts TerminalOptions) ([]byte, error) {
+	state, err := termIO.GetState(fd)
+	if err != nil {
+		return nil, err
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, interruptSignals...)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case sig := <-signals:
+			termIO.Restore(fd, state)
+			signal.Stop(signals)
+			if !opts.NoRaiseInterrupt {
+				raise(sig)
+			}
+		case <-done:
+		}
+	}()
+	defer func() {
+		signal.Stop(signals)
+		close(done)
+		<-stopped
+		termIO.Restore(fd, state)
+	}()
+	return termIO.ReadPassword(fd)
+}
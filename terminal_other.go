@@ -0,0 +1,20 @@
+//go:build !windows
+// +build !windows
+
+package rbxauth
+
+import (
+	"os"
+	"syscall"
+)
+
+// interruptSignals are the signals that interrupt a masked read.
+var interruptSignals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP}
+
+// raise sends sig to the current process. Its handling must already have been
+// restored, so that the signal has its default effect.
+func raise(sig os.Signal) {
+	if s, ok := sig.(syscall.Signal); ok {
+		syscall.Kill(syscall.Getpid(), s)
+	}
+}
@@ -0,0 +1,127 @@
+package rbxauth
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// fakeTerminal implements terminal by recording each call.
+type fakeTerminal struct {
+	state    *term.State
+	stateErr error
+	// read is called by ReadPassword, if not nil.
+	read func() ([]byte, error)
+
+	mu       sync.Mutex
+	calls    []string
+	restored []*term.State
+}
+
+func (f *fakeTerminal) record(call string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, call)
+}
+
+func (f *fakeTerminal) GetState(fd int) (*term.State, error) {
+	f.record("GetState")
+	return f.state, f.stateErr
+}
+
+func (f *fakeTerminal) Restore(fd int, state *term.State) error {
+	f.record("Restore")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.restored = append(f.restored, state)
+	return nil
+}
+
+func (f *fakeTerminal) ReadPassword(fd int) ([]byte, error) {
+	f.record("ReadPassword")
+	if f.read != nil {
+		return f.read()
+	}
+	return []byte("pass"), nil
+}
+
+// install replaces termIO with f until the test ends.
+func (f *fakeTerminal) install(t *testing.T) {
+	prev := termIO
+	termIO = f
+	t.Cleanup(func() { termIO = prev })
+}
+
+func TestReadPasswordOrder(t *testing.T) {
+	fake := &fakeTerminal{state: &term.State{}}
+	fake.install(t)
+	b, err := readPassword(0, TerminalOptions{})
+	if err != nil || string(b) != "pass" {
+		t.Fatalf("expected password, got %q, %v", b, err)
+	}
+	if want := []string{"GetState", "ReadPassword", "Restore"}; !reflect.DeepEqual(fake.calls, want) {
+		t.Errorf("expected calls %v, got %v", want, fake.calls)
+	}
+	if len(fake.restored) != 1 || fake.restored[0] != fake.state {
+		t.Errorf("expected captured state to be restored")
+	}
+
+	// A failed read still restores the terminal.
+	readErr := errors.New("read failed")
+	fake = &fakeTerminal{state: &term.State{}, read: func() ([]byte, error) { return nil, readErr }}
+	fake.install(t)
+	if _, err := readPassword(0, TerminalOptions{}); err != readErr {
+		t.Errorf("failed read: expected read error, got %v", err)
+	}
+	if want := []string{"GetState", "ReadPassword", "Restore"}; !reflect.DeepEqual(fake.calls, want) {
+		t.Errorf("failed read: expected calls %v, got %v", want, fake.calls)
+	}
+
+	// Without the state, echo is not disabled.
+	stateErr := errors.New("not a terminal")
+	fake = &fakeTerminal{stateErr: stateErr}
+	fake.install(t)
+	if _, err := readPassword(0, TerminalOptions{}); err != stateErr {
+		t.Errorf("no state: expected state error, got %v", err)
+	}
+	if want := []string{"GetState"}; !reflect.DeepEqual(fake.calls, want) {
+		t.Errorf("no state: expected calls %v, got %v", want, fake.calls)
+	}
+}
+
+func TestReadPasswordInterrupt(t *testing.T) {
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Skip(err)
+	}
+	fake := &fakeTerminal{state: &term.State{}}
+	// The read lasts until the interrupt has restored the terminal.
+	fake.read = func() ([]byte, error) {
+		if err := p.Signal(os.Interrupt); err != nil {
+			t.Skip(err)
+		}
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+			fake.mu.Lock()
+			n := len(fake.restored)
+			fake.mu.Unlock()
+			if n > 0 {
+				return []byte("pass"), nil
+			}
+		}
+		return nil, errors.New("terminal not restored on interrupt")
+	}
+	fake.install(t)
+
+	// The signal is not raised again, so the test continues.
+	if _, err := readPassword(0, TerminalOptions{NoRaiseInterrupt: true}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"GetState", "ReadPassword", "Restore", "Restore"}; !reflect.DeepEqual(fake.calls, want) {
+		t.Errorf("expected calls %v, got %v", want, fake.calls)
+	}
+}
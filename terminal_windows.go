@@ -0,0 +1,13 @@
+package rbxauth
+
+import "os"
+
+// interruptSignals are the signals that interrupt a masked read.
+var interruptSignals = []os.Signal{os.Interrupt}
+
+// raise emulates the default effect of sig. Because a console interrupt
+// cannot be sent to the current process, the process exits with the status
+// used for an interrupt.
+func raise(sig os.Signal) {
+	os.Exit(130)
+}
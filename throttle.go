@@ -0,0 +1,111 @@
+package rbxauth
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrAccountThrottled is matched by a ThrottleError.
+var ErrAccountThrottled = errors.New("account throttled")
+
+// DefaultThrottleWait is the duration to wait after a login is throttled, when
+// the API does not report a duration. It is deliberately long, because
+// logging in again before the lock has lifted extends it.
+const DefaultThrottleWait = 15 * time.Minute
+
+// ThrottleError is returned by a login when the account is temporarily locked
+// due to too many attempts. It also matches ErrTooManyAttempts.
+type ThrottleError struct {
+	// RetryAfter is the duration to wait before logging in again. It is
+	// reported by the API if possible, and is DefaultThrottleWait otherwise.
+	RetryAfter time.Duration
+
+	err error
+}
+
+// Error implements the error interface.
+func (err *ThrottleError) Error() string {
+	msg := "account throttled, wait " + err.RetryAfter.Round(time.Second).String()
+	if err.err != nil {
+		msg += ": " + err.err.Error()
+	}
+	return msg
+}
+
+// Unwrap implements the Unwrap interface.
+func (err *ThrottleError) Unwrap() error {
+	return err.err
+}
+
+// Is returns whether target is ErrAccountThrottled.
+func (err *ThrottleError) Is(target error) bool {
+	return target == ErrAccountThrottled
+}
+
+// ifThrottled returns a ThrottleError if err, returned by a login that
+// received resp at now, indicates that the account is throttled. Returns err
+// otherwise.
+func ifThrottled(resp *http.Response, err error, now time.Time) error {
+	if !errors.Is(err, ErrTooManyAttempts) {
+		return err
+	}
+	if d, ok := retryAfter(resp, now); ok && d > 0 {
+		return &ThrottleError{RetryAfter: d, err: err}
+	}
+	for _, e := range AllErrors(err) {
+		if d, ok := parseWait(e.Message); ok {
+			return &ThrottleError{RetryAfter: d, err: err}
+		}
+	}
+	return &ThrottleError{RetryAfter: DefaultThrottleWait, err: err}
+}
+
+// retryAfter returns the duration reported by the Retry-After header of resp,
+// which is either a number of seconds or a date, relative to now.
+func retryAfter(resp *http.Response, now time.Time) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if sec, err := strconv.Atoi(v); err == nil && sec >= 0 {
+		return time.Duration(sec) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// waitPattern matches a duration within an error message, such as "Please
+// wait 5 minutes".
+var waitPattern = regexp.MustCompile(`(?i)\b(\d+)\s*(second|minute|hour)s?\b`)
+
+// parseWait returns the duration mentioned by msg, if any.
+func parseWait(msg string) (time.Duration, bool) {
+	m := waitPattern.FindStringSubmatch(msg)
+	if m == nil {
+		return 0, false
+	}
+	n, err := strconv.Atoi(m[1])
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+	unit := time.Second
+	switch strings.ToLower(m[2]) {
+	case "minute":
+		unit = time.Minute
+	case "hour":
+		unit = time.Hour
+	}
+	return time.Duration(n) * unit, true
+}
@@ -0,0 +1,123 @@
+package rbxauth_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/anaminus/rbxauth"
+	"github.com/anaminus/rbxauth/rbxauthtest"
+)
+
+func TestThrottleError(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		message string
+		// throttled is the number of logins rejected with a Retry-After
+		// header. Otherwise, the login fails with message.
+		throttled int
+		wait      time.Duration
+	}{
+		{"header", "", 1, rbxauthtest.LoginRetryAfter * time.Second},
+		{"message", "Too many attempts. Please wait 5 minutes.", 0, 5 * time.Minute},
+		{"default", "Too many attempts. Please wait a bit.", 0, rbxauth.DefaultThrottleWait},
+	} {
+		srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass", Throttled: test.throttled})
+		if test.throttled == 0 {
+			srv.Fail(rbxauthtest.LoginPath, 429, 7, test.message)
+		}
+		cfg := srv.Config()
+		delays := recordSleep(&cfg)
+		_, _, err := cfg.Login("alice", []byte("pass"))
+		var terr *rbxauth.ThrottleError
+		if !errors.As(err, &terr) {
+			t.Errorf("%s: expected ThrottleError, got %v", test.name, err)
+			continue
+		}
+		if terr.RetryAfter != test.wait {
+			t.Errorf("%s: expected RetryAfter %s, got %s", test.name, test.wait, terr.RetryAfter)
+		}
+		if !errors.Is(err, rbxauth.ErrAccountThrottled) || !errors.Is(err, rbxauth.ErrTooManyAttempts) {
+			t.Errorf("%s: expected ErrAccountThrottled and ErrTooManyAttempts, got %v", test.name, err)
+		}
+		if code, ok := rbxauth.HTTPStatus(err); !ok || code != 429 {
+			t.Errorf("%s: expected status 429, got %v", test.name, err)
+		}
+		// Without WaitOnThrottle, the login is not sent again. The first
+		// request learns the token.
+		if len(*delays) != 0 || srv.Count(rbxauthtest.LoginPath) != 2 {
+			t.Errorf("%s: expected no retry, got %d delays and %d requests", test.name, len(*delays), srv.Count(rbxauthtest.LoginPath))
+		}
+	}
+
+	// A Retry-After date is relative to the time of the Config.
+	var clock fakeClock
+	cfg := rbxauth.Config{AllowInsecure: true}
+	clock.install(&cfg)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", cfg.Now().Add(90*time.Second).Format(http.TimeFormat))
+		w.WriteHeader(429)
+		io.WriteString(w, `{"errors":[{"code":7,"message":"Too many attempts."}]}`)
+	}))
+	defer srv.Close()
+	cfg.LoginEndpoint = srv.URL
+	var terr *rbxauth.ThrottleError
+	if _, _, err := cfg.Login("alice", []byte("pass")); !errors.As(err, &terr) {
+		t.Errorf("date: expected ThrottleError, got %v", err)
+	} else if terr.RetryAfter != 90*time.Second {
+		t.Errorf("date: expected RetryAfter 1m30s, got %s", terr.RetryAfter)
+	}
+}
+
+func TestWaitOnThrottle(t *testing.T) {
+	srv := newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass", Throttled: 1})
+	cfg := srv.Config()
+	cfg.WaitOnThrottle = true
+	delays := recordSleep(&cfg)
+	cookies, _, err := cfg.Login("alice", []byte("pass"))
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	checkSession(t, cfg, cookies, rbxauth.UserInfo{ID: 1, Name: "alice"})
+	if want := rbxauthtest.LoginRetryAfter * time.Second; len(*delays) != 1 || (*delays)[0] != want {
+		t.Errorf("expected a single wait of %s, got %v", want, *delays)
+	}
+	// The first request learns the token.
+	if n := srv.Count(rbxauthtest.LoginPath); n != 3 {
+		t.Errorf("expected 3 requests, got %d", n)
+	}
+
+	// The login is sent again only once.
+	srv = newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass", Throttled: 2})
+	cfg = srv.Config()
+	cfg.WaitOnThrottle = true
+	delays = recordSleep(&cfg)
+	if _, _, err := cfg.Login("alice", []byte("pass")); !errors.Is(err, rbxauth.ErrAccountThrottled) {
+		t.Errorf("still throttled: expected ErrAccountThrottled, got %v", err)
+	}
+	if len(*delays) != 1 || srv.Count(rbxauthtest.LoginPath) != 3 {
+		t.Errorf("still throttled: expected 1 wait and 3 requests, got %d and %d", len(*delays), srv.Count(rbxauthtest.LoginPath))
+	}
+
+	// A canceled wait ends the login without sending it again.
+	srv = newServer(t, rbxauthtest.Account{ID: 1, Name: "alice", Password: "pass", Throttled: 1})
+	cfg = srv.Config()
+	cfg.WaitOnThrottle = true
+	ctx, cancel := context.WithCancel(context.Background())
+	cfg.Sleep = func(context.Context, time.Duration) error {
+		cancel()
+		return ctx.Err()
+	}
+	_, err = cfg.LoginCredResult(ctx, rbxauth.Cred{Type: "Username", Ident: "alice"}, []byte("pass"), nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled: expected context.Canceled, got %v", err)
+	}
+	if n := srv.Count(rbxauthtest.LoginPath); n != 2 {
+		t.Errorf("canceled: expected 2 requests, got %d", n)
+	}
+}
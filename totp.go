@@ -0,0 +1,69 @@
+package rbxauth
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"strings"
+	"time"
+)
+
+// TOTPPeriod is the duration for which a code generated by GenerateTOTP is
+// valid.
+const TOTPPeriod = 30 * time.Second
+
+// TOTPDigits is the number of digits in a code generated by GenerateTOTP.
+const TOTPDigits = 6
+
+// GenerateTOTP returns the time-based one-time password for secret at time t,
+// as described by RFC 6238, using HMAC-SHA1, TOTPPeriod, and TOTPDigits. These
+// match the codes shown by an authenticator app.
+//
+// secret is the base32-encoded key given when the authenticator was set up.
+// Spaces and padding are ignored, and letters are case-insensitive.
+func GenerateTOTP(secret string, t time.Time) (string, error) {
+	secret = strings.ToUpper(strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' || r == '=' {
+			return -1
+		}
+		return r
+	}, secret))
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		return "", fmt.Errorf("decode TOTP secret: %w", err)
+	}
+	defer wipe(key)
+	return hotp(sha1.New, key, uint64(t.Unix()/int64(TOTPPeriod/time.Second)), TOTPDigits), nil
+}
+
+// hotp returns the HMAC-based one-time password of the given number of digits
+// for key and counter, as described by RFC 4226, using HMAC with h.
+func hotp(h func() hash.Hash, key []byte, counter uint64, digits int) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(h, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0xF
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7FFFFFFF
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, code%mod)
+}
+
+// TOTPCodeProvider returns a function suitable for Config.CodeProvider that
+// generates codes from secret with GenerateTOTP. now returns the current
+// time; if nil, time.Now is used.
+func TOTPCodeProvider(secret string, now func() time.Time) func(mediaType string) (string, error) {
+	if now == nil {
+		now = time.Now
+	}
+	return func(mediaType string) (string, error) {
+		return GenerateTOTP(secret, now())
+	}
+}
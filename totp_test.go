@@ -0,0 +1,64 @@
+package rbxauth
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+	"testing"
+	"time"
+)
+
+// The test vectors of RFC 6238, Appendix B.
+var totpVectors = []struct {
+	time                 int64
+	sha1, sha256, sha512 string
+}{
+	{59, "94287082", "46119246", "90693936"},
+	{1111111109, "07081804", "68084774", "25091201"},
+	{1111111111, "14050471", "67062674", "99943326"},
+	{1234567890, "89005924", "91819424", "93441116"},
+	{2000000000, "69279037", "90698825", "38618901"},
+	{20000000000, "65353130", "77737706", "47863826"},
+}
+
+func TestHOTPVectors(t *testing.T) {
+	const seed = "1234567890"
+	for _, alg := range []struct {
+		name string
+		h    func() hash.Hash
+		key  string
+		code func(int) string
+	}{
+		{"SHA1", sha1.New, seed + seed, func(i int) string { return totpVectors[i].sha1 }},
+		{"SHA256", sha256.New, seed + seed + seed + "12", func(i int) string { return totpVectors[i].sha256 }},
+		{"SHA512", sha512.New, seed + seed + seed + seed + seed + seed + "1234", func(i int) string { return totpVectors[i].sha512 }},
+	} {
+		for i, v := range totpVectors {
+			counter := uint64(v.time / int64(TOTPPeriod/time.Second))
+			if code := hotp(alg.h, []byte(alg.key), counter, 8); code != alg.code(i) {
+				t.Errorf("%s at %d: expected %s, got %s", alg.name, v.time, alg.code(i), code)
+			}
+		}
+	}
+}
+
+func TestGenerateTOTP(t *testing.T) {
+	// Base32 encoding of the SHA1 key of RFC 6238.
+	const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	for _, v := range totpVectors {
+		expected := v.sha1[len(v.sha1)-TOTPDigits:]
+		for _, s := range []string{secret, "gezd gnbv gy3t qojq gezd gnbv gy3t qojq", secret + "===="} {
+			code, err := GenerateTOTP(s, time.Unix(v.time, 0))
+			if err != nil {
+				t.Fatalf("%q: %v", s, err)
+			}
+			if code != expected {
+				t.Errorf("%q at %d: expected %s, got %s", s, v.time, expected, code)
+			}
+		}
+	}
+	if _, err := GenerateTOTP("not base32!", time.Unix(59, 0)); err == nil {
+		t.Error("expected error for invalid secret")
+	}
+}
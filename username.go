@@ -0,0 +1,155 @@
+package rbxauth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// UsernameStatus indicates whether a username is acceptable.
+type UsernameStatus int
+
+const (
+	// UsernameInvalid indicates that the username was rejected for a reason
+	// not known to the package. UsernameValidation.Message describes the
+	// reason.
+	UsernameInvalid UsernameStatus = iota
+	// UsernameValid indicates that the username is available.
+	UsernameValid
+	// UsernameTaken indicates that the username is already in use.
+	UsernameTaken
+	// UsernameInappropriate indicates that the username is not appropriate.
+	UsernameInappropriate
+	// UsernameBadLength indicates that the username is too short or too
+	// long.
+	UsernameBadLength
+	// UsernameBadCharacters indicates that the username contains disallowed
+	// characters, or underscores in disallowed positions.
+	UsernameBadCharacters
+	// UsernamePrivateInfo indicates that the username might contain private
+	// information.
+	UsernamePrivateInfo
+)
+
+// String returns a description of the status.
+func (s UsernameStatus) String() string {
+	switch s {
+	case UsernameValid:
+		return "valid"
+	case UsernameTaken:
+		return "taken"
+	case UsernameInappropriate:
+		return "inappropriate"
+	case UsernameBadLength:
+		return "bad length"
+	case UsernameBadCharacters:
+		return "bad characters"
+	case UsernamePrivateInfo:
+		return "private information"
+	}
+	return "invalid"
+}
+
+// usernameCodes maps codes returned by the username validation endpoint to a
+// status.
+//
+//	0: Username is valid.
+//	1: Username is already in use.
+//	2: Username not appropriate for Roblox.
+//	3: Usernames can be 3 to 20 characters long.
+//	4: Usernames can't start or end with _.
+//	5: Usernames can have at most one _.
+//	6: Only a-z, A-Z, 0-9, and _ are allowed.
+//	7: Username might contain private information.
+var usernameCodes = map[int]UsernameStatus{
+	0: UsernameValid,
+	1: UsernameTaken,
+	2: UsernameInappropriate,
+	3: UsernameBadLength,
+	4: UsernameBadCharacters,
+	5: UsernameBadCharacters,
+	6: UsernameBadCharacters,
+	7: UsernamePrivateInfo,
+}
+
+// UsernameValidation is the result of validating a username.
+type UsernameValidation struct {
+	// Status is the status derived from Code.
+	Status UsernameStatus
+	// Code is the code reported by the API.
+	Code int
+	// Message is the description reported by the API.
+	Message string
+}
+
+// ValidateUsername checks whether name is acceptable as the username of a new
+// account belonging to a user with the given birthday. birthday may be zero.
+func (c Config) ValidateUsername(name string, birthday time.Time) (UsernameValidation, error) {
+	return c.ValidateUsernameContext(context.Background(), name, birthday)
+}
+
+// ValidateUsernameContext is like ValidateUsername, but with a context.
+func (c Config) ValidateUsernameContext(ctx context.Context, name string, birthday time.Time) (v UsernameValidation, err error) {
+	defer wrapOp("validate username", &err)
+
+	apiReq := usernameValidationRequest{Username: name, Context: "Signup"}
+	if !birthday.IsZero() {
+		apiReq.Birthday = formatBirthday(birthday)
+	}
+	body, _ := json.Marshal(&apiReq)
+	endpoint := c.ValidateUsernameEndpoint
+	if endpoint == "" {
+		endpoint = DefaultValidateUsernameEndpoint
+	}
+	req, err := c.newRequest(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return v, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	var apiResp usernameValidationResponse
+	if _, err = c.requestAPI("validate-username", req, &apiResp); err != nil {
+		return v, err
+	}
+	return newUsernameValidation(apiResp.Code, apiResp.Message), nil
+}
+
+// newUsernameValidation returns the validation corresponding to a code and
+// message reported by the API.
+func newUsernameValidation(code int, message string) UsernameValidation {
+	status, ok := usernameCodes[code]
+	if !ok {
+		status = UsernameInvalid
+	}
+	return UsernameValidation{Status: status, Code: code, Message: message}
+}
+
+// RecommendUsernames returns a list of available usernames similar to name.
+func (c Config) RecommendUsernames(name string) ([]string, error) {
+	return c.RecommendUsernamesContext(context.Background(), name)
+}
+
+// RecommendUsernamesContext is like RecommendUsernames, but with a context.
+func (c Config) RecommendUsernamesContext(ctx context.Context, name string) (names []string, err error) {
+	defer wrapOp("recommend usernames", &err)
+
+	body, _ := json.Marshal(&usernameRecommendationRequest{Username: name})
+	endpoint := c.RecommendUsernamesEndpoint
+	if endpoint == "" {
+		endpoint = DefaultRecommendUsernamesEndpoint
+	}
+	req, err := c.newRequest(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	var apiResp usernameRecommendationResponse
+	if _, err = c.requestAPI("recommend-usernames", req, &apiResp); err != nil {
+		return nil, err
+	}
+	return apiResp.SuggestedUsernames, nil
+}